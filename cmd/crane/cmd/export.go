@@ -24,7 +24,9 @@ import (
 
 // NewCmdExport creates a new cobra.Command for the export subcommand.
 func NewCmdExport(options *[]crane.Option) *cobra.Command {
-	return &cobra.Command{
+	var whiteout string
+
+	cmd := &cobra.Command{
 		Use:   "export IMAGE TARBALL",
 		Short: "Export contents of a remote image as a tarball",
 		Example: `  # Write tarball to stdout
@@ -47,11 +49,16 @@ func NewCmdExport(options *[]crane.Option) *cobra.Command {
 				log.Fatal(err)
 			}
 
-			if err := crane.Export(img, f); err != nil {
+			if err := crane.ExportWithOptions(img, f, crane.WithWhiteoutPolicy(crane.WhiteoutPolicy(whiteout))); err != nil {
 				log.Fatalf("exporting %s: %v", src, err)
 			}
 		},
 	}
+
+	cmd.Flags().StringVar(&whiteout, "whiteout", string(crane.WhiteoutOverlay),
+		"How to handle whiteout files: overlay applies them, none passes them through, aufs passes them through without AUFS metadata.")
+
+	return cmd
 }
 
 func openFile(s string) (*os.File, error) {
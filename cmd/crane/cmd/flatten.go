@@ -0,0 +1,56 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/spf13/cobra"
+)
+
+// NewCmdFlatten creates a new cobra.Command for the flatten subcommand.
+func NewCmdFlatten(options *[]crane.Option) *cobra.Command {
+	return &cobra.Command{
+		Use:   "flatten SRC DST",
+		Short: "Squash the layers of an image into a single layer",
+		Example: `  # Flatten an image and push the result to a new tag
+  crane flatten ubuntu gcr.io/my-project/ubuntu:flat`,
+		Args: cobra.ExactArgs(2),
+		Run: func(_ *cobra.Command, args []string) {
+			src, dst := args[0], args[1]
+			img, err := crane.Pull(src, *options...)
+			if err != nil {
+				log.Fatalf("pulling %s: %v", src, err)
+			}
+
+			flat, err := crane.Flatten(img)
+			if err != nil {
+				log.Fatalf("flattening %s: %v", src, err)
+			}
+
+			if err := crane.Push(flat, dst, *options...); err != nil {
+				log.Fatalf("pushing %s: %v", dst, err)
+			}
+
+			digest, err := flat.Digest()
+			if err != nil {
+				log.Fatalf("digesting flattened: %v", err)
+			}
+			fmt.Println(digest.String())
+		},
+	}
+}
@@ -24,17 +24,28 @@ import (
 
 // NewCmdManifest creates a new cobra.Command for the manifest subcommand.
 func NewCmdManifest(options *[]crane.Option) *cobra.Command {
-	return &cobra.Command{
+	var pretty, validate bool
+	cmd := &cobra.Command{
 		Use:   "manifest IMAGE",
 		Short: "Get the manifest of an image",
 		Args:  cobra.ExactArgs(1),
 		Run: func(_ *cobra.Command, args []string) {
 			src := args[0]
-			manifest, err := crane.Manifest(src, *options...)
+			o := *options
+			if pretty {
+				o = append(o, crane.WithPretty())
+			}
+			if validate {
+				o = append(o, crane.WithSchemaValidation())
+			}
+			manifest, err := crane.Manifest(src, o...)
 			if err != nil {
 				log.Fatalf("fetching manifest %s: %v", src, err)
 			}
 			fmt.Print(string(manifest))
 		},
 	}
+	cmd.Flags().BoolVar(&pretty, "pretty", false, "Indent the manifest JSON")
+	cmd.Flags().BoolVar(&validate, "validate", false, "Check that the manifest conforms to the schema of its media type")
+	return cmd
 }
@@ -19,7 +19,6 @@ import (
 	"log"
 
 	"github.com/google/go-containerregistry/pkg/crane"
-	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/spf13/cobra"
 )
 
@@ -32,24 +31,9 @@ func NewCmdRebase(options *[]crane.Option) *cobra.Command {
 		Short: "Rebase an image onto a new base image",
 		Args:  cobra.NoArgs,
 		Run: func(*cobra.Command, []string) {
-			origImg, err := crane.Pull(orig, *options...)
+			img, err := crane.Rebase(orig, oldBase, newBase, *options...)
 			if err != nil {
-				log.Fatalf("pulling %s: %v", orig, err)
-			}
-
-			oldBaseImg, err := crane.Pull(oldBase, *options...)
-			if err != nil {
-				log.Fatalf("pulling %s: %v", oldBase, err)
-			}
-
-			newBaseImg, err := crane.Pull(newBase, *options...)
-			if err != nil {
-				log.Fatalf("pulling %s: %v", newBase, err)
-			}
-
-			img, err := mutate.Rebase(origImg, oldBaseImg, newBaseImg)
-			if err != nil {
-				log.Fatalf("rebasing: %v", err)
+				log.Fatal(err)
 			}
 
 			if err := crane.Push(img, rebased, *options...); err != nil {
@@ -46,6 +46,10 @@ func parsePlatform(platform string) (*v1.Platform, error) {
 	if platform == "all" {
 		return nil, nil
 	}
+	if platform == "host" {
+		p := v1.HostPlatform()
+		return &p, nil
+	}
 
 	p := &v1.Platform{}
 	parts := strings.Split(platform, "/")
@@ -34,22 +34,30 @@ func NewCmdValidate(options *[]crane.Option) *cobra.Command {
 		Short: "Validate that an image is well-formed",
 		Args:  cobra.ExactArgs(0),
 		Run: func(_ *cobra.Command, args []string) {
-			for flag, maker := range map[string]func(string, ...crane.Option) (v1.Image, error){
-				tarballPath: makeTarball,
-				remoteRef:   crane.Pull,
-			} {
-				if flag == "" {
-					continue
-				}
-				img, err := maker(flag, *options...)
+			if tarballPath != "" {
+				img, err := makeTarball(tarballPath, *options...)
 				if err != nil {
-					log.Fatalf("failed to read image %s: %v", flag, err)
+					log.Fatalf("failed to read image %s: %v", tarballPath, err)
 				}
 
 				if err := validate.Image(img); err != nil {
-					fmt.Printf("FAIL: %s: %v\n", flag, err)
+					fmt.Printf("FAIL: %s: %v\n", tarballPath, err)
 				} else {
-					fmt.Printf("PASS: %s\n", flag)
+					fmt.Printf("PASS: %s\n", tarballPath)
+				}
+			}
+			if remoteRef != "" {
+				report, err := crane.Validate(remoteRef, *options...)
+				if err != nil {
+					log.Fatalf("failed to read image %s: %v", remoteRef, err)
+				}
+
+				for _, c := range report.Checks {
+					if c.Err != nil {
+						fmt.Printf("FAIL: %s: %s: %v\n", remoteRef, c.Name, c.Err)
+					} else {
+						fmt.Printf("PASS: %s: %s\n", remoteRef, c.Name)
+					}
 				}
 			}
 		},
@@ -16,6 +16,7 @@ require (
 	github.com/docker/go-units v0.4.0 // indirect
 	github.com/google/go-cmp v0.5.2
 	github.com/gorilla/mux v1.7.3 // indirect
+	github.com/klauspost/compress v1.11.4
 	github.com/kr/text v0.2.0 // indirect
 	github.com/maxbrunsfeld/counterfeiter/v6 v6.2.2
 	github.com/morikuni/aec v1.0.0 // indirect
@@ -15,6 +15,11 @@
 package authn
 
 // Bearer implements Authenticator for bearer authentication.
+//
+// The token is sent to the registry as-is in an "Authorization: Bearer" header,
+// skipping the token exchange that is otherwise performed in response to a
+// bearer challenge. This is useful when a registry token has been obtained out
+// of band, e.g. by a CI system.
 type Bearer struct {
 	Token string `json:"token"`
 }
@@ -0,0 +1,99 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authn
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// now is overridden in tests.
+var now = time.Now
+
+type cachedAuth struct {
+	auth    Authenticator
+	expires time.Time
+}
+
+type cachedKeychain struct {
+	inner Keychain
+	ttl   time.Duration
+
+	group singleflight.Group
+
+	mu    sync.Mutex
+	cache map[string]cachedAuth
+}
+
+// Assert that our cached keychain implements Keychain.
+var _ (Keychain) = (*cachedKeychain)(nil)
+
+// NewCachedKeychain wraps inner so that the Authenticator it resolves for a
+// registry is reused for ttl, which avoids e.g. invoking a credential helper
+// for every request when copying many images. Concurrent resolves for the
+// same registry share a single call to inner.
+//
+// Authenticators are cached per registry host, so inner must not return
+// different credentials for different repositories of the same registry,
+// which holds for DefaultKeychain. Errors are not cached. Since a cached
+// Authenticator isn't refreshed until ttl elapses, ttl should be shorter than
+// the lifetime of the credentials inner returns.
+func NewCachedKeychain(inner Keychain, ttl time.Duration) Keychain {
+	return &cachedKeychain{
+		inner: inner,
+		ttl:   ttl,
+		cache: map[string]cachedAuth{},
+	}
+}
+
+// Resolve implements Keychain.
+func (ck *cachedKeychain) Resolve(target Resource) (Authenticator, error) {
+	key := target.RegistryStr()
+	if auth, ok := ck.get(key); ok {
+		return auth, nil
+	}
+
+	auth, err, _ := ck.group.Do(key, func() (interface{}, error) {
+		// Another call may have just finished resolving key.
+		if auth, ok := ck.get(key); ok {
+			return auth, nil
+		}
+		auth, err := ck.inner.Resolve(target)
+		if err != nil {
+			return nil, err
+		}
+		ck.mu.Lock()
+		ck.cache[key] = cachedAuth{auth: auth, expires: now().Add(ck.ttl)}
+		ck.mu.Unlock()
+		return auth, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return auth.(Authenticator), nil
+}
+
+// get returns the cached Authenticator for key, if it hasn't expired.
+func (ck *cachedKeychain) get(key string) (Authenticator, bool) {
+	ck.mu.Lock()
+	defer ck.mu.Unlock()
+	c, ok := ck.cache[key]
+	if !ok || !now().Before(c.expires) {
+		return nil, false
+	}
+	return c.auth, true
+}
@@ -0,0 +1,122 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authn
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+type countingKeychain struct {
+	calls   int32
+	release chan struct{}
+	err     error
+}
+
+func (ck *countingKeychain) Resolve(target Resource) (Authenticator, error) {
+	atomic.AddInt32(&ck.calls, 1)
+	if ck.release != nil {
+		<-ck.release
+	}
+	if ck.err != nil {
+		return nil, ck.err
+	}
+	return &Basic{Username: target.RegistryStr(), Password: "secret"}, nil
+}
+
+func TestCachedKeychain(t *testing.T) {
+	start := time.Now()
+	clock := start
+	now = func() time.Time { return clock }
+	defer func() { now = time.Now }()
+
+	inner := &countingKeychain{}
+	kc := NewCachedKeychain(inner, time.Minute)
+
+	one, _ := name.NewRepository("one.gcr.io/foo", name.StrictValidation)
+	other, _ := name.NewRepository("one.gcr.io/bar", name.StrictValidation)
+	two, _ := name.NewRegistry("two.gcr.io", name.StrictValidation)
+
+	for _, target := range []Resource{one, other, two, one} {
+		auth, err := kc.Resolve(target)
+		if err != nil {
+			t.Fatalf("Resolve(%s) = %v", target, err)
+		}
+		if got := auth.(*Basic).Username; got != target.RegistryStr() {
+			t.Errorf("Resolve(%s) = %s, want the credentials for %s", target, got, target.RegistryStr())
+		}
+	}
+	if inner.calls != 2 {
+		t.Errorf("inner keychain called %d times, want 2", inner.calls)
+	}
+
+	// Once the TTL elapses, the credentials are resolved again.
+	clock = start.Add(2 * time.Minute)
+	if _, err := kc.Resolve(one); err != nil {
+		t.Fatal(err)
+	}
+	if inner.calls != 3 {
+		t.Errorf("inner keychain called %d times, want 3", inner.calls)
+	}
+}
+
+func TestCachedKeychainConcurrent(t *testing.T) {
+	inner := &countingKeychain{release: make(chan struct{})}
+	kc := NewCachedKeychain(inner, time.Minute)
+	reg, _ := name.NewRegistry("gcr.io", name.StrictValidation)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := kc.Resolve(reg); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	// Wait for the first resolve to start, give the others a chance to pile up
+	// behind it, then let it finish.
+	for atomic.LoadInt32(&inner.calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(inner.release)
+	wg.Wait()
+
+	if calls := atomic.LoadInt32(&inner.calls); calls != 1 {
+		t.Errorf("inner keychain called %d times, want 1", calls)
+	}
+}
+
+func TestCachedKeychainError(t *testing.T) {
+	inner := &countingKeychain{err: errors.New("helper failed")}
+	kc := NewCachedKeychain(inner, time.Minute)
+	reg, _ := name.NewRegistry("gcr.io", name.StrictValidation)
+
+	for i := 0; i < 2; i++ {
+		if _, err := kc.Resolve(reg); err == nil {
+			t.Error("Resolve() = nil, want error")
+		}
+	}
+	if inner.calls != 2 {
+		t.Errorf("inner keychain called %d times, want 2 since errors aren't cached", inner.calls)
+	}
+}
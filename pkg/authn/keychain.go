@@ -18,7 +18,6 @@ import (
 	"os"
 
 	"github.com/docker/cli/cli/config"
-	"github.com/docker/cli/cli/config/types"
 	"github.com/google/go-containerregistry/pkg/name"
 )
 
@@ -75,15 +74,18 @@ func (dk *defaultKeychain) Resolve(target Resource) (Authenticator, error) {
 		return nil, err
 	}
 
-	empty := types.AuthConfig{}
-	if cfg == empty {
-		return Anonymous, nil
-	}
-	return FromConfig(AuthConfig{
+	ac := AuthConfig{
 		Username:      cfg.Username,
 		Password:      cfg.Password,
 		Auth:          cfg.Auth,
 		IdentityToken: cfg.IdentityToken,
 		RegistryToken: cfg.RegistryToken,
-	}), nil
+	}
+	// Only the credentials matter: entries in auths without any (e.g. those
+	// written alongside a credsStore) still have a ServerAddress, and the
+	// Docker CLI treats them as anonymous too.
+	if ac == (AuthConfig{}) {
+		return Anonymous, nil
+	}
+	return FromConfig(ac), nil
 }
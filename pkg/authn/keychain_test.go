@@ -21,6 +21,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"runtime"
 	"testing"
 
 	"github.com/google/go-containerregistry/pkg/name"
@@ -139,3 +140,144 @@ func TestVariousPaths(t *testing.T) {
 		}
 	}
 }
+
+// setupCredHelpers installs fake docker-credential-<name> helpers on the PATH,
+// which return the helper name as the username and the server URL they were
+// asked for as the password. Helpers in missing report that they have no
+// credentials for that server URL.
+func setupCredHelpers(t *testing.T, names []string, missing map[string]bool) func() {
+	dir, err := ioutil.TempDir("", "cred-helpers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range names {
+		script := `#!/bin/sh
+read url
+case "$url" in
+`
+		for m := range missing {
+			script += fmt.Sprintf("%q) echo 'credentials not found in native keychain'; exit 1;;\n", m)
+		}
+		script += fmt.Sprintf(`*) printf '{"ServerURL":"%%s","Username":"%s","Secret":"%%s"}' "$url" "$url";;
+esac
+`, n)
+		if err := ioutil.WriteFile(filepath.Join(dir, "docker-credential-"+n), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+	return func() {
+		os.Setenv("PATH", path)
+		os.RemoveAll(dir)
+	}
+}
+
+func TestDockerConfigLayouts(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake credential helpers are shell scripts")
+	}
+	defer setupCredHelpers(t, []string{"global", "special"}, map[string]bool{"nothing.io": true})()
+
+	dockerIO, _ := name.NewRegistry("docker.io", name.WeakValidation)
+	other, _ := name.NewRegistry("other.io", name.WeakValidation)
+	nothing, _ := name.NewRegistry("nothing.io", name.WeakValidation)
+	withPort, _ := name.NewRegistry("test.io:5000", name.WeakValidation)
+
+	tests := []struct {
+		name    string
+		content string
+		target  name.Registry
+		cfg     *AuthConfig
+	}{{
+		name:    "credHelpers wins over credsStore",
+		content: `{"credsStore": "global", "credHelpers": {"test.io": "special"}}`,
+		target:  testRegistry,
+		cfg:     &AuthConfig{Username: "special", Password: "test.io"},
+	}, {
+		name:    "credsStore for other hosts",
+		content: `{"credsStore": "global", "credHelpers": {"test.io": "special"}}`,
+		target:  other,
+		cfg:     &AuthConfig{Username: "global", Password: "other.io"},
+	}, {
+		name:    "credHelpers without credsStore",
+		content: fmt.Sprintf(`{"credHelpers": {"test.io": "special"}, "auths": {"other.io": {"auth": %q}}}`, encode("foo", "bar")),
+		target:  other,
+		cfg:     &AuthConfig{Username: "foo", Password: "bar"},
+	}, {
+		name:    "helpers are asked about Docker Hub by its legacy key",
+		content: `{"credsStore": "global"}`,
+		target:  dockerIO,
+		cfg:     &AuthConfig{Username: "global", Password: DefaultAuthKey},
+	}, {
+		name:    "credHelpers match Docker Hub by its legacy key",
+		content: fmt.Sprintf(`{"credsStore": "global", "credHelpers": {%q: "special"}}`, DefaultAuthKey),
+		target:  defaultRegistry,
+		cfg:     &AuthConfig{Username: "special", Password: DefaultAuthKey},
+	}, {
+		name:    "docker.io is an alias for index.docker.io",
+		content: fmt.Sprintf(`{"auths": {%q: {"auth": %q}}}`, DefaultAuthKey, encode("foo", "bar")),
+		target:  dockerIO,
+		cfg:     &AuthConfig{Username: "foo", Password: "bar"},
+	}, {
+		name:    "auths with a scheme",
+		content: fmt.Sprintf(`{"auths": {"https://test.io": {"auth": %q}}}`, encode("foo", "bar")),
+		target:  testRegistry,
+		cfg:     &AuthConfig{Username: "foo", Password: "bar"},
+	}, {
+		name:    "auths with a legacy URL",
+		content: fmt.Sprintf(`{"auths": {"http://test.io:5000/v1/": {"auth": %q}}}`, encode("foo", "bar")),
+		target:  withPort,
+		cfg:     &AuthConfig{Username: "foo", Password: "bar"},
+	}, {
+		name:    "auths don't match other ports",
+		content: fmt.Sprintf(`{"auths": {"test.io:5000": {"auth": %q}}}`, encode("foo", "bar")),
+		target:  testRegistry,
+	}, {
+		name:    "auths with username and password",
+		content: `{"auths": {"test.io": {"username": "foo", "password": "bar"}}}`,
+		target:  testRegistry,
+		cfg:     &AuthConfig{Username: "foo", Password: "bar"},
+	}, {
+		name:    "auths with an identity token",
+		content: `{"auths": {"test.io": {"identitytoken": "token"}}}`,
+		target:  testRegistry,
+		cfg:     &AuthConfig{IdentityToken: "token"},
+	}, {
+		// This is what Docker Desktop writes.
+		name:    "empty auths entry with a credsStore that has nothing",
+		content: `{"credsStore": "global", "auths": {"nothing.io": {}}}`,
+		target:  nothing,
+	}, {
+		// Like the Docker CLI, inline credentials are ignored once a helper
+		// applies to the host.
+		name:    "inline auths are ignored if the helper has nothing",
+		content: fmt.Sprintf(`{"credsStore": "global", "auths": {"nothing.io": {"auth": %q}}}`, encode("foo", "bar")),
+		target:  nothing,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cd := setupConfigFile(t, test.content)
+			defer os.RemoveAll(filepath.Dir(cd))
+
+			auth, err := DefaultKeychain.Resolve(test.target)
+			if err != nil {
+				t.Fatalf("Resolve() = %v", err)
+			}
+			if test.cfg == nil {
+				if auth != Anonymous {
+					t.Errorf("Resolve() = %v, want Anonymous", auth)
+				}
+				return
+			}
+			cfg, err := auth.Authorization()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(cfg, test.cfg) {
+				t.Errorf("got %+v, want %+v", cfg, test.cfg)
+			}
+		})
+	}
+}
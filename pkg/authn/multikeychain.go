@@ -22,6 +22,12 @@ type multiKeychain struct {
 var _ (Keychain) = (*multiKeychain)(nil)
 
 // NewMultiKeychain composes a list of keychains into one new keychain.
+//
+// Keychains are consulted in order, and the first non-Anonymous
+// Authenticator is returned. A keychain that has no match for a resource
+// should return Anonymous, which falls through to the next keychain; any
+// error is returned immediately. If no keychain matches, Anonymous is
+// returned.
 func NewMultiKeychain(kcs ...Keychain) Keychain {
 	return &multiKeychain{keychains: kcs}
 }
@@ -15,6 +15,7 @@
 package authn
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/google/go-containerregistry/pkg/name"
@@ -85,6 +86,40 @@ func TestMultiKeychain(t *testing.T) {
 	}
 }
 
+func TestMultiKeychainError(t *testing.T) {
+	one := &Basic{Username: "one", Password: "secret"}
+	regOne, _ := name.NewRegistry("one.gcr.io", name.StrictValidation)
+	regTwo, _ := name.NewRegistry("two.gcr.io", name.StrictValidation)
+
+	want := errors.New("boom")
+	kc := NewMultiKeychain(
+		fixedKeychain{regOne: one},
+		errorKeychain{want},
+		fixedKeychain{regTwo: one},
+	)
+
+	// A match before the failing keychain never reaches it.
+	if got, err := kc.Resolve(regOne); err != nil {
+		t.Errorf("Resolve() = %v", err)
+	} else if got != one {
+		t.Errorf("Resolve() = %v, wanted %v", got, one)
+	}
+
+	// A hard error short-circuits the remaining keychains.
+	if _, err := kc.Resolve(regTwo); err != want {
+		t.Errorf("Resolve() = %v, wanted %v", err, want)
+	}
+}
+
+type errorKeychain struct {
+	err error
+}
+
+// Resolve implements Keychain.
+func (ek errorKeychain) Resolve(Resource) (Authenticator, error) {
+	return nil, ek.err
+}
+
 type fixedKeychain map[Resource]Authenticator
 
 var _ Keychain = (fixedKeychain)(nil)
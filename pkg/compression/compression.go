@@ -0,0 +1,42 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package compression abstracts over gzip and zstd.
+package compression
+
+import "github.com/google/go-containerregistry/pkg/v1/types"
+
+// Compression is an enumeration of the supported compression algorithms.
+type Compression string
+
+// The collection of known Compression values.
+const (
+	None Compression = "none"
+	GZip Compression = "gzip"
+	ZStd Compression = "zstd"
+)
+
+// FromMediaType returns the Compression implied by the given layer media
+// type, and whether the media type was recognized.
+func FromMediaType(mt types.MediaType) (Compression, bool) {
+	switch mt {
+	case types.OCIUncompressedLayer, types.OCIUncompressedRestrictedLayer, types.DockerUncompressedLayer:
+		return None, true
+	case types.OCILayer, types.OCIRestrictedLayer, types.DockerLayer, types.DockerForeignLayer:
+		return GZip, true
+	case types.OCILayerZStd:
+		return ZStd, true
+	}
+	return None, false
+}
@@ -14,6 +14,14 @@
 
 package crane
 
+import (
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
 // Config returns the config file for the remote image ref.
 func Config(ref string, opt ...Option) ([]byte, error) {
 	i, _, err := getImage(ref, opt...)
@@ -22,3 +30,18 @@ func Config(ref string, opt ...Option) ([]byte, error) {
 	}
 	return i.RawConfigFile()
 }
+
+// ConfigFile returns the parsed config file for the remote image ref, without
+// fetching anything but its manifest and config, see remote.ConfigFile.
+func ConfigFile(ref string, opt ...Option) (*v1.ConfigFile, error) {
+	o := makeOptions(opt...)
+	r, err := name.ParseReference(ref, o.name...)
+	if err != nil {
+		return nil, fmt.Errorf("parsing reference %q: %w", ref, err)
+	}
+	cf, err := remote.ConfigFile(r, o.remote...)
+	if err != nil {
+		return nil, fmt.Errorf("reading config %q: %w", r, err)
+	}
+	return cf, nil
+}
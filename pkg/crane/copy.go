@@ -15,12 +15,16 @@
 package crane
 
 import (
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/internal/legacy"
 	"github.com/google/go-containerregistry/pkg/logs"
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 )
@@ -30,18 +34,38 @@ func Copy(src, dst string, opt ...Option) error {
 	o := makeOptions(opt...)
 	srcRef, err := name.ParseReference(src, o.name...)
 	if err != nil {
-		return fmt.Errorf("parsing reference %q: %v", src, err)
+		return fmt.Errorf("parsing reference %q: %w", src, err)
 	}
 
 	dstRef, err := name.ParseReference(dst, o.name...)
 	if err != nil {
-		return fmt.Errorf("parsing reference for %q: %v", dst, err)
+		return fmt.Errorf("parsing reference for %q: %w", dst, err)
+	}
+
+	if o.progress != nil {
+		updates := make(chan v1.Update, 100)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for update := range updates {
+				o.progress(update)
+			}
+		}()
+		defer func() {
+			close(updates)
+			<-done
+		}()
+		o.remote = append(o.remote, remote.WithProgress(updates))
 	}
 
 	logs.Progress.Printf("Copying from %v to %v", srcRef, dstRef)
 	desc, err := remote.Get(srcRef, o.remote...)
 	if err != nil {
-		return fmt.Errorf("fetching %q: %v", src, err)
+		return fmt.Errorf("fetching %q: %w", src, err)
+	}
+
+	if err := checkMediaTypes(desc, o); err != nil {
+		return fmt.Errorf("refusing to copy %q: %w", src, err)
 	}
 
 	switch desc.MediaType {
@@ -50,22 +74,22 @@ func Copy(src, dst string, opt ...Option) error {
 		if o.platform != nil {
 			// If platform is explicitly set, don't copy the whole index, just the appropriate image.
 			if err := copyImage(desc, dstRef, o); err != nil {
-				return fmt.Errorf("failed to copy image: %v", err)
+				return fmt.Errorf("failed to copy image: %w", err)
 			}
 		} else {
 			if err := copyIndex(desc, dstRef, o); err != nil {
-				return fmt.Errorf("failed to copy index: %v", err)
+				return fmt.Errorf("failed to copy index: %w", err)
 			}
 		}
 	case types.DockerManifestSchema1, types.DockerManifestSchema1Signed:
 		// Handle schema 1 images separately.
 		if err := copySchema1(desc, srcRef, dstRef); err != nil {
-			return fmt.Errorf("failed to copy schema 1 image: %v", err)
+			return fmt.Errorf("failed to copy schema 1 image: %w", err)
 		}
 	default:
 		// Assume anything else is an image, since some registries don't set mediaTypes properly.
 		if err := copyImage(desc, dstRef, o); err != nil {
-			return fmt.Errorf("failed to copy image: %v", err)
+			return fmt.Errorf("failed to copy image: %w", err)
 		}
 	}
 
@@ -100,3 +124,120 @@ func copySchema1(desc *remote.Descriptor, srcRef, dstRef name.Reference) error {
 
 	return legacy.CopySchema1(desc, srcRef, dstRef, srcAuth, dstAuth)
 }
+
+// mediaTypePolicy tracks the media types that options forbid.
+type mediaTypePolicy struct {
+	allowed, denied map[types.MediaType]bool
+	forbidden       map[types.MediaType]bool
+}
+
+func (p *mediaTypePolicy) check(mt types.MediaType) {
+	if p.denied[mt] || (len(p.allowed) != 0 && !p.allowed[mt]) {
+		p.forbidden[mt] = true
+	}
+}
+
+func (p *mediaTypePolicy) checkImage(img v1.Image) error {
+	m, err := img.Manifest()
+	if err != nil {
+		return err
+	}
+	p.check(m.Config.MediaType)
+	for _, l := range m.Layers {
+		p.check(l.MediaType)
+	}
+	return nil
+}
+
+func (p *mediaTypePolicy) checkIndex(idx v1.ImageIndex) error {
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return err
+	}
+	for _, desc := range im.Manifests {
+		switch {
+		case desc.MediaType.IsIndex():
+			child, err := idx.ImageIndex(desc.Digest)
+			if err != nil {
+				return err
+			}
+			if err := p.checkIndex(child); err != nil {
+				return err
+			}
+		case desc.MediaType.IsImage():
+			child, err := idx.Image(desc.Digest)
+			if err != nil {
+				return err
+			}
+			if err := p.checkImage(child); err != nil {
+				return err
+			}
+		default:
+			p.check(desc.MediaType)
+		}
+	}
+	return nil
+}
+
+// checkMediaTypes returns an error listing the config and layer media types
+// of desc, or of every image in it if it's an index, that aren't allowed by
+// WithAllowedMediaTypes or WithDeniedMediaTypes. Only manifests are fetched.
+func checkMediaTypes(desc *remote.Descriptor, o options) error {
+	if len(o.allowedMediaTypes) == 0 && len(o.deniedMediaTypes) == 0 {
+		return nil
+	}
+	p := &mediaTypePolicy{
+		allowed:   map[types.MediaType]bool{},
+		denied:    map[types.MediaType]bool{},
+		forbidden: map[types.MediaType]bool{},
+	}
+	for _, mt := range o.allowedMediaTypes {
+		p.allowed[mt] = true
+	}
+	for _, mt := range o.deniedMediaTypes {
+		p.denied[mt] = true
+	}
+
+	switch desc.MediaType {
+	case types.OCIImageIndex, types.DockerManifestList:
+		if o.platform != nil {
+			img, err := desc.Image()
+			if err != nil {
+				return err
+			}
+			if err := p.checkImage(img); err != nil {
+				return err
+			}
+		} else {
+			idx, err := desc.ImageIndex()
+			if err != nil {
+				return err
+			}
+			if err := p.checkIndex(idx); err != nil {
+				return err
+			}
+		}
+	case types.DockerManifestSchema1, types.DockerManifestSchema1Signed:
+		if len(p.allowed) != 0 {
+			return errors.New("schema 1 images have no layer media types to check against the allowed media types")
+		}
+	default:
+		img, err := desc.Image()
+		if err != nil {
+			return err
+		}
+		if err := p.checkImage(img); err != nil {
+			return err
+		}
+	}
+
+	if len(p.forbidden) == 0 {
+		return nil
+	}
+	forbidden := []string{}
+	for mt := range p.forbidden {
+		forbidden = append(forbidden, string(mt))
+	}
+	sort.Strings(forbidden)
+	return fmt.Errorf("media type policy forbids %s", strings.Join(forbidden, ", "))
+}
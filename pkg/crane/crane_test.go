@@ -17,6 +17,7 @@ package crane_test
 import (
 	"archive/tar"
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -24,14 +25,22 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-containerregistry/pkg/crane"
 	"github.com/google/go-containerregistry/pkg/internal/compare"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
 )
 
 // TODO(jonjohnsonjr): Test crane.Catalog behavior.
@@ -240,6 +249,107 @@ func TestCraneCopyIndex(t *testing.T) {
 	}
 }
 
+func TestCraneCopyIndexProgress(t *testing.T) {
+	// Set up a fake registry.
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := fmt.Sprintf("%s/test/crane", u.Host)
+	dst := fmt.Sprintf("%s/test/crane/progress", u.Host)
+
+	// Load up the registry.
+	idx, err := random.Index(1024, 3, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(ref, idx); err != nil {
+		t.Fatal(err)
+	}
+
+	var updates []v1.Update
+	if err := crane.Copy(src, dst, crane.WithProgress(func(u v1.Update) {
+		updates = append(updates, u)
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(updates) < 2 {
+		t.Fatalf("got %d updates, want more", len(updates))
+	}
+	total := updates[0].Total
+	for _, u := range updates {
+		if u.Total != total {
+			t.Errorf("Total changed from %d to %d", total, u.Total)
+		}
+	}
+	last := updates[len(updates)-1]
+	if last.Error != io.EOF {
+		t.Errorf("final update error = %v, want io.EOF", last.Error)
+	}
+	if last.Complete != last.Total {
+		t.Errorf("final update = %d/%d, want complete", last.Complete, last.Total)
+	}
+	// 3 images with 3 layers of 1024 bytes each, plus their configs.
+	if want := int64(3 * 3 * 1024); total <= want {
+		t.Errorf("Total = %d, want more than %d", total, want)
+	}
+}
+
+func TestCranePushStats(t *testing.T) {
+	// Set up a fake registry.
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stats remote.WriteStats
+	if err := crane.Push(base, fmt.Sprintf("%s/test/crane:v1", u.Host), crane.WithWriteStats(&stats)); err != nil {
+		t.Fatal(err)
+	}
+	// 3 layers and the config.
+	if stats.BlobsUploaded != 4 || stats.BlobsSkipped != 0 {
+		t.Errorf("first push: uploaded %d, skipped %d blobs, want 4, 0", stats.BlobsUploaded, stats.BlobsSkipped)
+	}
+
+	top, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := mutate.AppendLayers(base, top)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats = remote.WriteStats{}
+	if err := crane.Push(img, fmt.Sprintf("%s/test/crane:v2", u.Host), crane.WithWriteStats(&stats)); err != nil {
+		t.Fatal(err)
+	}
+	// Only the new layer and the config are uploaded.
+	if stats.BlobsUploaded != 2 || stats.BlobsSkipped != 3 {
+		t.Errorf("second push: uploaded %d, skipped %d blobs, want 2, 3", stats.BlobsUploaded, stats.BlobsSkipped)
+	}
+	size, err := top.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.BytesUploaded <= size {
+		t.Errorf("second push: uploaded %d bytes, want more than %d", stats.BytesUploaded, size)
+	}
+}
+
 func TestCraneTarball(t *testing.T) {
 	t.Parallel()
 	// Write an image as a tarball.
@@ -315,6 +425,95 @@ func TestCraneSaveOCI(t *testing.T) {
 	}
 }
 
+func TestCranePullTo(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := random.Index(1024, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imgSrc := fmt.Sprintf("%s/test/pull:image", u.Host)
+	idxSrc := fmt.Sprintf("%s/test/pull:index", u.Host)
+	if err := crane.Push(img, imgSrc); err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(idxSrc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(ref, idx); err != nil {
+		t.Fatal(err)
+	}
+
+	tmp, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	t.Run("tarball", func(t *testing.T) {
+		path := filepath.Join(tmp, "image.tar")
+		if err := crane.PullToTarball(imgSrc, path); err != nil {
+			t.Fatalf("PullToTarball() = %v", err)
+		}
+		got, err := crane.Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := compare.Images(img, got); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("layout", func(t *testing.T) {
+		path := filepath.Join(tmp, "layout")
+		if err := crane.PullToLayout(idxSrc, path); err != nil {
+			t.Fatalf("PullToLayout(index) = %v", err)
+		}
+		if err := crane.PullToLayout(imgSrc, path); err != nil {
+			t.Fatalf("PullToLayout(image) = %v", err)
+		}
+		p, err := layout.FromPath(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		root, err := p.ImageIndex()
+		if err != nil {
+			t.Fatal(err)
+		}
+		im, err := root.IndexManifest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(im.Manifests) != 2 {
+			t.Fatalf("index.json has %d manifests, want 2", len(im.Manifests))
+		}
+		got, err := root.ImageIndex(im.Manifests[0].Digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := compare.Indexes(idx, got); err != nil {
+			t.Error(err)
+		}
+		gotImg, err := root.Image(im.Manifests[1].Digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := compare.Images(img, gotImg); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
 func TestCraneFilesystem(t *testing.T) {
 	t.Parallel()
 	tmp, err := ioutil.TempFile("", "")
@@ -373,6 +572,83 @@ func TestCraneFilesystem(t *testing.T) {
 	}
 }
 
+func TestExportPath(t *testing.T) {
+	base, err := crane.Image(map[string][]byte{
+		"etc/passwd":     []byte("root"),
+		"etc/hosts":      []byte("localhost"),
+		"etc/ssl/cert":   []byte("cert"),
+		"var/lib/old":    []byte("old"),
+		"usr/bin/hello":  []byte("hello"),
+		"etceteras/file": []byte("not etc"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	upper, err := crane.Layer(map[string][]byte{
+		"etc/.wh.passwd":       {},
+		"etc/hosts":            []byte("updated"),
+		"var/lib/.wh..wh..opq": {},
+		"var/lib/new":          []byte("new"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := mutate.AppendLayers(base, upper)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		prefix string
+		want   map[string]string
+	}{{
+		prefix: "/etc",
+		want: map[string]string{
+			"etc/hosts":    "updated",
+			"etc/ssl/cert": "cert",
+		},
+	}, {
+		prefix: "etc/ssl/cert",
+		want: map[string]string{
+			"etc/ssl/cert": "cert",
+		},
+	}, {
+		prefix: "var/",
+		want: map[string]string{
+			"var/lib/new": "new",
+		},
+	}, {
+		prefix: "etc/passwd",
+		want:   map[string]string{},
+	}} {
+		t.Run(tc.prefix, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := crane.ExportPath(img, tc.prefix, &buf); err != nil {
+				t.Fatal(err)
+			}
+
+			got := map[string]string{}
+			tr := tar.NewReader(&buf)
+			for {
+				header, err := tr.Next()
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatal(err)
+				}
+				b, err := ioutil.ReadAll(tr)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got[header.Name] = string(b)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("ExportPath(%q) (-want +got) = %s", tc.prefix, diff)
+			}
+		})
+	}
+}
+
 func TestBadInputs(t *testing.T) {
 	t.Parallel()
 	invalid := "/dev/null/@@@@@@"
@@ -426,3 +702,595 @@ func TestBadInputs(t *testing.T) {
 		}
 	}
 }
+
+func TestCraneDigestPlatform(t *testing.T) {
+	// Set up a fake registry.
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := fmt.Sprintf("%s/test/crane", u.Host)
+
+	amd64, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arm64, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := mutate.AppendManifests(mutate.IndexMediaType(empty.Index, types.OCIImageIndex),
+		mutate.IndexAddendum{
+			Add: amd64,
+			Descriptor: v1.Descriptor{
+				Platform: &v1.Platform{OS: "linux", Architecture: "amd64"},
+			},
+		},
+		mutate.IndexAddendum{
+			Add: arm64,
+			Descriptor: v1.Descriptor{
+				Platform: &v1.Platform{OS: "linux", Architecture: "arm64"},
+			},
+		},
+	)
+	ref, err := name.ParseReference(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(ref, idx); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := idx.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := crane.Digest(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != id.String() {
+		t.Errorf("Digest(): %v != %v", d, id)
+	}
+
+	want, err := arm64.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err = crane.Digest(src, crane.WithPlatform(&v1.Platform{OS: "linux", Architecture: "arm64"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != want.String() {
+		t.Errorf("Digest(linux/arm64): %v != %v", d, want)
+	}
+
+	if _, err := crane.Digest(src, crane.WithPlatform(&v1.Platform{OS: "windows", Architecture: "amd64"})); err == nil {
+		t.Error("Digest(windows/amd64): expected error for a platform not in the index")
+	}
+}
+
+func TestCraneDefaultPlatform(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := fmt.Sprintf("%s/test/crane", u.Host)
+
+	amd64, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arm64, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := mutate.AppendManifests(mutate.IndexMediaType(empty.Index, types.OCIImageIndex),
+		mutate.IndexAddendum{
+			Add: amd64,
+			Descriptor: v1.Descriptor{
+				Platform: &v1.Platform{OS: "linux", Architecture: "amd64"},
+			},
+		},
+		mutate.IndexAddendum{
+			Add: arm64,
+			Descriptor: v1.Descriptor{
+				Platform: &v1.Platform{OS: "linux", Architecture: "arm64"},
+			},
+		},
+	)
+	ref, err := name.ParseReference(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(ref, idx); err != nil {
+		t.Fatal(err)
+	}
+
+	crane.DefaultPlatform = &v1.Platform{OS: "linux", Architecture: "arm64"}
+	defer func() { crane.DefaultPlatform = nil }()
+
+	want, err := arm64.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := crane.Pull(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := img.Digest(); err != nil {
+		t.Fatal(err)
+	} else if got != want {
+		t.Errorf("Pull(): %v != %v", got, want)
+	}
+
+	// An explicit platform still wins.
+	want, err = amd64.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err = crane.Pull(src, crane.WithPlatform(&v1.Platform{OS: "linux", Architecture: "amd64"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := img.Digest(); err != nil {
+		t.Fatal(err)
+	} else if got != want {
+		t.Errorf("Pull(linux/amd64): %v != %v", got, want)
+	}
+}
+
+type rawManifest []byte
+
+func (r rawManifest) RawManifest() ([]byte, error) {
+	return r, nil
+}
+
+func (r rawManifest) MediaType() (types.MediaType, error) {
+	return types.DockerManifestSchema2, nil
+}
+
+func TestCraneManifestOptions(t *testing.T) {
+	// Set up a fake registry.
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := fmt.Sprintf("%s/test/crane", u.Host)
+
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crane.Push(img, src); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := crane.Manifest(src, crane.WithPretty(), crane.WithSchemaValidation())
+	if err != nil {
+		t.Fatalf("Manifest() = %v", err)
+	}
+	if !bytes.Contains(b, []byte("\n  \"schemaVersion\": 2")) {
+		t.Errorf("Manifest(WithPretty) is not indented: %s", b)
+	}
+	raw, err := img.RawManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, b); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(compacted.Bytes(), raw) {
+		t.Errorf("Manifest(WithPretty) = %s, want %s", compacted.Bytes(), raw)
+	}
+
+	// A docker manifest with an OCI config.
+	m, err := img.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Config.MediaType = types.OCIConfigJSON
+	bad, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	badRef, err := name.ParseReference(src + ":bad")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Tag(badRef.(name.Tag), rawManifest(bad)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := crane.Manifest(badRef.String()); err != nil {
+		t.Errorf("Manifest() without validation = %v", err)
+	}
+	if _, err := crane.Manifest(badRef.String(), crane.WithSchemaValidation()); err == nil || !strings.Contains(err.Error(), "config: mediaType") {
+		t.Errorf("Manifest(WithSchemaValidation) = %v, want config media type error", err)
+	}
+}
+
+func TestExportWhiteoutPolicy(t *testing.T) {
+	base, err := crane.Image(map[string][]byte{
+		"etc/hosts":  []byte("hosts"),
+		"etc/passwd": []byte("passwd"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	upper, err := crane.Layer(map[string][]byte{
+		"etc/.wh.passwd":   {},
+		".wh..wh.plnk/foo": []byte("link"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := mutate.AppendLayers(base, upper)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		policy crane.WhiteoutPolicy
+		want   []string
+	}{{
+		policy: crane.WhiteoutOverlay,
+		want:   []string{".wh..wh.plnk/foo", "etc/hosts"},
+	}, {
+		policy: crane.WhiteoutNone,
+		want:   []string{"etc/hosts", "etc/passwd", ".wh..wh.plnk/foo", "etc/.wh.passwd"},
+	}, {
+		policy: crane.WhiteoutAUFS,
+		want:   []string{"etc/hosts", "etc/passwd", "etc/.wh.passwd"},
+	}} {
+		t.Run(string(tc.policy), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := crane.ExportWithOptions(img, &buf, crane.WithWhiteoutPolicy(tc.policy)); err != nil {
+				t.Fatal(err)
+			}
+
+			got := []string{}
+			tr := tar.NewReader(&buf)
+			for {
+				header, err := tr.Next()
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatal(err)
+				}
+				if header.Typeflag != tar.TypeDir {
+					got = append(got, header.Name)
+				}
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Export(%s) (-want +got) = %s", tc.policy, diff)
+			}
+		})
+	}
+
+	if err := crane.ExportWithOptions(img, ioutil.Discard, crane.WithWhiteoutPolicy("bogus")); err == nil {
+		t.Error("Export(bogus) = nil, want error")
+	}
+}
+
+func TestCraneTagIndex(t *testing.T) {
+	var uploads int
+	reg := registry.New()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/blobs/") && r.Method != http.MethodGet && r.Method != http.MethodHead {
+			uploads++
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := fmt.Sprintf("%s/test/crane:latest", u.Host)
+	idx, err := random.Index(1024, 3, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(ref, idx); err != nil {
+		t.Fatal(err)
+	}
+	uploads = 0
+
+	if err := crane.Tag(src, "retagged", crane.WithPlatform(&v1.Platform{OS: "linux", Architecture: "amd64"})); err != nil {
+		t.Fatalf("Tag() = %v", err)
+	}
+	if uploads != 0 {
+		t.Errorf("Tag() uploaded %d blobs, want 0", uploads)
+	}
+
+	want, err := idx.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc, err := remote.Head(ref.Context().Tag("retagged"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc.Digest != want {
+		t.Errorf("retagged digest = %v, want the index %v", desc.Digest, want)
+	}
+
+	if err := crane.Tag(src, "not:a:tag"); err == nil {
+		t.Error("Tag(invalid) = nil, want error")
+	}
+}
+
+func TestCraneConfigFile(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := fmt.Sprintf("%s/test/crane", u.Host)
+
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crane.Push(img, src); err != nil {
+		t.Fatal(err)
+	}
+	want, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := crane.ConfigFile(src)
+	if err != nil {
+		t.Fatalf("ConfigFile() = %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ConfigFile() (-want +got) = %s", diff)
+	}
+}
+
+func TestCraneValidate(t *testing.T) {
+	reg := registry.New()
+	var badDigest bool
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if badDigest && r.Method == http.MethodHead && strings.Contains(r.URL.Path, "/manifests/") {
+			w.Header().Set("Content-Type", string(types.DockerManifestSchema2))
+			w.Header().Set("Content-Length", "1")
+			w.Header().Set("Docker-Content-Digest", "sha256:0000000000000000000000000000000000000000000000000000000000000000")
+			return
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := fmt.Sprintf("%s/test/crane", u.Host)
+
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crane.Push(img, src); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := crane.Validate(src)
+	if err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	if !report.Passed() {
+		t.Errorf("Validate() = %v, want all checks to pass", report.Checks)
+	}
+	if len(report.Checks) != 3 {
+		t.Errorf("Validate() ran %d checks, want 3", len(report.Checks))
+	}
+
+	badDigest = true
+	report, err = crane.Validate(src)
+	if err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	if report.Passed() {
+		t.Error("Validate() passed, want manifest digest check to fail")
+	}
+	for _, c := range report.Checks {
+		if failed := c.Err != nil; failed != (c.Name == "manifest digest") {
+			t.Errorf("check %q: err = %v", c.Name, c.Err)
+		}
+	}
+}
+
+func TestCraneRebase(t *testing.T) {
+	reg := registry.New()
+	blobGets := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/blobs/") {
+			blobGets++
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldSrc := fmt.Sprintf("%s/test/old", u.Host)
+	newSrc := fmt.Sprintf("%s/test/new", u.Host)
+	origSrc := fmt.Sprintf("%s/test/orig", u.Host)
+
+	oldBase, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newBase, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	top, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	amd64, err := mutate.AppendLayers(oldBase, top)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arm64, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{
+			Add: amd64,
+			Descriptor: v1.Descriptor{
+				Platform: &v1.Platform{OS: "linux", Architecture: "amd64"},
+			},
+		},
+		mutate.IndexAddendum{
+			Add: arm64,
+			Descriptor: v1.Descriptor{
+				Platform: &v1.Platform{OS: "linux", Architecture: "arm64"},
+			},
+		},
+	)
+	if err := crane.Push(oldBase, oldSrc); err != nil {
+		t.Fatal(err)
+	}
+	if err := crane.Push(newBase, newSrc); err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(origSrc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(ref, idx); err != nil {
+		t.Fatal(err)
+	}
+
+	blobGets = 0
+	platform := crane.WithPlatform(&v1.Platform{OS: "linux", Architecture: "amd64"})
+	img, err := crane.Rebase(origSrc, oldSrc, newSrc, platform)
+	if err != nil {
+		t.Fatalf("Rebase() = %v", err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layers) != 4 {
+		t.Fatalf("Rebase() has %d layers, want 4", len(layers))
+	}
+	want, err := top.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := layers[3].Digest(); err != nil {
+		t.Fatal(err)
+	} else if got != want {
+		t.Errorf("top layer = %s, want %s", got, want)
+	}
+	// Only the three configs should have been fetched.
+	if blobGets != 3 {
+		t.Errorf("Rebase() fetched %d blobs, want 3", blobGets)
+	}
+
+	// The arm64 image isn't based on the old base.
+	platform = crane.WithPlatform(&v1.Platform{OS: "linux", Architecture: "arm64"})
+	if _, err := crane.Rebase(origSrc, oldSrc, newSrc, platform); err == nil || !strings.Contains(err.Error(), "is not based on") {
+		t.Errorf("Rebase(arm64) = %v, want not based on error", err)
+	}
+}
+
+func TestCraneCopyMediaTypePolicy(t *testing.T) {
+	reg := registry.New()
+	uploads := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			uploads++
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := fmt.Sprintf("%s/test/src", u.Host)
+	srcIdx := fmt.Sprintf("%s/test/src:index", u.Host)
+	dst := fmt.Sprintf("%s/test/dst", u.Host)
+
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crane.Push(img, src); err != nil {
+		t.Fatal(err)
+	}
+	idx, err := random.Index(1024, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(srcIdx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(ref, idx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		src     string
+		opt     crane.Option
+		wantErr string
+	}{{
+		name:    "denied",
+		src:     src,
+		opt:     crane.WithDeniedMediaTypes(types.DockerLayer),
+		wantErr: string(types.DockerLayer),
+	}, {
+		name:    "not allowed",
+		src:     src,
+		opt:     crane.WithAllowedMediaTypes(types.OCIConfigJSON, types.OCILayer),
+		wantErr: fmt.Sprintf("%s, %s", types.DockerConfigJSON, types.DockerLayer),
+	}, {
+		name:    "denied in index",
+		src:     srcIdx,
+		opt:     crane.WithDeniedMediaTypes(types.DockerLayer),
+		wantErr: string(types.DockerLayer),
+	}, {
+		name: "allowed",
+		src:  src,
+		opt:  crane.WithAllowedMediaTypes(types.DockerConfigJSON, types.DockerLayer),
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			uploads = 0
+			err := crane.Copy(tc.src, dst, tc.opt)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Errorf("Copy() = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Copy() = %v, want error listing %s", err, tc.wantErr)
+			}
+			if uploads != 0 {
+				t.Errorf("Copy() made %d write requests before refusing", uploads)
+			}
+		})
+	}
+}
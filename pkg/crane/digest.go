@@ -15,6 +15,10 @@
 package crane
 
 // Digest returns the sha256 hash of the remote image at ref.
+//
+// If WithPlatform is passed and ref refers to an index, Digest returns the
+// digest of the child manifest matching that platform, or an error if none of
+// the children match.
 func Digest(ref string, opt ...Option) (string, error) {
 	o := makeOptions(opt...)
 	if o.platform != nil {
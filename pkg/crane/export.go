@@ -15,15 +15,178 @@
 package crane
 
 import (
+	"archive/tar"
+	"fmt"
 	"io"
+	"path"
+	"strings"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 )
 
+// WhiteoutPolicy controls how Export represents files deleted by a layer.
+type WhiteoutPolicy string
+
+const (
+	// WhiteoutOverlay applies whiteouts and opaque directories, so that the
+	// tarball contains the filesystem as an overlay mount of the image would
+	// present it. This is the default.
+	WhiteoutOverlay WhiteoutPolicy = "overlay"
+
+	// WhiteoutNone concatenates the layers from the bottom up without
+	// interpreting whiteouts, passing ".wh." files through untouched so that
+	// callers can implement their own deletion logic.
+	WhiteoutNone WhiteoutPolicy = "none"
+
+	// WhiteoutAUFS is like WhiteoutNone, but drops the ".wh..wh." metadata
+	// files that AUFS keeps for its own bookkeeping, other than opaque
+	// directory markers.
+	WhiteoutAUFS WhiteoutPolicy = "aufs"
+)
+
+const (
+	whiteoutPrefix     = ".wh."
+	whiteoutMetaPrefix = whiteoutPrefix + whiteoutPrefix
+	whiteoutOpaqueDir  = whiteoutMetaPrefix + ".opq"
+)
+
 // Export writes the filesystem contents (as a tarball) of img to w.
 func Export(img v1.Image, w io.Writer) error {
-	fs := mutate.Extract(img)
-	_, err := io.Copy(w, fs)
+	return ExportWithOptions(img, w)
+}
+
+// ExportWithOptions is Export with options. See WithWhiteoutPolicy to control
+// how deleted files are represented.
+func ExportWithOptions(img v1.Image, w io.Writer, opt ...Option) error {
+	fs, err := extract(img, makeOptions(opt...).whiteout)
+	if err != nil {
+		return err
+	}
+	defer fs.Close()
+	_, err = io.Copy(w, fs)
 	return err
 }
+
+// extract returns the filesystem contents of img as a tarball, representing
+// whiteouts according to policy.
+func extract(img v1.Image, policy WhiteoutPolicy) (io.ReadCloser, error) {
+	switch policy {
+	case "", WhiteoutOverlay:
+		return mutate.Extract(img), nil
+	case WhiteoutNone, WhiteoutAUFS:
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(concatLayers(img, policy, pw))
+		}()
+		return pr, nil
+	default:
+		return nil, fmt.Errorf("unknown whiteout policy %q", policy)
+	}
+}
+
+// concatLayers writes the entries of every layer of img to w, bottom layer
+// first, without applying whiteouts.
+func concatLayers(img v1.Image, policy WhiteoutPolicy, w io.Writer) error {
+	layers, err := img.Layers()
+	if err != nil {
+		return fmt.Errorf("retrieving image layers: %v", err)
+	}
+	tw := tar.NewWriter(w)
+	for _, layer := range layers {
+		if err := copyLayer(layer, policy, tw); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func copyLayer(layer v1.Layer, policy WhiteoutPolicy, tw *tar.Writer) error {
+	rc, err := layer.Uncompressed()
+	if err != nil {
+		return fmt.Errorf("reading layer contents: %v", err)
+	}
+	defer rc.Close()
+
+	tr := tar.NewReader(rc)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading tar: %v", err)
+		}
+		if policy == WhiteoutAUFS && isAUFSMeta(header.Name) {
+			continue
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// ExportPath writes the filesystem contents of img under prefix (as a tarball)
+// to w. The prefix may name a single file or a directory, in which case
+// everything beneath it is included. Whiteouts are applied across all layers
+// first, so files deleted in an upper layer are never written.
+func ExportPath(img v1.Image, prefix string, w io.Writer) error {
+	return ExportPathWithOptions(img, prefix, w)
+}
+
+// ExportPathWithOptions is ExportPath with options, e.g. WithWhiteoutPolicy.
+func ExportPathWithOptions(img v1.Image, prefix string, w io.Writer, opt ...Option) error {
+	fs, err := extract(img, makeOptions(opt...).whiteout)
+	if err != nil {
+		return err
+	}
+	defer fs.Close()
+
+	prefix = cleanPath(prefix)
+
+	tr := tar.NewReader(fs)
+	tw := tar.NewWriter(w)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if !underPrefix(cleanPath(header.Name), prefix) {
+			continue
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// isAUFSMeta returns whether name is, or is beneath, one of AUFS's own
+// metadata files, e.g. ".wh..wh.plnk/...".
+func isAUFSMeta(name string) bool {
+	for _, part := range strings.Split(cleanPath(name), "/") {
+		if strings.HasPrefix(part, whiteoutMetaPrefix) && part != whiteoutOpaqueDir {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanPath normalizes tar entry names like "./etc/" and "/etc" to "etc".
+func cleanPath(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
+
+func underPrefix(name, prefix string) bool {
+	return prefix == "" || name == prefix || strings.HasPrefix(name, prefix+"/")
+}
@@ -14,19 +14,124 @@
 
 package crane
 
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
 // Manifest returns the manifest for the remote image or index ref.
+//
+// With WithSchemaValidation, the manifest is checked against the schema of its
+// media type, and with WithPretty, it is indented for human consumption.
 func Manifest(ref string, opt ...Option) ([]byte, error) {
 	desc, err := getManifest(ref, opt...)
 	if err != nil {
 		return nil, err
 	}
 	o := makeOptions(opt...)
+	b, mt := desc.Manifest, desc.MediaType
 	if o.platform != nil {
 		img, err := desc.Image()
 		if err != nil {
 			return nil, err
 		}
-		return img.RawManifest()
+		if b, err = img.RawManifest(); err != nil {
+			return nil, err
+		}
+		if mt, err = img.MediaType(); err != nil {
+			return nil, err
+		}
+	}
+	if o.validateSchema {
+		if err := validateManifest(b, mt); err != nil {
+			return nil, fmt.Errorf("validating manifest for %q: %w", ref, err)
+		}
+	}
+	if o.pretty {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, b, "", "  "); err != nil {
+			return nil, err
+		}
+		buf.WriteByte('\n')
+		return buf.Bytes(), nil
+	}
+	return b, nil
+}
+
+// validateManifest checks that b conforms to the schema of mt.
+func validateManifest(b []byte, mt types.MediaType) error {
+	switch mt {
+	case types.DockerManifestSchema2, types.OCIManifestSchema1:
+		return validateImageManifest(b, mt)
+	case types.DockerManifestList, types.OCIImageIndex:
+		return validateIndexManifest(b, mt)
+	}
+	return fmt.Errorf("unable to validate media type %s", mt)
+}
+
+func validateImageManifest(b []byte, mt types.MediaType) error {
+	m, err := v1.ParseManifest(bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	if m.SchemaVersion != 2 {
+		return fmt.Errorf("schemaVersion = %d, want 2", m.SchemaVersion)
+	}
+	// The mediaType field is optional for OCI, but must match if it's set.
+	if m.MediaType != mt && (m.MediaType != "" || mt == types.DockerManifestSchema2) {
+		return fmt.Errorf("mediaType = %q, want %q", m.MediaType, mt)
+	}
+	if err := validateDescriptor(m.Config); err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	switch {
+	case mt == types.DockerManifestSchema2 && m.Config.MediaType != types.DockerConfigJSON:
+		return fmt.Errorf("config: mediaType = %q, want %q", m.Config.MediaType, types.DockerConfigJSON)
+	case mt == types.OCIManifestSchema1 && m.Config.MediaType == types.DockerConfigJSON:
+		// Other media types are allowed for artifacts.
+		return fmt.Errorf("config: mediaType = %q, want %q", m.Config.MediaType, types.OCIConfigJSON)
+	}
+	for i, l := range m.Layers {
+		if err := validateDescriptor(l); err != nil {
+			return fmt.Errorf("layers[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func validateIndexManifest(b []byte, mt types.MediaType) error {
+	im, err := v1.ParseIndexManifest(bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	if im.SchemaVersion != 2 {
+		return fmt.Errorf("schemaVersion = %d, want 2", im.SchemaVersion)
+	}
+	if im.MediaType != mt && (im.MediaType != "" || mt == types.DockerManifestList) {
+		return fmt.Errorf("mediaType = %q, want %q", im.MediaType, mt)
+	}
+	for i, desc := range im.Manifests {
+		if err := validateDescriptor(desc); err != nil {
+			return fmt.Errorf("manifests[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func validateDescriptor(desc v1.Descriptor) error {
+	if desc.MediaType == "" {
+		return errors.New("missing mediaType")
+	}
+	if desc.Digest == (v1.Hash{}) {
+		return errors.New("missing digest")
+	}
+	if desc.Size < 0 {
+		return fmt.Errorf("size = %d, must not be negative", desc.Size)
 	}
-	return desc.Manifest, nil
+	return nil
 }
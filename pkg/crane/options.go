@@ -21,20 +21,39 @@ import (
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
 )
 
 type options struct {
 	name     []name.Option
 	remote   []remote.Option
 	platform *v1.Platform
+	progress func(v1.Update)
+
+	pretty         bool
+	validateSchema bool
+	whiteout       WhiteoutPolicy
+
+	allowedMediaTypes []types.MediaType
+	deniedMediaTypes  []types.MediaType
 }
 
+// DefaultPlatform is the platform used when WithPlatform isn't given, e.g.
+// v1.HostPlatform() so that Pull of an index returns the image for the machine
+// crane is running on. It is nil by default, which works on indexes as a whole
+// where possible and otherwise resolves them to linux/amd64.
+var DefaultPlatform *v1.Platform
+
 func makeOptions(opts ...Option) options {
 	opt := options{
 		remote: []remote.Option{
 			remote.WithAuthFromKeychain(authn.DefaultKeychain),
 		},
 	}
+	if DefaultPlatform != nil {
+		p := *DefaultPlatform
+		WithPlatform(&p)(&opt)
+	}
 	for _, o := range opts {
 		o(&opt)
 	}
@@ -97,3 +116,86 @@ func WithUserAgent(ua string) Option {
 		o.remote = append(o.remote, remote.WithUserAgent(ua))
 	}
 }
+
+// WithProgress is a functional option for receiving updates about the bytes
+// written by Copy. When copying an index, updates are aggregated across the
+// blobs of all of its children.
+//
+// The final update has an Error of io.EOF on success, or the error that caused
+// the copy to fail.
+func WithProgress(f func(v1.Update)) Option {
+	return func(o *options) {
+		o.progress = f
+	}
+}
+
+// WithWriteStats is a functional option for finding out how many blobs Copy or
+// Push uploaded, and how many were skipped because the destination already had
+// them. See remote.WithWriteStats.
+func WithWriteStats(stats *remote.WriteStats) Option {
+	return func(o *options) {
+		o.remote = append(o.remote, remote.WithWriteStats(stats))
+	}
+}
+
+// WithJobs sets the number of concurrent requests used by operations that
+// support parallelism, e.g. how many layers Pull downloads at once with
+// WithPrefetch, see remote.WithJobs.
+func WithJobs(jobs int) Option {
+	return func(o *options) {
+		o.remote = append(o.remote, remote.WithJobs(jobs))
+	}
+}
+
+// WithPrefetch makes Pull download the image's layers concurrently into dir
+// the first time they are accessed, see remote.WithPrefetch.
+func WithPrefetch(dir string) Option {
+	return func(o *options) {
+		o.remote = append(o.remote, remote.WithPrefetch(dir))
+	}
+}
+
+// WithPretty is an Option that makes Manifest indent the returned JSON.
+func WithPretty() Option {
+	return func(o *options) {
+		o.pretty = true
+	}
+}
+
+// WithSchemaValidation is an Option that makes Manifest check that the
+// manifest conforms to the schema of its media type, e.g. that it has a config
+// of the right media type and that each descriptor has a digest, returning a
+// descriptive error if it doesn't.
+func WithSchemaValidation() Option {
+	return func(o *options) {
+		o.validateSchema = true
+	}
+}
+
+// WithWhiteoutPolicy is an Option that sets how ExportWithOptions and
+// ExportPathWithOptions handle whiteout files, see WhiteoutPolicy. The default
+// is WhiteoutOverlay.
+func WithWhiteoutPolicy(policy WhiteoutPolicy) Option {
+	return func(o *options) {
+		o.whiteout = policy
+	}
+}
+
+// WithAllowedMediaTypes is an Option that makes Copy refuse to copy images
+// with config or layer media types other than mts. The check happens before
+// any blobs are copied, so nothing is written to the destination if it fails.
+func WithAllowedMediaTypes(mts ...types.MediaType) Option {
+	return func(o *options) {
+		o.allowedMediaTypes = append(o.allowedMediaTypes, mts...)
+	}
+}
+
+// WithDeniedMediaTypes is an Option that makes Copy refuse to copy images
+// with any of the config or layer media types mts, e.g.
+// types.DockerForeignLayer. Like WithAllowedMediaTypes, the check happens
+// before any blobs are copied.
+func WithDeniedMediaTypes(mts ...types.MediaType) Option {
+	return func(o *options) {
+		o.deniedMediaTypes = append(o.deniedMediaTypes, mts...)
+	}
+}
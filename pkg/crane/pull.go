@@ -37,7 +37,7 @@ func Pull(src string, opt ...Option) (v1.Image, error) {
 	o := makeOptions(opt...)
 	ref, err := name.ParseReference(src, o.name...)
 	if err != nil {
-		return nil, fmt.Errorf("parsing tag %q: %v", src, err)
+		return nil, fmt.Errorf("parsing tag %q: %w", src, err)
 	}
 
 	return remote.Image(ref, o.remote...)
@@ -47,9 +47,20 @@ func Pull(src string, opt ...Option) (v1.Image, error) {
 func Save(img v1.Image, src, path string) error {
 	ref, err := name.ParseReference(src)
 	if err != nil {
-		return fmt.Errorf("parsing ref %q: %v", src, err)
+		return fmt.Errorf("parsing ref %q: %w", src, err)
 	}
 
+	tag, err := tarballTag(ref)
+	if err != nil {
+		return err
+	}
+
+	// no progress channel (for now)
+	return tarball.WriteToFile(path, tag, img)
+}
+
+// tarballTag returns the tag to write ref to a tarball with.
+func tarballTag(ref name.Reference) (name.Tag, error) {
 	// WriteToFile wants a tag to write to the tarball, but we might have
 	// been given a digest.
 	// If the original ref was a tag, use that. Otherwise, if it was a
@@ -58,13 +69,74 @@ func Save(img v1.Image, src, path string) error {
 	if !ok {
 		d, ok := ref.(name.Digest)
 		if !ok {
-			return fmt.Errorf("ref wasn't a tag or digest")
+			return name.Tag{}, fmt.Errorf("ref wasn't a tag or digest")
 		}
 		tag = d.Repository.Tag(iWasADigestTag)
 	}
+	return tag, nil
+}
 
-	// no progress channel (for now)
-	return tarball.WriteToFile(path, tag, img)
+// PullToTarball writes the remote image src as a tarball at path. It is a
+// convenience wrapper around remote.Image and tarball.WriteToFile, see those
+// for how the layers are fetched and written.
+func PullToTarball(src, path string, opt ...Option) error {
+	o := makeOptions(opt...)
+	ref, err := name.ParseReference(src, o.name...)
+	if err != nil {
+		return fmt.Errorf("parsing reference %q: %w", src, err)
+	}
+	tag, err := tarballTag(ref)
+	if err != nil {
+		return err
+	}
+
+	img, err := remote.Image(ref, o.remote...)
+	if err != nil {
+		return fmt.Errorf("fetching %q: %w", src, err)
+	}
+	if err := tarball.WriteToFile(path, tag, img); err != nil {
+		return fmt.Errorf("writing %q to %s: %w", src, path, err)
+	}
+	return nil
+}
+
+// PullToLayout writes the remote image or index src to the OCI Image Layout
+// at path, creating it if necessary. Unless WithPlatform is given, an index is
+// written in full, including all of its children.
+//
+// Blobs are verified as they are read, and blobs that the layout already has
+// are not fetched at all, see SaveOCI.
+func PullToLayout(src, path string, opt ...Option) error {
+	o := makeOptions(opt...)
+	ref, err := name.ParseReference(src, o.name...)
+	if err != nil {
+		return fmt.Errorf("parsing reference %q: %w", src, err)
+	}
+	desc, err := remote.Get(ref, o.remote...)
+	if err != nil {
+		return fmt.Errorf("fetching %q: %w", src, err)
+	}
+
+	p, err := layout.FromPath(path)
+	if err != nil {
+		p, err = layout.Write(path, empty.Index)
+		if err != nil {
+			return err
+		}
+	}
+
+	if desc.MediaType.IsIndex() && o.platform == nil {
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			return err
+		}
+		return p.AppendIndex(idx)
+	}
+	img, err := desc.Image()
+	if err != nil {
+		return err
+	}
+	return p.AppendImage(img)
 }
 
 // PullLayer returns the given layer from a registry.
@@ -82,7 +154,7 @@ func PullLayer(ref string, opt ...Option) (v1.Layer, error) {
 func SaveLegacy(img v1.Image, src, path string) error {
 	ref, err := name.ParseReference(src)
 	if err != nil {
-		return fmt.Errorf("parsing ref %q: %v", src, err)
+		return fmt.Errorf("parsing ref %q: %w", src, err)
 	}
 
 	w, err := os.Create(path)
@@ -95,7 +167,8 @@ func SaveLegacy(img v1.Image, src, path string) error {
 }
 
 // SaveOCI writes the v1.Image img as an OCI Image Layout at path. If a layout
-// already exists at that path, it will add the image to the index.
+// already exists at that path, it will add the image to the index, skipping
+// the blobs it already has.
 func SaveOCI(img v1.Image, path string) error {
 	p, err := layout.FromPath(path)
 	if err != nil {
@@ -0,0 +1,50 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane
+
+import (
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+)
+
+// Rebase pulls orig, oldBase and newBase and returns orig with the layers of
+// oldBase replaced by those of newBase, see mutate.Rebase. The result is ready
+// to be pushed.
+//
+// The images are fetched lazily, so only the manifests and configs are read;
+// layer contents aren't downloaded. If WithPlatform is given, multi-platform
+// references are resolved to the image for that platform.
+func Rebase(orig, oldBase, newBase string, opt ...Option) (v1.Image, error) {
+	origImg, err := Pull(orig, opt...)
+	if err != nil {
+		return nil, fmt.Errorf("pulling %s: %w", orig, err)
+	}
+	oldBaseImg, err := Pull(oldBase, opt...)
+	if err != nil {
+		return nil, fmt.Errorf("pulling %s: %w", oldBase, err)
+	}
+	newBaseImg, err := Pull(newBase, opt...)
+	if err != nil {
+		return nil, fmt.Errorf("pulling %s: %w", newBase, err)
+	}
+
+	img, err := mutate.Rebase(origImg, oldBaseImg, newBaseImg)
+	if err != nil {
+		return nil, fmt.Errorf("rebasing %s from %s onto %s: %w", orig, oldBase, newBase, err)
+	}
+	return img, nil
+}
@@ -21,19 +21,25 @@ import (
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 )
 
-// Tag adds tag to the remote img.
+// Tag adds tag to the remote img, in the same repository.
+//
+// Only the manifest is fetched and PUT under the new tag, since its blobs are
+// already in the repository, so nothing is pulled or uploaded. If img is an
+// index, the whole index is retagged, regardless of WithPlatform.
 func Tag(img, tag string, opt ...Option) error {
 	o := makeOptions(opt...)
 	ref, err := name.ParseReference(img, o.name...)
 	if err != nil {
 		return fmt.Errorf("parsing reference %q: %v", img, err)
 	}
+	dst, err := ref.Context().ParseTag(tag)
+	if err != nil {
+		return fmt.Errorf("parsing tag %q: %w", tag, err)
+	}
 	desc, err := remote.Get(ref, o.remote...)
 	if err != nil {
 		return fmt.Errorf("fetching %q: %v", img, err)
 	}
 
-	dst := ref.Context().Tag(tag)
-
 	return remote.Tag(dst, desc, o.remote...)
 }
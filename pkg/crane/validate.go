@@ -0,0 +1,115 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crane
+
+import (
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+// ValidationCheck is the outcome of one of the checks run by Validate.
+type ValidationCheck struct {
+	// Name describes what was checked, e.g. "manifest digest".
+	Name string
+
+	// Err is nil if the check passed.
+	Err error
+}
+
+// ValidationReport is the outcome of Validate.
+type ValidationReport struct {
+	// Digest is the digest of the manifest that was validated.
+	Digest v1.Hash
+
+	Checks []ValidationCheck
+}
+
+// Passed returns whether every check passed.
+func (r *ValidationReport) Passed() bool {
+	for _, c := range r.Checks {
+		if c.Err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *ValidationReport) add(name string, err error) {
+	r.Checks = append(r.Checks, ValidationCheck{Name: name, Err: err})
+}
+
+// Validate pulls the remote image or index ref and checks it end to end:
+//
+//   - "manifest digest": the manifest hashes to the registry's
+//     Docker-Content-Digest for it.
+//   - "media types": the manifest conforms to the schema of its media type,
+//     see WithSchemaValidation.
+//   - "contents": every blob hashes to its digest, the config's diff IDs match
+//     the layers, and so on, see validate.Image and validate.Index.
+//
+// An error is only returned if ref couldn't be fetched at all; failed checks
+// are recorded in the returned report. With WithPlatform, only the matching
+// child of an index is validated.
+func Validate(ref string, opt ...Option) (*ValidationReport, error) {
+	o := makeOptions(opt...)
+	r, err := name.ParseReference(ref, o.name...)
+	if err != nil {
+		return nil, fmt.Errorf("parsing reference %q: %w", ref, err)
+	}
+	desc, err := remote.Get(r, o.remote...)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %q: %w", ref, err)
+	}
+
+	report := &ValidationReport{Digest: desc.Digest}
+	report.add("manifest digest", checkContentDigest(r, desc, o))
+	report.add("media types", validateManifest(desc.Manifest, desc.MediaType))
+
+	switch desc.MediaType {
+	case types.OCIImageIndex, types.DockerManifestList:
+		if o.platform == nil {
+			idx, err := desc.ImageIndex()
+			if err == nil {
+				err = validate.Index(idx)
+			}
+			report.add("contents", err)
+			return report, nil
+		}
+	}
+	img, err := desc.Image()
+	if err == nil {
+		err = validate.Image(img)
+	}
+	report.add("contents", err)
+	return report, nil
+}
+
+// checkContentDigest compares the digest of the manifest we fetched with the
+// Docker-Content-Digest the registry returns for it.
+func checkContentDigest(ref name.Reference, desc *remote.Descriptor, o options) error {
+	head, err := remote.Head(ref, o.remote...)
+	if err != nil {
+		return fmt.Errorf("HEAD %s: %w", ref, err)
+	}
+	if head.Digest != desc.Digest {
+		return fmt.Errorf("Docker-Content-Digest %s does not match manifest digest %s", head.Digest, desc.Digest)
+	}
+	return nil
+}
@@ -26,9 +26,14 @@ const (
 )
 
 // Digest stores a digest name in a structured form.
+//
+// A Digest may also carry the tag it was parsed with, as in
+// registry/repository:tag@digest. The tag is informational only: the digest
+// always identifies the content.
 type Digest struct {
 	Repository
 	digest   string
+	tag      string
 	original string
 }
 
@@ -50,8 +55,17 @@ func (d Digest) DigestStr() string {
 	return d.digest
 }
 
+// TagStr returns the tag component of the Digest, or "" if it was not
+// parsed with a tag.
+func (d Digest) TagStr() string {
+	return d.tag
+}
+
 // Name returns the name from which the Digest was derived.
 func (d Digest) Name() string {
+	if d.tag != "" {
+		return d.Repository.Name() + tagDelim + d.tag + digestDelim + d.DigestStr()
+	}
 	return d.Repository.Name() + digestDelim + d.DigestStr()
 }
 
@@ -79,18 +93,20 @@ func NewDigest(name string, opts ...Option) (Digest, error) {
 		return Digest{}, err
 	}
 
-	tag, err := NewTag(base, opts...)
-	if err == nil {
-		base = tag.Repository.Name()
-	}
-
+	tag := ""
 	repo, err := NewRepository(base, opts...)
 	if err != nil {
-		return Digest{}, err
+		// The base may also include a tag, e.g. registry/repository:tag@digest.
+		t, terr := NewTag(base, opts...)
+		if terr != nil {
+			return Digest{}, err
+		}
+		repo, tag = t.Repository, t.TagStr()
 	}
 	return Digest{
 		Repository: repo,
 		digest:     digest,
+		tag:        tag,
 		original:   name,
 	}, nil
 }
@@ -63,8 +63,10 @@ func TestNewDigestStrictValidation(t *testing.T) {
 	}
 
 	for _, name := range goodStrictValidationTagDigestNames {
-		if _, err := NewDigest(name, StrictValidation); err != nil {
+		if digest, err := NewDigest(name, StrictValidation); err != nil {
 			t.Errorf("`%s` should be a valid Digest name, got error: %v", name, err)
+		} else if digest.Name() != name {
+			t.Errorf("`%v` .Name() should reproduce the original name. Wanted: %s Got: %s", digest, name, digest.Name())
 		}
 	}
 
@@ -127,6 +129,49 @@ func TestDigestComponents(t *testing.T) {
 	}
 }
 
+func TestDigestWithTag(t *testing.T) {
+	t.Parallel()
+	repoStr := "gcr.io/project-id/image"
+	nameStr := repoStr + ":v1.0@" + validDigest
+
+	ref, err := ParseReference(nameStr, StrictValidation)
+	if err != nil {
+		t.Fatalf("ParseReference(%q) = %v", nameStr, err)
+	}
+	digest, ok := ref.(Digest)
+	if !ok {
+		t.Fatalf("ParseReference(%q) = %T, want Digest", nameStr, ref)
+	}
+
+	if got := digest.TagStr(); got != "v1.0" {
+		t.Errorf("TagStr() was incorrect for %v. Wanted: `v1.0` Got: `%s`", digest, got)
+	}
+	if got := digest.DigestStr(); got != validDigest {
+		t.Errorf("DigestStr() was incorrect for %v. Wanted: `%s` Got: `%s`", digest, validDigest, got)
+	}
+	if got := digest.Identifier(); got != validDigest {
+		t.Errorf("Identifier() was incorrect for %v. Wanted: `%s` Got: `%s`", digest, validDigest, got)
+	}
+	if got := digest.Context().String(); got != repoStr {
+		t.Errorf("Context().String() was incorrect for %v. Wanted: `%s` Got: `%s`", digest, repoStr, got)
+	}
+	if got := digest.Name(); got != nameStr {
+		t.Errorf("Name() was incorrect for %v. Wanted: `%s` Got: `%s`", digest, nameStr, got)
+	}
+	if got := digest.String(); got != nameStr {
+		t.Errorf("String() was incorrect for %v. Wanted: `%s` Got: `%s`", digest, nameStr, got)
+	}
+
+	// Without a tag, TagStr is empty.
+	digest, err = NewDigest(repoStr+"@"+validDigest, StrictValidation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := digest.TagStr(); got != "" {
+		t.Errorf("TagStr() was incorrect for %v. Wanted: `` Got: `%s`", digest, got)
+	}
+}
+
 func TestDigestScopes(t *testing.T) {
 	t.Parallel()
 	testRegistry := "gcr.io"
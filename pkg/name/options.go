@@ -53,6 +53,15 @@ func StrictValidation(opts *options) {
 	opts.strict = true
 }
 
+// NoDefaults is an Option that rejects image references rather than inserting
+// a default registry, repository namespace or tag, so that callers know
+// exactly what was given. It is equivalent to StrictValidation, and like any
+// other Option, the last of StrictValidation, WeakValidation and NoDefaults
+// wins.
+func NoDefaults(opts *options) {
+	StrictValidation(opts)
+}
+
 // WeakValidation is an Option that sets defaults when parsing names, see
 // StrictValidation.
 func WeakValidation(opts *options) {
@@ -16,6 +16,7 @@ package name
 
 import (
 	"fmt"
+	"strings"
 )
 
 // Reference defines the interface that consumers use when they can
@@ -37,15 +38,26 @@ type Reference interface {
 }
 
 // ParseReference parses the string as a reference, either by tag or digest.
+//
+// With StrictValidation (or NoDefaults), the returned error explains which
+// part of the reference would have needed to be defaulted.
 func ParseReference(s string, opts ...Option) (Reference, error) {
-	if t, err := NewTag(s, opts...); err == nil {
+	t, terr := NewTag(s, opts...)
+	if terr == nil {
 		return t, nil
 	}
-	if d, err := NewDigest(s, opts...); err == nil {
+	d, derr := NewDigest(s, opts...)
+	if derr == nil {
 		return d, nil
 	}
+	if makeOptions(opts...).strict {
+		err := terr
+		if strings.Contains(s, digestDelim) {
+			err = derr
+		}
+		return nil, NewErrBadName("could not parse reference: %s: %v", s, err)
+	}
 	return nil, NewErrBadName("could not parse reference: " + s)
-
 }
 
 // MustParseReference behaves like ParseReference, but panics instead of returning an error.
@@ -56,3 +68,21 @@ func MustParseReference(s string, opts ...Option) Reference {
 	}
 	return ref
 }
+
+// SameRepository returns whether a and b refer to the same repository, after
+// defaulting the registry and namespace, e.g. "ubuntu" and
+// "docker.io/library/ubuntu" are in the same repository.
+//
+// Registry hostnames are compared case-insensitively, but ports must match
+// exactly, so "localhost:5000/foo" and "localhost/foo" are different repositories.
+func SameRepository(a, b Reference) bool {
+	ar, br := a.Context(), b.Context()
+	return strings.EqualFold(ar.RegistryStr(), br.RegistryStr()) && ar.RepositoryStr() == br.RepositoryStr()
+}
+
+// Equal returns whether a and b refer to the same tag or digest in the same
+// repository, see SameRepository. A tag never equals a digest, and the tag
+// carried by a Digest is ignored since the digest identifies the content.
+func Equal(a, b Reference) bool {
+	return SameRepository(a, b) && a.Identifier() == b.Identifier()
+}
@@ -15,6 +15,7 @@
 package name
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -151,3 +152,74 @@ func TestMustParseReference(t *testing.T) {
 		}()
 	}
 }
+
+func TestParseReferenceNoDefaults(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		want string
+	}{{
+		name: "ubuntu",
+		want: "tag",
+	}, {
+		name: "ubuntu:latest",
+		want: "registry",
+	}, {
+		name: "index.docker.io/ubuntu:latest",
+		want: "library",
+	}, {
+		name: "ubuntu@sha256:deadb33fdeadb33fdeadb33fdeadb33fdeadb33fdeadb33fdeadb33fdeadb33f",
+		want: "registry",
+	}} {
+		_, err := ParseReference(test.name, NoDefaults)
+		if err == nil {
+			t.Errorf("ParseReference(%q, NoDefaults); expected error", test.name)
+			continue
+		}
+		if !IsErrBadName(err) {
+			t.Errorf("ParseReference(%q, NoDefaults); got %T, want ErrBadName", test.name, err)
+		}
+		if !strings.Contains(err.Error(), test.want) {
+			t.Errorf("ParseReference(%q, NoDefaults); got %v, want mention of %q", test.name, err, test.want)
+		}
+	}
+
+	name := "gcr.io/library/ubuntu:latest"
+	ref, err := ParseReference(name, NoDefaults)
+	if err != nil {
+		t.Fatalf("ParseReference(%q, NoDefaults); %v", name, err)
+	}
+	if ref.Name() != name {
+		t.Errorf("ParseReference(%q, NoDefaults); got %v, want %v", name, ref.Name(), name)
+	}
+
+	// Options compose, so the last one wins.
+	if _, err := ParseReference("ubuntu", NoDefaults, WeakValidation); err != nil {
+		t.Errorf("ParseReference(ubuntu, NoDefaults, WeakValidation); %v", err)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	const digest = "sha256:deadb33fdeadb33fdeadb33fdeadb33fdeadb33fdeadb33fdeadb33fdeadb33f"
+	for _, tc := range []struct {
+		a, b     string
+		sameRepo bool
+		equal    bool
+	}{
+		{"ubuntu", "docker.io/library/ubuntu", true, true},
+		{"ubuntu:latest", "index.docker.io/library/ubuntu", true, true},
+		{"ubuntu:18.04", "ubuntu:20.04", true, false},
+		{"GCR.io/foo/bar", "gcr.io/foo/bar", true, true},
+		{"localhost:5000/foo", "localhost/foo", false, false},
+		{"gcr.io/foo/bar", "gcr.io/foo/baz", false, false},
+		{"gcr.io/foo/bar@" + digest, "gcr.io/foo/bar:tag@" + digest, true, true},
+		{"gcr.io/foo/bar:sha256-deadb33f", "gcr.io/foo/bar@" + digest, true, false},
+	} {
+		a, b := MustParseReference(tc.a), MustParseReference(tc.b)
+		if got := SameRepository(a, b); got != tc.sameRepo {
+			t.Errorf("SameRepository(%q, %q) = %t, want %t", tc.a, tc.b, got, tc.sameRepo)
+		}
+		if got := Equal(a, b); got != tc.equal {
+			t.Errorf("Equal(%q, %q) = %t, want %t", tc.a, tc.b, got, tc.equal)
+		}
+	}
+}
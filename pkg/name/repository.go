@@ -119,3 +119,22 @@ func (r Repository) Digest(identifier string) Digest {
 	d.original = d.Name()
 	return d
 }
+
+// ParseTag is like Tag, but validates identifier, e.g. for tags returned by
+// remote.List. The returned Tag inherits the registry (including whether it is
+// insecure) of this Repository.
+func (r Repository) ParseTag(identifier string) (Tag, error) {
+	if err := checkTag(identifier); err != nil {
+		return Tag{}, err
+	}
+	return r.Tag(identifier), nil
+}
+
+// ParseDigest is like Digest, but validates identifier. The returned Digest
+// inherits the registry (including whether it is insecure) of this Repository.
+func (r Repository) ParseDigest(identifier string) (Digest, error) {
+	if err := checkDigest(identifier); err != nil {
+		return Digest{}, err
+	}
+	return r.Digest(identifier), nil
+}
@@ -141,3 +141,37 @@ func TestRepositoryChildren(t *testing.T) {
 		t.Errorf("digest.String(): got %s want %s", got, want)
 	}
 }
+
+func TestRepositoryParseChildren(t *testing.T) {
+	repo, err := NewRepository("localhost:5000/repo", Insecure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, err := repo.ParseTag("v1.2.3")
+	if err != nil {
+		t.Fatalf("ParseTag() = %v", err)
+	}
+	if got, want := tag.String(), "localhost:5000/repo:v1.2.3"; got != want {
+		t.Errorf("tag.String(): got %s want %s", got, want)
+	}
+	if got, want := tag.Scheme(), "http"; got != want {
+		t.Errorf("tag.Scheme(): got %s want %s", got, want)
+	}
+	for _, bad := range []string{"", "not/valid", "has:colon", strings.Repeat("a", 129)} {
+		if _, err := repo.ParseTag(bad); err == nil {
+			t.Errorf("ParseTag(%q): expected error", bad)
+		}
+	}
+
+	d := "sha256:deadb33fdeadb33fdeadb33fdeadb33fdeadb33fdeadb33fdeadb33fdeadb33f"
+	digest, err := repo.ParseDigest(d)
+	if err != nil {
+		t.Fatalf("ParseDigest() = %v", err)
+	}
+	if got, want := digest.String(), "localhost:5000/repo@"+d; got != want {
+		t.Errorf("digest.String(): got %s want %s", got, want)
+	}
+	if _, err := repo.ParseDigest("badf00d"); err == nil {
+		t.Error("ParseDigest(badf00d): expected error")
+	}
+}
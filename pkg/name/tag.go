@@ -84,9 +84,10 @@ func NewTag(name string, opts ...Option) (Tag, error) {
 
 	// We don't require a tag, but if we get one check it's valid,
 	// even when not being strict.
-	// If we are being strict, we want to validate the tag regardless in case
-	// it's empty.
-	if tag != "" || opt.strict {
+	if opt.strict && tag == "" {
+		return Tag{}, NewErrBadName("strict validation requires the tag to be explicitly defined")
+	}
+	if tag != "" {
 		if err := checkTag(tag); err != nil {
 			return Tag{}, err
 		}
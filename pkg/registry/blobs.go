@@ -25,6 +25,7 @@ import (
 	"path"
 	"strings"
 	"sync"
+	"time"
 )
 
 // Returns whether this url should be handled by the blob handler
@@ -90,6 +91,27 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 		return nil
 	}
 
+	if req.Method == "GET" && service == "uploads" {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+		l, ok := b.uploads[target]
+		if !ok {
+			return &regError{
+				Status:  http.StatusNotFound,
+				Code:    "BLOB_UPLOAD_UNKNOWN",
+				Message: "Unknown upload",
+			}
+		}
+
+		resp.Header().Set("Location", "/"+path.Join("v2", path.Join(elem[1:len(elem)-3]...), "blobs/uploads", target))
+		// Range is inclusive, so "0-0" would claim a byte we don't have.
+		if len(l) > 0 {
+			resp.Header().Set("Range", fmt.Sprintf("0-%d", len(l)-1))
+		}
+		resp.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+
 	if req.Method == "GET" {
 		b.lock.Lock()
 		defer b.lock.Unlock()
@@ -102,10 +124,10 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 			}
 		}
 
-		resp.Header().Set("Content-Length", fmt.Sprint(len(b)))
+		// ServeContent handles Range requests for us.
+		resp.Header().Set("Content-Type", "application/octet-stream")
 		resp.Header().Set("Docker-Content-Digest", target)
-		resp.WriteHeader(http.StatusOK)
-		io.Copy(resp, bytes.NewReader(b))
+		http.ServeContent(resp, req, "", time.Time{}, bytes.NewReader(b))
 		return nil
 	}
 
@@ -0,0 +1,106 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Fault describes a failure for the registry to inject, see WithFaults.
+type Fault struct {
+	// Match selects the requests to fail. If nil, every request matches.
+	Match func(*http.Request) bool
+
+	// Status, if set, is written with an empty body instead of handling the
+	// request, e.g. http.StatusTooManyRequests or
+	// http.StatusInternalServerError.
+	Status int
+
+	// Truncate, if Status is not set, handles the request normally but cuts
+	// the response body off after this many bytes, leaving the client with
+	// less than the advertised Content-Length.
+	Truncate int
+
+	// Times is how many matching requests fail, after which they are handled
+	// normally. If zero, every matching request fails.
+	Times int
+}
+
+// WithFaults makes the registry fail requests as described by faults, which
+// is useful to test a client's retry logic. For each request, the first
+// matching fault that hasn't been used up applies.
+func WithFaults(faults ...Fault) Option {
+	return func(r *registry) {
+		for _, f := range faults {
+			r.faults = append(r.faults, &fault{Fault: f})
+		}
+	}
+}
+
+type fault struct {
+	Fault
+
+	lock sync.Mutex
+	seen int
+}
+
+// take reports whether f applies to req, counting it against f.Times.
+func (f *fault) take(req *http.Request) bool {
+	if f.Match != nil && !f.Match(req) {
+		return false
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.Times != 0 && f.seen >= f.Times {
+		return false
+	}
+	f.seen++
+	return true
+}
+
+// inject applies the first matching fault to req. It returns the writer to
+// handle req with, or nil if the request has already been failed.
+func (r *registry) inject(resp http.ResponseWriter, req *http.Request) http.ResponseWriter {
+	for _, f := range r.faults {
+		if !f.take(req) {
+			continue
+		}
+		if f.Status != 0 {
+			r.log.Printf("%s %s %d (injected)", req.Method, req.URL, f.Status)
+			resp.WriteHeader(f.Status)
+			return nil
+		}
+		return &truncatingWriter{ResponseWriter: resp, left: f.Truncate}
+	}
+	return resp
+}
+
+// truncatingWriter discards everything written after the first left bytes.
+type truncatingWriter struct {
+	http.ResponseWriter
+	left int
+}
+
+func (w *truncatingWriter) Write(b []byte) (int, error) {
+	if len(b) > w.left {
+		w.ResponseWriter.Write(b[:w.left])
+		w.left = 0
+		// Pretend the write succeeded so that the handler carries on.
+		return len(b), nil
+	}
+	w.left -= len(b)
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,128 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// WithReferrersSupport enables the referrers API, listing the manifests in a
+// repository whose subject is a given digest. Without it, the registry
+// responds with 404 like registries that predate the API, so clients fall
+// back to the tag schema.
+func WithReferrersSupport() Option {
+	return func(r *registry) {
+		r.referrers = true
+	}
+}
+
+func isReferrers(req *http.Request) bool {
+	elems := strings.Split(req.URL.Path, "/")
+	elems = elems[1:]
+	if len(elems) < 4 {
+		return false
+	}
+	return elems[len(elems)-2] == "referrers"
+}
+
+// https://github.com/opencontainers/distribution-spec/blob/main/spec.md#listing-referrers
+func (m *manifests) handleReferrers(resp http.ResponseWriter, req *http.Request) *regError {
+	elem := strings.Split(req.URL.Path, "/")
+	elem = elem[1:]
+	target := elem[len(elem)-1]
+	repo := strings.Join(elem[1:len(elem)-2], "/")
+
+	if req.Method != "GET" {
+		return &regError{
+			Status:  http.StatusBadRequest,
+			Code:    "METHOD_UNKNOWN",
+			Message: "We don't understand your method + url",
+		}
+	}
+	h, err := v1.NewHash(target)
+	if err != nil {
+		return &regError{
+			Status:  http.StatusBadRequest,
+			Code:    "DIGEST_INVALID",
+			Message: err.Error(),
+		}
+	}
+	artifactType := req.URL.Query().Get("artifactType")
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	im := v1.IndexManifest{
+		SchemaVersion: 2,
+		MediaType:     types.OCIImageIndex,
+		Manifests:     []v1.Descriptor{},
+	}
+	for key, mf := range m.manifests[repo] {
+		// Manifests are stored under both their tags and digests, only look
+		// at the latter so that each is listed once.
+		d, err := v1.NewHash(key)
+		if err != nil {
+			continue
+		}
+		var refer struct {
+			ArtifactType string            `json:"artifactType"`
+			Config       v1.Descriptor     `json:"config"`
+			Subject      *v1.Descriptor    `json:"subject"`
+			Annotations  map[string]string `json:"annotations"`
+		}
+		if err := json.Unmarshal(mf.blob, &refer); err != nil {
+			continue
+		}
+		if refer.Subject == nil || refer.Subject.Digest != h {
+			continue
+		}
+		at := refer.ArtifactType
+		if at == "" {
+			at = string(refer.Config.MediaType)
+		}
+		if artifactType != "" && at != artifactType {
+			continue
+		}
+		im.Manifests = append(im.Manifests, v1.Descriptor{
+			MediaType:    types.MediaType(mf.contentType),
+			Size:         int64(len(mf.blob)),
+			Digest:       d,
+			ArtifactType: at,
+			Annotations:  refer.Annotations,
+		})
+	}
+	sort.Slice(im.Manifests, func(i, j int) bool {
+		return im.Manifests[i].Digest.String() < im.Manifests[j].Digest.String()
+	})
+
+	msg, _ := json.Marshal(im)
+	if artifactType != "" {
+		resp.Header().Set("OCI-Filters-Applied", "artifactType")
+	}
+	resp.Header().Set("Content-Type", string(types.OCIImageIndex))
+	resp.Header().Set("Content-Length", fmt.Sprint(len(msg)))
+	resp.WriteHeader(http.StatusOK)
+	io.Copy(resp, bytes.NewReader(msg))
+	return nil
+}
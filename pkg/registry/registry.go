@@ -15,7 +15,11 @@
 // Package registry implements a docker V2 registry and the OCI distribution specification.
 //
 // It is designed to be used anywhere a low dependency container registry is needed, with an
-// initial focus on tests.
+// initial focus on tests. New returns an in-memory http.Handler that supports blob uploads
+// (monolithic and chunked), pushing, pulling and deleting manifests by tag or
+// digest, and listing tags. The referrers API can be enabled with WithReferrersSupport, and
+// failures such as 429s, 500s and truncated responses injected with WithFaults, which makes
+// it suitable for testing push/pull round trips and retry logic without a real registry.
 //
 // Its goal is to be standards compliant and its strictness will increase over time.
 //
@@ -33,6 +37,8 @@ type registry struct {
 	log       *log.Logger
 	blobs     blobs
 	manifests manifests
+	faults    []*fault
+	referrers bool
 }
 
 // https://docs.docker.com/registry/spec/api/#api-version-check
@@ -44,6 +50,9 @@ func (r *registry) v2(resp http.ResponseWriter, req *http.Request) *regError {
 	if isManifest(req) {
 		return r.manifests.handle(resp, req)
 	}
+	if r.referrers && isReferrers(req) {
+		return r.manifests.handleReferrers(resp, req)
+	}
 	if isTags(req) {
 		return r.manifests.handleTags(resp, req)
 	}
@@ -60,6 +69,9 @@ func (r *registry) v2(resp http.ResponseWriter, req *http.Request) *regError {
 }
 
 func (r *registry) root(resp http.ResponseWriter, req *http.Request) {
+	if resp = r.inject(resp, req); resp == nil {
+		return
+	}
 	if rerr := r.v2(resp, req); rerr != nil {
 		r.log.Printf("%s %s %d %s %s", req.Method, req.URL, rerr.Status, rerr.Code, rerr.Message)
 		rerr.Write(resp)
@@ -496,3 +496,126 @@ func TestCalls(t *testing.T) {
 		t.Run(tc.Description+" - custom log", testf)
 	}
 }
+
+func TestFaults(t *testing.T) {
+	blob := "some blob contents"
+	digest := "sha256:" + sha256String(blob)
+	isBlob := func(r *http.Request) bool {
+		return r.Method == "GET" && strings.Contains(r.URL.Path, "/blobs/")
+	}
+	s := httptest.NewServer(registry.New(
+		registry.Logger(log.New(ioutil.Discard, "", log.Ldate)),
+		registry.WithFaults(
+			registry.Fault{Status: http.StatusTooManyRequests, Times: 1},
+			registry.Fault{Match: isBlob, Truncate: 4, Times: 1},
+		),
+	))
+	defer s.Close()
+
+	// The first request of all fails, the next ones are handled.
+	for _, want := range []int{http.StatusTooManyRequests, http.StatusOK} {
+		resp, err := s.Client().Get(s.URL + "/v2/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("GET /v2/ = %d, want %d", resp.StatusCode, want)
+		}
+	}
+
+	req, err := http.NewRequest("PUT", fmt.Sprintf("%s/v2/foo/blobs/uploads/1?digest=%s", s.URL, digest), strings.NewReader(blob))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("PUT blob = %d", resp.StatusCode)
+	}
+
+	// The first fetch of the blob is truncated, the second isn't.
+	for i, wantErr := range []bool{true, false} {
+		resp, err := s.Client().Get(fmt.Sprintf("%s/v2/foo/blobs/%s", s.URL, digest))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if gotErr := err != nil; gotErr != wantErr {
+			t.Errorf("GET blob #%d: ReadAll() = %q, %v", i, b, err)
+		}
+		if !wantErr && string(b) != blob {
+			t.Errorf("GET blob #%d = %q, want %q", i, b, blob)
+		}
+	}
+}
+
+func TestReferrers(t *testing.T) {
+	subject := `{"schemaVersion":2}`
+	subjectDigest := "sha256:" + sha256String(subject)
+	referrer := fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","artifactType":"application/vnd.example.sig","config":{"mediaType":"application/vnd.oci.empty.v1+json","size":2,"digest":"sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a"},"subject":{"mediaType":"application/vnd.oci.image.manifest.v1+json","size":%d,"digest":%q}}`, len(subject), subjectDigest)
+	referrerDigest := "sha256:" + sha256String(referrer)
+
+	for _, enabled := range []bool{false, true} {
+		t.Run(fmt.Sprintf("enabled=%t", enabled), func(t *testing.T) {
+			opts := []registry.Option{registry.Logger(log.New(ioutil.Discard, "", log.Ldate))}
+			if enabled {
+				opts = append(opts, registry.WithReferrersSupport())
+			}
+			s := httptest.NewServer(registry.New(opts...))
+			defer s.Close()
+
+			for tag, m := range map[string]string{"subject": subject, "referrer": referrer} {
+				req, err := http.NewRequest("PUT", fmt.Sprintf("%s/v2/foo/manifests/%s", s.URL, tag), strings.NewReader(m))
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.Header.Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+				resp, err := s.Client().Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusCreated {
+					t.Fatalf("PUT %s = %d", tag, resp.StatusCode)
+				}
+			}
+
+			for query, want := range map[string]int{
+				"": 1,
+				"?artifactType=application/vnd.example.sig":  1,
+				"?artifactType=application/vnd.example.sbom": 0,
+			} {
+				resp, err := s.Client().Get(fmt.Sprintf("%s/v2/foo/referrers/%s%s", s.URL, subjectDigest, query))
+				if err != nil {
+					t.Fatal(err)
+				}
+				b, err := ioutil.ReadAll(resp.Body)
+				resp.Body.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !enabled {
+					if resp.StatusCode != http.StatusNotFound {
+						t.Errorf("GET referrers = %d, want 404", resp.StatusCode)
+					}
+					continue
+				}
+				if resp.StatusCode != http.StatusOK {
+					t.Fatalf("GET referrers%s = %d: %s", query, resp.StatusCode, b)
+				}
+				n := strings.Count(string(b), referrerDigest)
+				if n != want {
+					t.Errorf("GET referrers%s listed the referrer %d times, want %d: %s", query, n, want, b)
+				}
+				if got := resp.Header.Get("OCI-Filters-Applied"); (got != "") != (query != "") {
+					t.Errorf("GET referrers%s: OCI-Filters-Applied = %q", query, got)
+				}
+			}
+		})
+	}
+}
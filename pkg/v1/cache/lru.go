@@ -0,0 +1,247 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/logs"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// tmpPrefix is used for blobs that are still being written, which are neither
+// returned by Get nor counted towards the size limit.
+const tmpPrefix = ".tmp-"
+
+type lrucache struct {
+	fscache
+	maxBytes int64
+
+	mu sync.Mutex
+	// inflight holds the hashes of blobs currently being written.
+	inflight map[v1.Hash]struct{}
+}
+
+// NewFilesystemCacheWithLimit returns a Cache implementation backed by files,
+// like NewFilesystemCache, which keeps the total size of the cached blobs
+// under maxBytes by evicting the least recently used blobs whenever a new one
+// is written. Get counts as a use.
+//
+// Blobs are written to a temporary file and only moved into place once they
+// have been read completely, so a partially consumed layer is never cached.
+// If several readers populate the same blob at once, only one of them writes
+// it to disk.
+func NewFilesystemCacheWithLimit(path string, maxBytes int64) Cache {
+	return &lrucache{
+		fscache:  fscache{path},
+		maxBytes: maxBytes,
+		inflight: map[v1.Hash]struct{}{},
+	}
+}
+
+func (c *lrucache) Put(l v1.Layer) (v1.Layer, error) {
+	digest, err := l.Digest()
+	if err != nil {
+		return nil, err
+	}
+	diffID, err := l.DiffID()
+	if err != nil {
+		return nil, err
+	}
+	return &lruLayer{
+		Layer:  l,
+		c:      c,
+		digest: digest,
+		diffID: diffID,
+	}, nil
+}
+
+func (c *lrucache) Get(h v1.Hash) (v1.Layer, error) {
+	l, err := c.fscache.Get(h)
+	if err != nil {
+		return nil, err
+	}
+	// Mark this blob as recently used.
+	now := time.Now()
+	if err := os.Chtimes(cachepath(c.path, h), now, now); err != nil {
+		logs.Warn.Printf("Failed to update access time for %s: %v", h, err)
+	}
+	return l, nil
+}
+
+// tee returns a ReadCloser that writes the contents of rc to the cache as h,
+// unless h is already cached or being written by someone else.
+func (c *lrucache) tee(h v1.Hash, rc io.ReadCloser) (io.ReadCloser, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.inflight[h]; ok {
+		return rc, nil
+	}
+	if _, err := os.Stat(cachepath(c.path, h)); err == nil {
+		return rc, nil
+	}
+
+	if err := os.MkdirAll(c.path, 0700); err != nil {
+		rc.Close()
+		return nil, err
+	}
+	f, err := ioutil.TempFile(c.path, tmpPrefix)
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+	c.inflight[h] = struct{}{}
+
+	return &lruWriter{
+		rc: rc,
+		f:  f,
+		h:  h,
+		c:  c,
+	}, nil
+}
+
+// commit moves a completely written blob into place and evicts old blobs.
+func (c *lrucache) commit(h v1.Hash, tmp string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.inflight, h)
+
+	if err := os.Rename(tmp, cachepath(c.path, h)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return c.evict()
+}
+
+// abort discards a partially written blob.
+func (c *lrucache) abort(h v1.Hash, tmp string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.inflight, h)
+	os.Remove(tmp)
+}
+
+// evict removes the least recently used blobs until the total size of the
+// cache is at most maxBytes. It must be called with c.mu held.
+func (c *lrucache) evict() error {
+	fis, err := ioutil.ReadDir(c.path)
+	if err != nil {
+		return err
+	}
+
+	var (
+		total int64
+		blobs []os.FileInfo
+	)
+	for _, fi := range fis {
+		if fi.IsDir() || strings.HasPrefix(fi.Name(), tmpPrefix) {
+			continue
+		}
+		total += fi.Size()
+		blobs = append(blobs, fi)
+	}
+
+	sort.Slice(blobs, func(i, j int) bool {
+		return blobs[i].ModTime().Before(blobs[j].ModTime())
+	})
+	for _, fi := range blobs {
+		if total <= c.maxBytes {
+			break
+		}
+		if err := os.Remove(filepath.Join(c.path, fi.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		logs.Debug.Printf("Evicted %s from cache", fi.Name())
+		total -= fi.Size()
+	}
+	return nil
+}
+
+type lruLayer struct {
+	v1.Layer
+	c              *lrucache
+	digest, diffID v1.Hash
+}
+
+func (l *lruLayer) Compressed() (io.ReadCloser, error) {
+	rc, err := l.Layer.Compressed()
+	if err != nil {
+		return nil, err
+	}
+	return l.c.tee(l.digest, rc)
+}
+
+func (l *lruLayer) Uncompressed() (io.ReadCloser, error) {
+	rc, err := l.Layer.Uncompressed()
+	if err != nil {
+		return nil, err
+	}
+	return l.c.tee(l.diffID, rc)
+}
+
+// lruWriter copies everything read from rc into f, and commits f to the cache
+// on Close if rc was read to the end without errors.
+type lruWriter struct {
+	rc     io.ReadCloser
+	f      *os.File
+	h      v1.Hash
+	c      *lrucache
+	err    error
+	done   bool
+	closed bool
+}
+
+func (w *lruWriter) Read(b []byte) (int, error) {
+	n, err := w.rc.Read(b)
+	if n > 0 && w.err == nil {
+		if _, werr := w.f.Write(b[:n]); werr != nil {
+			w.err = werr
+		}
+	}
+	if err == io.EOF {
+		w.done = true
+	} else if err != nil && w.err == nil {
+		w.err = err
+	}
+	return n, err
+}
+
+func (w *lruWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	err := w.rc.Close()
+	if ferr := w.f.Close(); ferr != nil && w.err == nil {
+		w.err = ferr
+	}
+	if !w.done || w.err != nil {
+		w.c.abort(w.h, w.f.Name())
+		return err
+	}
+	if cerr := w.c.commit(w.h, w.f.Name()); cerr != nil {
+		logs.Warn.Printf("Failed to cache %s: %v", w.h, cerr)
+	}
+	return err
+}
@@ -0,0 +1,207 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// consume reads the compressed contents of l through c.
+func consume(c Cache, l v1.Layer) error {
+	cl, err := c.Put(l)
+	if err != nil {
+		return err
+	}
+	rc, err := cl.Compressed()
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(ioutil.Discard, rc); err != nil {
+		rc.Close()
+		return err
+	}
+	return rc.Close()
+}
+
+func TestFilesystemCacheWithLimit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ggcr-cache")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		ls   []v1.Layer
+		hs   []v1.Hash
+		size int64
+	)
+	for i := 0; i < 4; i++ {
+		l, err := random.Layer(1024, types.DockerLayer)
+		if err != nil {
+			t.Fatalf("random.Layer: %v", err)
+		}
+		h, err := l.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := l.Size()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n > size {
+			size = n
+		}
+		ls = append(ls, l)
+		hs = append(hs, h)
+	}
+
+	// Room for three layers.
+	c := NewFilesystemCacheWithLimit(dir, 3*size)
+
+	for _, l := range ls[:3] {
+		if err := consume(c, l); err != nil {
+			t.Fatalf("consume: %v", err)
+		}
+		// Make sure modification times are distinct.
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Use the oldest layer, so the second one is evicted next.
+	if _, err := c.Get(hs[0]); err != nil {
+		t.Fatalf("Get(%s): %v", hs[0], err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	if err := consume(c, ls[3]); err != nil {
+		t.Fatalf("consume: %v", err)
+	}
+
+	for i, want := range []bool{true, false, true, true} {
+		_, err := c.Get(hs[i])
+		if got := err == nil; got != want {
+			t.Errorf("Get(layer %d) = %v, want cached: %v", i, err, want)
+		}
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if got, want := len(files), 3; got != want {
+		t.Errorf("Got %d cached files, want %d", got, want)
+	}
+}
+
+func TestFilesystemCacheWithLimitPartialRead(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ggcr-cache")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	l, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatalf("random.Layer: %v", err)
+	}
+	h, err := l.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewFilesystemCacheWithLimit(dir, 1<<20)
+	cl, err := c.Put(l)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	rc, err := cl.Compressed()
+	if err != nil {
+		t.Fatalf("Compressed: %v", err)
+	}
+	if _, err := rc.Read(make([]byte, 10)); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	rc.Close()
+
+	if _, err := c.Get(h); err != ErrNotFound {
+		t.Errorf("Get(%s) = %v, want %v", h, err, ErrNotFound)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(files) != 0 {
+		t.Errorf("Got %d files after partial read, want 0", len(files))
+	}
+}
+
+func TestFilesystemCacheWithLimitConcurrentPut(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ggcr-cache")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	l, err := random.Layer(1<<16, types.DockerLayer)
+	if err != nil {
+		t.Fatalf("random.Layer: %v", err)
+	}
+	h, err := l.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewFilesystemCacheWithLimit(dir, 1<<20)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := consume(c, l); err != nil {
+				t.Errorf("consume: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("Got %d cached files, want 1", len(files))
+	}
+
+	cl, err := c.Get(h)
+	if err != nil {
+		t.Fatalf("Get(%s): %v", h, err)
+	}
+	got, err := cl.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != h {
+		t.Errorf("cached layer digest = %v, want %v", got, h)
+	}
+}
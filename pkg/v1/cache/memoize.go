@@ -0,0 +1,100 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// MemoizedLayer is a v1.Layer whose uncompressed contents are written to a
+// temporary file the first time they are read, see MemoizeUncompressed.
+type MemoizedLayer struct {
+	v1.Layer
+
+	dir  string
+	mu   sync.Mutex
+	path string
+}
+
+// MemoizeUncompressed wraps l so that it is only decompressed once, the first
+// time Uncompressed is called, into a temporary file in dir (or the default
+// directory for temporary files if dir is ""). Every call to Uncompressed is
+// served from that file, and all other methods are passed through to l.
+//
+// Unlike a Cache, nothing outlives the returned layer: callers must Close it
+// when they're done to remove the temporary file.
+func MemoizeUncompressed(l v1.Layer, dir string) *MemoizedLayer {
+	return &MemoizedLayer{
+		Layer: l,
+		dir:   dir,
+	}
+}
+
+// Uncompressed implements v1.Layer.
+func (l *MemoizedLayer) Uncompressed() (io.ReadCloser, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.path == "" {
+		path, err := l.decompress()
+		if err != nil {
+			return nil, err
+		}
+		l.path = path
+	}
+	return os.Open(l.path)
+}
+
+// decompress writes the uncompressed contents of the layer to a new temporary
+// file, returning its path.
+func (l *MemoizedLayer) decompress() (string, error) {
+	rc, err := l.Layer.Uncompressed()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	f, err := ioutil.TempFile(l.dir, "uncompressed-")
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(f, rc); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// Close removes the temporary file, if any. The layer can still be used
+// afterwards, but will be decompressed again.
+func (l *MemoizedLayer) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.path == "" {
+		return nil
+	}
+	path := l.path
+	l.path = ""
+	return os.Remove(path)
+}
@@ -0,0 +1,87 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+// countingLayer counts the calls to Uncompressed.
+type countingLayer struct {
+	v1.Layer
+	uncompressed int
+}
+
+func (l *countingLayer) Uncompressed() (io.ReadCloser, error) {
+	l.uncompressed++
+	return l.Layer.Uncompressed()
+}
+
+func TestMemoizeUncompressed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rl, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cl := &countingLayer{Layer: rl}
+	l := MemoizeUncompressed(cl, dir)
+
+	for i := 0; i < 3; i++ {
+		rc, err := l.Uncompressed()
+		if err != nil {
+			t.Fatalf("Uncompressed() = %v", err)
+		}
+		if _, err := io.Copy(ioutil.Discard, rc); err != nil {
+			t.Fatal(err)
+		}
+		rc.Close()
+	}
+	if cl.uncompressed != 1 {
+		t.Errorf("decompressed %d times, want 1", cl.uncompressed)
+	}
+	if err := validate.Layer(l); err != nil {
+		t.Errorf("validate.Layer() = %v", err)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("%d files in %s, want 1", len(files), dir)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	if files, err = ioutil.ReadDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("%d files in %s after Close(), want 0", len(files), dir)
+	}
+}
@@ -35,13 +35,11 @@ var _ v1.Image = (*image)(nil)
 
 type imageOpener struct {
 	ref      name.Reference
+	ctx      context.Context
 	buffered bool
 	client   Client
 }
 
-// ImageOption is a functional option for Image.
-type ImageOption func(*imageOpener) error
-
 func (i *imageOpener) Open() (v1.Image, error) {
 	var opener tarball.Opener
 	var err error
@@ -65,7 +63,7 @@ func (i *imageOpener) Open() (v1.Image, error) {
 }
 
 func (i *imageOpener) saveImage(ref name.Reference) (io.ReadCloser, error) {
-	return i.client.ImageSave(context.Background(), []string{ref.Name()})
+	return i.client.ImageSave(i.ctx, []string{ref.Name()})
 }
 
 func (i *imageOpener) bufferedOpener(ref name.Reference) (tarball.Opener, error) {
@@ -97,25 +95,25 @@ func (i *imageOpener) unbufferedOpener(ref name.Reference) (tarball.Opener, erro
 // Image provides access to an image reference from the Docker daemon,
 // applying functional options to the underlying imageOpener before
 // resolving the reference into a v1.Image.
-func Image(ref name.Reference, options ...ImageOption) (v1.Image, error) {
-	i := &imageOpener{
-		ref:      ref,
-		buffered: true, // buffer by default
-	}
-	for _, option := range options {
-		if err := option(i); err != nil {
-			return nil, err
-		}
+func Image(ref name.Reference, options ...Option) (v1.Image, error) {
+	o, err := makeOptions(options...)
+	if err != nil {
+		return nil, err
 	}
 
-	if i.client == nil {
-		var err error
-		i.client, err = client.NewClientWithOpts(client.FromEnv)
+	if o.client == nil {
+		o.client, err = client.NewClientWithOpts(client.FromEnv)
 		if err != nil {
 			return nil, err
 		}
 	}
-	i.client.NegotiateAPIVersion(context.Background())
+	o.client.NegotiateAPIVersion(o.ctx)
 
+	i := &imageOpener{
+		ref:      ref,
+		ctx:      o.ctx,
+		buffered: o.buffered,
+		client:   o.client,
+	}
 	return i.Open()
 }
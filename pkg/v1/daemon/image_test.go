@@ -74,3 +74,51 @@ func TestImage(t *testing.T) {
 		}
 	}
 }
+
+type ctxKey struct{}
+
+func TestImageContext(t *testing.T) {
+	tag, err := name.NewTag("unused", name.WeakValidation)
+	if err != nil {
+		t.Fatalf("error creating test name: %s", err)
+	}
+
+	var got []context.Context
+	saver := &contextSaver{
+		MockImageSaver: MockImageSaver{path: imagePath},
+		saved:          &got,
+	}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+	if _, err := Image(tag, WithClient(saver), WithContext(ctx)); err != nil {
+		t.Fatalf("Image: %v", err)
+	}
+	if len(got) == 0 {
+		t.Fatal("ImageSave was never called")
+	}
+	for _, c := range got {
+		if c.Value(ctxKey{}) != "value" {
+			t.Errorf("ImageSave called with wrong context")
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Image(tag, WithClient(saver), WithContext(ctx)); err != context.Canceled {
+		t.Errorf("Image: got %v, want %v", err, context.Canceled)
+	}
+}
+
+// contextSaver records the contexts it's called with, and fails if they are
+// done, like a real client would.
+type contextSaver struct {
+	MockImageSaver
+	saved *[]context.Context
+}
+
+func (c *contextSaver) ImageSave(ctx context.Context, refs []string) (io.ReadCloser, error) {
+	*c.saved = append(*c.saved, ctx)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.MockImageSaver.ImageSave(ctx, refs)
+}
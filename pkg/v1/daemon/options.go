@@ -21,31 +21,70 @@ import (
 	"github.com/docker/docker/api/types"
 )
 
+// ImageOption is an alias for Option.
+type ImageOption = Option
+
+// Option is a functional option for daemon operations.
+type Option func(*options) error
+
+type options struct {
+	ctx      context.Context
+	client   Client
+	buffered bool
+}
+
+func makeOptions(opts ...Option) (*options, error) {
+	o := &options{
+		buffered: true,
+		ctx:      context.Background(),
+	}
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
 // WithBufferedOpener buffers the image.
-func WithBufferedOpener() ImageOption {
-	return func(i *imageOpener) error {
-		return i.setBuffered(true)
+func WithBufferedOpener() Option {
+	return func(o *options) error {
+		return o.setBuffered(true)
 	}
 }
 
 // WithUnbufferedOpener streams the image to avoid buffering.
-func WithUnbufferedOpener() ImageOption {
-	return func(i *imageOpener) error {
-		return i.setBuffered(false)
+func WithUnbufferedOpener() Option {
+	return func(o *options) error {
+		return o.setBuffered(false)
 	}
 }
 
-func (i *imageOpener) setBuffered(buffer bool) error {
-	i.buffered = buffer
+func (o *options) setBuffered(buffer bool) error {
+	o.buffered = buffer
 	return nil
 }
 
 // WithClient is a functional option to allow injecting a docker client.
 //
 // By default, github.com/docker/docker/client.FromEnv is used.
-func WithClient(client Client) ImageOption {
-	return func(i *imageOpener) error {
-		i.client = client
+func WithClient(client Client) Option {
+	return func(o *options) error {
+		o.client = client
+		return nil
+	}
+}
+
+// WithContext is a functional option to pass through a context.Context.
+//
+// The context is used for every request to the daemon, so cancelling it
+// aborts an in-flight save or load. For an unbuffered Image, this includes
+// the saves made when reading layers later on.
+//
+// By default, context.Background() is used.
+func WithContext(ctx context.Context) Option {
+	return func(o *options) error {
+		o.ctx = ctx
 		return nil
 	}
 }
@@ -43,19 +43,47 @@ var GetImageLoader = func() (ImageLoader, error) {
 	return cli, nil
 }
 
+// imageLoader returns the client from WithClient, falling back on
+// GetImageLoader.
+func imageLoader(o *options) (ImageLoader, error) {
+	if o.client == nil {
+		return GetImageLoader()
+	}
+	o.client.NegotiateAPIVersion(o.ctx)
+	return o.client, nil
+}
+
 // Tag adds a tag to an already existent image.
 func Tag(src, dest name.Tag) error {
-	cli, err := GetImageLoader()
+	return TagWithOptions(src, dest)
+}
+
+// TagWithOptions is Tag with options, e.g. WithClient or WithContext.
+func TagWithOptions(src, dest name.Tag, options ...Option) error {
+	o, err := makeOptions(options...)
+	if err != nil {
+		return err
+	}
+	cli, err := imageLoader(o)
 	if err != nil {
 		return err
 	}
 
-	return cli.ImageTag(context.Background(), src.String(), dest.String())
+	return cli.ImageTag(o.ctx, src.String(), dest.String())
 }
 
 // Write saves the image into the daemon as the given tag.
 func Write(tag name.Tag, img v1.Image) (string, error) {
-	cli, err := GetImageLoader()
+	return WriteWithOptions(tag, img)
+}
+
+// WriteWithOptions is Write with options, e.g. WithClient or WithContext.
+func WriteWithOptions(tag name.Tag, img v1.Image, options ...Option) (string, error) {
+	o, err := makeOptions(options...)
+	if err != nil {
+		return "", err
+	}
+	cli, err := imageLoader(o)
 	if err != nil {
 		return "", err
 	}
@@ -66,15 +94,17 @@ func Write(tag name.Tag, img v1.Image) (string, error) {
 	}()
 
 	// write the image in docker save format first, then load it
-	resp, err := cli.ImageLoad(context.Background(), pr, false)
+	resp, err := cli.ImageLoad(o.ctx, pr, false)
 	if err != nil {
+		// Unblock the writer, which would otherwise wait forever for us to read.
+		pr.CloseWithError(err)
 		return "", fmt.Errorf("error loading image: %v", err)
 	}
 	defer resp.Body.Close()
 	b, readErr := ioutil.ReadAll(resp.Body)
 	response := string(b)
 	if readErr != nil {
-		return response, fmt.Errorf("error reading load response body: %v", err)
+		return response, fmt.Errorf("error reading load response body: %v", readErr)
 	}
 	return response, nil
 }
@@ -20,6 +20,7 @@ import (
 	"io/ioutil"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/docker/docker/api/types"
 
@@ -70,3 +71,34 @@ func TestWriteImage(t *testing.T) {
 		t.Errorf("Error tagging image: %v", err)
 	}
 }
+
+// blockingLoader stops reading the image when its context is cancelled.
+type blockingLoader struct {
+	Client
+}
+
+func (b *blockingLoader) NegotiateAPIVersion(context.Context) {}
+
+func (b *blockingLoader) ImageLoad(ctx context.Context, r io.Reader, _ bool) (types.ImageLoadResponse, error) {
+	<-ctx.Done()
+	return types.ImageLoadResponse{}, ctx.Err()
+}
+
+func TestWriteContext(t *testing.T) {
+	image, err := tarball.ImageFromPath("../tarball/testdata/test_image_1.tar", nil)
+	if err != nil {
+		t.Fatalf("Error loading image: %v", err)
+	}
+	tag, err := name.NewTag("test_image_2:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := WriteWithOptions(tag, image, WithClient(&blockingLoader{}), WithContext(ctx)); err == nil {
+		t.Error("Write: expected error from cancelled context")
+	} else if !strings.Contains(err.Error(), context.DeadlineExceeded.Error()) {
+		t.Errorf("Write: got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
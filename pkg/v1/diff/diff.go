@@ -0,0 +1,263 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package diff provides functionality for describing the differences between
+// two images, e.g. to show what changed between two tags.
+package diff
+
+import (
+	"reflect"
+	"sort"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// Diff describes how image b differs from image a. The zero value means the
+// images have the same layers, media type and the config fields that are
+// compared, see Empty.
+type Diff struct {
+	// MediaType is set if the images' media types differ.
+	MediaType *MediaTypeChange
+
+	// AddedLayers and RemovedLayers are the DiffIDs of the layers that are
+	// only in b and only in a, respectively, in order. A DiffID that appears
+	// more often in one image is listed once for each extra occurrence.
+	AddedLayers   []v1.Hash
+	RemovedLayers []v1.Hash
+
+	// LayersReordered is set if the layers that are in both images appear in
+	// a different order.
+	LayersReordered bool
+
+	// Env and ExposedPorts list the entries that were added to or removed
+	// from the config.
+	Env          ListDiff
+	ExposedPorts ListDiff
+
+	// Entrypoint and Cmd are set if they differ.
+	Entrypoint *ListChange
+	Cmd        *ListChange
+
+	// Labels lists the labels that were added, removed or changed.
+	Labels MapDiff
+
+	// History lists the history entries that differ, by index.
+	History []HistoryChange
+}
+
+// MediaTypeChange records a media type that differs between a and b.
+type MediaTypeChange struct {
+	A, B types.MediaType
+}
+
+// ListChange records a list that differs between a and b.
+type ListChange struct {
+	A, B []string
+}
+
+// ListDiff lists the entries that are only in b (Added) or only in a
+// (Removed).
+type ListDiff struct {
+	Added   []string
+	Removed []string
+}
+
+// Empty returns true if there are no differences.
+func (l ListDiff) Empty() bool {
+	return len(l.Added) == 0 && len(l.Removed) == 0
+}
+
+// MapDiff lists the keys that are only in b (Added), only in a (Removed), or
+// in both, but with different values (Changed).
+type MapDiff struct {
+	Added   map[string]string
+	Removed map[string]string
+	Changed map[string]ValueChange
+}
+
+// Empty returns true if there are no differences.
+func (m MapDiff) Empty() bool {
+	return len(m.Added) == 0 && len(m.Removed) == 0 && len(m.Changed) == 0
+}
+
+// ValueChange records a value that differs between a and b.
+type ValueChange struct {
+	A, B string
+}
+
+// HistoryChange records a history entry that differs between a and b. A or B
+// is nil if the entry only exists in the other image.
+type HistoryChange struct {
+	Index int
+	A, B  *v1.History
+}
+
+// Empty returns true if there are no differences.
+func (d *Diff) Empty() bool {
+	return d.MediaType == nil &&
+		len(d.AddedLayers) == 0 && len(d.RemovedLayers) == 0 && !d.LayersReordered &&
+		d.Env.Empty() && d.ExposedPorts.Empty() &&
+		d.Entrypoint == nil && d.Cmd == nil &&
+		d.Labels.Empty() && len(d.History) == 0
+}
+
+// Images returns the differences between a and b.
+func Images(a, b v1.Image) (*Diff, error) {
+	d := &Diff{}
+
+	amt, err := a.MediaType()
+	if err != nil {
+		return nil, err
+	}
+	bmt, err := b.MediaType()
+	if err != nil {
+		return nil, err
+	}
+	if amt != bmt {
+		d.MediaType = &MediaTypeChange{A: amt, B: bmt}
+	}
+
+	acf, err := a.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	bcf, err := b.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+
+	d.AddedLayers, d.RemovedLayers, d.LayersReordered = diffLayers(acf.RootFS.DiffIDs, bcf.RootFS.DiffIDs)
+
+	d.Env = diffLists(acf.Config.Env, bcf.Config.Env)
+	d.ExposedPorts = diffLists(keys(acf.Config.ExposedPorts), keys(bcf.Config.ExposedPorts))
+	if !reflect.DeepEqual(acf.Config.Entrypoint, bcf.Config.Entrypoint) {
+		d.Entrypoint = &ListChange{A: acf.Config.Entrypoint, B: bcf.Config.Entrypoint}
+	}
+	if !reflect.DeepEqual(acf.Config.Cmd, bcf.Config.Cmd) {
+		d.Cmd = &ListChange{A: acf.Config.Cmd, B: bcf.Config.Cmd}
+	}
+	d.Labels = diffMaps(acf.Config.Labels, bcf.Config.Labels)
+	d.History = diffHistory(acf.History, bcf.History)
+
+	return d, nil
+}
+
+// diffLayers returns the layers that were added and removed, and whether the
+// remaining layers were reordered. The layers are compared as multisets, so a
+// DiffID that appears more often in one image than the other is added or
+// removed that many times, e.g. a layer that is appended twice.
+func diffLayers(a, b []v1.Hash) (added, removed []v1.Hash, reordered bool) {
+	commonA, removed := intersect(a, b)
+	commonB, added := intersect(b, a)
+	return added, removed, !reflect.DeepEqual(commonA, commonB)
+}
+
+// intersect splits a into the layers that are also in b, each matching at most
+// one occurrence in b, and the rest, both in the order they appear in a.
+func intersect(a, b []v1.Hash) (common, rest []v1.Hash) {
+	inB := map[v1.Hash]int{}
+	for _, h := range b {
+		inB[h]++
+	}
+	for _, h := range a {
+		if inB[h] > 0 {
+			inB[h]--
+			common = append(common, h)
+		} else {
+			rest = append(rest, h)
+		}
+	}
+	return common, rest
+}
+
+func diffLists(a, b []string) ListDiff {
+	inA := map[string]bool{}
+	for _, s := range a {
+		inA[s] = true
+	}
+	inB := map[string]bool{}
+	for _, s := range b {
+		inB[s] = true
+	}
+
+	var d ListDiff
+	for _, s := range b {
+		if !inA[s] {
+			d.Added = append(d.Added, s)
+		}
+	}
+	for _, s := range a {
+		if !inB[s] {
+			d.Removed = append(d.Removed, s)
+		}
+	}
+	return d
+}
+
+func diffMaps(a, b map[string]string) MapDiff {
+	var d MapDiff
+	for k, bv := range b {
+		av, ok := a[k]
+		switch {
+		case !ok:
+			if d.Added == nil {
+				d.Added = map[string]string{}
+			}
+			d.Added[k] = bv
+		case av != bv:
+			if d.Changed == nil {
+				d.Changed = map[string]ValueChange{}
+			}
+			d.Changed[k] = ValueChange{A: av, B: bv}
+		}
+	}
+	for k, av := range a {
+		if _, ok := b[k]; !ok {
+			if d.Removed == nil {
+				d.Removed = map[string]string{}
+			}
+			d.Removed[k] = av
+		}
+	}
+	return d
+}
+
+func diffHistory(a, b []v1.History) []HistoryChange {
+	var changes []HistoryChange
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var ah, bh *v1.History
+		if i < len(a) {
+			ah = &a[i]
+		}
+		if i < len(b) {
+			bh = &b[i]
+		}
+		if ah != nil && bh != nil && reflect.DeepEqual(*ah, *bh) {
+			continue
+		}
+		changes = append(changes, HistoryChange{Index: i, A: ah, B: bh})
+	}
+	return changes
+}
+
+// keys returns the sorted keys of m.
+func keys(m map[string]struct{}) []string {
+	ks := make([]string, 0, len(m))
+	for k := range m {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return ks
+}
@@ -0,0 +1,187 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/diff"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func mustDiffID(t *testing.T, l v1.Layer) v1.Hash {
+	t.Helper()
+	h, err := l.DiffID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestIdentical(t *testing.T) {
+	img, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := diff.Images(img, img)
+	if err != nil {
+		t.Fatalf("Images() = %v", err)
+	}
+	if !d.Empty() {
+		t.Errorf("Images() = %+v, want empty", d)
+	}
+}
+
+func TestMediaTypeOnly(t *testing.T) {
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oci := mutate.MediaType(img, types.OCIManifestSchema1)
+	d, err := diff.Images(img, oci)
+	if err != nil {
+		t.Fatalf("Images() = %v", err)
+	}
+	want := &diff.Diff{MediaType: &diff.MediaTypeChange{A: types.DockerManifestSchema2, B: types.OCIManifestSchema1}}
+	if df := cmp.Diff(want, d); df != "" {
+		t.Errorf("Images() (-want +got) = %s", df)
+	}
+}
+
+func TestImages(t *testing.T) {
+	var layers []v1.Layer
+	for i := 0; i < 4; i++ {
+		l, err := random.Layer(1024, types.DockerLayer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		layers = append(layers, l)
+	}
+	a, err := mutate.AppendLayers(empty.Image, layers[0], layers[1], layers[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := mutate.Append(empty.Image,
+		mutate.Addendum{Layer: layers[1], History: v1.History{CreatedBy: "b"}},
+		mutate.Addendum{Layer: layers[0]},
+		mutate.Addendum{Layer: layers[3]},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a = mustConfig(t, a, v1.Config{
+		Env:          []string{"A=1", "B=2"},
+		Cmd:          []string{"sh"},
+		Labels:       map[string]string{"keep": "x", "change": "1", "drop": "y"},
+		ExposedPorts: map[string]struct{}{"80/tcp": {}},
+	})
+	b = mustConfig(t, b, v1.Config{
+		Env:          []string{"A=1", "B=3"},
+		Cmd:          []string{"sh"},
+		Entrypoint:   []string{"/init"},
+		Labels:       map[string]string{"keep": "x", "change": "2", "new": "z"},
+		ExposedPorts: map[string]struct{}{"80/tcp": {}, "443/tcp": {}},
+	})
+
+	d, err := diff.Images(a, b)
+	if err != nil {
+		t.Fatalf("Images() = %v", err)
+	}
+	if d.Empty() {
+		t.Fatal("Images() is empty")
+	}
+	if d.MediaType != nil {
+		t.Errorf("MediaType = %v, want nil", d.MediaType)
+	}
+	if df := cmp.Diff([]v1.Hash{mustDiffID(t, layers[3])}, d.AddedLayers); df != "" {
+		t.Errorf("AddedLayers (-want +got) = %s", df)
+	}
+	if df := cmp.Diff([]v1.Hash{mustDiffID(t, layers[2])}, d.RemovedLayers); df != "" {
+		t.Errorf("RemovedLayers (-want +got) = %s", df)
+	}
+	if !d.LayersReordered {
+		t.Error("LayersReordered = false, want true")
+	}
+	if df := cmp.Diff(diff.ListDiff{Added: []string{"B=3"}, Removed: []string{"B=2"}}, d.Env); df != "" {
+		t.Errorf("Env (-want +got) = %s", df)
+	}
+	if df := cmp.Diff(diff.ListDiff{Added: []string{"443/tcp"}}, d.ExposedPorts); df != "" {
+		t.Errorf("ExposedPorts (-want +got) = %s", df)
+	}
+	if df := cmp.Diff(&diff.ListChange{B: []string{"/init"}}, d.Entrypoint); df != "" {
+		t.Errorf("Entrypoint (-want +got) = %s", df)
+	}
+	if d.Cmd != nil {
+		t.Errorf("Cmd = %v, want nil", d.Cmd)
+	}
+	wantLabels := diff.MapDiff{
+		Added:   map[string]string{"new": "z"},
+		Removed: map[string]string{"drop": "y"},
+		Changed: map[string]diff.ValueChange{"change": {A: "1", B: "2"}},
+	}
+	if df := cmp.Diff(wantLabels, d.Labels); df != "" {
+		t.Errorf("Labels (-want +got) = %s", df)
+	}
+	if len(d.History) != 1 || d.History[0].Index != 0 || d.History[0].B.CreatedBy != "b" {
+		t.Errorf("History = %+v, want only the first entry", d.History)
+	}
+}
+
+func TestDuplicateLayers(t *testing.T) {
+	x, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	y, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := mutate.AppendLayers(empty.Image, x, y, x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := mutate.AppendLayers(empty.Image, x, y)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := diff.Images(a, b)
+	if err != nil {
+		t.Fatalf("Images() = %v", err)
+	}
+	if len(d.AddedLayers) != 0 {
+		t.Errorf("AddedLayers = %v, want none", d.AddedLayers)
+	}
+	if df := cmp.Diff([]v1.Hash{mustDiffID(t, x)}, d.RemovedLayers); df != "" {
+		t.Errorf("RemovedLayers (-want +got) = %s", df)
+	}
+	if d.LayersReordered {
+		t.Error("LayersReordered = true, want false")
+	}
+}
+
+func mustConfig(t *testing.T, img v1.Image, cfg v1.Config) v1.Image {
+	t.Helper()
+	img, err := mutate.Config(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
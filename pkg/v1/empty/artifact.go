@@ -0,0 +1,72 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package empty
+
+import (
+	"encoding/json"
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// Artifact is a singleton empty OCI artifact: an OCI manifest with no layers
+// whose config is the empty config, "{}" with types.OCIEmptyJSON. Layers
+// appended with mutate keep the config empty.
+var Artifact, _ = partial.CompressedToImage(emptyArtifact{})
+
+// emptyConfig is the canonical content of an OCIEmptyJSON blob.
+var emptyConfig = []byte("{}")
+
+type emptyArtifact struct{}
+
+// MediaType implements partial.CompressedImageCore.
+func (a emptyArtifact) MediaType() (types.MediaType, error) {
+	return types.OCIManifestSchema1, nil
+}
+
+// RawConfigFile implements partial.CompressedImageCore.
+func (a emptyArtifact) RawConfigFile() ([]byte, error) {
+	return emptyConfig, nil
+}
+
+// RawManifest implements partial.CompressedImageCore.
+func (a emptyArtifact) RawManifest() ([]byte, error) {
+	h, err := partial.ConfigName(a)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&v1.Manifest{
+		SchemaVersion: 2,
+		MediaType:     types.OCIManifestSchema1,
+		Config: v1.Descriptor{
+			MediaType: types.OCIEmptyJSON,
+			Size:      int64(len(emptyConfig)),
+			Digest:    h,
+		},
+		Layers: []v1.Descriptor{},
+	})
+}
+
+// LayerByDigest implements partial.CompressedImageCore.
+func (a emptyArtifact) LayerByDigest(h v1.Hash) (partial.CompressedLayer, error) {
+	if cn, err := partial.ConfigName(a); err != nil {
+		return nil, err
+	} else if h == cn {
+		return partial.ConfigLayer(a)
+	}
+	return nil, fmt.Errorf("LayerByDigest(%s): empty artifact", h)
+}
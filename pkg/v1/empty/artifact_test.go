@@ -0,0 +1,50 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package empty
+
+import (
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+func TestArtifact(t *testing.T) {
+	if err := validate.Image(Artifact); err != nil {
+		t.Fatalf("validate.Image(empty.Artifact) = %v", err)
+	}
+
+	m, err := Artifact.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.Config.Digest.String(), "sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a"; got != want {
+		t.Errorf("config digest = %s, want %s", got, want)
+	}
+	if m.Config.Size != 2 || m.Config.MediaType != types.OCIEmptyJSON {
+		t.Errorf("config = %v, want 2 bytes of %s", m.Config, types.OCIEmptyJSON)
+	}
+	if m.Layers == nil || len(m.Layers) != 0 {
+		t.Errorf("layers = %v, want an empty list", m.Layers)
+	}
+
+	raw, err := Artifact.RawConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(raw) != "{}" {
+		t.Errorf("RawConfigFile() = %s, want {}", raw)
+	}
+}
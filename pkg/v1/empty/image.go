@@ -23,13 +23,22 @@ import (
 )
 
 // Image is a singleton empty image, think: FROM scratch.
-var Image, _ = partial.UncompressedToImage(emptyImage{})
+var Image = ImageWithMediaType(types.DockerManifestSchema2)
 
-type emptyImage struct{}
+// ImageWithMediaType returns an empty image with the given manifest media
+// type, e.g. types.OCIManifestSchema1. The config media type matches it.
+func ImageWithMediaType(mt types.MediaType) v1.Image {
+	img, _ := partial.UncompressedToImage(emptyImage{mediaType: mt})
+	return img
+}
+
+type emptyImage struct {
+	mediaType types.MediaType
+}
 
 // MediaType implements partial.UncompressedImageCore.
 func (i emptyImage) MediaType() (types.MediaType, error) {
-	return types.DockerManifestSchema2, nil
+	return i.mediaType, nil
 }
 
 // RawConfigFile implements partial.UncompressedImageCore.
@@ -17,6 +17,7 @@ package empty
 import (
 	"testing"
 
+	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/google/go-containerregistry/pkg/v1/validate"
 )
 
@@ -46,3 +47,30 @@ func TestManifestAndConfig(t *testing.T) {
 		t.Fatalf("rootfs type; got %v, want %v", got, want)
 	}
 }
+
+func TestImageWithMediaType(t *testing.T) {
+	for _, tc := range []struct {
+		mt, config types.MediaType
+	}{
+		{types.DockerManifestSchema2, types.DockerConfigJSON},
+		{types.OCIManifestSchema1, types.OCIConfigJSON},
+	} {
+		img := ImageWithMediaType(tc.mt)
+		if err := validate.Image(img); err != nil {
+			t.Errorf("validate.Image(%s) = %v", tc.mt, err)
+		}
+		if mt, err := img.MediaType(); err != nil || mt != tc.mt {
+			t.Errorf("MediaType() = %v, %v, want %v", mt, err, tc.mt)
+		}
+		m, err := img.Manifest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.MediaType != tc.mt {
+			t.Errorf("Manifest().MediaType = %v, want %v", m.MediaType, tc.mt)
+		}
+		if m.Config.MediaType != tc.config {
+			t.Errorf("Manifest().Config.MediaType = %v, want %v", m.Config.MediaType, tc.config)
+		}
+	}
+}
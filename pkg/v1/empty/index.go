@@ -26,9 +26,21 @@ import (
 // Index is a singleton empty index, think: FROM scratch.
 var Index = emptyIndex{}
 
-type emptyIndex struct{}
+// IndexWithMediaType returns an empty index with the given media type, e.g.
+// types.DockerManifestList.
+func IndexWithMediaType(mt types.MediaType) v1.ImageIndex {
+	return emptyIndex{mediaType: mt}
+}
+
+type emptyIndex struct {
+	// If unset, the index is an OCI index without an explicit mediaType.
+	mediaType types.MediaType
+}
 
 func (i emptyIndex) MediaType() (types.MediaType, error) {
+	if i.mediaType != "" {
+		return i.mediaType, nil
+	}
 	return types.OCIImageIndex, nil
 }
 
@@ -41,11 +53,11 @@ func (i emptyIndex) Size() (int64, error) {
 }
 
 func (i emptyIndex) IndexManifest() (*v1.IndexManifest, error) {
-	return base(), nil
+	return base(i.mediaType), nil
 }
 
 func (i emptyIndex) RawManifest() ([]byte, error) {
-	return json.Marshal(base())
+	return json.Marshal(base(i.mediaType))
 }
 
 func (i emptyIndex) Image(v1.Hash) (v1.Image, error) {
@@ -56,8 +68,9 @@ func (i emptyIndex) ImageIndex(v1.Hash) (v1.ImageIndex, error) {
 	return nil, errors.New("empty index")
 }
 
-func base() *v1.IndexManifest {
+func base(mt types.MediaType) *v1.IndexManifest {
 	return &v1.IndexManifest{
 		SchemaVersion: 2,
+		MediaType:     mt,
 	}
 }
@@ -38,3 +38,20 @@ func TestIndex(t *testing.T) {
 		t.Errorf("empty.Index.ImageIndex() should always fail")
 	}
 }
+
+func TestIndexWithMediaType(t *testing.T) {
+	idx := IndexWithMediaType(types.DockerManifestList)
+	if err := validate.Index(idx); err != nil {
+		t.Fatalf("validate.Index() = %v", err)
+	}
+	if mt, err := idx.MediaType(); err != nil || mt != types.DockerManifestList {
+		t.Errorf("MediaType() = %v, %v", mt, err)
+	}
+	im, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if im.MediaType != types.DockerManifestList {
+		t.Errorf("IndexManifest().MediaType = %v", im.MediaType)
+	}
+}
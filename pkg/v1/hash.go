@@ -16,6 +16,7 @@ package v1
 
 import (
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -74,11 +75,13 @@ func (h *Hash) UnmarshalText(text []byte) error {
 	return h.parse(string(text))
 }
 
-// Hasher returns a hash.Hash for the named algorithm (e.g. "sha256")
+// Hasher returns a hash.Hash for the named algorithm (e.g. "sha256" or "sha512")
 func Hasher(name string) (hash.Hash, error) {
 	switch name {
 	case "sha256":
 		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
 	default:
 		return nil, fmt.Errorf("unsupported hash: %q", name)
 	}
@@ -111,13 +114,22 @@ func (h *Hash) parse(unquoted string) error {
 
 // SHA256 computes the Hash of the provided io.Reader's content.
 func SHA256(r io.Reader) (Hash, int64, error) {
-	hasher := sha256.New()
+	return ComputeHash("sha256", r)
+}
+
+// ComputeHash computes the Hash of the provided io.Reader's content using the
+// named algorithm, see Hasher.
+func ComputeHash(algorithm string, r io.Reader) (Hash, int64, error) {
+	hasher, err := Hasher(algorithm)
+	if err != nil {
+		return Hash{}, 0, err
+	}
 	n, err := io.Copy(hasher, r)
 	if err != nil {
 		return Hash{}, 0, err
 	}
 	return Hash{
-		Algorithm: "sha256",
+		Algorithm: algorithm,
 		Hex:       hex.EncodeToString(hasher.Sum(make([]byte, 0, hasher.Size()))),
 	}, n, nil
 }
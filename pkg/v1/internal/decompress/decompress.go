@@ -0,0 +1,52 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package decompress decompresses streams based on their contents.
+package decompress
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"github.com/google/go-containerregistry/pkg/compression"
+	"github.com/google/go-containerregistry/pkg/v1/internal/and"
+	"github.com/google/go-containerregistry/pkg/v1/internal/gzip"
+	"github.com/google/go-containerregistry/pkg/v1/internal/zstd"
+)
+
+// ReadCloser sniffs the compression of r from its magic bytes and returns a
+// reader for the uncompressed contents, along with the detected compression.
+// Unrecognized streams are assumed to be uncompressed.
+func ReadCloser(r io.ReadCloser) (io.ReadCloser, compression.Compression, error) {
+	br := bufio.NewReader(r)
+	// Peek returns an error if the stream is shorter than the header, which
+	// just means it can't be compressed.
+	header, _ := br.Peek(4)
+	rc := &and.ReadCloser{Reader: br, CloseFunc: r.Close}
+
+	if ok, err := gzip.Is(bytes.NewReader(header)); err != nil {
+		return nil, compression.None, err
+	} else if ok {
+		zr, err := gzip.UnzipReadCloser(rc)
+		return zr, compression.GZip, err
+	}
+	if ok, err := zstd.Is(bytes.NewReader(header)); err != nil {
+		return nil, compression.None, err
+	} else if ok {
+		zr, err := zstd.UnzipReadCloser(rc)
+		return zr, compression.ZStd, err
+	}
+	return rc, compression.None, nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package decompress
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/compression"
+	"github.com/google/go-containerregistry/pkg/v1/internal/gzip"
+	"github.com/google/go-containerregistry/pkg/v1/internal/zstd"
+)
+
+func TestReadCloser(t *testing.T) {
+	want := []byte("hello, world")
+	compressed := func(c compression.Compression) []byte {
+		rc := ioutil.NopCloser(bytes.NewReader(want))
+		switch c {
+		case compression.GZip:
+			rc = gzip.ReadCloser(rc)
+		case compression.ZStd:
+			rc = zstd.ReadCloser(rc)
+		}
+		b, err := ioutil.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	for _, c := range []compression.Compression{compression.None, compression.GZip, compression.ZStd} {
+		t.Run(string(c), func(t *testing.T) {
+			rc, got, err := ReadCloser(ioutil.NopCloser(bytes.NewReader(compressed(c))))
+			if err != nil {
+				t.Fatalf("ReadCloser() = %v", err)
+			}
+			defer rc.Close()
+			if got != c {
+				t.Errorf("ReadCloser() detected %s, want %s", got, c)
+			}
+			b, err := ioutil.ReadAll(rc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b, want) {
+				t.Errorf("ReadCloser() = %q, want %q", b, want)
+			}
+		})
+	}
+
+	// Streams shorter than any magic header are passed through.
+	rc, got, err := ReadCloser(ioutil.NopCloser(bytes.NewReader([]byte("a"))))
+	if err != nil || got != compression.None {
+		t.Fatalf("ReadCloser(short) = %s, %v", got, err)
+	}
+	if b, err := ioutil.ReadAll(rc); err != nil || string(b) != "a" {
+		t.Errorf("ReadCloser(short) = %q, %v", b, err)
+	}
+}
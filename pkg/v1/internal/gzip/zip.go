@@ -105,10 +105,14 @@ func UnzipReadCloser(r io.ReadCloser) (io.ReadCloser, error) {
 // Is detects whether the input stream is compressed.
 func Is(r io.Reader) (bool, error) {
 	magicHeader := make([]byte, 2)
-	n, err := r.Read(magicHeader)
+	n, err := io.ReadFull(r, magicHeader)
 	if n == 0 && err == io.EOF {
 		return false, nil
 	}
+	// Too short to be gzip.
+	if err == io.ErrUnexpectedEOF {
+		return false, nil
+	}
 	if err != nil {
 		return false, err
 	}
@@ -17,9 +17,11 @@ package gzip
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func TestReader(t *testing.T) {
@@ -52,15 +54,20 @@ func TestIs(t *testing.T) {
 		{[]byte{}, false, nil},
 		{[]byte{'\x00', '\x00', '\x00'}, false, nil},
 		{[]byte{'\x1f', '\x8b', '\x1b'}, true, nil},
+		{[]byte{'\x1f', '\x8b'}, true, nil},
+		{[]byte{'\x1f'}, false, nil},
 	}
 	for _, test := range tests {
-		reader := bytes.NewReader(test.in)
-		got, err := Is(reader)
-		if got != test.out {
-			t.Errorf("Is; n: got %v, wanted %v\n", got, test.out)
-		}
-		if err != test.err {
-			t.Errorf("Is; err: got %v, wanted %v\n", err, test.err)
+		// Readers may return io.EOF along with the last bytes, e.g. the
+		// contents of a tar entry.
+		for _, reader := range []io.Reader{bytes.NewReader(test.in), iotest.DataErrReader(bytes.NewReader(test.in))} {
+			got, err := Is(reader)
+			if got != test.out {
+				t.Errorf("Is(%v); n: got %v, wanted %v\n", test.in, got, test.out)
+			}
+			if err != test.err {
+				t.Errorf("Is(%v); err: got %v, wanted %v\n", test.in, err, test.err)
+			}
 		}
 	}
 }
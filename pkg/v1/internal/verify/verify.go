@@ -24,20 +24,43 @@ import (
 	"github.com/google/go-containerregistry/pkg/v1/internal/and"
 )
 
+// Error is returned by the reader from ReadCloser when the contents don't
+// match the expected digest.
+type Error struct {
+	// Want is the expected digest, and Got the digest of what was read.
+	Want, Got v1.Hash
+
+	// BytesRead is the number of bytes read before the mismatch was detected.
+	// Comparing it with the expected size distinguishes a truncated transfer
+	// from corrupted contents.
+	BytesRead int64
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return fmt.Sprintf("error verifying %s checksum after reading %d bytes; got %q, want %q",
+		e.Want.Algorithm, e.BytesRead, e.Got.Hex, e.Want.Hex)
+}
+
 type verifyReader struct {
 	inner    io.Reader
 	hasher   hash.Hash
 	expected v1.Hash
+	read     int64
 }
 
 // Read implements io.Reader
 func (vc *verifyReader) Read(b []byte) (int, error) {
 	n, err := vc.inner.Read(b)
+	vc.read += int64(n)
 	if err == io.EOF {
 		got := hex.EncodeToString(vc.hasher.Sum(make([]byte, 0, vc.hasher.Size())))
 		if want := vc.expected.Hex; got != want {
-			return n, fmt.Errorf("error verifying %s checksum; got %q, want %q",
-				vc.expected.Algorithm, got, want)
+			return n, &Error{
+				Want:      vc.expected,
+				Got:       v1.Hash{Algorithm: vc.expected.Algorithm, Hex: got},
+				BytesRead: vc.read,
+			}
 		}
 	}
 	return n, err
@@ -16,6 +16,7 @@ package verify
 
 import (
 	"bytes"
+	"errors"
 	"io/ioutil"
 	"strings"
 	"testing"
@@ -39,8 +40,19 @@ func TestVerificationFailure(t *testing.T) {
 	if err != nil {
 		t.Fatal("ReadCloser() =", err)
 	}
-	if b, err := ioutil.ReadAll(verified); err == nil {
-		t.Errorf("ReadAll() = %q; want verification error", string(b))
+	b, err := ioutil.ReadAll(verified)
+	if err == nil {
+		t.Fatalf("ReadAll() = %q; want verification error", string(b))
+	}
+	var verr *Error
+	if !errors.As(err, &verr) {
+		t.Fatalf("ReadAll() = %T, want *Error", err)
+	}
+	if got := mustHash(want, t); verr.Got != got {
+		t.Errorf("Got = %s, want %s", verr.Got, got)
+	}
+	if verr.BytesRead != int64(len(want)) {
+		t.Errorf("BytesRead = %d, want %d", verr.BytesRead, len(want))
 	}
 }
 
@@ -0,0 +1,109 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zstd
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"github.com/google/go-containerregistry/pkg/v1/internal/and"
+	"github.com/klauspost/compress/zstd"
+)
+
+var zstdMagicHeader = []byte{'\x28', '\xb5', '\x2f', '\xfd'}
+
+// ReadCloser reads uncompressed input data from the io.ReadCloser and
+// returns an io.ReadCloser from which compressed data may be read.
+// This uses zstd.SpeedFastest for the compression level.
+func ReadCloser(r io.ReadCloser) io.ReadCloser {
+	return ReadCloserLevel(r, zstd.SpeedFastest)
+}
+
+// ReadCloserLevel reads uncompressed input data from the io.ReadCloser and
+// returns an io.ReadCloser from which compressed data may be read.
+// Refer to klauspost/compress/zstd for the level:
+// https://pkg.go.dev/github.com/klauspost/compress/zstd#EncoderLevel
+func ReadCloserLevel(r io.ReadCloser, level zstd.EncoderLevel) io.ReadCloser {
+	pr, pw := io.Pipe()
+
+	// Like gzip, buffer writes so we don't send tons of tiny writes over
+	// the wire when pushing highly compressible layers.
+	bw := bufio.NewWriterSize(pw, 2<<16)
+
+	go func() {
+		defer r.Close()
+
+		zw, err := zstd.NewWriter(bw, zstd.WithEncoderLevel(level))
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+
+		if _, err := io.Copy(zw, r); err != nil {
+			zw.Close()
+			pw.CloseWithError(err)
+			return
+		}
+
+		// Close zstd writer to Flush it and write the final frame.
+		if err := zw.Close(); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+
+		// Flush bufio writer to ensure we write out everything.
+		if err := bw.Flush(); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+
+		pw.Close()
+	}()
+
+	return pr
+}
+
+// UnzipReadCloser reads compressed input data from the io.ReadCloser and
+// returns an io.ReadCloser from which uncompessed data may be read.
+func UnzipReadCloser(r io.ReadCloser) (io.ReadCloser, error) {
+	zr, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return &and.ReadCloser{
+		Reader: zr,
+		CloseFunc: func() error {
+			zr.Close()
+			return r.Close()
+		},
+	}, nil
+}
+
+// Is detects whether the input stream is compressed.
+func Is(r io.Reader) (bool, error) {
+	magicHeader := make([]byte, 4)
+	n, err := io.ReadFull(r, magicHeader)
+	if n == 0 && err == io.EOF {
+		return false, nil
+	}
+	if err == io.ErrUnexpectedEOF {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(magicHeader, zstdMagicHeader), nil
+}
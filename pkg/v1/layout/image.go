@@ -20,6 +20,7 @@ import (
 	"sync"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/match"
 	"github.com/google/go-containerregistry/pkg/v1/partial"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 )
@@ -33,7 +34,8 @@ type layoutImage struct {
 
 var _ partial.CompressedImageCore = (*layoutImage)(nil)
 
-// Image reads a v1.Image with digest h from the Path.
+// Image reads a v1.Image with digest h from the Path. The manifest may be
+// referenced by the top-level index.json or by any index nested within it.
 func (l Path) Image(h v1.Hash) (v1.Image, error) {
 	ii, err := l.ImageIndex()
 	if err != nil {
@@ -43,6 +45,32 @@ func (l Path) Image(h v1.Hash) (v1.Image, error) {
 	return ii.Image(h)
 }
 
+// FindImage reads the v1.Image that index.json names refName with the
+// "org.opencontainers.image.ref.name" annotation, see WithRefName. It returns
+// an error if no image has that name, or if several different ones do.
+func (l Path) FindImage(refName string) (v1.Image, error) {
+	ii, err := l.ImageIndex()
+	if err != nil {
+		return nil, err
+	}
+	descs, err := partial.FindManifests(ii, match.Name(refName))
+	if err != nil {
+		return nil, err
+	}
+	if len(descs) == 0 {
+		return nil, fmt.Errorf("no image named %q in %s", refName, l)
+	}
+	for _, desc := range descs[1:] {
+		if desc.Digest != descs[0].Digest {
+			return nil, fmt.Errorf("ambiguous name %q in %s: %s and %s", refName, l, descs[0].Digest, desc.Digest)
+		}
+	}
+	if descs[0].MediaType.IsIndex() {
+		return nil, fmt.Errorf("%q in %s is a %s, not an image", refName, l, descs[0].MediaType)
+	}
+	return ii.Image(descs[0].Digest)
+}
+
 func (li *layoutImage) MediaType() (types.MediaType, error) {
 	return li.desc.MediaType, nil
 }
@@ -44,6 +44,9 @@ func ImageIndexFromPath(path string) (v1.ImageIndex, error) {
 }
 
 // ImageIndex returns a v1.ImageIndex for the Path.
+//
+// The Image and ImageIndex methods of the returned index resolve digests
+// anywhere in the layout, including within nested indexes.
 func (l Path) ImageIndex() (v1.ImageIndex, error) {
 	rawIndex, err := ioutil.ReadFile(l.path("index.json"))
 	if err != nil {
@@ -139,13 +142,57 @@ func (i *layoutIndex) findDescriptor(h v1.Hash) (*v1.Descriptor, error) {
 		return &(im.Manifests)[0], nil
 	}
 
+	desc, err := i.search(h, map[v1.Hash]struct{}{})
+	if err != nil {
+		return nil, err
+	}
+	if desc == nil {
+		return nil, fmt.Errorf("could not find descriptor in index or any nested index: %s", h)
+	}
+	return desc, nil
+}
+
+// search looks for h among the children of this index and then, depth-first,
+// among the children of any nested index. It returns nil if h is not found.
+// Every blob in a layout lives in the same directory, so a descriptor found in
+// a nested index is just as readable as one found at the top level.
+func (i *layoutIndex) search(h v1.Hash, seen map[v1.Hash]struct{}) (*v1.Descriptor, error) {
+	im, err := i.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+
 	for _, desc := range im.Manifests {
 		if desc.Digest == h {
 			return &desc, nil
 		}
 	}
 
-	return nil, fmt.Errorf("could not find descriptor in index: %s", h)
+	for _, desc := range im.Manifests {
+		if !isExpectedMediaType(desc.MediaType, types.OCIImageIndex, types.DockerManifestList) {
+			continue
+		}
+		if _, ok := seen[desc.Digest]; ok {
+			continue
+		}
+		seen[desc.Digest] = struct{}{}
+
+		rawIndex, err := i.path.Bytes(desc.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("reading nested index %s: %w", desc.Digest, err)
+		}
+		child := &layoutIndex{
+			mediaType: desc.MediaType,
+			path:      i.path,
+			rawIndex:  rawIndex,
+		}
+		found, err := child.search(h, seen)
+		if err != nil || found != nil {
+			return found, err
+		}
+	}
+
+	return nil, nil
 }
 
 // TODO: Pull this out into methods on types.MediaType? e.g. instead, have:
@@ -15,9 +15,15 @@
 package layout
 
 import (
+	"io/ioutil"
+	"os"
+	"strings"
 	"testing"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/google/go-containerregistry/pkg/v1/validate"
 )
@@ -79,3 +85,62 @@ func TestIndexErrors(t *testing.T) {
 		t.Errorf("idx.ImageIndex(%s) = nil, expected err", bogusDigest)
 	}
 }
+
+func TestNestedIndex(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "layout-nested")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	// top -> mid -> inner -> images
+	inner, err := random.Index(1024, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mid := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{Add: inner})
+	top := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{Add: mid})
+
+	lp, err := Write(tmp, top)
+	if err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+
+	innerDigest, err := inner.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	im, err := inner.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	imgDigest := im.Manifests[1].Digest
+
+	img, err := lp.Image(imgDigest)
+	if err != nil {
+		t.Fatalf("Image(%s) = %v", imgDigest, err)
+	}
+	if err := validate.Image(img); err != nil {
+		t.Errorf("validate.Image() = %v", err)
+	}
+
+	idx, err := lp.ImageIndex()
+	if err != nil {
+		t.Fatalf("ImageIndex() = %v", err)
+	}
+	ii, err := idx.ImageIndex(innerDigest)
+	if err != nil {
+		t.Fatalf("ImageIndex(%s) = %v", innerDigest, err)
+	}
+	if got, err := ii.Digest(); err != nil {
+		t.Fatal(err)
+	} else if got != innerDigest {
+		t.Errorf("Digest(); want: %v got: %v", innerDigest, got)
+	}
+
+	if _, err := lp.Image(bogusDigest); err == nil {
+		t.Errorf("Image(%s) = nil, expected err", bogusDigest)
+	} else if !strings.Contains(err.Error(), "nested index") {
+		t.Errorf("Image(%s) = %v, expected error mentioning nested indexes", bogusDigest, err)
+	}
+}
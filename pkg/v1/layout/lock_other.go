@@ -0,0 +1,31 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package layout
+
+import "sync"
+
+var mu sync.Mutex
+
+// lock serializes updates within this process. flock isn't available on this
+// platform, so concurrent updates from other processes aren't prevented.
+func lock(string) (func() error, error) {
+	mu.Lock()
+	return func() error {
+		mu.Unlock()
+		return nil
+	}, nil
+}
@@ -1,12 +1,16 @@
 package layout
 
-import v1 "github.com/google/go-containerregistry/pkg/v1"
+import (
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	imagespec "github.com/opencontainers/image-spec/specs-go/v1"
+)
 
 // Option is a functional option for Layout.
 type Option func(*options)
 
 type options struct {
 	descOpts []descriptorOption
+	resume   bool
 }
 
 func makeOptions(opts ...Option) *options {
@@ -35,6 +39,13 @@ func WithAnnotations(annotations map[string]string) Option {
 	}
 }
 
+// WithRefName sets the "org.opencontainers.image.ref.name" annotation of the
+// artifact descriptor, which names it within the layout, see FindImage. To
+// move a name to a different image, use ReplaceImage with match.Name.
+func WithRefName(name string) Option {
+	return WithAnnotations(map[string]string{imagespec.AnnotationRefName: name})
+}
+
 // WithURLs adds urls to the artifact descriptor.
 func WithURLs(urls []string) Option {
 	return func(o *options) {
@@ -55,3 +66,14 @@ func WithPlatform(platform v1.Platform) Option {
 		})
 	}
 }
+
+// WithResume makes writes check the size of blobs that are already in the
+// layout and write them again if they don't match, e.g. because a previous
+// write was interrupted, instead of assuming they are complete. Combined with
+// AppendImage, this makes it safe to restart a large write that failed part
+// way through without starting from scratch.
+func WithResume() Option {
+	return func(o *options) {
+		o.resume = true
+	}
+}
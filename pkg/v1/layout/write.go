@@ -17,16 +17,19 @@ package layout
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/internal/verify"
 	"github.com/google/go-containerregistry/pkg/v1/match"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/partial"
 	"github.com/google/go-containerregistry/pkg/v1/types"
+	imagespec "github.com/opencontainers/image-spec/specs-go/v1"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -37,7 +40,7 @@ var layoutFile = `{
 // AppendImage writes a v1.Image to the Path and updates
 // the index.json to reference it.
 func (l Path) AppendImage(img v1.Image, options ...Option) error {
-	if err := l.WriteImage(img); err != nil {
+	if err := l.WriteImageWithOptions(img, options...); err != nil {
 		return err
 	}
 
@@ -73,7 +76,7 @@ func (l Path) AppendImage(img v1.Image, options ...Option) error {
 // AppendIndex writes a v1.ImageIndex to the Path and updates
 // the index.json to reference it.
 func (l Path) AppendIndex(ii v1.ImageIndex, options ...Option) error {
-	if err := l.WriteIndex(ii); err != nil {
+	if err := l.WriteIndexWithOptions(ii, options...); err != nil {
 		return err
 	}
 
@@ -106,19 +109,44 @@ func (l Path) AppendIndex(ii v1.ImageIndex, options ...Option) error {
 	return l.AppendDescriptor(desc)
 }
 
-// AppendDescriptor adds a descriptor to the index.json of the Path.
+// AppendDescriptor adds a descriptor to the index.json of the Path. If a
+// descriptor with the same digest and ref name (see WithRefName) is already
+// there, it is replaced in place, so appending the same image again doesn't
+// add a second entry for it. The same image can still be appended under
+// several names.
+//
+// The index is updated under an exclusive lock on the layout directory, so it
+// is safe for several processes to append to the same layout concurrently.
 func (l Path) AppendDescriptor(desc v1.Descriptor) error {
-	ii, err := l.ImageIndex()
+	return l.updateIndex(func(ii v1.ImageIndex) (*v1.IndexManifest, error) {
+		index, err := ii.IndexManifest()
+		if err != nil {
+			return nil, err
+		}
+		index.Manifests = appendByName(index.Manifests, desc)
+		return index, nil
+	})
+}
+
+// updateIndex rewrites index.json with the result of f, which is passed the
+// current index. The read-modify-write happens while holding the lock on the
+// layout directory, so that concurrent updates don't drop each other's
+// changes.
+func (l Path) updateIndex(f func(v1.ImageIndex) (*v1.IndexManifest, error)) error {
+	unlock, err := lock(l.path())
 	if err != nil {
 		return err
 	}
+	defer unlock()
 
-	index, err := ii.IndexManifest()
+	ii, err := l.ImageIndex()
+	if err != nil {
+		return err
+	}
+	index, err := f(ii)
 	if err != nil {
 		return err
 	}
-
-	index.Manifests = append(index.Manifests, desc)
 
 	rawIndex, err := json.MarshalIndent(index, "", "   ")
 	if err != nil {
@@ -128,10 +156,22 @@ func (l Path) AppendDescriptor(desc v1.Descriptor) error {
 	return l.WriteFile("index.json", rawIndex, os.ModePerm)
 }
 
+// appendByName appends desc to manifests, or replaces the descriptor with
+// the same digest and ref name if there already is one.
+func appendByName(manifests []v1.Descriptor, desc v1.Descriptor) []v1.Descriptor {
+	for i, m := range manifests {
+		if m.Digest == desc.Digest && m.Annotations[imagespec.AnnotationRefName] == desc.Annotations[imagespec.AnnotationRefName] {
+			manifests[i] = desc
+			return manifests
+		}
+	}
+	return append(manifests, desc)
+}
+
 // ReplaceImage writes a v1.Image to the Path and updates
 // the index.json to reference it, replacing any existing one that matches matcher, if found.
 func (l Path) ReplaceImage(img v1.Image, matcher match.Matcher, options ...Option) error {
-	if err := l.WriteImage(img); err != nil {
+	if err := l.WriteImageWithOptions(img, options...); err != nil {
 		return err
 	}
 
@@ -141,7 +181,7 @@ func (l Path) ReplaceImage(img v1.Image, matcher match.Matcher, options ...Optio
 // ReplaceIndex writes a v1.ImageIndex to the Path and updates
 // the index.json to reference it, replacing any existing one that matches matcher, if found.
 func (l Path) ReplaceIndex(ii v1.ImageIndex, matcher match.Matcher, options ...Option) error {
-	if err := l.WriteIndex(ii); err != nil {
+	if err := l.WriteIndexWithOptions(ii, options...); err != nil {
 		return err
 	}
 
@@ -151,11 +191,6 @@ func (l Path) ReplaceIndex(ii v1.ImageIndex, matcher match.Matcher, options ...O
 // replaceDescriptor adds a descriptor to the index.json of the Path, replacing
 // any one matching matcher, if found.
 func (l Path) replaceDescriptor(append mutate.Appendable, matcher match.Matcher, options ...Option) error {
-	ii, err := l.ImageIndex()
-	if err != nil {
-		return err
-	}
-
 	desc, err := partial.Descriptor(append)
 	if err != nil {
 		return err
@@ -166,44 +201,21 @@ func (l Path) replaceDescriptor(append mutate.Appendable, matcher match.Matcher,
 		opt(desc)
 	}
 
-	add := mutate.IndexAddendum{
-		Add:        append,
-		Descriptor: *desc,
-	}
-	ii = mutate.AppendManifests(mutate.RemoveManifests(ii, matcher), add)
-
-	index, err := ii.IndexManifest()
-	if err != nil {
-		return err
-	}
-
-	rawIndex, err := json.MarshalIndent(index, "", "   ")
-	if err != nil {
-		return err
-	}
-
-	return l.WriteFile("index.json", rawIndex, os.ModePerm)
+	return l.updateIndex(func(ii v1.ImageIndex) (*v1.IndexManifest, error) {
+		index, err := mutate.RemoveManifests(ii, matcher).IndexManifest()
+		if err != nil {
+			return nil, err
+		}
+		index.Manifests = appendByName(index.Manifests, *desc)
+		return index, nil
+	})
 }
 
 // RemoveDescriptors removes any descriptors that match the match.Matcher from the index.json of the Path.
 func (l Path) RemoveDescriptors(matcher match.Matcher) error {
-	ii, err := l.ImageIndex()
-	if err != nil {
-		return err
-	}
-	ii = mutate.RemoveManifests(ii, matcher)
-
-	index, err := ii.IndexManifest()
-	if err != nil {
-		return err
-	}
-
-	rawIndex, err := json.MarshalIndent(index, "", "   ")
-	if err != nil {
-		return err
-	}
-
-	return l.WriteFile("index.json", rawIndex, os.ModePerm)
+	return l.updateIndex(func(ii v1.ImageIndex) (*v1.IndexManifest, error) {
+		return mutate.RemoveManifests(ii, matcher).IndexManifest()
+	})
 }
 
 // WriteFile write a file with arbitrary data at an arbitrary location in a v1
@@ -221,44 +233,81 @@ func (l Path) WriteFile(name string, data []byte, perm os.FileMode) error {
 
 // WriteBlob copies a file to the blobs/ directory in the Path from the given ReadCloser at
 // blobs/{hash.Algorithm}/{hash.Hex}.
+//
+// The contents are streamed to a temporary file in the same directory and
+// verified against hash before being renamed into place, so that a failed or
+// interrupted write never leaves a partial blob behind. If the blob already
+// exists, nothing is written. WriteBlob closes r.
 func (l Path) WriteBlob(hash v1.Hash, r io.ReadCloser) error {
+	defer r.Close()
+	return l.writeBlob(hash, -1, func() (io.ReadCloser, error) {
+		return r, nil
+	}, makeOptions())
+}
+
+// writeBlob is like WriteBlob, but only opens the blob if it isn't already in
+// the layout, which avoids e.g. fetching layers from a registry needlessly.
+// With WithResume, an existing blob is only kept if it has the given size,
+// unless that is negative.
+func (l Path) writeBlob(hash v1.Hash, size int64, open func() (io.ReadCloser, error), o *options) error {
 	dir := l.path("blobs", hash.Algorithm)
 	if err := os.MkdirAll(dir, os.ModePerm); err != nil && !os.IsExist(err) {
 		return err
 	}
 
 	file := filepath.Join(dir, hash.Hex)
-	if _, err := os.Stat(file); err == nil {
-		// Blob already exists, that's fine.
-		return nil
+	if fi, err := os.Stat(file); err == nil {
+		if !o.resume || size < 0 || fi.Size() == size {
+			// Blob already exists, that's fine.
+			return nil
+		}
+		// The blob is incomplete, write it again.
+		if err := os.Remove(file); err != nil {
+			return err
+		}
+	}
+	rc, err := open()
+	if err != nil {
+		return err
 	}
-	w, err := os.Create(file)
+	defer rc.Close()
+	vrc, err := verify.ReadCloser(rc, hash)
 	if err != nil {
 		return err
 	}
-	defer w.Close()
 
-	_, err = io.Copy(w, r)
-	return err
+	w, err := ioutil.TempFile(dir, hash.Hex+".tmp")
+	if err != nil {
+		return err
+	}
+	// The rename below succeeds on the happy path, in which case this is a no-op.
+	defer os.Remove(w.Name())
+
+	if _, err := io.Copy(w, vrc); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return os.Rename(w.Name(), file)
 }
 
 // TODO: A streaming version of WriteBlob so we don't have to know the hash
 // before we write it.
-
-// TODO: For streaming layers we should write to a tmp file then Rename to the
-// final digest.
-func (l Path) writeLayer(layer v1.Layer) error {
+func (l Path) writeLayer(layer v1.Layer, o *options) error {
 	d, err := layer.Digest()
 	if err != nil {
 		return err
 	}
-
-	r, err := layer.Compressed()
-	if err != nil {
-		return err
+	size := int64(-1)
+	if o.resume {
+		if size, err = layer.Size(); err != nil {
+			return err
+		}
 	}
 
-	return l.WriteBlob(d, r)
+	return l.writeBlob(d, size, layer.Compressed, o)
 }
 
 // RemoveBlob removes a file from the blobs directory in the Path
@@ -281,6 +330,16 @@ func (l Path) RemoveBlob(hash v1.Hash) error {
 // image and also update the `index.json`, call AppendImage(), which wraps this
 // and also updates the `index.json`.
 func (l Path) WriteImage(img v1.Image) error {
+	return l.WriteImageWithOptions(img)
+}
+
+// WriteImageWithOptions is WriteImage with options, e.g. WithResume to
+// rewrite existing blobs that are incomplete.
+func (l Path) WriteImageWithOptions(img v1.Image, options ...Option) error {
+	return l.writeImage(img, makeOptions(options...))
+}
+
+func (l Path) writeImage(img v1.Image, o *options) error {
 	layers, err := img.Layers()
 	if err != nil {
 		return err
@@ -291,7 +350,7 @@ func (l Path) WriteImage(img v1.Image) error {
 	for _, layer := range layers {
 		layer := layer
 		g.Go(func() error {
-			return l.writeLayer(layer)
+			return l.writeLayer(layer, o)
 		})
 	}
 	if err := g.Wait(); err != nil {
@@ -307,7 +366,7 @@ func (l Path) WriteImage(img v1.Image) error {
 	if err != nil {
 		return err
 	}
-	if err := l.WriteBlob(cfgName, ioutil.NopCloser(bytes.NewReader(cfgBlob))); err != nil {
+	if err := l.writeBytes(cfgName, cfgBlob, o); err != nil {
 		return err
 	}
 
@@ -321,7 +380,14 @@ func (l Path) WriteImage(img v1.Image) error {
 		return err
 	}
 
-	return l.WriteBlob(d, ioutil.NopCloser(bytes.NewReader(manifest)))
+	return l.writeBytes(d, manifest, o)
+}
+
+// writeBytes writes b as the blob hash.
+func (l Path) writeBytes(hash v1.Hash, b []byte, o *options) error {
+	return l.writeBlob(hash, int64(len(b)), func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	}, o)
 }
 
 type withLayer interface {
@@ -332,7 +398,21 @@ type withBlob interface {
 	Blob(v1.Hash) (io.ReadCloser, error)
 }
 
-func (l Path) writeIndexToFile(indexFile string, ii v1.ImageIndex) error {
+func (l Path) writeIndexToFile(indexFile string, ii v1.ImageIndex, o *options) error {
+	if err := l.writeChildren(ii, o); err != nil {
+		return err
+	}
+
+	rawIndex, err := ii.RawManifest()
+	if err != nil {
+		return err
+	}
+
+	return l.WriteFile(indexFile, rawIndex, os.ModePerm)
+}
+
+// writeChildren writes the manifests and blobs that ii refers to.
+func (l Path) writeChildren(ii v1.ImageIndex, o *options) error {
 	index, err := ii.IndexManifest()
 	if err != nil {
 		return err
@@ -347,7 +427,7 @@ func (l Path) writeIndexToFile(indexFile string, ii v1.ImageIndex) error {
 			if err != nil {
 				return err
 			}
-			if err := l.WriteIndex(ii); err != nil {
+			if err := l.writeIndex(ii, o); err != nil {
 				return err
 			}
 		case types.OCIManifestSchema1, types.DockerManifestSchema2:
@@ -355,39 +435,33 @@ func (l Path) writeIndexToFile(indexFile string, ii v1.ImageIndex) error {
 			if err != nil {
 				return err
 			}
-			if err := l.WriteImage(img); err != nil {
+			if err := l.writeImage(img, o); err != nil {
 				return err
 			}
 		default:
 			// TODO: The layout could reference arbitrary things, which we should
 			// probably just pass through.
 
-			var blob io.ReadCloser
-			// Workaround for #819.
-			if wl, ok := ii.(withLayer); ok {
-				layer, err := wl.Layer(desc.Digest)
-				if err != nil {
-					return err
+			digest := desc.Digest
+			open := func() (io.ReadCloser, error) {
+				// Workaround for #819.
+				if wl, ok := ii.(withLayer); ok {
+					layer, err := wl.Layer(digest)
+					if err != nil {
+						return nil, err
+					}
+					return layer.Compressed()
+				} else if wb, ok := ii.(withBlob); ok {
+					return wb.Blob(digest)
 				}
-				blob, err = layer.Compressed()
-			} else if wb, ok := ii.(withBlob); ok {
-				blob, err = wb.Blob(desc.Digest)
-			}
-			if err != nil {
-				return err
+				return nil, fmt.Errorf("unable to fetch blob %s of type %s from index", digest, desc.MediaType)
 			}
-			if err := l.WriteBlob(desc.Digest, blob); err != nil {
+			if err := l.writeBlob(digest, desc.Size, open, o); err != nil {
 				return err
 			}
 		}
 	}
-
-	rawIndex, err := ii.RawManifest()
-	if err != nil {
-		return err
-	}
-
-	return l.WriteFile(indexFile, rawIndex, os.ModePerm)
+	return nil
 }
 
 // WriteIndex writes an index to the blobs directory. Walks down the children,
@@ -398,6 +472,16 @@ func (l Path) writeIndexToFile(indexFile string, ii v1.ImageIndex) error {
 // index and also update the `index.json`, call AppendIndex(), which wraps this
 // and also updates the `index.json`.
 func (l Path) WriteIndex(ii v1.ImageIndex) error {
+	return l.WriteIndexWithOptions(ii)
+}
+
+// WriteIndexWithOptions is WriteIndex with options, e.g. WithResume to
+// rewrite existing blobs that are incomplete.
+func (l Path) WriteIndexWithOptions(ii v1.ImageIndex, options ...Option) error {
+	return l.writeIndex(ii, makeOptions(options...))
+}
+
+func (l Path) writeIndex(ii v1.ImageIndex, o *options) error {
 	// Always just write oci-layout file, since it's small.
 	if err := l.WriteFile("oci-layout", []byte(layoutFile), os.ModePerm); err != nil {
 		return err
@@ -409,7 +493,7 @@ func (l Path) WriteIndex(ii v1.ImageIndex) error {
 	}
 
 	indexFile := filepath.Join("blobs", h.Algorithm, h.Hex)
-	return l.writeIndexToFile(indexFile, ii)
+	return l.writeIndexToFile(indexFile, ii, o)
 
 }
 
@@ -424,6 +508,12 @@ func (l Path) WriteIndex(ii v1.ImageIndex) error {
 //   One file for each config blob, named after its SHA.
 //   One file for each manifest blob, named after its SHA.
 func Write(path string, ii v1.ImageIndex) (Path, error) {
+	return WriteWithOptions(path, ii)
+}
+
+// WriteWithOptions is Write with options. Options such as WithResume apply to
+// writing the blobs.
+func WriteWithOptions(path string, ii v1.ImageIndex, options ...Option) (Path, error) {
 	lp := Path(path)
 	// Always just write oci-layout file, since it's small.
 	if err := lp.WriteFile("oci-layout", []byte(layoutFile), os.ModePerm); err != nil {
@@ -432,5 +522,19 @@ func Write(path string, ii v1.ImageIndex) (Path, error) {
 
 	// TODO create blobs/ in case there is a blobs file which would prevent the directory from being created
 
-	return lp, lp.writeIndexToFile("index.json", ii)
+	if err := lp.writeChildren(ii, makeOptions(options...)); err != nil {
+		return "", err
+	}
+	rawIndex, err := ii.RawManifest()
+	if err != nil {
+		return "", err
+	}
+
+	// Don't race with concurrent updates of index.json, see AppendDescriptor.
+	unlock, err := lock(lp.path())
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	return lp, lp.WriteFile("index.json", rawIndex, os.ModePerm)
 }
@@ -6,15 +6,19 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/match"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/google/go-containerregistry/pkg/v1/validate"
+	"golang.org/x/sync/errgroup"
 )
 
 func TestWrite(t *testing.T) {
@@ -210,6 +214,79 @@ func TestDeduplicatedWrites(t *testing.T) {
 	}
 }
 
+func TestWriteBlobVerifies(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "write-blob-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	lp, err := Write(tmp, empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, _, err := v1.SHA256(bytes.NewReader([]byte("foo")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lp.WriteBlob(hash, ioutil.NopCloser(bytes.NewReader([]byte("bar")))); err == nil {
+		t.Error("WriteBlob() with mismatched contents: expected error")
+	}
+	if _, err := lp.Blob(hash); err == nil {
+		t.Error("Blob() found a blob that failed verification")
+	}
+	entries, err := ioutil.ReadDir(lp.path("blobs", hash.Algorithm))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if strings.Contains(e.Name(), ".tmp") {
+			t.Errorf("temporary file %s was left behind", e.Name())
+		}
+	}
+}
+
+type countingLayer struct {
+	v1.Layer
+	opened int
+}
+
+func (l *countingLayer) Compressed() (io.ReadCloser, error) {
+	l.opened++
+	return l.Layer.Compressed()
+}
+
+func TestWriteImageSkipsExistingLayers(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "write-image-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	lp, err := Write(tmp, empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl, err := random.Layer(1024, types.OCILayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer := &countingLayer{Layer: rl}
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := lp.WriteImage(img); err != nil {
+			t.Fatalf("WriteImage() = %v", err)
+		}
+	}
+	if layer.opened != 1 {
+		t.Errorf("layer was opened %d times, want 1", layer.opened)
+	}
+}
+
 func TestRemoveDescriptor(t *testing.T) {
 	// need to set up a basic path
 	tmp, err := ioutil.TempDir("", "remove-descriptor-test")
@@ -462,3 +539,301 @@ func TestRemoveBlob(t *testing.T) {
 		t.Fatal("still existed after deletion")
 	}
 }
+
+func TestAppendImageConcurrent(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "append-concurrent-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	l, err := Write(tmp, empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 10
+	images := make([]v1.Image, n)
+	for i := range images {
+		if images[i], err = random.Image(256, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var g errgroup.Group
+	for _, img := range images {
+		img := img
+		g.Go(func() error {
+			return l.AppendImage(img)
+		})
+	}
+	if err := g.Wait(); err != nil {
+		t.Fatalf("AppendImage() = %v", err)
+	}
+
+	// Appending the same image again is a no-op.
+	if err := l.AppendImage(images[0]); err != nil {
+		t.Fatal(err)
+	}
+	ii, err := l.ImageIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := ii.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Manifests) != n {
+		t.Fatalf("mismatched manifests count, had %d, expected %d", len(manifest.Manifests), n)
+	}
+	for _, img := range images {
+		d, err := img.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ii.Image(d); err != nil {
+			t.Errorf("Image(%s) = %v", d, err)
+		}
+	}
+
+	// Appending it under a name adds an entry for that name, once.
+	for i := 0; i < 2; i++ {
+		if err := l.AppendImage(images[0], WithRefName("again")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ii, err = l.ImageIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest, err = ii.IndexManifest(); err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Manifests) != n+1 {
+		t.Fatalf("mismatched manifests count, had %d, expected %d", len(manifest.Manifests), n+1)
+	}
+	if _, err := l.FindImage("again"); err != nil {
+		t.Errorf("FindImage(again) = %v", err)
+	}
+}
+
+func TestAppendImageSeveralNames(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "append-names-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	l, err := Write(tmp, empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"latest", "v1", "latest"} {
+		if err := l.AppendImage(img, WithRefName(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ii, err := l.ImageIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := ii.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Manifests) != 2 {
+		t.Fatalf("mismatched manifests count, had %d, expected 2", len(manifest.Manifests))
+	}
+	for _, name := range []string{"latest", "v1"} {
+		if _, err := l.FindImage(name); err != nil {
+			t.Errorf("FindImage(%s) = %v", name, err)
+		}
+	}
+}
+
+func TestWriteImageResume(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "write-resume-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	l, err := Write(tmp, empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := layers[0].Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate an earlier write that was interrupted.
+	blob := l.path("blobs", d.Algorithm, d.Hex)
+	if err := os.MkdirAll(filepath.Dir(blob), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(blob, []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stale := blob + ".tmp123"
+	if err := ioutil.WriteFile(stale, []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without WithResume, the existing blob is trusted.
+	if err := l.WriteImage(img); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := l.Bytes(d); err != nil || string(b) != "partial" {
+		t.Errorf("Bytes() = %q, %v, want the existing blob to be kept", b, err)
+	}
+
+	if err := l.AppendImage(img, WithResume()); err != nil {
+		t.Fatalf("AppendImage(WithResume) = %v", err)
+	}
+	// Temporary files may belong to another writer, so they are left alone.
+	if _, err := os.Stat(stale); err != nil {
+		t.Errorf("temporary file was removed: %v", err)
+	}
+	if err := validateImage(l, img); err != nil {
+		t.Errorf("validate.Image() = %v", err)
+	}
+}
+
+func TestAppendImageResumeConcurrent(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "append-resume-concurrent-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	l, err := Write(tmp, empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The same layer is written twice per image, concurrently.
+	layer, err := random.Layer(64*1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := mutate.AppendLayers(empty.Image, layer, layer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var g errgroup.Group
+	for i := 0; i < 4; i++ {
+		g.Go(func() error {
+			return l.AppendImage(img, WithResume())
+		})
+	}
+	if err := g.Wait(); err != nil {
+		t.Fatalf("AppendImage(WithResume) = %v", err)
+	}
+	if err := validateImage(l, img); err != nil {
+		t.Errorf("validate.Image() = %v", err)
+	}
+}
+
+func validateImage(l Path, img v1.Image) error {
+	d, err := img.Digest()
+	if err != nil {
+		return err
+	}
+	got, err := l.Image(d)
+	if err != nil {
+		return err
+	}
+	return validate.Image(got)
+}
+
+func TestFindImage(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "find-image-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	l, err := Write(tmp, empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	image1, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	image2, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := random.Index(1024, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.AppendImage(image1, WithRefName("v1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.AppendImage(image2, WithRefName("v2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.AppendIndex(idx, WithRefName("index")); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]v1.Image{"v1": image1, "v2": image2} {
+		got, err := l.FindImage(name)
+		if err != nil {
+			t.Fatalf("FindImage(%s) = %v", name, err)
+		}
+		wantDigest, err := want.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotDigest, err := got.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gotDigest != wantDigest {
+			t.Errorf("FindImage(%s) = %s, want %s", name, gotDigest, wantDigest)
+		}
+	}
+
+	if _, err := l.FindImage("missing"); err == nil {
+		t.Error("FindImage(missing) = nil, want error")
+	}
+	if _, err := l.FindImage("index"); err == nil {
+		t.Error("FindImage(index) = nil, want error")
+	}
+
+	// Appending another image with the same name makes it ambiguous...
+	if err := l.AppendImage(image2, WithRefName("v1")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.FindImage("v1"); err == nil {
+		t.Error("FindImage(v1) = nil, want error")
+	}
+	// ...but replacing it moves the name.
+	if err := l.ReplaceImage(image2, match.Name("v1"), WithRefName("v1")); err != nil {
+		t.Fatal(err)
+	}
+	got, err := l.FindImage("v1")
+	if err != nil {
+		t.Fatalf("FindImage(v1) = %v", err)
+	}
+	if err := validate.Image(got); err != nil {
+		t.Error(err)
+	}
+}
@@ -15,6 +15,8 @@
 package v1
 
 import (
+	"bytes"
+	"encoding/json"
 	"strings"
 	"testing"
 
@@ -74,3 +76,54 @@ func TestParseIndexManifest(t *testing.T) {
 		t.Errorf("expected error, got: %v", got)
 	}
 }
+
+// Manifests that were serialized compactly with the usual field order (as
+// registries and most tools do) survive a round trip byte for byte, which
+// keeps their signatures valid.
+func TestManifestRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		raw   string
+		parse func(string) (interface{}, error)
+	}{{
+		name: "image",
+		raw:  `{"schemaVersion":2,"mediaType":"application/vnd.docker.distribution.manifest.v2+json","config":{"mediaType":"application/vnd.docker.container.image.v1+json","size":1510,"digest":"sha256:a0a59a5a5cc8e1bf9c8bd33d2e5a2a2f6c4f2f56a4c3e3c4d36e1f1f3a6f0e0b"},"layers":[{"mediaType":"application/vnd.docker.image.rootfs.diff.tar.gzip","size":2811969,"digest":"sha256:0f4b2d2f46d53b4b0d0f233a09eb2d2bd8c164b52ebb0f2898f3c3bd1db5b07b"}],"annotations":{"a":"1","b":"2"}}`,
+		parse: func(s string) (interface{}, error) {
+			return ParseManifest(strings.NewReader(s))
+		},
+	}, {
+		name: "artifact",
+		raw:  `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","artifactType":"application/vnd.example+type","config":{"mediaType":"application/vnd.oci.empty.v1+json","size":2,"digest":"sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a"},"layers":[{"mediaType":"application/vnd.example+text","size":12,"digest":"sha256:0f4b2d2f46d53b4b0d0f233a09eb2d2bd8c164b52ebb0f2898f3c3bd1db5b07b"}],"subject":{"mediaType":"application/vnd.oci.image.manifest.v1+json","size":528,"digest":"sha256:a0a59a5a5cc8e1bf9c8bd33d2e5a2a2f6c4f2f56a4c3e3c4d36e1f1f3a6f0e0b"},"annotations":{"a":"1"}}`,
+		parse: func(s string) (interface{}, error) {
+			return ParseManifest(strings.NewReader(s))
+		},
+	}, {
+		name: "index",
+		raw:  `{"schemaVersion":2,"mediaType":"application/vnd.docker.distribution.manifest.list.v2+json","manifests":[{"mediaType":"application/vnd.docker.distribution.manifest.v2+json","size":528,"digest":"sha256:a0a59a5a5cc8e1bf9c8bd33d2e5a2a2f6c4f2f56a4c3e3c4d36e1f1f3a6f0e0b","platform":{"architecture":"arm","os":"linux","variant":"v7"}}]}`,
+		parse: func(s string) (interface{}, error) {
+			return ParseIndexManifest(strings.NewReader(s))
+		},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := tc.parse(tc.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := json.Marshal(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, _, err := SHA256(strings.NewReader(tc.raw))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _, err := SHA256(bytes.NewReader(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("digest changed after round trip: %s != %s\n%s", got, want, b)
+			}
+		})
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"reflect"
 	"strings"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
@@ -30,12 +31,27 @@ type image struct {
 	base v1.Image
 	adds []Addendum
 
-	computed   bool
-	configFile *v1.ConfigFile
-	manifest   *v1.Manifest
-	mediaType  *types.MediaType
-	diffIDMap  map[v1.Hash]v1.Layer
-	digestMap  map[v1.Hash]v1.Layer
+	computed    bool
+	configFile  *v1.ConfigFile
+	manifest    *v1.Manifest
+	rawConfig   []byte
+	rawManifest []byte
+	mediaType   *types.MediaType
+	diffIDMap   map[v1.Hash]v1.Layer
+	digestMap   map[v1.Hash]v1.Layer
+
+	// See Annotations and DeleteAnnotations.
+	annotations       map[string]string
+	deleteAnnotations []string
+
+	// See Subject.
+	subject *v1.Descriptor
+
+	// See ArtifactType.
+	artifactType *string
+
+	// See ConfigMediaType.
+	configMediaType *types.MediaType
 }
 
 var _ v1.Image = (*image)(nil)
@@ -44,7 +60,20 @@ func (i *image) MediaType() (types.MediaType, error) {
 	if i.mediaType != nil {
 		return *i.mediaType, nil
 	}
-	return i.base.MediaType()
+	mt, err := i.base.MediaType()
+	if err != nil {
+		return "", err
+	}
+	if i.oci() {
+		return ociMediaType(mt), nil
+	}
+	return mt, nil
+}
+
+// oci returns whether the manifest has fields that only OCI manifests have, so
+// that its media types need to be converted.
+func (i *image) oci() bool {
+	return i.subject != nil || (i.artifactType != nil && *i.artifactType != "")
 }
 
 func (i *image) compute() error {
@@ -109,18 +138,81 @@ func (i *image) compute() error {
 			desc.MediaType = add.MediaType
 		}
 
+		layer := add.Layer
+		if len(add.Annotations) != 0 || len(add.URLs) != 0 || add.MediaType != "" {
+			// Make the overrides visible to consumers of the layer, e.g. so
+			// that remote.Write doesn't upload layers marked as foreign.
+			layer = &describedLayer{Layer: add.Layer, desc: *desc}
+			diffID, err := layer.DiffID()
+			if err != nil {
+				return err
+			}
+			diffIDMap[diffID] = layer
+		}
+
 		manifestLayers = append(manifestLayers, *desc)
-		digestMap[desc.Digest] = add.Layer
+		digestMap[desc.Digest] = layer
 	}
 
 	configFile.RootFS.DiffIDs = diffIDs
 	configFile.History = history
 
 	manifest.Layers = manifestLayers
+	manifest.Annotations = mergeAnnotations(manifest.Annotations, i.annotations, i.deleteAnnotations)
 
-	rcfg, err := json.Marshal(configFile)
-	if err != nil {
-		return err
+	if i.subject != nil {
+		manifest.Subject = i.subject.DeepCopy()
+	}
+	if i.artifactType != nil {
+		manifest.ArtifactType = *i.artifactType
+	}
+	if i.oci() {
+		// Only OCI manifests have a subject or artifactType. As with MediaType,
+		// the OCI media type isn't written to the manifest itself.
+		if strings.Contains(string(manifest.MediaType), types.DockerVendorPrefix) {
+			manifest.MediaType = ""
+		}
+		manifest.Config.MediaType = ociMediaType(manifest.Config.MediaType)
+		for j, desc := range manifest.Layers {
+			mt := ociMediaType(desc.MediaType)
+			if mt == desc.MediaType {
+				continue
+			}
+			layer, ok := digestMap[desc.Digest]
+			if !ok {
+				if layer, err = i.base.LayerByDigest(desc.Digest); err != nil {
+					return err
+				}
+			}
+			desc.MediaType = mt
+			// Make the new media type visible to consumers of the layer too.
+			layer = &describedLayer{Layer: layer, desc: desc}
+			diffID, err := layer.DiffID()
+			if err != nil {
+				return err
+			}
+			diffIDMap[diffID] = layer
+			digestMap[desc.Digest] = layer
+			manifest.Layers[j] = desc
+		}
+	}
+
+	if i.configMediaType != nil {
+		manifest.Config.MediaType = *i.configMediaType
+	}
+
+	var rcfg []byte
+	if manifest.Config.MediaType == types.OCIEmptyJSON {
+		// The empty config is always "{}", so it doesn't record the layers
+		// or history; see Layers.
+		configFile = &v1.ConfigFile{}
+		rcfg = []byte("{}")
+	} else {
+		// An error just means we can't preserve the original bytes.
+		baseConfig, _ := i.base.ConfigFile()
+		if rcfg, err = canonicalBytes(configFile, baseConfig, i.base.RawConfigFile); err != nil {
+			return err
+		}
 	}
 	d, sz, err := v1.SHA256(bytes.NewBuffer(rcfg))
 	if err != nil {
@@ -138,15 +230,44 @@ func (i *image) compute() error {
 			manifest.MediaType = *i.mediaType
 		}
 	}
+	if strings.Contains(string(manifest.MediaType), types.DockerVendorPrefix) {
+		// Docker manifests don't have an artifactType.
+		manifest.ArtifactType = ""
+	}
+
+	rm, err := canonicalBytes(manifest, m, i.base.RawManifest)
+	if err != nil {
+		return err
+	}
 
 	i.configFile = configFile
 	i.manifest = manifest
+	i.rawConfig = rcfg
+	i.rawManifest = rm
 	i.diffIDMap = diffIDMap
 	i.digestMap = digestMap
 	i.computed = true
 	return nil
 }
 
+// canonicalBytes serializes v, a *v1.Manifest, *v1.IndexManifest or
+// *v1.ConfigFile. If v is identical to the parsed base, base's raw bytes are
+// returned as they are, so that mutations which don't change anything don't
+// change the digest either, e.g. of a signed manifest that wasn't serialized
+// the way encoding/json would.
+//
+// Otherwise, v is serialized with encoding/json, which is deterministic:
+// fields are written in the order they are declared, map keys are sorted and
+// there is no insignificant whitespace.
+func canonicalBytes(v, base interface{}, raw func() ([]byte, error)) ([]byte, error) {
+	if reflect.DeepEqual(v, base) {
+		if b, err := raw(); err == nil {
+			return b, nil
+		}
+	}
+	return json.Marshal(v)
+}
+
 // Layers returns the ordered collection of filesystem layers that comprise this image.
 // The order of the list is oldest/base layer first, and most-recent/top layer last.
 func (i *image) Layers() ([]v1.Layer, error) {
@@ -166,6 +287,19 @@ func (i *image) Layers() ([]v1.Layer, error) {
 		return nil, err
 	}
 
+	if i.manifest.Config.MediaType == types.OCIEmptyJSON {
+		// There are no diff ids in the empty config, go by the manifest.
+		ls := make([]v1.Layer, 0, len(i.manifest.Layers))
+		for _, desc := range i.manifest.Layers {
+			l, err := i.LayerByDigest(desc.Digest)
+			if err != nil {
+				return nil, err
+			}
+			ls = append(ls, l)
+		}
+		return ls, nil
+	}
+
 	diffIDs, err := partial.DiffIDs(i)
 	if err != nil {
 		return nil, err
@@ -202,7 +336,7 @@ func (i *image) RawConfigFile() ([]byte, error) {
 	if err := i.compute(); err != nil {
 		return nil, err
 	}
-	return json.Marshal(i.configFile)
+	return i.rawConfig, nil
 }
 
 // Digest returns the sha256 of this image's manifest.
@@ -229,12 +363,12 @@ func (i *image) Manifest() (*v1.Manifest, error) {
 	return i.manifest, nil
 }
 
-// RawManifest returns the serialized bytes of Manifest()
+// RawManifest returns the serialized bytes of Manifest(), see canonicalBytes.
 func (i *image) RawManifest() ([]byte, error) {
 	if err := i.compute(); err != nil {
 		return nil, err
 	}
-	return json.Marshal(i.manifest)
+	return i.rawManifest, nil
 }
 
 // LayerByDigest returns a Layer for interacting with a particular layer of
@@ -268,3 +402,26 @@ func validate(adds []Addendum) error {
 	}
 	return nil
 }
+
+// describedLayer overrides the descriptor of a layer with the fields of an
+// Addendum.
+type describedLayer struct {
+	v1.Layer
+	desc v1.Descriptor
+}
+
+// MediaType implements v1.Layer
+func (l *describedLayer) MediaType() (types.MediaType, error) {
+	return l.desc.MediaType, nil
+}
+
+// Descriptor implements partial.withDescriptor.
+func (l *describedLayer) Descriptor() (*v1.Descriptor, error) {
+	return &l.desc, nil
+}
+
+// Unwrap returns the layer whose descriptor is overridden, so that remote.Write
+// can still mount it from where it was pulled from.
+func (l *describedLayer) Unwrap() v1.Layer {
+	return l.Layer
+}
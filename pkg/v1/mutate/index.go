@@ -15,7 +15,6 @@
 package mutate
 
 import (
-	"encoding/json"
 	"fmt"
 	"strings"
 
@@ -61,12 +60,21 @@ type index struct {
 	// remove is removed before adds
 	remove match.Matcher
 
-	computed  bool
-	manifest  *v1.IndexManifest
-	mediaType *types.MediaType
-	imageMap  map[v1.Hash]v1.Image
-	indexMap  map[v1.Hash]v1.ImageIndex
-	layerMap  map[v1.Hash]v1.Layer
+	computed    bool
+	manifest    *v1.IndexManifest
+	rawManifest []byte
+	mediaType   *types.MediaType
+	imageMap    map[v1.Hash]v1.Image
+	indexMap    map[v1.Hash]v1.ImageIndex
+	layerMap    map[v1.Hash]v1.Layer
+	removed     map[v1.Hash]struct{}
+
+	// See Annotations and DeleteAnnotations.
+	annotations       map[string]string
+	deleteAnnotations []string
+
+	// See IndexSubject.
+	subject *v1.Descriptor
 }
 
 var _ v1.ImageIndex = (*index)(nil)
@@ -75,7 +83,14 @@ func (i *index) MediaType() (types.MediaType, error) {
 	if i.mediaType != nil {
 		return *i.mediaType, nil
 	}
-	return i.base.MediaType()
+	mt, err := i.base.MediaType()
+	if err != nil {
+		return "", err
+	}
+	if i.subject != nil {
+		return ociMediaType(mt), nil
+	}
+	return mt, nil
 }
 
 func (i *index) Size() (int64, error) { return partial.Size(i) }
@@ -89,6 +104,7 @@ func (i *index) compute() error {
 	i.imageMap = make(map[v1.Hash]v1.Image)
 	i.indexMap = make(map[v1.Hash]v1.ImageIndex)
 	i.layerMap = make(map[v1.Hash]v1.Layer)
+	i.removed = make(map[v1.Hash]struct{})
 
 	m, err := i.base.IndexManifest()
 	if err != nil {
@@ -100,10 +116,17 @@ func (i *index) compute() error {
 	if i.remove != nil {
 		var cleanedManifests []v1.Descriptor
 		for _, m := range manifests {
-			if !i.remove(m) {
+			if i.remove(m) {
+				i.removed[m.Digest] = struct{}{}
+			} else {
 				cleanedManifests = append(cleanedManifests, m)
 			}
 		}
+		// The same digest may be listed more than once, so it's only gone
+		// if we've removed every descriptor for it.
+		for _, m := range cleanedManifests {
+			delete(i.removed, m.Digest)
+		}
 		manifests = cleanedManifests
 	}
 
@@ -126,6 +149,16 @@ func (i *index) compute() error {
 	}
 
 	manifest.Manifests = manifests
+	manifest.Annotations = mergeAnnotations(manifest.Annotations, i.annotations, i.deleteAnnotations)
+
+	if i.subject != nil {
+		manifest.Subject = i.subject.DeepCopy()
+		// Only OCI indexes have a subject. As with MediaType, the OCI media
+		// type isn't written to the manifest itself.
+		if strings.Contains(string(manifest.MediaType), types.DockerVendorPrefix) {
+			manifest.MediaType = ""
+		}
+	}
 
 	// With OCI media types, this should not be set, see discussion:
 	// https://github.com/opencontainers/image-spec/pull/795
@@ -137,12 +170,21 @@ func (i *index) compute() error {
 		}
 	}
 
+	rm, err := canonicalBytes(manifest, m, i.base.RawManifest)
+	if err != nil {
+		return err
+	}
+
 	i.manifest = manifest
+	i.rawManifest = rm
 	i.computed = true
 	return nil
 }
 
 func (i *index) Image(h v1.Hash) (v1.Image, error) {
+	if err := i.checkRemoved(h); err != nil {
+		return nil, err
+	}
 	if img, ok := i.imageMap[h]; ok {
 		return img, nil
 	}
@@ -150,12 +192,37 @@ func (i *index) Image(h v1.Hash) (v1.Image, error) {
 }
 
 func (i *index) ImageIndex(h v1.Hash) (v1.ImageIndex, error) {
+	if err := i.checkRemoved(h); err != nil {
+		return nil, err
+	}
 	if idx, ok := i.indexMap[h]; ok {
 		return idx, nil
 	}
 	return i.base.ImageIndex(h)
 }
 
+// checkRemoved returns an error if h has been removed from this index, so we
+// don't hand out children that are no longer referenced.
+func (i *index) checkRemoved(h v1.Hash) error {
+	if i.remove == nil {
+		return nil
+	}
+	if err := i.compute(); err != nil {
+		return err
+	}
+	if _, ok := i.removed[h]; ok {
+		// It may have been added back as an addendum.
+		if _, ok := i.imageMap[h]; ok {
+			return nil
+		}
+		if _, ok := i.indexMap[h]; ok {
+			return nil
+		}
+		return fmt.Errorf("manifest %s was removed from the index", h)
+	}
+	return nil
+}
+
 type withLayer interface {
 	Layer(v1.Hash) (v1.Layer, error)
 }
@@ -187,10 +254,10 @@ func (i *index) IndexManifest() (*v1.IndexManifest, error) {
 	return i.manifest, nil
 }
 
-// RawManifest returns the serialized bytes of Manifest()
+// RawManifest returns the serialized bytes of Manifest(), see canonicalBytes.
 func (i *index) RawManifest() ([]byte, error) {
 	if err := i.compute(); err != nil {
 		return nil, err
 	}
-	return json.Marshal(i.manifest)
+	return i.rawManifest, nil
 }
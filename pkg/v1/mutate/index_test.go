@@ -15,13 +15,16 @@
 package mutate_test
 
 import (
+	"encoding/json"
 	"log"
 	"strings"
 	"testing"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/match"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
 	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/google/go-containerregistry/pkg/v1/validate"
@@ -137,3 +140,109 @@ func TestAppendIndex(t *testing.T) {
 		t.Errorf("Validate() = %v", err)
 	}
 }
+
+// annotatedIndex adds an annotation to its IndexManifest.
+type annotatedIndex struct {
+	base v1.ImageIndex
+}
+
+func (a annotatedIndex) MediaType() (types.MediaType, error)         { return a.base.MediaType() }
+func (a annotatedIndex) Digest() (v1.Hash, error)                    { return partial.Digest(a) }
+func (a annotatedIndex) Size() (int64, error)                        { return partial.Size(a) }
+func (a annotatedIndex) Image(h v1.Hash) (v1.Image, error)           { return a.base.Image(h) }
+func (a annotatedIndex) ImageIndex(h v1.Hash) (v1.ImageIndex, error) { return a.base.ImageIndex(h) }
+
+func (a annotatedIndex) RawManifest() ([]byte, error) {
+	im, err := a.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(im)
+}
+
+func (a annotatedIndex) IndexManifest() (*v1.IndexManifest, error) {
+	im, err := a.base.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	im = im.DeepCopy()
+	im.Annotations = map[string]string{"foo": "bar"}
+	return im, nil
+}
+
+func TestFilterIndex(t *testing.T) {
+	platforms := []v1.Platform{
+		{OS: "linux", Architecture: "amd64"},
+		{OS: "linux", Architecture: "arm64"},
+		{OS: "windows", Architecture: "amd64"},
+		{OS: "linux", Architecture: "s390x"},
+	}
+	var adds []mutate.IndexAddendum
+	for i := range platforms {
+		img, err := random.Image(100, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		adds = append(adds, mutate.IndexAddendum{
+			Add: img,
+			Descriptor: v1.Descriptor{
+				Platform: &platforms[i],
+			},
+		})
+	}
+	base := mutate.AppendManifests(annotatedIndex{empty.Index}, adds...)
+
+	filtered := mutate.FilterIndex(base, match.Platforms(platforms[0], platforms[1]))
+	if err := validate.Index(filtered); err != nil {
+		t.Errorf("validate.Index() = %v", err)
+	}
+
+	im, err := filtered.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(im.Manifests) != 2 {
+		t.Fatalf("got %d manifests, want 2", len(im.Manifests))
+	}
+	for i, desc := range im.Manifests {
+		if desc.Platform.Equals(platforms[0]) || desc.Platform.Equals(platforms[1]) {
+			continue
+		}
+		t.Errorf("manifest %d has unexpected platform %v", i, desc.Platform)
+	}
+	if got := im.Annotations["foo"]; got != "bar" {
+		t.Errorf("annotation foo = %q, want %q", got, "bar")
+	}
+
+	bm, err := base.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	windows := bm.Manifests[2].Digest
+	if _, err := filtered.Image(windows); err == nil {
+		t.Errorf("Image(%s) = nil, want error for removed manifest", windows)
+	}
+	if _, err := filtered.Image(im.Manifests[0].Digest); err != nil {
+		t.Errorf("Image(%s) = %v", im.Manifests[0].Digest, err)
+	}
+}
+
+func TestIndexAnnotations(t *testing.T) {
+	base, err := random.Index(1024, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := mutate.Annotations(base, map[string]string{"foo": "bar", "baz": "quux"}).(v1.ImageIndex)
+	idx = mutate.DeleteAnnotations(idx, []string{"baz"}).(v1.ImageIndex)
+
+	m, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Annotations) != 1 || m.Annotations["foo"] != "bar" {
+		t.Errorf("Annotations = %v, want only foo=bar", m.Annotations)
+	}
+	if err := validate.Index(idx); err != nil {
+		t.Errorf("validate.Index() = %v", err)
+	}
+}
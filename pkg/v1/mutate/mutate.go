@@ -21,21 +21,32 @@ import (
 	"io"
 	"io/ioutil"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/google/go-containerregistry/pkg/logs"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
-	"github.com/google/go-containerregistry/pkg/v1/internal/gzip"
 	"github.com/google/go-containerregistry/pkg/v1/match"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
 	"github.com/google/go-containerregistry/pkg/v1/tarball"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 )
 
 const whiteoutPrefix = ".wh."
 
+// opaqueWhiteout marks its directory as opaque, hiding the contents of that
+// directory in lower layers. It is ".wh..wh..opq" with whiteoutPrefix removed.
+const opaqueWhiteout = ".wh..opq"
+
 // Addendum contains layers and history to be appended
-// to a base image
+// to a base image.
+//
+// URLs, Annotations and MediaType override the layer's descriptor, and are
+// reflected by the layer returned from the resulting image. For example, to
+// append a foreign layer that remote.Write won't upload, set MediaType to
+// types.DockerForeignLayer and URLs to where the layer can be fetched from.
 type Addendum struct {
 	Layer       v1.Layer
 	History     v1.History
@@ -45,16 +56,69 @@ type Addendum struct {
 }
 
 // AppendLayers applies layers to a base image.
+//
+// The layers are appended as they are, so their compressed form is whatever
+// they were created with. To recompress them, see AppendLayersWithOptions.
 func AppendLayers(base v1.Image, layers ...v1.Layer) (v1.Image, error) {
+	return AppendLayersWithOptions(base, layers)
+}
+
+// AppendLayersWithOptions is AppendLayers with options. With
+// WithCompressionLevel, gzip layers are recompressed at that level before they
+// are appended, which changes their digests (but not their DiffIDs). Other
+// layers, and non-distributable layers, are appended as they are.
+//
+// With WithStrippedXattrs, every layer is rewritten without the matching
+// xattrs instead, and compressed with gzip at the level given by
+// WithCompressionLevel. This changes their DiffIDs too.
+func AppendLayersWithOptions(base v1.Image, layers []v1.Layer, opts ...Option) (v1.Image, error) {
+	o, err := makeOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
 	additions := make([]Addendum, 0, len(layers))
 	for _, layer := range layers {
+		switch {
+		case len(o.xattrs) != 0:
+			if layer, err = rewriteLayer(layer, o, func(*tar.Header) {}); err != nil {
+				return nil, fmt.Errorf("stripping xattrs: %w", err)
+			}
+		case o.recompress:
+			if layer, err = o.recompressed(layer); err != nil {
+				return nil, err
+			}
+		}
 		additions = append(additions, Addendum{Layer: layer})
 	}
 
 	return Append(base, additions...)
 }
 
-// Append will apply the list of addendums to the base image
+// AppendLayersWithHistory applies layers to a base image, pairing each layer
+// with the history entry at the same index. EmptyLayer is cleared on each of
+// the history entries, since they describe a layer. To record config-only
+// changes, use Append with an Addendum that has no Layer and sets
+// History.EmptyLayer.
+func AppendLayersWithHistory(base v1.Image, layers []v1.Layer, history []v1.History) (v1.Image, error) {
+	if len(layers) != len(history) {
+		return nil, fmt.Errorf("mismatched layers and history: len(layers)=%d, len(history)=%d", len(layers), len(history))
+	}
+	additions := make([]Addendum, 0, len(layers))
+	for i, layer := range layers {
+		h := history[i]
+		h.EmptyLayer = false
+		additions = append(additions, Addendum{Layer: layer, History: h})
+	}
+
+	return Append(base, additions...)
+}
+
+// Append will apply the list of addendums to the base image.
+//
+// Each Addendum contributes its History to the config, so this is the way to
+// attach created_by or comment entries to appended layers. An Addendum with a
+// nil Layer adds only its History, which must have EmptyLayer set.
 func Append(base v1.Image, adds ...Addendum) (v1.Image, error) {
 	if len(adds) == 0 {
 		return base, nil
@@ -101,6 +165,18 @@ func RemoveManifests(base v1.ImageIndex, matcher match.Matcher) v1.ImageIndex {
 	}
 }
 
+// FilterIndex returns an index containing only the manifests of base whose
+// descriptors satisfy keep, e.g. match.Platforms. Everything else about the
+// index, such as its annotations, is preserved.
+//
+// Children that were filtered out can no longer be read through the returned
+// index's Image and ImageIndex methods.
+func FilterIndex(base v1.ImageIndex, keep match.Matcher) v1.ImageIndex {
+	return RemoveManifests(base, func(desc v1.Descriptor) bool {
+		return !keep(desc)
+	})
+}
+
 // Config mutates the provided v1.Image to have the provided v1.Config
 func Config(base v1.Image, cfg v1.Config) (v1.Image, error) {
 	cf, err := base.ConfigFile()
@@ -142,12 +218,256 @@ func CreatedAt(base v1.Image, created v1.Time) (v1.Image, error) {
 	return ConfigFile(base, cfg)
 }
 
+// SetLabel returns base with the label key set to value in its config,
+// leaving the other labels as they are.
+func SetLabel(base v1.Image, key, value string) (v1.Image, error) {
+	cf, err := base.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := cf.DeepCopy()
+	if cfg.Config.Labels == nil {
+		cfg.Config.Labels = map[string]string{}
+	}
+	cfg.Config.Labels[key] = value
+
+	return ConfigFile(base, cfg)
+}
+
+// DeleteLabel returns base with the label key removed from its config. If
+// the label isn't set, base is returned unchanged.
+func DeleteLabel(base v1.Image, key string) (v1.Image, error) {
+	cf, err := base.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := cf.Config.Labels[key]; !ok {
+		return base, nil
+	}
+
+	cfg := cf.DeepCopy()
+	delete(cfg.Config.Labels, key)
+
+	return ConfigFile(base, cfg)
+}
+
+// ExposePort returns base with port, e.g. "8080/tcp", added to the exposed
+// ports in its config. As with the EXPOSE instruction of a Dockerfile, a port
+// without a protocol is exposed over tcp.
+func ExposePort(base v1.Image, port string) (v1.Image, error) {
+	return editConfig(base, func(cfg *v1.Config) {
+		if cfg.ExposedPorts == nil {
+			cfg.ExposedPorts = map[string]struct{}{}
+		}
+		cfg.ExposedPorts[portWithProtocol(port)] = struct{}{}
+	})
+}
+
+// DeleteExposedPort returns base with port removed from the exposed ports in
+// its config, see ExposePort. If the port isn't exposed, base is returned
+// unchanged.
+func DeleteExposedPort(base v1.Image, port string) (v1.Image, error) {
+	cf, err := base.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	port = portWithProtocol(port)
+	if _, ok := cf.Config.ExposedPorts[port]; !ok {
+		return base, nil
+	}
+	return editConfig(base, func(cfg *v1.Config) {
+		delete(cfg.ExposedPorts, port)
+	})
+}
+
+func portWithProtocol(port string) string {
+	if !strings.Contains(port, "/") {
+		return port + "/tcp"
+	}
+	return port
+}
+
+// AddVolume returns base with path added to the volumes in its config.
+func AddVolume(base v1.Image, path string) (v1.Image, error) {
+	return editConfig(base, func(cfg *v1.Config) {
+		if cfg.Volumes == nil {
+			cfg.Volumes = map[string]struct{}{}
+		}
+		cfg.Volumes[path] = struct{}{}
+	})
+}
+
+// DeleteVolume returns base with path removed from the volumes in its config.
+// If there is no such volume, base is returned unchanged.
+func DeleteVolume(base v1.Image, path string) (v1.Image, error) {
+	cf, err := base.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := cf.Config.Volumes[path]; !ok {
+		return base, nil
+	}
+	return editConfig(base, func(cfg *v1.Config) {
+		delete(cfg.Volumes, path)
+	})
+}
+
+// WorkingDir returns base with the working directory in its config set to
+// dir. An empty dir clears it.
+func WorkingDir(base v1.Image, dir string) (v1.Image, error) {
+	return editConfig(base, func(cfg *v1.Config) {
+		cfg.WorkingDir = dir
+	})
+}
+
+// Env returns base with the KEY=value pairs in env set in the environment of
+// its config. A key that is already set is overridden in place rather than
+// duplicated; new keys are appended in the order they are given.
+func Env(base v1.Image, env ...string) (v1.Image, error) {
+	return editConfig(base, func(cfg *v1.Config) {
+		index := make(map[string]int, len(cfg.Env))
+		for i, e := range cfg.Env {
+			index[envName(e)] = i
+		}
+		for _, e := range env {
+			k := envName(e)
+			if i, ok := index[k]; ok {
+				cfg.Env[i] = e
+				continue
+			}
+			index[k] = len(cfg.Env)
+			cfg.Env = append(cfg.Env, e)
+		}
+	})
+}
+
+// Entrypoint returns base with the entrypoint in its config set to
+// entrypoint. Unlike the ENTRYPOINT instruction of a Dockerfile, this leaves
+// the Cmd as it is, see Cmd. A nil entrypoint clears it.
+func Entrypoint(base v1.Image, entrypoint []string) (v1.Image, error) {
+	return editConfig(base, func(cfg *v1.Config) {
+		cfg.Entrypoint = append([]string(nil), entrypoint...)
+	})
+}
+
+// Cmd returns base with the command in its config set to cmd. A nil cmd
+// clears it.
+func Cmd(base v1.Image, cmd []string) (v1.Image, error) {
+	return editConfig(base, func(cfg *v1.Config) {
+		cfg.Cmd = append([]string(nil), cmd...)
+	})
+}
+
+// editConfig returns base with edit applied to a copy of its config.
+func editConfig(base v1.Image, edit func(*v1.Config)) (v1.Image, error) {
+	cf, err := base.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := cf.DeepCopy()
+	edit(&cfg.Config)
+
+	return ConfigFile(base, cfg)
+}
+
+// History returns base with the history in its config replaced by history,
+// e.g. to redact commands that leaked secrets. Only the config changes, the
+// layers are left as they are.
+//
+// Unless history is empty, it must have one entry that isn't an EmptyLayer
+// for each layer of base.
+func History(base v1.Image, history []v1.History) (v1.Image, error) {
+	cf, err := base.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(history) != 0 {
+		layers := 0
+		for _, h := range history {
+			if !h.EmptyLayer {
+				layers++
+			}
+		}
+		if want := len(cf.RootFS.DiffIDs); layers != want {
+			return nil, fmt.Errorf("history has %d non-empty entries, image has %d layers", layers, want)
+		}
+	}
+
+	cfg := cf.DeepCopy()
+	cfg.History = append([]v1.History{}, history...)
+
+	return ConfigFile(base, cfg)
+}
+
+// Annotations merges anns into the annotations of the manifest of f, which
+// must be a v1.Image or v1.ImageIndex. Keys in anns override existing ones,
+// and unrelated keys are preserved, so annotations can be accumulated across
+// several calls. See DeleteAnnotations to remove keys.
+//
+// For other types, f is returned unchanged.
+func Annotations(f partial.WithRawManifest, anns map[string]string) partial.WithRawManifest {
+	switch t := f.(type) {
+	case v1.Image:
+		return &image{base: t, annotations: anns}
+	case v1.ImageIndex:
+		return &index{base: t, annotations: anns}
+	}
+	logs.Warn.Printf("Unexpected type for Annotations: %T", f)
+	return f
+}
+
+// DeleteAnnotations removes keys from the annotations of the manifest of f,
+// which must be a v1.Image or v1.ImageIndex. Keys that aren't set are
+// ignored.
+//
+// For other types, f is returned unchanged.
+func DeleteAnnotations(f partial.WithRawManifest, keys []string) partial.WithRawManifest {
+	switch t := f.(type) {
+	case v1.Image:
+		return &image{base: t, deleteAnnotations: keys}
+	case v1.ImageIndex:
+		return &index{base: t, deleteAnnotations: keys}
+	}
+	logs.Warn.Printf("Unexpected type for DeleteAnnotations: %T", f)
+	return f
+}
+
+// mergeAnnotations returns cur with set added and remove removed, or nil if
+// nothing is left.
+func mergeAnnotations(cur, set map[string]string, remove []string) map[string]string {
+	if len(set) == 0 && len(remove) == 0 {
+		return cur
+	}
+	merged := make(map[string]string, len(cur)+len(set))
+	for k, v := range cur {
+		merged[k] = v
+	}
+	for k, v := range set {
+		merged[k] = v
+	}
+	for _, k := range remove {
+		delete(merged, k)
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
+
 // Extract takes an image and returns an io.ReadCloser containing the image's
 // flattened filesystem.
 //
 // Callers can read the filesystem contents by passing the reader to
 // tar.NewReader, or io.Copy it directly to some output.
 //
+// Hardlinks are preserved, and written after the file they link to. If that
+// file has been deleted or replaced by a later layer, the hardlink becomes a
+// regular file with the contents it had in its own layer. Sparse files are
+// written out in full, since archive/tar can't write sparse entries.
+//
 // If a caller doesn't read the full contents, they should Close it to free up
 // resources used during extraction.
 func Extract(img v1.Image) io.ReadCloser {
@@ -164,12 +484,23 @@ func Extract(img v1.Image) io.ReadCloser {
 	return pr
 }
 
+// pendingLink is a hardlink whose target hasn't been written yet, or not with
+// the contents it had in the link's layer.
+type pendingLink struct {
+	layer  int
+	header *tar.Header
+}
+
 // Adapted from https://github.com/google/containerregistry/blob/da03b395ccdc4e149e34fbb540483efce962dc64/client/v2_2/docker_image_.py#L816
 func extract(img v1.Image, w io.Writer) error {
 	tarWriter := tar.NewWriter(w)
 	defer tarWriter.Close()
 
 	fileMap := map[string]bool{}
+	opaqueDirs := map[string]bool{}
+	// Maps the files we've written to the index of the layer they came from.
+	written := map[string]int{}
+	var pending []pendingLink
 
 	layers, err := img.Layers()
 	if err != nil {
@@ -186,6 +517,9 @@ func extract(img v1.Image, w io.Writer) error {
 		}
 		defer layerReader.Close()
 		tarReader := tar.NewReader(layerReader)
+		// Opaque directories only hide lower layers, not this one, so wait
+		// until we're done with this layer before honoring them.
+		var layerOpaqueDirs []string
 		for {
 			header, err := tarReader.Next()
 			if err == io.EOF {
@@ -200,6 +534,10 @@ func extract(img v1.Image, w io.Writer) error {
 			tombstone := strings.HasPrefix(basename, whiteoutPrefix)
 			if tombstone {
 				basename = basename[len(whiteoutPrefix):]
+				if basename == opaqueWhiteout {
+					layerOpaqueDirs = append(layerOpaqueDirs, dirname)
+					continue
+				}
 			}
 
 			// check if we have seen value before
@@ -220,22 +558,158 @@ func extract(img v1.Image, w io.Writer) error {
 				continue
 			}
 
+			// check for an opaque parent directory from a higher layer
+			if inOpaqueDir(opaqueDirs, name) {
+				continue
+			}
+
 			// mark file as handled. non-directory implicitly tombstones
 			// any entries with a matching (or child) name
 			fileMap[name] = tombstone || !(header.Typeflag == tar.TypeDir)
-			if !tombstone {
-				tarWriter.WriteHeader(header)
-				if header.Size > 0 {
-					if _, err := io.Copy(tarWriter, tarReader); err != nil {
-						return err
-					}
+			if tombstone {
+				continue
+			}
+			if header.Typeflag == tar.TypeLink {
+				// We can only link to the target if we've already written
+				// it from this layer; otherwise, wait until we know whether
+				// it's visible at all.
+				if l, ok := written[cleanName(header.Linkname)]; !ok || l != i {
+					pending = append(pending, pendingLink{layer: i, header: header})
+					continue
 				}
 			}
+			if err := writeEntry(tarWriter, header, tarReader); err != nil {
+				return err
+			}
+			if header.Typeflag != tar.TypeDir {
+				written[cleanName(name)] = i
+			}
+		}
+		for _, dir := range layerOpaqueDirs {
+			opaqueDirs[dir] = true
+		}
+	}
+
+	for _, link := range pending {
+		if l, ok := written[cleanName(link.header.Linkname)]; ok && l <= link.layer {
+			// The target is visible with the same contents, so it's
+			// safe to link to it now that it has been written.
+			if err := tarWriter.WriteHeader(link.header); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := materializeLink(tarWriter, layers, link); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
+// writeEntry writes header and its contents from r to tw.
+func writeEntry(tw *tar.Writer, header *tar.Header, r io.Reader) error {
+	if header.Typeflag == tar.TypeGNUSparse {
+		// archive/tar expands sparse files when reading them, but would
+		// write the sparse header back without a sparse map.
+		header.Typeflag = tar.TypeReg
+	}
+	for k := range header.PAXRecords {
+		if strings.HasPrefix(k, "GNU.sparse.") {
+			delete(header.PAXRecords, k)
+		}
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if header.Size > 0 {
+		if _, err := io.Copy(tw, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// materializeLink writes link as a copy of the file it links to, as of the
+// link's layer, because that file has since been deleted or replaced.
+func materializeLink(tw *tar.Writer, layers []v1.Layer, link pendingLink) error {
+	target := cleanName(link.header.Linkname)
+	for i := link.layer; i >= 0; i-- {
+		found, hidden, err := copyLinkTarget(tw, layers[i], target, link.header.Name)
+		if err != nil {
+			return err
+		}
+		if found {
+			return nil
+		}
+		if hidden {
+			break
+		}
+	}
+	return fmt.Errorf("hardlink %s points to missing file %s", link.header.Name, link.header.Linkname)
+}
+
+// copyLinkTarget looks for target in layer, writing it to tw as name if it is
+// found. Otherwise, it reports whether the layer hides target in lower layers.
+func copyLinkTarget(tw *tar.Writer, layer v1.Layer, target, name string) (found, hidden bool, err error) {
+	rc, err := layer.Uncompressed()
+	if err != nil {
+		return false, false, fmt.Errorf("reading layer contents: %v", err)
+	}
+	defer rc.Close()
+
+	tr := tar.NewReader(rc)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return false, hidden, nil
+		}
+		if err != nil {
+			return false, false, fmt.Errorf("reading tar: %v", err)
+		}
+
+		entry := cleanName(header.Name)
+		base, dir := filepath.Base(entry), filepath.Dir(entry)
+		if strings.HasPrefix(base, whiteoutPrefix) {
+			deleted := filepath.Join(dir, base[len(whiteoutPrefix):])
+			if base[len(whiteoutPrefix):] == opaqueWhiteout {
+				deleted = dir
+			}
+			// An opaque root directory hides everything.
+			if target == deleted || deleted == "." || strings.HasPrefix(target, deleted+"/") {
+				hidden = true
+			}
+			continue
+		}
+		if entry != target {
+			continue
+		}
+		if header.Typeflag == tar.TypeLink {
+			// Chains of hardlinks aren't worth the complexity.
+			return false, false, fmt.Errorf("hardlink %s points to another hardlink %s", name, header.Name)
+		}
+		header.Name = name
+		return true, false, writeEntry(tw, header, tr)
+	}
+}
+
+// cleanName normalizes tar entry names like "./etc/hosts" and "/etc/hosts" to
+// "etc/hosts", so that they can be compared with hardlink targets.
+func cleanName(name string) string {
+	return strings.TrimPrefix(filepath.Clean("/"+name), "/")
+}
+
+// inOpaqueDir returns true if file is beneath one of the opaque directories.
+func inOpaqueDir(opaqueDirs map[string]bool, file string) bool {
+	for dir := filepath.Dir(filepath.Clean(file)); ; dir = filepath.Dir(dir) {
+		if opaqueDirs[dir] {
+			return true
+		}
+		if dir == "." || dir == "/" {
+			return false
+		}
+	}
+}
+
 func inWhiteoutDir(fileMap map[string]bool, file string) bool {
 	for {
 		if file == "" {
@@ -253,8 +727,95 @@ func inWhiteoutDir(fileMap map[string]bool, file string) bool {
 	return false
 }
 
+// Squash flattens all of img's layers into a single layer, resolving
+// whiteouts and opaque directories so that deleted files don't reappear.
+//
+// The config is preserved, except that its rootfs.diff_ids and history are
+// replaced with a single entry for the squashed layer, which is compressed
+// at the level given by WithCompressionLevel. An OCI image stays OCI, and
+// anything else becomes a Docker image. Xattrs can be stripped from the
+// squashed layer, see WithStrippedXattrs.
+func Squash(img v1.Image, opts ...Option) (v1.Image, error) {
+	o, err := makeOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	ocf, err := img.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("getting original config file: %w", err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, fmt.Errorf("getting image layers: %w", err)
+	}
+
+	opener := func() (io.ReadCloser, error) {
+		rc := Extract(img)
+		if len(o.xattrs) == 0 {
+			return rc, nil
+		}
+		pr, pw := io.Pipe()
+		go func() {
+			defer rc.Close()
+			pw.CloseWithError(rewriteTar(pw, rc, o, func(*tar.Header) {}))
+		}()
+		return pr, nil
+	}
+	layer, err := tarball.LayerFromOpener(opener, tarball.WithCompressionLevel(o.compression))
+	if err != nil {
+		return nil, fmt.Errorf("creating squashed layer: %w", err)
+	}
+
+	mt, err := img.MediaType()
+	if err != nil {
+		return nil, fmt.Errorf("getting original media type: %w", err)
+	}
+
+	cfg := ocf.DeepCopy()
+	cfg.RootFS.DiffIDs = []v1.Hash{}
+	cfg.History = []v1.History{}
+	base, err := ConfigFile(empty.Image, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	add := Addendum{
+		Layer: layer,
+		History: v1.History{
+			Created: ocf.Created,
+			Comment: fmt.Sprintf("squashed %d layers", len(layers)),
+		},
+	}
+	// empty.Image and the squashed layer use Docker media types, so switch
+	// them to OCI ones for an OCI image.
+	if mt == types.OCIManifestSchema1 {
+		base = MediaType(ConfigMediaType(base, ociMediaType(types.DockerConfigJSON)), mt)
+		add.MediaType = ociMediaType(types.DockerLayer)
+	}
+
+	return Append(base, add)
+}
+
 // Time sets all timestamps in an image to the given timestamp.
+//
+// This includes the creation time in the config and its history, as well as
+// the modification, access and change times of every entry in every layer,
+// so that images built from the same contents have the same digest. File
+// contents, ownership and permissions are preserved.
 func Time(img v1.Image, t time.Time) (v1.Image, error) {
+	return TimeWithOptions(img, t)
+}
+
+// TimeWithOptions is Time with options. Since every layer is rewritten, it is
+// also recompressed, see WithCompressionLevel, and xattrs can be stripped, see
+// WithStrippedXattrs.
+func TimeWithOptions(img v1.Image, t time.Time, opts ...Option) (v1.Image, error) {
+	o, err := makeOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
 	newImage := empty.Image
 
 	layers, err := img.Layers()
@@ -265,7 +826,9 @@ func Time(img v1.Image, t time.Time) (v1.Image, error) {
 	// Strip away all timestamps from layers
 	var newLayers []v1.Layer
 	for _, layer := range layers {
-		newLayer, err := layerTime(layer, t)
+		newLayer, err := rewriteLayer(layer, o, func(header *tar.Header) {
+			setHeaderTime(header, t)
+		})
 		if err != nil {
 			return nil, fmt.Errorf("setting layer times: %v", err)
 		}
@@ -298,68 +861,175 @@ func Time(img v1.Image, t time.Time) (v1.Image, error) {
 	// Strip away timestamps from the config file
 	cfg.Created = v1.Time{Time: t}
 
-	for _, h := range cfg.History {
-		h.Created = v1.Time{Time: t}
+	for i := range cfg.History {
+		cfg.History[i].Created = v1.Time{Time: t}
+	}
+
+	return ConfigFile(newImage, cfg)
+}
+
+// setHeaderTime sets every timestamp in header to t, leaving everything else
+// about the entry untouched. Access and change times are only set if they
+// were present, since adding them would change the format of the header.
+func setHeaderTime(header *tar.Header, t time.Time) {
+	header.ModTime = t
+	if !header.AccessTime.IsZero() {
+		header.AccessTime = t
+	}
+	if !header.ChangeTime.IsZero() {
+		header.ChangeTime = t
+	}
+	// Don't let stale PAX records carry the old times through.
+	for _, k := range []string{"mtime", "atime", "ctime"} {
+		delete(header.PAXRecords, k)
+	}
+}
+
+// NormalizeOwnership sets the owner of every entry in every layer of img to
+// uid and gid, and clears the user and group names, so that images built on
+// machines with different users have the same digest. Extended attributes are
+// preserved unless they are stripped with WithStrippedXattrs.
+//
+// The config is preserved, except that its rootfs.diff_ids are updated to
+// match the rewritten layers, see WithCompressionLevel.
+func NormalizeOwnership(img v1.Image, uid, gid int, opts ...Option) (v1.Image, error) {
+	o, err := makeOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, fmt.Errorf("getting image layers: %v", err)
+	}
+
+	var newLayers []v1.Layer
+	for _, layer := range layers {
+		newLayer, err := rewriteLayer(layer, o, func(header *tar.Header) {
+			setHeaderOwner(header, uid, gid)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("setting layer ownership: %v", err)
+		}
+		newLayers = append(newLayers, newLayer)
+	}
+
+	newImage, err := AppendLayers(empty.Image, newLayers...)
+	if err != nil {
+		return nil, fmt.Errorf("appending layers: %v", err)
+	}
+
+	ocf, err := img.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("getting original config file: %v", err)
+	}
+	cf, err := newImage.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("setting config file: %v", err)
 	}
 
+	cfg := ocf.DeepCopy()
+	cfg.RootFS = cf.RootFS
 	return ConfigFile(newImage, cfg)
 }
 
-func layerTime(layer v1.Layer, t time.Time) (v1.Layer, error) {
+// setHeaderOwner sets the owner of header to uid and gid, dropping the user
+// and group names.
+func setHeaderOwner(header *tar.Header, uid, gid int) {
+	header.Uid = uid
+	header.Gid = gid
+	header.Uname = ""
+	header.Gname = ""
+	for _, k := range []string{"uid", "gid", "uname", "gname"} {
+		delete(header.PAXRecords, k)
+	}
+}
+
+// rewriteLayer returns a copy of layer with edit applied to the header of
+// every entry, and the xattrs matching WithStrippedXattrs removed.
+func rewriteLayer(layer v1.Layer, o *options, edit func(*tar.Header)) (v1.Layer, error) {
 	layerReader, err := layer.Uncompressed()
 	if err != nil {
 		return nil, fmt.Errorf("getting layer: %v", err)
 	}
 	defer layerReader.Close()
 	w := new(bytes.Buffer)
+	if err := rewriteTar(w, layerReader, o, edit); err != nil {
+		return nil, err
+	}
+
+	b := w.Bytes()
+	// tarball gzips the contents at the given level.
+	opener := func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	}
+	layer, err = tarball.LayerFromOpener(opener, tarball.WithCompressionLevel(o.compression))
+	if err != nil {
+		return nil, fmt.Errorf("creating layer: %v", err)
+	}
+
+	return layer, nil
+}
+
+// rewriteTar copies the tarball in r to w, applying edit to the header of
+// every entry and removing the xattrs matching WithStrippedXattrs.
+func rewriteTar(w io.Writer, r io.Reader, o *options, edit func(*tar.Header)) error {
 	tarWriter := tar.NewWriter(w)
 	defer tarWriter.Close()
 
-	tarReader := tar.NewReader(layerReader)
+	tarReader := tar.NewReader(r)
 	for {
 		header, err := tarReader.Next()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return nil, fmt.Errorf("reading layer: %v", err)
+			return fmt.Errorf("reading layer: %v", err)
 		}
 
-		header.ModTime = t
+		edit(header)
+		o.stripXattrs(header)
 		if err := tarWriter.WriteHeader(header); err != nil {
-			return nil, fmt.Errorf("writing tar header: %v", err)
+			return fmt.Errorf("writing tar header: %v", err)
 		}
 
-		if header.Typeflag == tar.TypeReg {
-			if _, err = io.Copy(tarWriter, tarReader); err != nil {
-				return nil, fmt.Errorf("writing layer file: %v", err)
-			}
+		// This is a no-op for entries without contents.
+		if _, err = io.Copy(tarWriter, tarReader); err != nil {
+			return fmt.Errorf("writing layer file: %v", err)
 		}
 	}
 
-	if err := tarWriter.Close(); err != nil {
-		return nil, err
-	}
-
-	b := w.Bytes()
-	// gzip the contents, then create the layer
-	opener := func() (io.ReadCloser, error) {
-		return gzip.ReadCloser(ioutil.NopCloser(bytes.NewReader(b))), nil
-	}
-	layer, err = tarball.LayerFromOpener(opener)
-	if err != nil {
-		return nil, fmt.Errorf("creating layer: %v", err)
-	}
-
-	return layer, nil
+	return tarWriter.Close()
 }
 
-// Canonical is a helper function to combine Time and configFile
-// to remove any randomness during a docker build.
+// Canonical normalizes img so that functionally equivalent images have the
+// same config and manifest digests, regardless of when or where they were
+// built. Specifically, it:
+//
+//   - sets every timestamp to the zero time.Time: the config's created time,
+//     the created time of every history entry, and the modification times of
+//     every entry in every layer, see Time;
+//   - replaces the history with one empty entry per layer, dropping the
+//     commands, comments and authors of the original build, and drops the
+//     config's author;
+//   - clears the container, docker_version and config.Hostname fields, and
+//     drops container_config, which isn't part of v1.ConfigFile;
+//   - sorts config.Env by variable name, keeping the relative order of
+//     duplicate variables, since the last one wins.
+//
+// The architecture, OS, OS version and the rest of config (including the
+// labels, whose keys are always serialized in sorted order) are preserved,
+// as are the contents, ownership and permissions of every file.
 func Canonical(img v1.Image) (v1.Image, error) {
+	return CanonicalWithOptions(img)
+}
+
+// CanonicalWithOptions is Canonical with options. Every layer is rewritten,
+// see WithCompressionLevel and WithStrippedXattrs.
+func CanonicalWithOptions(img v1.Image, opts ...Option) (v1.Image, error) {
 	// Set all timestamps to 0
 	created := time.Time{}
-	img, err := Time(img, created)
+	img, err := TimeWithOptions(img, created, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -376,9 +1046,20 @@ func Canonical(img v1.Image) (v1.Image, error) {
 	cfg.Config.Hostname = ""
 	cfg.DockerVersion = ""
 
+	// Make the order of environment variables deterministic.
+	sort.SliceStable(cfg.Config.Env, func(i, j int) bool {
+		return envName(cfg.Config.Env[i]) < envName(cfg.Config.Env[j])
+	})
+
 	return ConfigFile(img, cfg)
 }
 
+// envName returns the name of the variable in an environment entry of the
+// form NAME=value.
+func envName(env string) string {
+	return strings.SplitN(env, "=", 2)[0]
+}
+
 // MediaType modifies the MediaType() of the given image.
 func MediaType(img v1.Image, mt types.MediaType) v1.Image {
 	return &image{
@@ -387,6 +1068,72 @@ func MediaType(img v1.Image, mt types.MediaType) v1.Image {
 	}
 }
 
+// ConfigMediaType sets the media type of the config descriptor in the manifest
+// of img, e.g. to the type of an artifact. With types.OCIEmptyJSON, the config
+// is also replaced with the empty config, "{}", see empty.Artifact.
+func ConfigMediaType(img v1.Image, mt types.MediaType) v1.Image {
+	return &image{
+		base:            img,
+		configMediaType: &mt,
+	}
+}
+
+// Subject sets the subject of the manifest of img, the descriptor of the
+// manifest it refers to, so that registries list img as one of its
+// referrers, e.g. for signatures or SBOMs. Only OCI manifests have a subject,
+// so Docker media types of the manifest, config and layers are replaced with
+// their OCI equivalents.
+func Subject(img v1.Image, subject v1.Descriptor) v1.Image {
+	return &image{
+		base:    img,
+		subject: &subject,
+	}
+}
+
+// ArtifactType sets the artifactType of the manifest of img, which registries
+// use to classify it, e.g. in the referrers index of its subject. As with
+// Subject, Docker media types are replaced with their OCI equivalents, since
+// only OCI manifests have an artifactType. An empty t removes it.
+func ArtifactType(img v1.Image, t string) v1.Image {
+	return &image{
+		base:         img,
+		artifactType: &t,
+	}
+}
+
+// IndexSubject sets the subject of the manifest of idx, see Subject.
+//
+// Only OCI image indexes have a subject, so a Docker manifest list becomes an
+// OCI image index, but its children are not converted: they, and their
+// descriptors in the index, keep their Docker media types, since converting a
+// child would change its digest. The result is an OCI index of Docker
+// manifests, which registries accept but which isn't pure OCI. To get that,
+// convert the children and add them to a new index before calling IndexSubject.
+func IndexSubject(idx v1.ImageIndex, subject v1.Descriptor) v1.ImageIndex {
+	return &index{
+		base:    idx,
+		subject: &subject,
+	}
+}
+
+// ociMediaTypes maps Docker media types to their OCI equivalents.
+var ociMediaTypes = map[types.MediaType]types.MediaType{
+	types.DockerManifestSchema2:   types.OCIManifestSchema1,
+	types.DockerManifestList:      types.OCIImageIndex,
+	types.DockerConfigJSON:        types.OCIConfigJSON,
+	types.DockerLayer:             types.OCILayer,
+	types.DockerForeignLayer:      types.OCIRestrictedLayer,
+	types.DockerUncompressedLayer: types.OCIUncompressedLayer,
+}
+
+// ociMediaType returns the OCI equivalent of mt, or mt if it has none.
+func ociMediaType(mt types.MediaType) types.MediaType {
+	if oci, ok := ociMediaTypes[mt]; ok {
+		return oci
+	}
+	return mt
+}
+
 // IndexMediaType modifies the MediaType() of the given index.
 func IndexMediaType(idx v1.ImageIndex, mt types.MediaType) v1.ImageIndex {
 	return &index{
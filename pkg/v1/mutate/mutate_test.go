@@ -17,6 +17,8 @@ package mutate_test
 import (
 	"archive/tar"
 	"bytes"
+	"compress/gzip"
+	"encoding/json"
 	"errors"
 	"io"
 	"io/ioutil"
@@ -28,10 +30,12 @@ import (
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/compression"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/match"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
 	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/stream"
 	"github.com/google/go-containerregistry/pkg/v1/tarball"
@@ -61,6 +65,92 @@ func TestExtractWhiteout(t *testing.T) {
 	}
 }
 
+func TestSquash(t *testing.T) {
+	img, err := tarball.ImageFromPath("testdata/whiteout_image.tar", nil)
+	if err != nil {
+		t.Fatalf("Error loading image: %v", err)
+	}
+	squashed, err := mutate.Squash(img)
+	if err != nil {
+		t.Fatalf("Squash() = %v", err)
+	}
+	if err := validate.Image(squashed); err != nil {
+		t.Fatalf("validate.Image() = %v", err)
+	}
+
+	layers, err := squashed.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layers) != 1 {
+		t.Fatalf("Squash() = %d layers, want 1", len(layers))
+	}
+	cf, err := squashed.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cf.RootFS.DiffIDs) != 1 || len(cf.History) != 1 {
+		t.Errorf("Squash() = %d diff_ids and %d history entries, want 1 of each", len(cf.RootFS.DiffIDs), len(cf.History))
+	}
+	ocf, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(ocf.Config, cf.Config); diff != "" {
+		t.Errorf("Squash() changed the config (-want +got) = %s", diff)
+	}
+
+	rc, err := layers[0].Uncompressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	tr := tar.NewReader(rc)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, part := range strings.Split(header.Name, "/") {
+			if part == "foo" || strings.HasPrefix(part, ".wh.") {
+				t.Errorf("whiteout file found in squashed layer: %v", header.Name)
+			}
+		}
+	}
+}
+
+func TestSquashOCI(t *testing.T) {
+	img, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img = mutate.MediaType(mutate.ConfigMediaType(img, types.OCIConfigJSON), types.OCIManifestSchema1)
+	squashed, err := mutate.Squash(img)
+	if err != nil {
+		t.Fatalf("Squash() = %v", err)
+	}
+	if err := validate.Image(squashed); err != nil {
+		t.Fatalf("validate.Image() = %v", err)
+	}
+
+	if mt, err := squashed.MediaType(); err != nil || mt != types.OCIManifestSchema1 {
+		t.Errorf("MediaType() = %s, %v; want %s", mt, err, types.OCIManifestSchema1)
+	}
+	m, err := squashed.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Config.MediaType != types.OCIConfigJSON {
+		t.Errorf("config mediaType = %s, want %s", m.Config.MediaType, types.OCIConfigJSON)
+	}
+	if len(m.Layers) != 1 || m.Layers[0].MediaType != types.OCILayer {
+		t.Errorf("layers = %v, want one %s", m.Layers, types.OCILayer)
+	}
+}
+
 func TestExtractOverwrittenFile(t *testing.T) {
 	img, err := tarball.ImageFromPath("testdata/overwritten_file.tar", nil)
 	if err != nil {
@@ -157,8 +247,11 @@ func TestAppendWithAddendum(t *testing.T) {
 
 	layers := getLayers(t, result)
 
-	if diff := cmp.Diff(layers[1], mockLayer{}); diff != "" {
-		t.Fatalf("correct layer was not appended (-got, +want) %v", diff)
+	if got, want := mustDigest(t, layers[1]), mustDigest(t, mockLayer{}); got != want {
+		t.Fatalf("correct layer was not appended: got %v; want %v", got, want)
+	}
+	if mt, err := layers[1].MediaType(); err != nil || mt != addendum.MediaType {
+		t.Errorf("MediaType() = %v, %v; want %v", mt, err, addendum.MediaType)
 	}
 
 	if configSizesAreEqual(t, source, result) {
@@ -227,6 +320,47 @@ func TestAppendLayers(t *testing.T) {
 	}
 }
 
+func TestAppendLayersWithHistory(t *testing.T) {
+	source := sourceImage(t)
+	layer, err := random.Layer(100, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history := v1.History{
+		CreatedBy:  "RUN make",
+		Comment:    "build",
+		EmptyLayer: true,
+	}
+	result, err := mutate.AppendLayersWithHistory(source, []v1.Layer{layer}, []v1.History{history})
+	if err != nil {
+		t.Fatalf("failed to append a layer: %v", err)
+	}
+
+	// Config-only changes can still be recorded alongside.
+	empty := v1.History{CreatedBy: "ENV foo=bar", EmptyLayer: true}
+	result, err = mutate.Append(result, mutate.Addendum{History: empty})
+	if err != nil {
+		t.Fatalf("failed to append history: %v", err)
+	}
+
+	cf := getConfigFile(t, result)
+	want := history
+	want.EmptyLayer = false
+	if diff := cmp.Diff(cf.History[1:], []v1.History{want, empty}); diff != "" {
+		t.Errorf("the appended history is not the same (-got, +want) %s", diff)
+	}
+	if got, want := len(getLayers(t, result)), 2; got != want {
+		t.Errorf("len(Layers()) = %d, want %d", got, want)
+	}
+	if err := validate.Image(result); err != nil {
+		t.Errorf("validate.Image() = %v", err)
+	}
+
+	if _, err := mutate.AppendLayersWithHistory(source, []v1.Layer{layer}, nil); err == nil {
+		t.Error("AppendLayersWithHistory() with mismatched history: expected error")
+	}
+}
+
 func TestMutateConfig(t *testing.T) {
 	source := sourceImage(t)
 	cfg, err := source.ConfigFile()
@@ -302,6 +436,104 @@ func TestMutateTime(t *testing.T) {
 	}
 }
 
+// timedLayer returns a layer containing a single file, with every timestamp
+// set to when.
+func timedLayer(t *testing.T, when time.Time) v1.Layer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	contents := []byte("hello world")
+	if err := tw.WriteHeader(&tar.Header{
+		Name:       "usr/bin/hello",
+		Mode:       04755,
+		Uid:        1000,
+		Gid:        1000,
+		Size:       int64(len(contents)),
+		Typeflag:   tar.TypeReg,
+		ModTime:    when,
+		AccessTime: when,
+		ChangeTime: when,
+		Format:     tar.FormatPAX,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write(contents); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	l, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestMutateTimeLayers(t *testing.T) {
+	want := time.Unix(1234567890, 0)
+
+	var digests []v1.Hash
+	for _, when := range []time.Time{time.Unix(1, 0), time.Now().Truncate(time.Second)} {
+		img, err := mutate.AppendLayers(empty.Image, timedLayer(t, when))
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := mutate.Time(img, want)
+		if err != nil {
+			t.Fatalf("Time: %v", err)
+		}
+		if err := validate.Image(result); err != nil {
+			t.Errorf("validate.Image() = %v", err)
+		}
+		d, err := result.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		digests = append(digests, d)
+
+		for _, h := range getConfigFile(t, result).History {
+			if !h.Created.Time.Equal(want) {
+				t.Errorf("history created = %v, want %v", h.Created.Time, want)
+			}
+		}
+
+		layers := getLayers(t, result)
+		rc, err := layers[0].Uncompressed()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr := tar.NewReader(rc)
+		hdr, err := tr.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, got := range []time.Time{hdr.ModTime, hdr.AccessTime, hdr.ChangeTime} {
+			if !got.Equal(want) {
+				t.Errorf("tar entry time = %v, want %v", got, want)
+			}
+		}
+		if hdr.Mode != 04755 || hdr.Uid != 1000 || hdr.Gid != 1000 {
+			t.Errorf("tar entry mode/owner changed: %o %d:%d", hdr.Mode, hdr.Uid, hdr.Gid)
+		}
+		b, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "hello world" {
+			t.Errorf("tar entry contents = %q, want %q", string(b), "hello world")
+		}
+		rc.Close()
+	}
+
+	if digests[0] != digests[1] {
+		t.Errorf("Time should be reproducible, got digests %v and %v", digests[0], digests[1])
+	}
+}
+
 func TestMutateMediaType(t *testing.T) {
 	want := types.OCIManifestSchema1
 	img := mutate.MediaType(empty.Image, want)
@@ -486,6 +718,137 @@ func TestCanonical(t *testing.T) {
 	}
 }
 
+func TestCanonicalReproducible(t *testing.T) {
+	build := func(when time.Time, env []string, createdBy string) v1.Image {
+		img, err := mutate.Append(empty.Image, mutate.Addendum{
+			Layer: timedLayer(t, when),
+			History: v1.History{
+				Created:   v1.Time{Time: when},
+				CreatedBy: createdBy,
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cf, err := img.ConfigFile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cf = cf.DeepCopy()
+		cf.Created = v1.Time{Time: when}
+		cf.Author = createdBy
+		cf.Container = createdBy
+		cf.DockerVersion = createdBy
+		cf.Config.Hostname = createdBy
+		cf.Config.Env = env
+		cf.Config.Labels = map[string]string{"b": "2", "a": "1"}
+		img, err = mutate.ConfigFile(img, cf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err = mutate.Canonical(img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return img
+	}
+
+	first := build(time.Unix(1000, 0), []string{"B=2", "A=1", "A=3"}, "machine-1")
+	second := build(time.Unix(2000, 0), []string{"A=1", "B=2", "A=3"}, "machine-2")
+
+	if !manifestsAreEqual(t, first, second) {
+		t.Error("canonical manifests differ")
+	}
+	if !configDigestsAreEqual(t, first, second) {
+		t.Error("canonical config digests differ")
+	}
+
+	cf := getConfigFile(t, first)
+	if diff := cmp.Diff([]string{"A=1", "A=3", "B=2"}, cf.Config.Env); diff != "" {
+		t.Errorf("Env (-want +got) = %s", diff)
+	}
+	if diff := cmp.Diff([]v1.History{{}}, cf.History); diff != "" {
+		t.Errorf("History (-want +got) = %s", diff)
+	}
+	if cf.Author != "" {
+		t.Errorf("Author = %q, want empty", cf.Author)
+	}
+}
+
+func TestNormalizeOwnership(t *testing.T) {
+	build := func(uid int, uname, user string) v1.Image {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		if err := tw.WriteHeader(&tar.Header{
+			Name:     "bin/tool",
+			Typeflag: tar.TypeReg,
+			Mode:     0755,
+			Size:     4,
+			Uid:      uid,
+			Gid:      uid,
+			Uname:    uname,
+			Gname:    uname,
+			Format:   tar.FormatPAX,
+			PAXRecords: map[string]string{
+				"SCHILY.xattr.security.capability": "cap",
+				"SCHILY.xattr.user.origin":         user,
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte("tool")); err != nil {
+			t.Fatal(err)
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		b := buf.Bytes()
+		layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(b)), nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := mutate.AppendLayers(empty.Image, layer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err = mutate.NormalizeOwnership(img, 0, 0, mutate.WithStrippedXattrs("user.*"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return img
+	}
+
+	first := build(1000, "alice", "machine-1")
+	second := build(1001, "bob", "machine-2")
+	if err := validate.Image(first); err != nil {
+		t.Fatal(err)
+	}
+	if !manifestsAreEqual(t, first, second) {
+		t.Error("normalized manifests differ")
+	}
+
+	tr := tar.NewReader(mutate.Extract(first))
+	hdr, err := tr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hdr.Uid != 0 || hdr.Gid != 0 || hdr.Uname != "" || hdr.Gname != "" {
+		t.Errorf("owner = %d:%d (%q:%q), want 0:0", hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname)
+	}
+	if got := hdr.PAXRecords["SCHILY.xattr.security.capability"]; got != "cap" {
+		t.Errorf("security.capability = %q, want it preserved", got)
+	}
+	if _, ok := hdr.PAXRecords["SCHILY.xattr.user.origin"]; ok {
+		t.Error("user.origin was not stripped")
+	}
+
+	if _, err := mutate.NormalizeOwnership(first, 0, 0, mutate.WithStrippedXattrs("[")); err == nil {
+		t.Error("NormalizeOwnership() with a bad pattern = nil, want error")
+	}
+}
+
 func TestRemoveManifests(t *testing.T) {
 	// Load up the registry.
 	count := 3
@@ -576,6 +939,17 @@ func getLayers(t *testing.T, i v1.Image) []v1.Layer {
 	return l
 }
 
+func mustDigest(t *testing.T, l v1.Layer) v1.Hash {
+	t.Helper()
+
+	d, err := l.Digest()
+	if err != nil {
+		t.Fatalf("Error fetching layer digest: %v", err)
+	}
+
+	return d
+}
+
 func getConfigFile(t *testing.T, i v1.Image) *v1.ConfigFile {
 	t.Helper()
 
@@ -644,3 +1018,792 @@ func (m mockLayer) Compressed() (io.ReadCloser, error) {
 func (m mockLayer) Uncompressed() (io.ReadCloser, error) {
 	return ioutil.NopCloser(strings.NewReader("uncompressed")), nil
 }
+
+func TestTimeCompressionLevel(t *testing.T) {
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	when := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	none, err := mutate.TimeWithOptions(img, when, mutate.WithCompressionLevel(gzip.NoCompression))
+	if err != nil {
+		t.Fatalf("Time(NoCompression) = %v", err)
+	}
+	best, err := mutate.TimeWithOptions(img, when, mutate.WithCompressionLevel(gzip.BestCompression))
+	if err != nil {
+		t.Fatalf("Time(BestCompression) = %v", err)
+	}
+	for _, img := range []v1.Image{none, best} {
+		if err := validate.Image(img); err != nil {
+			t.Errorf("validate.Image() = %v", err)
+		}
+	}
+
+	// The level changes the layer digests, but not their contents.
+	noneLayers, err := none.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bestLayers, err := best.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range noneLayers {
+		nd, err := noneLayers[i].Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		bd, err := bestLayers[i].Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if nd == bd {
+			t.Errorf("layer %d: digests should differ between compression levels", i)
+		}
+		ndiff, err := noneLayers[i].DiffID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		bdiff, err := bestLayers[i].DiffID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ndiff != bdiff {
+			t.Errorf("layer %d: diffids differ: %s != %s", i, ndiff, bdiff)
+		}
+	}
+
+	if _, err := mutate.TimeWithOptions(img, when, mutate.WithCompressionLevel(42)); err == nil {
+		t.Error("Time() with an invalid compression level should fail")
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	base := mutate.MediaType(empty.Image, types.OCIManifestSchema1)
+	img := mutate.Annotations(base, map[string]string{
+		"org.example.step1": "a",
+		"org.example.both":  "1",
+	}).(v1.Image)
+	img = mutate.Annotations(img, map[string]string{
+		"org.example.step2": "b",
+		"org.example.both":  "2",
+	}).(v1.Image)
+
+	m, err := img.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"org.example.step1": "a",
+		"org.example.step2": "b",
+		"org.example.both":  "2",
+	}
+	if diff := cmp.Diff(want, m.Annotations); diff != "" {
+		t.Errorf("Annotations() (-want +got) = %s", diff)
+	}
+
+	img = mutate.DeleteAnnotations(img, []string{"org.example.step1", "org.example.missing"}).(v1.Image)
+	m, err = img.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	delete(want, "org.example.step1")
+	if diff := cmp.Diff(want, m.Annotations); diff != "" {
+		t.Errorf("DeleteAnnotations() (-want +got) = %s", diff)
+	}
+
+	if err := validate.Image(img); err != nil {
+		t.Errorf("validate.Image() = %v", err)
+	}
+}
+
+// indentedImage serves its manifest with indentation, as some signing tools
+// push them, so that re-encoding it would change its digest.
+type indentedImage struct {
+	v1.Image
+}
+
+func (i *indentedImage) RawManifest() ([]byte, error) {
+	m, err := i.Image.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(m, "", "  ")
+}
+
+func (i *indentedImage) Digest() (v1.Hash, error) {
+	return partial.Digest(i)
+}
+
+func TestPreserveRawManifest(t *testing.T) {
+	rnd, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := &indentedImage{rnd}
+	want, err := base.RawManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A no-op mutation keeps the original bytes, and so the digest.
+	img := mutate.Annotations(base, nil).(v1.Image)
+	got, err := img.RawManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("RawManifest() = %s, want %s", got, want)
+	}
+	wantDigest, err := base.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, err := img.Digest(); err != nil {
+		t.Fatal(err)
+	} else if d != wantDigest {
+		t.Errorf("Digest() = %s, want %s", d, wantDigest)
+	}
+
+	// Real changes are serialized compactly and deterministically.
+	img = mutate.Annotations(base, map[string]string{"b": "2", "a": "1"}).(v1.Image)
+	first, err := img.RawManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.ContainsAny(first, "\n ") {
+		t.Errorf("RawManifest() is not compact: %s", first)
+	}
+	img = mutate.Annotations(base, map[string]string{"a": "1", "b": "2"}).(v1.Image)
+	second, err := img.RawManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Errorf("RawManifest() is not deterministic: %s != %s", first, second)
+	}
+}
+
+type tarEntry struct {
+	name, link, content string
+}
+
+func tarLayer(t *testing.T, entries ...tarEntry) v1.Layer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(e.content))}
+		if e.link != "" {
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeLink, e.link, 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	l, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestExtractHardlinks(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		layers  [][]tarEntry
+		want    []tarEntry
+		wantErr bool
+	}{{
+		name:   "same layer",
+		layers: [][]tarEntry{{{name: "a", content: "x"}, {name: "b", link: "a"}}},
+		want:   []tarEntry{{name: "a", content: "x"}, {name: "b", link: "a"}},
+	}, {
+		name:   "lower layer",
+		layers: [][]tarEntry{{{name: "a", content: "x"}}, {{name: "b", link: "./a"}}},
+		want:   []tarEntry{{name: "a", content: "x"}, {name: "b", link: "./a"}},
+	}, {
+		name:   "target deleted",
+		layers: [][]tarEntry{{{name: "a", content: "x"}, {name: "b", link: "a"}}, {{name: ".wh.a"}}},
+		want:   []tarEntry{{name: "b", content: "x"}},
+	}, {
+		name:   "target replaced",
+		layers: [][]tarEntry{{{name: "a", content: "x"}, {name: "b", link: "a"}}, {{name: "a", content: "y"}}},
+		want:   []tarEntry{{name: "a", content: "y"}, {name: "b", content: "x"}},
+	}, {
+		name:    "target missing",
+		layers:  [][]tarEntry{{{name: "b", link: "a"}}},
+		wantErr: true,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			var layers []v1.Layer
+			for _, entries := range tc.layers {
+				layers = append(layers, tarLayer(t, entries...))
+			}
+			img, err := mutate.AppendLayers(empty.Image, layers...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []tarEntry
+			rc := mutate.Extract(img)
+			defer rc.Close()
+			tr := tar.NewReader(rc)
+			for {
+				hdr, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				b, err := ioutil.ReadAll(tr)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, tarEntry{name: hdr.Name, link: hdr.Linkname, content: string(b)})
+			}
+			// Errors surface after the end of the tarball.
+			if _, err := io.Copy(ioutil.Discard, rc); (err != nil) != tc.wantErr {
+				t.Fatalf("Extract() = %v, wantErr %t", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(tarEntry{})); diff != "" {
+				t.Errorf("Extract() (-want +got) = %s", diff)
+			}
+		})
+	}
+}
+
+func TestExtractSparse(t *testing.T) {
+	// Contains a GNU sparse file of 64KiB of zeros, followed by "hi\n".
+	layer, err := tarball.LayerFromFile("testdata/sparse_layer.tar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rc := mutate.Extract(img)
+	defer rc.Close()
+	tr := tar.NewReader(rc)
+	hdr, err := tr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hdr.Typeflag != tar.TypeReg {
+		t.Errorf("Typeflag = %c, want %c", hdr.Typeflag, tar.TypeReg)
+	}
+	b, err := ioutil.ReadAll(tr)
+	if err != nil {
+		t.Fatalf("ReadAll() = %v", err)
+	}
+	if want := append(make([]byte, 64*1024), "hi\n"...); !bytes.Equal(b, want) {
+		t.Errorf("ReadAll() = %d bytes, want %d", len(b), len(want))
+	}
+}
+
+func TestLabels(t *testing.T) {
+	base, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// random.Image has no labels, so this starts from a nil map.
+	img, err := mutate.SetLabel(base, "a", "b")
+	if err != nil {
+		t.Fatalf("SetLabel() = %v", err)
+	}
+	img, err = mutate.SetLabel(img, "c", "d")
+	if err != nil {
+		t.Fatalf("SetLabel() = %v", err)
+	}
+	if err := validate.Image(img); err != nil {
+		t.Errorf("validate.Image() = %v", err)
+	}
+	for k, want := range map[string]string{"a": "b", "c": "d"} {
+		if got, ok, err := partial.Label(img, k); err != nil || !ok || got != want {
+			t.Errorf("Label(%q) = %q, %t, %v; want %q", k, got, ok, err, want)
+		}
+	}
+	if _, ok, err := partial.Label(base, "a"); err != nil || ok {
+		t.Errorf("Label(base) = %t, %v; SetLabel modified the base", ok, err)
+	}
+
+	img, err = mutate.DeleteLabel(img, "a")
+	if err != nil {
+		t.Fatalf("DeleteLabel() = %v", err)
+	}
+	if _, ok, err := partial.Label(img, "a"); err != nil || ok {
+		t.Errorf("Label(a) = %t, %v; want deleted", ok, err)
+	}
+	if got, ok, err := partial.Label(img, "c"); err != nil || !ok || got != "d" {
+		t.Errorf("Label(c) = %q, %t, %v; want d", got, ok, err)
+	}
+
+	// Deleting a missing label is a no-op.
+	same, err := mutate.DeleteLabel(img, "missing")
+	if err != nil {
+		t.Fatalf("DeleteLabel() = %v", err)
+	}
+	if same != img {
+		t.Error("DeleteLabel(missing) returned a different image")
+	}
+}
+
+func TestConfigHelpers(t *testing.T) {
+	base, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := base.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf = cf.DeepCopy()
+	cf.Config.Env = []string{"PATH=/bin", "HOME=/root"}
+	if base, err = mutate.ConfigFile(base, cf); err != nil {
+		t.Fatal(err)
+	}
+
+	img := base
+	for _, edit := range []func(v1.Image) (v1.Image, error){
+		func(img v1.Image) (v1.Image, error) { return mutate.ExposePort(img, "8080") },
+		func(img v1.Image) (v1.Image, error) { return mutate.ExposePort(img, "53/udp") },
+		func(img v1.Image) (v1.Image, error) { return mutate.AddVolume(img, "/data") },
+		func(img v1.Image) (v1.Image, error) { return mutate.WorkingDir(img, "/app") },
+		func(img v1.Image) (v1.Image, error) { return mutate.Env(img, "PATH=/usr/bin:/bin", "USER=app") },
+		func(img v1.Image) (v1.Image, error) { return mutate.Entrypoint(img, []string{"/app/server"}) },
+		func(img v1.Image) (v1.Image, error) { return mutate.Cmd(img, []string{"--port", "8080"}) },
+	} {
+		if img, err = edit(img); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := validate.Image(img); err != nil {
+		t.Errorf("validate.Image() = %v", err)
+	}
+
+	got, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := cf.Config.DeepCopy()
+	want.ExposedPorts = map[string]struct{}{"8080/tcp": {}, "53/udp": {}}
+	want.Volumes = map[string]struct{}{"/data": {}}
+	want.WorkingDir = "/app"
+	want.Env = []string{"PATH=/usr/bin:/bin", "HOME=/root", "USER=app"}
+	want.Entrypoint = []string{"/app/server"}
+	want.Cmd = []string{"--port", "8080"}
+	if diff := cmp.Diff(want, &got.Config); diff != "" {
+		t.Errorf("Config (-want +got) = %s", diff)
+	}
+
+	// The base is left as it is.
+	if bcf, err := base.ConfigFile(); err != nil {
+		t.Fatal(err)
+	} else if diff := cmp.Diff(cf.Config, bcf.Config); diff != "" {
+		t.Errorf("base Config (-want +got) = %s", diff)
+	}
+
+	for _, edit := range []func(v1.Image) (v1.Image, error){
+		func(img v1.Image) (v1.Image, error) { return mutate.DeleteExposedPort(img, "8080") },
+		func(img v1.Image) (v1.Image, error) { return mutate.DeleteVolume(img, "/data") },
+		func(img v1.Image) (v1.Image, error) { return mutate.WorkingDir(img, "") },
+		func(img v1.Image) (v1.Image, error) { return mutate.Entrypoint(img, nil) },
+		func(img v1.Image) (v1.Image, error) { return mutate.Cmd(img, nil) },
+	} {
+		if img, err = edit(img); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, err = img.ConfigFile(); err != nil {
+		t.Fatal(err)
+	}
+	want.ExposedPorts = map[string]struct{}{"53/udp": {}}
+	want.Volumes = map[string]struct{}{}
+	want.WorkingDir = ""
+	want.Entrypoint = nil
+	want.Cmd = nil
+	if diff := cmp.Diff(want, &got.Config); diff != "" {
+		t.Errorf("cleared Config (-want +got) = %s", diff)
+	}
+
+	// Deleting something that isn't there is a no-op.
+	if same, err := mutate.DeleteVolume(img, "/missing"); err != nil || same != img {
+		t.Errorf("DeleteVolume(missing) = %v; want the same image", err)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	base, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history := []v1.History{
+		{CreatedBy: "COPY a /"},
+		{CreatedBy: "ENV SECRET=hunter2", EmptyLayer: true},
+		{CreatedBy: "COPY b /"},
+	}
+	img, err := mutate.History(base, history)
+	if err != nil {
+		t.Fatalf("History() = %v", err)
+	}
+	if err := validate.Image(img); err != nil {
+		t.Errorf("validate.Image() = %v", err)
+	}
+
+	// Redact the secret.
+	got, err := partial.History(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got[1].CreatedBy = "ENV SECRET=<redacted>"
+	redacted, err := mutate.History(img, got)
+	if err != nil {
+		t.Fatalf("History() = %v", err)
+	}
+	if h, err := partial.History(img); err != nil || h[1].CreatedBy != history[1].CreatedBy {
+		t.Errorf("History(img) = %v, %v; modifying the copy changed the image", h, err)
+	}
+	want := append([]v1.History{}, history...)
+	want[1].CreatedBy = "ENV SECRET=<redacted>"
+	if h, err := partial.History(redacted); err != nil {
+		t.Fatal(err)
+	} else if diff := cmp.Diff(want, h); diff != "" {
+		t.Errorf("History() (-want +got) = %s", diff)
+	}
+
+	// Only the config changes.
+	bm, err := base.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rm, err := redacted.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(bm.Layers, rm.Layers); diff != "" {
+		t.Errorf("layers changed (-base +redacted) = %s", diff)
+	}
+
+	if _, err := mutate.History(base, history[:2]); err == nil {
+		t.Error("History() with too few layers = nil, want error")
+	}
+	if _, err := mutate.History(base, nil); err != nil {
+		t.Errorf("History(nil) = %v", err)
+	}
+}
+
+func TestSubject(t *testing.T) {
+	base, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := partial.Descriptor(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img = mutate.Subject(img, *subject)
+	if err := validate.Image(img); err != nil {
+		t.Errorf("validate.Image() = %v", err)
+	}
+	if mt, err := img.MediaType(); err != nil || mt != types.OCIManifestSchema1 {
+		t.Errorf("MediaType() = %s, %v; want %s", mt, err, types.OCIManifestSchema1)
+	}
+	m, err := img.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(subject, m.Subject); diff != "" {
+		t.Errorf("Subject (-want +got) = %s", diff)
+	}
+	if m.MediaType != "" {
+		t.Errorf("manifest mediaType = %s, want unset", m.MediaType)
+	}
+	if m.Config.MediaType != types.OCIConfigJSON {
+		t.Errorf("config mediaType = %s, want %s", m.Config.MediaType, types.OCIConfigJSON)
+	}
+	for _, l := range m.Layers {
+		if l.MediaType != types.OCILayer {
+			t.Errorf("layer mediaType = %s, want %s", l.MediaType, types.OCILayer)
+		}
+	}
+
+	idx, err := random.Index(1024, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx = mutate.IndexSubject(mutate.IndexMediaType(idx, types.DockerManifestList), *subject)
+	if mt, err := idx.MediaType(); err != nil || mt != types.OCIImageIndex {
+		t.Errorf("index MediaType() = %s, %v; want %s", mt, err, types.OCIImageIndex)
+	}
+	im, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(subject, im.Subject); diff != "" {
+		t.Errorf("index Subject (-want +got) = %s", diff)
+	}
+	if im.MediaType != "" {
+		t.Errorf("index mediaType = %s, want unset", im.MediaType)
+	}
+	// The children are left as they are.
+	if diff := cmp.Diff(orig.Manifests, im.Manifests); diff != "" {
+		t.Errorf("index Manifests (-want +got) = %s", diff)
+	}
+}
+
+func TestArtifactType(t *testing.T) {
+	const at = "application/vnd.example.sbom"
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img = mutate.ArtifactType(img, at)
+	if err := validate.Image(img); err != nil {
+		t.Errorf("validate.Image() = %v", err)
+	}
+	if mt, err := img.MediaType(); err != nil || mt != types.OCIManifestSchema1 {
+		t.Errorf("MediaType() = %s, %v; want %s", mt, err, types.OCIManifestSchema1)
+	}
+	m, err := img.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ArtifactType != at {
+		t.Errorf("artifactType = %q, want %q", m.ArtifactType, at)
+	}
+	if m.Config.MediaType != types.OCIConfigJSON {
+		t.Errorf("config mediaType = %s, want %s", m.Config.MediaType, types.OCIConfigJSON)
+	}
+
+	// Docker manifests don't have an artifactType.
+	docker := mutate.MediaType(img, types.DockerManifestSchema2)
+	m, err = docker.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ArtifactType != "" {
+		t.Errorf("Docker artifactType = %q, want unset", m.ArtifactType)
+	}
+
+	// An empty artifactType removes it.
+	cleared := mutate.ArtifactType(img, "")
+	m, err = cleared.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ArtifactType != "" {
+		t.Errorf("cleared artifactType = %q, want unset", m.ArtifactType)
+	}
+}
+
+func TestEmptyConfig(t *testing.T) {
+	layer, err := random.Layer(1024, types.OCILayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	artifact, err := mutate.AppendLayers(empty.Artifact, layer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	converted := mutate.ConfigMediaType(img, types.OCIEmptyJSON)
+
+	for name, img := range map[string]v1.Image{"artifact": artifact, "converted": converted} {
+		t.Run(name, func(t *testing.T) {
+			if err := validate.Image(img); err != nil {
+				t.Errorf("validate.Image() = %v", err)
+			}
+			raw, err := img.RawConfigFile()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(raw) != "{}" {
+				t.Errorf("RawConfigFile() = %s, want {}", raw)
+			}
+			m, err := img.Manifest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.Config.MediaType != types.OCIEmptyJSON || m.Config.Size != 2 {
+				t.Errorf("config = %v, want 2 bytes of %s", m.Config, types.OCIEmptyJSON)
+			}
+			ls, err := img.Layers()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ls) != 1 {
+				t.Errorf("Layers() = %d layers, want 1", len(ls))
+			}
+		})
+	}
+
+	// Other config media types are just passed through.
+	typed := mutate.ConfigMediaType(img, "application/vnd.example.config+json")
+	m, err := typed.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Config.MediaType != "application/vnd.example.config+json" {
+		t.Errorf("config mediaType = %s", m.Config.MediaType)
+	}
+	if err := validate.Image(typed); err != nil {
+		t.Errorf("validate.Image() = %v", err)
+	}
+}
+
+func TestAppendLayersCompressionLevel(t *testing.T) {
+	layer, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without the option, the layer is appended as it is.
+	img, err := mutate.AppendLayersWithOptions(empty.Image, []v1.Layer{layer})
+	if err != nil {
+		t.Fatalf("AppendLayersWithOptions() = %v", err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := layer.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := layers[0].Digest(); err != nil || got != want {
+		t.Errorf("Digest() = %v, %v; want %v", got, err, want)
+	}
+
+	img, err = mutate.AppendLayersWithOptions(empty.Image, []v1.Layer{layer}, mutate.WithCompressionLevel(gzip.NoCompression))
+	if err != nil {
+		t.Fatalf("AppendLayersWithOptions(NoCompression) = %v", err)
+	}
+	if err := validate.Image(img); err != nil {
+		t.Errorf("validate.Image() = %v", err)
+	}
+	layers, err = img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := layers[0].Digest(); err != nil || got == want {
+		t.Errorf("Digest() = %v, %v; want the layer to be recompressed", got, err)
+	}
+	wantDiffID, err := layer.DiffID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := layers[0].DiffID(); err != nil || got != wantDiffID {
+		t.Errorf("DiffID() = %v, %v; want %v", got, err, wantDiffID)
+	}
+
+	if _, err := mutate.AppendLayersWithOptions(empty.Image, []v1.Layer{layer}, mutate.WithCompressionLevel(42)); err == nil {
+		t.Error("AppendLayersWithOptions() with an invalid compression level should fail")
+	}
+}
+
+func TestStrippedXattrs(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     "bin/tool",
+		Typeflag: tar.TypeReg,
+		Mode:     0755,
+		Size:     4,
+		Format:   tar.FormatPAX,
+		PAXRecords: map[string]string{
+			"SCHILY.xattr.security.capability": "cap",
+			"SCHILY.xattr.user.origin":         "machine-1",
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte("tool")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	strip := mutate.WithStrippedXattrs("user.*")
+	for _, tc := range []struct {
+		name   string
+		mutate func() (v1.Image, error)
+	}{{
+		name: "Squash",
+		mutate: func() (v1.Image, error) {
+			return mutate.Squash(img, strip)
+		},
+	}, {
+		name: "AppendLayersWithOptions",
+		mutate: func() (v1.Image, error) {
+			return mutate.AppendLayersWithOptions(empty.Image, []v1.Layer{layer}, strip)
+		},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.mutate()
+			if err != nil {
+				t.Fatalf("%s() = %v", tc.name, err)
+			}
+			if err := validate.Image(got); err != nil {
+				t.Fatalf("validate.Image() = %v", err)
+			}
+			tr := tar.NewReader(mutate.Extract(got))
+			hdr, err := tr.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := hdr.PAXRecords["SCHILY.xattr.user.origin"]; ok {
+				t.Error("user.origin xattr wasn't stripped")
+			}
+			if _, ok := hdr.PAXRecords["SCHILY.xattr.security.capability"]; !ok {
+				t.Error("security.capability xattr was stripped")
+			}
+		})
+	}
+
+	if _, err := mutate.TranscodeLayers(img, compression.ZStd, strip); err == nil {
+		t.Error("TranscodeLayers(WithStrippedXattrs) = nil, want error")
+	}
+}
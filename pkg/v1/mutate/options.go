@@ -0,0 +1,110 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutate
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Option is a functional option for mutations that rewrite layers.
+type Option func(*options)
+
+type options struct {
+	compression int
+	// recompress is set by WithCompressionLevel, so that layers which would
+	// otherwise be appended as they are get recompressed at that level.
+	recompress bool
+	xattrs     []string
+}
+
+func makeOptions(opts ...Option) (*options, error) {
+	o := &options{
+		compression: gzip.BestSpeed,
+	}
+	for _, option := range opts {
+		option(o)
+	}
+	if o.compression < gzip.HuffmanOnly || o.compression > gzip.BestCompression {
+		return nil, fmt.Errorf("invalid compression level %d: must be between %d and %d", o.compression, gzip.HuffmanOnly, gzip.BestCompression)
+	}
+	for _, pattern := range o.xattrs {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid xattr pattern %q: %v", pattern, err)
+		}
+	}
+	return o, nil
+}
+
+// WithCompressionLevel is a functional option for setting the gzip level
+// used to compress rewritten layers, from gzip.HuffmanOnly to
+// gzip.BestCompression. The default is gzip.BestSpeed.
+//
+// AppendLayersWithOptions leaves layers as they are by default, and only
+// recompresses gzip layers when this option is passed.
+//
+// Since the compressed bytes depend on the level, so do the layer digests.
+func WithCompressionLevel(level int) Option {
+	return func(o *options) {
+		o.compression = level
+		o.recompress = true
+	}
+}
+
+// WithStrippedXattrs is a functional option for removing the extended
+// attributes whose names match any of patterns, in path.Match syntax (e.g.
+// "security.*" or "user.*"), from every entry of rewritten layers. By default,
+// xattrs are preserved, since some (e.g. security.capability or
+// security.selinux) may be needed at runtime.
+//
+// TimeWithOptions, CanonicalWithOptions, NormalizeOwnership, Squash and
+// AppendLayersWithOptions honor it. TranscodeLayers doesn't rewrite the layers' contents, so it
+// returns an error instead.
+func WithStrippedXattrs(patterns ...string) Option {
+	return func(o *options) {
+		o.xattrs = append(o.xattrs, patterns...)
+	}
+}
+
+// stripXattrs removes the xattrs matching o.xattrs from header.
+func (o *options) stripXattrs(header *tar.Header) {
+	if len(o.xattrs) == 0 {
+		return
+	}
+	// archive/tar populates both Xattrs and the equivalent PAX records.
+	for name := range header.Xattrs {
+		if o.stripXattr(name) {
+			delete(header.Xattrs, name)
+		}
+	}
+	for k := range header.PAXRecords {
+		if name := strings.TrimPrefix(k, "SCHILY.xattr."); name != k && o.stripXattr(name) {
+			delete(header.PAXRecords, k)
+		}
+	}
+}
+
+func (o *options) stripXattr(name string) bool {
+	for _, pattern := range o.xattrs {
+		// The patterns are validated by makeOptions.
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -34,19 +34,19 @@ func Rebase(orig, oldBase, newBase v1.Image) (v1.Image, error) {
 		return nil, err
 	}
 	if len(oldBaseLayers) > len(origLayers) {
-		return nil, fmt.Errorf("image %q is not based on %q (too few layers)", orig, oldBase)
+		return nil, fmt.Errorf("image %s is not based on %s (too few layers: %d < %d)", imageName(orig), imageName(oldBase), len(origLayers), len(oldBaseLayers))
 	}
 	for i, l := range oldBaseLayers {
 		oldLayerDigest, err := l.Digest()
 		if err != nil {
-			return nil, fmt.Errorf("failed to get digest of layer %d of %q: %v", i, oldBase, err)
+			return nil, fmt.Errorf("failed to get digest of layer %d of %s: %v", i, imageName(oldBase), err)
 		}
 		origLayerDigest, err := origLayers[i].Digest()
 		if err != nil {
-			return nil, fmt.Errorf("failed to get digest of layer %d of %q: %v", i, orig, err)
+			return nil, fmt.Errorf("failed to get digest of layer %d of %s: %v", i, imageName(orig), err)
 		}
 		if oldLayerDigest != origLayerDigest {
-			return nil, fmt.Errorf("image %q is not based on %q (layer %d mismatch)", orig, oldBase, i)
+			return nil, fmt.Errorf("image %s is not based on %s (layer %d mismatch: %s != %s)", imageName(orig), imageName(oldBase), i, origLayerDigest, oldLayerDigest)
 		}
 	}
 
@@ -135,10 +135,19 @@ func createAddendums(startHistory, startLayer int, history []v1.History, layers
 	}
 	// In the event history was malformed or non-existent, append the remaining layers.
 	for i := layerIndex; i < len(layers); i++ {
-		if i >= startLayer {
-			adds = append(adds, Addendum{Layer: layers[layerIndex]})
+		if i >= startLayer-1 {
+			adds = append(adds, Addendum{Layer: layers[i]})
 		}
 	}
 
 	return adds
 }
+
+// imageName identifies img by its digest in error messages.
+func imageName(img v1.Image) string {
+	h, err := img.Digest()
+	if err != nil {
+		return "<unknown>"
+	}
+	return h.String()
+}
@@ -15,6 +15,7 @@
 package mutate_test
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -177,3 +178,91 @@ func TestRebase(t *testing.T) {
 		t.Errorf("ConfigFile property OSVersion mismatch, got %q, want %q", rebasedConfig.OSVersion, newBaseConfig.OSVersion)
 	}
 }
+
+func TestRebaseNotBasedOn(t *testing.T) {
+	orig, err := random.Image(100, 3)
+	if err != nil {
+		t.Fatalf("random.Image (orig): %v", err)
+	}
+	oldBase, err := random.Image(100, 2)
+	if err != nil {
+		t.Fatalf("random.Image (oldBase): %v", err)
+	}
+	newBase, err := random.Image(100, 2)
+	if err != nil {
+		t.Fatalf("random.Image (newBase): %v", err)
+	}
+
+	if _, err := mutate.Rebase(orig, oldBase, newBase); err == nil {
+		t.Error("Rebase: expected error for unrelated base")
+	} else if !strings.Contains(err.Error(), "layer 0 mismatch") {
+		t.Errorf("Rebase: got %v, want layer mismatch", err)
+	}
+
+	tooBig, err := random.Image(100, 4)
+	if err != nil {
+		t.Fatalf("random.Image (tooBig): %v", err)
+	}
+	if _, err := mutate.Rebase(orig, tooBig, newBase); err == nil {
+		t.Error("Rebase: expected error for base with more layers")
+	} else if !strings.Contains(err.Error(), "too few layers") {
+		t.Errorf("Rebase: got %v, want too few layers", err)
+	}
+}
+
+// withoutHistory strips the history from img's config.
+func withoutHistory(t *testing.T, img v1.Image) v1.Image {
+	cf, err := img.ConfigFile()
+	if err != nil {
+		t.Fatalf("ConfigFile: %v", err)
+	}
+	cf.History = nil
+	img, err = mutate.ConfigFile(img, cf)
+	if err != nil {
+		t.Fatalf("mutate.ConfigFile: %v", err)
+	}
+	return img
+}
+
+// TestRebaseNoHistory tests that rebasing works for images without history.
+func TestRebaseNoHistory(t *testing.T) {
+	oldBase, err := random.Image(100, 2)
+	if err != nil {
+		t.Fatalf("random.Image (oldBase): %v", err)
+	}
+	top, err := random.Image(100, 2)
+	if err != nil {
+		t.Fatalf("random.Image (top): %v", err)
+	}
+	topLayers, err := top.Layers()
+	if err != nil {
+		t.Fatalf("top.Layers: %v", err)
+	}
+	orig, err := mutate.AppendLayers(oldBase, topLayers...)
+	if err != nil {
+		t.Fatalf("AppendLayers: %v", err)
+	}
+	newBase, err := random.Image(100, 3)
+	if err != nil {
+		t.Fatalf("random.Image (newBase): %v", err)
+	}
+
+	orig, oldBase, newBase = withoutHistory(t, orig), withoutHistory(t, oldBase), withoutHistory(t, newBase)
+
+	rebased, err := mutate.Rebase(orig, oldBase, newBase)
+	if err != nil {
+		t.Fatalf("Rebase: %v", err)
+	}
+
+	origLayerDigests := layerDigests(t, orig)
+	want := append(layerDigests(t, newBase), origLayerDigests[2:]...)
+	got := layerDigests(t, rebased)
+	if len(got) != len(want) {
+		t.Fatalf("Rebased image contained %d layers, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("Layer %d mismatch, got %q, want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,308 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/compression"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/internal/gzip"
+	"github.com/google/go-containerregistry/pkg/v1/internal/zstd"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// TranscodeLayers recompresses every layer of img with the target compression,
+// e.g. to republish a gzip image with zstd layers. The layer media types,
+// digests and sizes in the manifest are updated to match. Since the
+// uncompressed contents don't change, neither do the DiffIDs, so the config
+// (and its digest) is left untouched.
+//
+// Layers that already use the target compression are kept as they are, as are
+// non-distributable layers (which can't be re-uploaded) and layers whose media
+// type doesn't imply a compression. Gzip layers are compressed at the level
+// set with WithCompressionLevel. Since the contents are kept as they are,
+// WithStrippedXattrs is an error.
+//
+// Docker layer media types are kept in the Docker family, except for zstd,
+// which only exists as types.OCILayerZStd.
+func TranscodeLayers(img v1.Image, target compression.Compression, opts ...Option) (v1.Image, error) {
+	o, err := makeOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(o.xattrs) != 0 {
+		// That would change the DiffIDs, which this promises to keep.
+		return nil, errors.New("TranscodeLayers doesn't support WithStrippedXattrs")
+	}
+	switch target {
+	case compression.None, compression.GZip, compression.ZStd:
+	default:
+		return nil, fmt.Errorf("unsupported compression: %q", target)
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, fmt.Errorf("getting image layers: %v", err)
+	}
+
+	ti := &transcodedImage{
+		Image:  img,
+		layers: make([]v1.Layer, 0, len(layers)),
+	}
+	for _, layer := range layers {
+		mt, err := layer.MediaType()
+		if err != nil {
+			return nil, err
+		}
+		c, ok := compression.FromMediaType(mt)
+		if !ok || c == target || !mt.IsDistributable() {
+			ti.layers = append(ti.layers, layer)
+			continue
+		}
+		ti.layers = append(ti.layers, &transcodedLayer{
+			Layer: layer,
+			c:     target,
+			mt:    transcodedMediaType(mt, target),
+			level: o.compression,
+		})
+		ti.transcoded = true
+	}
+	if !ti.transcoded {
+		return img, nil
+	}
+	return ti, nil
+}
+
+// recompressed returns layer recompressed at o.compression if it is a
+// distributable gzip layer, and layer itself otherwise.
+func (o *options) recompressed(layer v1.Layer) (v1.Layer, error) {
+	mt, err := layer.MediaType()
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := compression.FromMediaType(mt); !ok || c != compression.GZip || !mt.IsDistributable() {
+		return layer, nil
+	}
+	return &transcodedLayer{
+		Layer: layer,
+		c:     compression.GZip,
+		mt:    mt,
+		level: o.compression,
+	}, nil
+}
+
+// transcodedMediaType returns the media type of a layer of type mt once it has
+// been recompressed with c.
+func transcodedMediaType(mt types.MediaType, c compression.Compression) types.MediaType {
+	docker := strings.Contains(string(mt), types.DockerVendorPrefix)
+	switch c {
+	case compression.None:
+		if docker {
+			return types.DockerUncompressedLayer
+		}
+		return types.OCIUncompressedLayer
+	case compression.GZip:
+		if docker {
+			return types.DockerLayer
+		}
+		return types.OCILayer
+	default:
+		return types.OCILayerZStd
+	}
+}
+
+// transcodedImage is img with its layers replaced by their transcoded
+// counterparts, see TranscodeLayers. The config is inherited from img.
+type transcodedImage struct {
+	v1.Image
+
+	layers     []v1.Layer
+	transcoded bool
+
+	once     sync.Once
+	err      error
+	manifest *v1.Manifest
+	byDigest map[v1.Hash]v1.Layer
+	byDiffID map[v1.Hash]v1.Layer
+}
+
+var _ v1.Image = (*transcodedImage)(nil)
+
+func (i *transcodedImage) compute() error {
+	i.once.Do(func() {
+		m, err := i.Image.Manifest()
+		if err != nil {
+			i.err = err
+			return
+		}
+		manifest := m.DeepCopy()
+		if len(manifest.Layers) != len(i.layers) {
+			i.err = fmt.Errorf("manifest has %d layers, image has %d", len(manifest.Layers), len(i.layers))
+			return
+		}
+
+		i.byDigest = make(map[v1.Hash]v1.Layer, len(i.layers))
+		i.byDiffID = make(map[v1.Hash]v1.Layer, len(i.layers))
+		for idx, layer := range i.layers {
+			diffID, err := layer.DiffID()
+			if err != nil {
+				i.err = err
+				return
+			}
+			i.byDiffID[diffID] = layer
+
+			if tl, ok := layer.(*transcodedLayer); ok {
+				desc := &manifest.Layers[idx]
+				if desc.Digest, err = tl.Digest(); err != nil {
+					i.err = err
+					return
+				}
+				if desc.Size, err = tl.Size(); err != nil {
+					i.err = err
+					return
+				}
+				desc.MediaType = tl.mt
+			}
+			i.byDigest[manifest.Layers[idx].Digest] = layer
+		}
+		i.manifest = manifest
+	})
+	return i.err
+}
+
+// Layers implements v1.Image.
+func (i *transcodedImage) Layers() ([]v1.Layer, error) {
+	return i.layers, nil
+}
+
+// Manifest implements v1.Image.
+func (i *transcodedImage) Manifest() (*v1.Manifest, error) {
+	if err := i.compute(); err != nil {
+		return nil, err
+	}
+	return i.manifest.DeepCopy(), nil
+}
+
+// RawManifest implements v1.Image.
+func (i *transcodedImage) RawManifest() ([]byte, error) {
+	if err := i.compute(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(i.manifest)
+}
+
+// Digest implements v1.Image.
+func (i *transcodedImage) Digest() (v1.Hash, error) {
+	return partial.Digest(i)
+}
+
+// Size implements v1.Image.
+func (i *transcodedImage) Size() (int64, error) {
+	return partial.Size(i)
+}
+
+// LayerByDigest implements v1.Image.
+func (i *transcodedImage) LayerByDigest(h v1.Hash) (v1.Layer, error) {
+	if err := i.compute(); err != nil {
+		return nil, err
+	}
+	if layer, ok := i.byDigest[h]; ok {
+		return layer, nil
+	}
+	// This is the config blob.
+	return i.Image.LayerByDigest(h)
+}
+
+// LayerByDiffID implements v1.Image.
+func (i *transcodedImage) LayerByDiffID(h v1.Hash) (v1.Layer, error) {
+	if err := i.compute(); err != nil {
+		return nil, err
+	}
+	if layer, ok := i.byDiffID[h]; ok {
+		return layer, nil
+	}
+	return nil, fmt.Errorf("unknown diff_id: %v", h)
+}
+
+// transcodedLayer recompresses the uncompressed contents of a layer with c.
+type transcodedLayer struct {
+	v1.Layer
+
+	c     compression.Compression
+	mt    types.MediaType
+	level int
+
+	once   sync.Once
+	err    error
+	digest v1.Hash
+	size   int64
+}
+
+// Compressed implements v1.Layer.
+func (l *transcodedLayer) Compressed() (io.ReadCloser, error) {
+	rc, err := l.Layer.Uncompressed()
+	if err != nil {
+		return nil, err
+	}
+	switch l.c {
+	case compression.GZip:
+		return gzip.ReadCloserLevel(rc, l.level), nil
+	case compression.ZStd:
+		return zstd.ReadCloser(rc), nil
+	default:
+		return rc, nil
+	}
+}
+
+func (l *transcodedLayer) compute() error {
+	l.once.Do(func() {
+		rc, err := l.Compressed()
+		if err != nil {
+			l.err = err
+			return
+		}
+		defer rc.Close()
+		l.digest, l.size, l.err = v1.SHA256(rc)
+	})
+	return l.err
+}
+
+// Digest implements v1.Layer.
+func (l *transcodedLayer) Digest() (v1.Hash, error) {
+	if err := l.compute(); err != nil {
+		return v1.Hash{}, err
+	}
+	return l.digest, nil
+}
+
+// Size implements v1.Layer.
+func (l *transcodedLayer) Size() (int64, error) {
+	if err := l.compute(); err != nil {
+		return 0, err
+	}
+	return l.size, nil
+}
+
+// MediaType implements v1.Layer.
+func (l *transcodedLayer) MediaType() (types.MediaType, error) {
+	return l.mt, nil
+}
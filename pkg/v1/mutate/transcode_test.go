@@ -0,0 +1,93 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutate_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/compression"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+func TestTranscodeLayers(t *testing.T) {
+	img, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := getConfigFile(t, img).RootFS.DiffIDs
+
+	for _, tc := range []struct {
+		target compression.Compression
+		mt     types.MediaType
+	}{
+		{compression.ZStd, types.OCILayerZStd},
+		{compression.None, types.DockerUncompressedLayer},
+		{compression.GZip, types.DockerLayer},
+	} {
+		t.Run(string(tc.target), func(t *testing.T) {
+			src := img
+			if tc.target == compression.GZip {
+				// Start from zstd, which is OCI-only.
+				if src, err = mutate.TranscodeLayers(img, compression.ZStd); err != nil {
+					t.Fatal(err)
+				}
+				tc.mt = types.OCILayer
+			}
+			got, err := mutate.TranscodeLayers(src, tc.target)
+			if err != nil {
+				t.Fatalf("TranscodeLayers() = %v", err)
+			}
+			if err := validate.Image(got); err != nil {
+				t.Fatalf("validate.Image() = %v", err)
+			}
+
+			// The uncompressed contents, and with them the config, are unchanged.
+			if diff := cmp.Diff(want, getConfigFile(t, got).RootFS.DiffIDs); diff != "" {
+				t.Errorf("DiffIDs (-want +got) = %s", diff)
+			}
+			if !configDigestsAreEqual(t, img, got) {
+				t.Error("config digest changed")
+			}
+
+			m := getManifest(t, got)
+			orig := getManifest(t, src)
+			for i, desc := range m.Layers {
+				if desc.MediaType != tc.mt {
+					t.Errorf("layer %d MediaType = %s, want %s", i, desc.MediaType, tc.mt)
+				}
+				if desc.Digest == orig.Layers[i].Digest {
+					t.Errorf("layer %d digest didn't change", i)
+				}
+			}
+		})
+	}
+
+	// Layers that are already in the target format are untouched.
+	same, err := mutate.TranscodeLayers(img, compression.GZip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !manifestsAreEqual(t, img, same) {
+		t.Error("TranscodeLayers(gzip) changed a gzip image")
+	}
+
+	if _, err := mutate.TranscodeLayers(img, "bogus"); err == nil {
+		t.Error("TranscodeLayers(bogus) = nil, want error")
+	}
+}
@@ -17,8 +17,10 @@ package partial
 import (
 	"io"
 
+	"github.com/google/go-containerregistry/pkg/compression"
+	"github.com/google/go-containerregistry/pkg/logs"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
-	"github.com/google/go-containerregistry/pkg/v1/internal/gzip"
+	"github.com/google/go-containerregistry/pkg/v1/internal/decompress"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 )
 
@@ -44,12 +46,24 @@ type compressedLayerExtender struct {
 }
 
 // Uncompressed implements v1.Layer
+//
+// The compression is detected from the contents rather than taken from the
+// media type, since some producers get the latter wrong.
 func (cle *compressedLayerExtender) Uncompressed() (io.ReadCloser, error) {
 	r, err := cle.Compressed()
 	if err != nil {
 		return nil, err
 	}
-	return gzip.UnzipReadCloser(r)
+	rc, detected, err := decompress.ReadCloser(r)
+	if err != nil {
+		return nil, err
+	}
+	if mt, err := cle.MediaType(); err == nil {
+		if declared, ok := compression.FromMediaType(mt); ok && declared != detected {
+			logs.Warn.Printf("layer has media type %s, but its contents are compressed with %s", mt, detected)
+		}
+	}
+	return rc, nil
 }
 
 // DiffID implements v1.Layer
@@ -64,11 +78,14 @@ func (cle *compressedLayerExtender) DiffID() (v1.Hash, error) {
 		return v1.Hash{}, err
 	}
 	defer r.Close()
-	h, _, err := v1.SHA256(r)
+	h, _, err := v1.ComputeHash(hashAlgorithm(cle.CompressedLayer), r)
 	return h, err
 }
 
 // CompressedToLayer fills in the missing methods from a CompressedLayer so that it implements v1.Layer
+//
+// Unless ul implements DiffID, the DiffID is computed with the algorithm of the
+// digest in ul's descriptor, if it has one, and with sha256 otherwise.
 func CompressedToLayer(ul CompressedLayer) (v1.Layer, error) {
 	return &compressedLayerExtender{ul}, nil
 }
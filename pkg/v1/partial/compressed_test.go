@@ -15,7 +15,9 @@
 package partial_test
 
 import (
+	"bytes"
 	"io"
+	"io/ioutil"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -23,6 +25,8 @@ import (
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/registry"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/internal/gzip"
+	"github.com/google/go-containerregistry/pkg/v1/internal/zstd"
 	"github.com/google/go-containerregistry/pkg/v1/partial"
 	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
@@ -169,3 +173,63 @@ func TestCompressed(t *testing.T) {
 		t.Fatalf("partial.Descriptor: %v", err)
 	}
 }
+
+type mislabeledLayer struct {
+	b  []byte
+	mt types.MediaType
+}
+
+func (l *mislabeledLayer) Digest() (v1.Hash, error) {
+	h, _, err := v1.SHA256(bytes.NewReader(l.b))
+	return h, err
+}
+func (l *mislabeledLayer) Compressed() (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(l.b)), nil
+}
+func (l *mislabeledLayer) Size() (int64, error) {
+	return int64(len(l.b)), nil
+}
+func (l *mislabeledLayer) MediaType() (types.MediaType, error) {
+	return l.mt, nil
+}
+
+func TestCompressedLayerSniffs(t *testing.T) {
+	want := []byte("pretend this is a tarball")
+
+	compress := func(rc io.ReadCloser) []byte {
+		b, err := ioutil.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	for _, tc := range []struct {
+		name string
+		b    []byte
+		mt   types.MediaType
+	}{
+		{"gzip as gzip", compress(gzip.ReadCloser(ioutil.NopCloser(bytes.NewReader(want)))), types.OCILayer},
+		{"zstd as gzip", compress(zstd.ReadCloser(ioutil.NopCloser(bytes.NewReader(want)))), types.DockerLayer},
+		{"gzip as zstd", compress(gzip.ReadCloser(ioutil.NopCloser(bytes.NewReader(want)))), types.OCILayerZStd},
+		{"uncompressed as gzip", want, types.OCILayer},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			l, err := partial.CompressedToLayer(&mislabeledLayer{b: tc.b, mt: tc.mt})
+			if err != nil {
+				t.Fatal(err)
+			}
+			rc, err := l.Uncompressed()
+			if err != nil {
+				t.Fatalf("Uncompressed() = %v", err)
+			}
+			defer rc.Close()
+			got, err := ioutil.ReadAll(rc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("Uncompressed() = %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -18,6 +18,10 @@ func (t testUIC) RawConfigFile() ([]byte, error) {
 	return t.configFile, nil
 }
 
+func (t testUIC) MediaType() (types.MediaType, error) {
+	return types.DockerManifestSchema2, nil
+}
+
 type testCIC struct {
 	CompressedImageCore
 	configFile []byte
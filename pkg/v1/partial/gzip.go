@@ -0,0 +1,157 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package partial
+
+import (
+	gogzip "compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sync"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/internal/and"
+	"github.com/google/go-containerregistry/pkg/v1/internal/gzip"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// CompressedOption is a functional option for CompressedFromUncompressed.
+type CompressedOption func(*compressedOptions)
+
+type compressedOptions struct {
+	level     int
+	mediaType types.MediaType
+}
+
+// WithCompressionLevel sets the gzip compression level, see:
+// https://golang.org/pkg/compress/gzip/#pkg-constants
+//
+// The default is gzip.BestSpeed.
+func WithCompressionLevel(level int) CompressedOption {
+	return func(o *compressedOptions) {
+		o.level = level
+	}
+}
+
+// WithMediaType overrides the media type of the resulting layer.
+//
+// The default is the MediaType of the UncompressedLayer.
+func WithMediaType(mt types.MediaType) CompressedOption {
+	return func(o *compressedOptions) {
+		o.mediaType = mt
+	}
+}
+
+// CompressedFromUncompressed returns a v1.Layer that gzips the contents of u
+// on the fly for Compressed.
+//
+// Digest, DiffID and Size are computed together in a single pass over u the
+// first time any of them is called, and cached after that. The DiffID of u is
+// not consulted.
+func CompressedFromUncompressed(u UncompressedLayer, opts ...CompressedOption) (v1.Layer, error) {
+	o := &compressedOptions{
+		level: gogzip.BestSpeed,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.level < gogzip.HuffmanOnly || o.level > gogzip.BestCompression {
+		return nil, fmt.Errorf("invalid gzip compression level: %d", o.level)
+	}
+
+	return &gzipLayer{
+		UncompressedLayer: u,
+		level:             o.level,
+		mediaType:         o.mediaType,
+	}, nil
+}
+
+type gzipLayer struct {
+	UncompressedLayer
+	level     int
+	mediaType types.MediaType
+
+	once   sync.Once
+	digest v1.Hash
+	diffID v1.Hash
+	size   int64
+	err    error
+}
+
+var _ v1.Layer = (*gzipLayer)(nil)
+
+// Compressed implements v1.Layer
+func (gl *gzipLayer) Compressed() (io.ReadCloser, error) {
+	u, err := gl.Uncompressed()
+	if err != nil {
+		return nil, err
+	}
+	return gzip.ReadCloserLevel(u, gl.level), nil
+}
+
+// Digest implements v1.Layer
+func (gl *gzipLayer) Digest() (v1.Hash, error) {
+	gl.compute()
+	return gl.digest, gl.err
+}
+
+// DiffID implements v1.Layer
+func (gl *gzipLayer) DiffID() (v1.Hash, error) {
+	gl.compute()
+	return gl.diffID, gl.err
+}
+
+// Size implements v1.Layer
+func (gl *gzipLayer) Size() (int64, error) {
+	gl.compute()
+	return gl.size, gl.err
+}
+
+// MediaType implements v1.Layer
+func (gl *gzipLayer) MediaType() (types.MediaType, error) {
+	if gl.mediaType != "" {
+		return gl.mediaType, nil
+	}
+	return gl.UncompressedLayer.MediaType()
+}
+
+// compute hashes the uncompressed stream as it is fed into gzip, and the gzip
+// output as it comes out, so both hashes only cost one read of the contents.
+func (gl *gzipLayer) compute() {
+	gl.once.Do(func() {
+		u, err := gl.Uncompressed()
+		if err != nil {
+			gl.err = err
+			return
+		}
+
+		h := sha256.New()
+		zr := gzip.ReadCloserLevel(&and.ReadCloser{
+			Reader:    io.TeeReader(u, h),
+			CloseFunc: u.Close,
+		}, gl.level)
+		defer zr.Close()
+
+		gl.digest, gl.size, gl.err = v1.SHA256(zr)
+		if gl.err != nil {
+			return
+		}
+		gl.diffID = v1.Hash{
+			Algorithm: "sha256",
+			Hex:       hex.EncodeToString(h.Sum(nil)),
+		}
+	})
+}
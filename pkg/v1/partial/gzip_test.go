@@ -0,0 +1,126 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package partial_test
+
+import (
+	"compress/gzip"
+	"io"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+// uncompressedOnly hides everything but the UncompressedLayer methods.
+type uncompressedOnly struct {
+	l     v1.Layer
+	reads int
+}
+
+func (u *uncompressedOnly) DiffID() (v1.Hash, error) {
+	return u.l.DiffID()
+}
+
+func (u *uncompressedOnly) Uncompressed() (io.ReadCloser, error) {
+	u.reads++
+	return u.l.Uncompressed()
+}
+
+func (u *uncompressedOnly) MediaType() (types.MediaType, error) {
+	return u.l.MediaType()
+}
+
+func TestCompressedFromUncompressed(t *testing.T) {
+	rnd, err := random.Layer(10000, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := &uncompressedOnly{l: rnd}
+
+	l, err := partial.CompressedFromUncompressed(u, partial.WithMediaType(types.OCILayer))
+	if err != nil {
+		t.Fatalf("CompressedFromUncompressed() = %v", err)
+	}
+
+	wantDiffID, err := rnd.DiffID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := l.DiffID(); err != nil {
+		t.Errorf("DiffID() = %v", err)
+	} else if got != wantDiffID {
+		t.Errorf("DiffID() = %v, want %v", got, wantDiffID)
+	}
+	if _, err := l.Digest(); err != nil {
+		t.Errorf("Digest() = %v", err)
+	}
+	if _, err := l.Size(); err != nil {
+		t.Errorf("Size() = %v", err)
+	}
+	if u.reads != 1 {
+		t.Errorf("Uncompressed() called %d times computing hashes, want 1", u.reads)
+	}
+
+	if mt, err := l.MediaType(); err != nil {
+		t.Errorf("MediaType() = %v", err)
+	} else if mt != types.OCILayer {
+		t.Errorf("MediaType() = %v, want %v", mt, types.OCILayer)
+	}
+
+	// Checks Digest and Size against Compressed, and DiffID against Uncompressed.
+	if err := validate.Layer(l); err != nil {
+		t.Errorf("validate.Layer() = %v", err)
+	}
+}
+
+func TestCompressedFromUncompressedLevel(t *testing.T) {
+	rnd, err := random.Layer(10000, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	none, err := partial.CompressedFromUncompressed(rnd, partial.WithCompressionLevel(gzip.NoCompression))
+	if err != nil {
+		t.Fatalf("CompressedFromUncompressed() = %v", err)
+	}
+	best, err := partial.CompressedFromUncompressed(rnd, partial.WithCompressionLevel(gzip.BestCompression))
+	if err != nil {
+		t.Fatalf("CompressedFromUncompressed() = %v", err)
+	}
+	for _, l := range []v1.Layer{none, best} {
+		if err := validate.Layer(l); err != nil {
+			t.Errorf("validate.Layer() = %v", err)
+		}
+	}
+
+	noneSize, err := none.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bestSize, err := best.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bestSize >= noneSize {
+		t.Errorf("BestCompression size %d should be smaller than NoCompression size %d", bestSize, noneSize)
+	}
+
+	if _, err := partial.CompressedFromUncompressed(rnd, partial.WithCompressionLevel(100)); err == nil {
+		t.Error("CompressedFromUncompressed(level=100) = nil, want error")
+	}
+}
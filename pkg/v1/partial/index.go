@@ -16,6 +16,7 @@ package partial
 
 import (
 	"fmt"
+	"strings"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/match"
@@ -83,3 +84,65 @@ func FindIndexes(index v1.ImageIndex, matcher match.Matcher) ([]v1.ImageIndex, e
 	}
 	return matches, nil
 }
+
+// FindImage returns the image in index for the given platform, descending
+// into nested indexes. Children match if their OS and architecture are the
+// same as platform's, as are their OS version and variant if platform sets
+// them, and if they have all of platform's features and OS features. An
+// arm64 child without a variant matches the implied "v8" variant and vice
+// versa.
+//
+// It returns an error if no image, or more than one, matches.
+func FindImage(index v1.ImageIndex, platform v1.Platform) (v1.Image, error) {
+	var matches []v1.Image
+	var digests []string
+	if err := findImages(index, platform, &matches, &digests); err != nil {
+		return nil, err
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no image for platform %s in index", platformString(platform))
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, fmt.Errorf("ambiguous platform %s, matched by %s", platformString(platform), strings.Join(digests, ", "))
+	}
+}
+
+func findImages(index v1.ImageIndex, platform v1.Platform, matches *[]v1.Image, digests *[]string) error {
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return fmt.Errorf("unable to get raw index: %v", err)
+	}
+	for _, desc := range indexManifest.Manifests {
+		switch {
+		case desc.MediaType.IsIndex():
+			child, err := index.ImageIndex(desc.Digest)
+			if err != nil {
+				return err
+			}
+			if err := findImages(child, platform, matches, digests); err != nil {
+				return err
+			}
+		case desc.MediaType.IsImage():
+			if desc.Platform == nil || !desc.Platform.Satisfies(platform) {
+				continue
+			}
+			img, err := index.Image(desc.Digest)
+			if err != nil {
+				return err
+			}
+			*matches = append(*matches, img)
+			*digests = append(*digests, desc.Digest.String())
+		}
+	}
+	return nil
+}
+
+func platformString(p v1.Platform) string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
@@ -18,6 +18,7 @@ import (
 	"testing"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/partial"
 	"github.com/google/go-containerregistry/pkg/v1/random"
@@ -120,3 +121,64 @@ func TestFindIndexes(t *testing.T) {
 		t.Errorf("failed on index, actual %d, expected %d", len(idxes), indexCount)
 	}
 }
+
+func TestFindImage(t *testing.T) {
+	images := map[string]v1.Image{}
+	addenda := map[string]mutate.IndexAddendum{}
+	for _, p := range []v1.Platform{
+		{OS: "linux", Architecture: "amd64"},
+		{OS: "linux", Architecture: "arm64"},
+		{OS: "linux", Architecture: "arm", Variant: "v6"},
+		{OS: "linux", Architecture: "arm", Variant: "v7"},
+	} {
+		p := p
+		img, err := random.Image(100, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key := p.Architecture + p.Variant
+		images[key] = img
+		addenda[key] = mutate.IndexAddendum{
+			Add:        img,
+			Descriptor: v1.Descriptor{Platform: &p},
+		}
+	}
+	// The arm/v7 image is in a nested index.
+	nested := mutate.AppendManifests(empty.Index, addenda["armv7"])
+	ii := mutate.AppendManifests(empty.Index, addenda["amd64"], addenda["arm64"], addenda["armv6"], mutate.IndexAddendum{Add: nested})
+
+	for _, tc := range []struct {
+		platform v1.Platform
+		want     string
+	}{
+		{platform: v1.Platform{OS: "linux", Architecture: "amd64"}, want: "amd64"},
+		{platform: v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}, want: "arm64"},
+		{platform: v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, want: "armv7"},
+		// Both arm images match.
+		{platform: v1.Platform{OS: "linux", Architecture: "arm"}},
+		{platform: v1.Platform{OS: "windows", Architecture: "amd64"}},
+	} {
+		img, err := partial.FindImage(ii, tc.platform)
+		if tc.want == "" {
+			if err == nil {
+				t.Errorf("FindImage(%v) = nil error, wanted error", tc.platform)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("FindImage(%v) = %v", tc.platform, err)
+			continue
+		}
+		got, err := img.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := images[tc.want].Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("FindImage(%v) = %s, want %s", tc.platform, got, want)
+		}
+	}
+}
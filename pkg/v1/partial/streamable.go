@@ -0,0 +1,164 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package partial
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/internal/and"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// ErrSizeNotComputed is returned by the Size of a StreamableLayer whose size
+// wasn't given, until its contents have been read once.
+var ErrSizeNotComputed = errors.New("size not computed until the layer has been read")
+
+// StreamableLayer returns a v1.Layer whose compressed contents are produced
+// by opener, which is called again each time they are needed, so they never
+// have to be held in memory.
+//
+// The Digest is computed from the first time the contents are read in full,
+// either by a caller of Compressed or, failing that, by Digest itself. If size
+// is negative, it is unknown, and Size returns ErrSizeNotComputed until the
+// contents have been read; otherwise reading fewer or more bytes than size is
+// an error.
+//
+// Unlike stream.Layer, the contents can be read any number of times.
+func StreamableLayer(opener func() (io.ReadCloser, error), size int64, mt types.MediaType) (v1.Layer, error) {
+	return CompressedToLayer(&streamableLayer{
+		opener: opener,
+		size:   size,
+		mt:     mt,
+	})
+}
+
+type streamableLayer struct {
+	opener func() (io.ReadCloser, error)
+	mt     types.MediaType
+
+	mu     sync.Mutex
+	size   int64
+	digest *v1.Hash
+}
+
+// Digest implements CompressedLayer
+func (l *streamableLayer) Digest() (v1.Hash, error) {
+	l.mu.Lock()
+	digest := l.digest
+	l.mu.Unlock()
+	if digest != nil {
+		return *digest, nil
+	}
+
+	// Nobody has read the contents yet, so do that now.
+	rc, err := l.Compressed()
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	defer rc.Close()
+	if _, err := io.Copy(ioutil.Discard, rc); err != nil {
+		return v1.Hash{}, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return *l.digest, nil
+}
+
+// Size implements CompressedLayer
+func (l *streamableLayer) Size() (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.size < 0 {
+		return -1, ErrSizeNotComputed
+	}
+	return l.size, nil
+}
+
+// MediaType implements CompressedLayer
+func (l *streamableLayer) MediaType() (types.MediaType, error) {
+	return l.mt, nil
+}
+
+// Compressed implements CompressedLayer
+func (l *streamableLayer) Compressed() (io.ReadCloser, error) {
+	rc, err := l.opener()
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	computed := l.digest != nil
+	l.mu.Unlock()
+	if computed {
+		return rc, nil
+	}
+
+	h, err := v1.Hasher("sha256")
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+	return &and.ReadCloser{
+		Reader:    &hashingReader{inner: rc, hasher: h, layer: l},
+		CloseFunc: rc.Close,
+	}, nil
+}
+
+// hashingReader hashes the contents of a streamableLayer as they are read,
+// recording the digest and size once they have been read in full.
+type hashingReader struct {
+	inner  io.Reader
+	hasher hash.Hash
+	layer  *streamableLayer
+	n      int64
+}
+
+func (r *hashingReader) Read(b []byte) (int, error) {
+	n, err := r.inner.Read(b)
+	r.hasher.Write(b[:n])
+	r.n += int64(n)
+	if err == io.EOF {
+		if err := r.layer.computed(r.hasher, r.n); err != nil {
+			return n, err
+		}
+	}
+	return n, err
+}
+
+// computed records the digest and size of the contents once they have been
+// read in full, checking them against the expected size, if any.
+func (l *streamableLayer) computed(h hash.Hash, n int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.size >= 0 && n != l.size {
+		return fmt.Errorf("read %d bytes, expected %d", n, l.size)
+	}
+	l.size = n
+	if l.digest == nil {
+		l.digest = &v1.Hash{
+			Algorithm: "sha256",
+			Hex:       hex.EncodeToString(h.Sum(nil)),
+		}
+	}
+	return nil
+}
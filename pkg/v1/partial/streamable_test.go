@@ -0,0 +1,101 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package partial_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func TestStreamableLayer(t *testing.T) {
+	rl, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := rl.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opens := 0
+	opener := func() (io.ReadCloser, error) {
+		opens++
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	}
+
+	l, err := partial.StreamableLayer(opener, -1, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Size(); !errors.Is(err, partial.ErrSizeNotComputed) {
+		t.Errorf("Size() = %v, want ErrSizeNotComputed", err)
+	}
+
+	// Reading the contents computes the digest and size along the way.
+	rc, err = l.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadAll(rc); err != nil {
+		t.Fatal(err)
+	}
+	rc.Close()
+	if size, err := l.Size(); err != nil || size != int64(len(b)) {
+		t.Errorf("Size() = %d, %v, want %d", size, err, len(b))
+	}
+	for _, tc := range []struct {
+		name      string
+		got, want func() (v1.Hash, error)
+	}{
+		{"Digest", l.Digest, rl.Digest},
+		{"DiffID", l.DiffID, rl.DiffID},
+	} {
+		got, err := tc.got()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := tc.want()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s() = %s, want %s", tc.name, got, want)
+		}
+	}
+	// Once for Compressed, once for DiffID.
+	if opens != 2 {
+		t.Errorf("opened %d times, want 2", opens)
+	}
+
+	// Digest reads the contents itself if nobody has, and checks the size.
+	l, err = partial.StreamableLayer(opener, int64(len(b))+1, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Digest(); err == nil {
+		t.Error("Digest() with the wrong size = nil, want error")
+	}
+}
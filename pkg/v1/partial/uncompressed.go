@@ -77,11 +77,14 @@ func (ule *uncompressedLayerExtender) calcSizeHash() {
 			return
 		}
 		defer r.Close()
-		ule.hash, ule.size, ule.hashSizeError = v1.SHA256(r)
+		ule.hash, ule.size, ule.hashSizeError = v1.ComputeHash(hashAlgorithm(ule.UncompressedLayer), r)
 	})
 }
 
 // UncompressedToLayer fills in the missing methods from an UncompressedLayer so that it implements v1.Layer
+//
+// The digest is computed with the algorithm of the digest in ul's descriptor,
+// if it has one, and with sha256 otherwise.
 func UncompressedToLayer(ul UncompressedLayer) (v1.Layer, error) {
 	return &uncompressedLayerExtender{UncompressedLayer: ul}, nil
 }
@@ -138,11 +141,22 @@ func (i *uncompressedImageExtender) Manifest() (*v1.Manifest, error) {
 		return nil, err
 	}
 
+	mt, err := i.MediaType()
+	if err != nil {
+		return nil, err
+	}
+
+	// Pair the config media type with the manifest media type.
+	cmt := types.DockerConfigJSON
+	if mt == types.OCIManifestSchema1 {
+		cmt = types.OCIConfigJSON
+	}
+
 	m := &v1.Manifest{
 		SchemaVersion: 2,
-		MediaType:     types.DockerManifestSchema2,
+		MediaType:     mt,
 		Config: v1.Descriptor{
-			MediaType: types.DockerConfigJSON,
+			MediaType: cmt,
 			Size:      cfgSize,
 			Digest:    cfgHash,
 		},
@@ -27,6 +27,7 @@ import (
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/partial"
 	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/static"
 	"github.com/google/go-containerregistry/pkg/v1/tarball"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/google/go-containerregistry/pkg/v1/validate"
@@ -107,6 +108,72 @@ func TestUncompressedLayer(t *testing.T) {
 	}
 }
 
+func TestUncompressedLayerAlgorithm(t *testing.T) {
+	rl, err := random.Layer(1024, types.OCIUncompressedLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := rl.Uncompressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	b, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := static.NewLayerWithAlgorithm(b, types.OCIUncompressedLayer, "sha512")
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc, err := partial.Descriptor(l)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The digest uses the algorithm of the layer's descriptor.
+	layer, err := partial.UncompressedToLayer(&describedUncompressed{uncompressedOnly: &uncompressedOnly{l: l}, desc: *desc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err := layer.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := digest.Algorithm, "sha512"; got != want {
+		t.Errorf("Digest().Algorithm = %s, want %s", got, want)
+	}
+	if err := validate.Layer(layer); err != nil {
+		t.Errorf("validate.Layer: %v", err)
+	}
+
+	// Without one, it's sha256, and the layer is only read to compute it.
+	u := &uncompressedOnly{l: l}
+	layer, err = partial.UncompressedToLayer(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err = layer.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := digest.Algorithm, "sha256"; got != want {
+		t.Errorf("Digest().Algorithm = %s, want %s", got, want)
+	}
+	if u.reads != 1 {
+		t.Errorf("Digest() read the layer %d times, want 1", u.reads)
+	}
+}
+
+type describedUncompressed struct {
+	*uncompressedOnly
+	desc v1.Descriptor
+}
+
+func (d *describedUncompressed) Descriptor() (*v1.Descriptor, error) {
+	return &d.desc, nil
+}
+
 // legacy/tarball.Write + tarball.Image leverages a lot of uncompressed partials.
 //
 // This is cribbed from pkg/legacy/tarball just to get intra-package coverage.
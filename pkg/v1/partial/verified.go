@@ -0,0 +1,73 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package partial
+
+import (
+	"io"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/internal/verify"
+)
+
+// DigestMismatchError is returned by the streams of a VerifiedLayer when their
+// contents don't match the expected hash. It records how many bytes were read,
+// so a truncated stream can be told apart from corrupted contents.
+type DigestMismatchError = verify.Error
+
+// VerifiedLayer wraps a v1.Layer so that the streams returned by Compressed
+// and Uncompressed verify that their contents hash to the layer's Digest and
+// DiffID, respectively. A mismatch is reported as an error from the final
+// Read, so callers must consume the stream until io.EOF for it to be checked.
+//
+// Contents are hashed as they are read, so the layer is never buffered.
+func VerifiedLayer(l v1.Layer) v1.Layer {
+	return &verifiedLayer{Layer: l}
+}
+
+type verifiedLayer struct {
+	v1.Layer
+}
+
+// Compressed implements v1.Layer
+func (vl *verifiedLayer) Compressed() (io.ReadCloser, error) {
+	digest, err := vl.Layer.Digest()
+	if err != nil {
+		return nil, err
+	}
+	rc, err := vl.Layer.Compressed()
+	if err != nil {
+		return nil, err
+	}
+	return verify.ReadCloser(rc, digest)
+}
+
+// Uncompressed implements v1.Layer
+func (vl *verifiedLayer) Uncompressed() (io.ReadCloser, error) {
+	diffID, err := vl.Layer.DiffID()
+	if err != nil {
+		return nil, err
+	}
+	rc, err := vl.Layer.Uncompressed()
+	if err != nil {
+		return nil, err
+	}
+	return verify.ReadCloser(rc, diffID)
+}
+
+// Descriptor implements withDescriptor so that wrapping doesn't drop
+// annotations or URLs of the underlying layer.
+func (vl *verifiedLayer) Descriptor() (*v1.Descriptor, error) {
+	return Descriptor(vl.Layer)
+}
@@ -0,0 +1,74 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package partial_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+// tamperedLayer serves different compressed bytes than it claims.
+type tamperedLayer struct {
+	v1.Layer
+}
+
+func (tl *tamperedLayer) Compressed() (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewBufferString("tampered")), nil
+}
+
+func (tl *tamperedLayer) Uncompressed() (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewBufferString("tampered")), nil
+}
+
+func TestVerifiedLayer(t *testing.T) {
+	l, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vl := partial.VerifiedLayer(l)
+	if err := validate.Layer(vl); err != nil {
+		t.Errorf("validate.Layer(VerifiedLayer) = %v", err)
+	}
+
+	if got, err := vl.MediaType(); err != nil {
+		t.Fatal(err)
+	} else if got != types.DockerLayer {
+		t.Errorf("MediaType() = %v, want %v", got, types.DockerLayer)
+	}
+
+	bad := partial.VerifiedLayer(&tamperedLayer{l})
+	for name, open := range map[string]func() (io.ReadCloser, error){
+		"Compressed":   bad.Compressed,
+		"Uncompressed": bad.Uncompressed,
+	} {
+		rc, err := open()
+		if err != nil {
+			t.Fatalf("%s() = %v", name, err)
+		}
+		if _, err := ioutil.ReadAll(rc); err == nil {
+			t.Errorf("%s(): expected verification error", name)
+		}
+		rc.Close()
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package partial
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// ErrStopWalk can be returned by the callback passed to Walk to stop walking
+// without an error.
+var ErrStopWalk = errors.New("stop walking the image")
+
+const (
+	whiteoutPrefix = ".wh."
+	// opaqueWhiteout is ".wh..wh..opq" with whiteoutPrefix removed.
+	opaqueWhiteout = ".wh..opq"
+)
+
+// FileInfo describes a file in the flattened filesystem of an image, see Walk.
+type FileInfo struct {
+	// Path is the cleaned path of the file, e.g. "etc/hosts".
+	Path string
+
+	// Header is the tar header of the file in the layer that last wrote it,
+	// with its size, mode, owner, type, link target and so on.
+	Header *tar.Header
+
+	// Layer is the index in img.Layers() of the layer that last wrote it.
+	Layer int
+}
+
+// Walk calls fn for every file in the flattened filesystem of img, i.e. what
+// mutate.Extract would write, but without reading the contents of any file.
+// Files that are deleted or hidden by whiteouts in a higher layer are
+// skipped, and each path is reported once, with the attributes it has in the
+// topmost layer that contains it.
+//
+// The layers are walked from the top down, so files in higher layers are
+// reported first. If fn returns ErrStopWalk, Walk stops and returns nil; any
+// other error stops the walk and is returned as is.
+func Walk(img v1.Image, fn func(FileInfo) error) error {
+	layers, err := img.Layers()
+	if err != nil {
+		return fmt.Errorf("retrieving image layers: %v", err)
+	}
+
+	w := &walker{
+		seen:   map[string]bool{},
+		opaque: map[string]bool{},
+		fn:     fn,
+	}
+	for i := len(layers) - 1; i >= 0; i-- {
+		if err := w.walkLayer(i, layers[i]); err == ErrStopWalk {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type walker struct {
+	// Maps the paths we've seen to whether they hide paths beneath them,
+	// i.e. whether they are whiteouts or non-directories.
+	seen map[string]bool
+	// Directories made opaque by a higher layer.
+	opaque map[string]bool
+	fn     func(FileInfo) error
+}
+
+func (w *walker) walkLayer(i int, layer v1.Layer) error {
+	rc, err := layer.Uncompressed()
+	if err != nil {
+		return fmt.Errorf("reading layer contents: %v", err)
+	}
+	defer rc.Close()
+
+	// Opaque directories only hide lower layers, not this one, so wait until
+	// we're done with this layer before honoring them.
+	var opaque []string
+	tr := tar.NewReader(rc)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("reading tar: %v", err)
+		}
+
+		name := cleanPath(header.Name)
+		dir, base := path.Dir(name), path.Base(name)
+		tombstone := strings.HasPrefix(base, whiteoutPrefix)
+		if tombstone {
+			base = base[len(whiteoutPrefix):]
+			if base == opaqueWhiteout {
+				opaque = append(opaque, dir)
+				continue
+			}
+			name = path.Join(dir, base)
+		}
+		if name == "." {
+			continue
+		}
+
+		if _, ok := w.seen[name]; ok || w.hidden(name) {
+			continue
+		}
+		w.seen[name] = tombstone || header.Typeflag != tar.TypeDir
+		if tombstone {
+			continue
+		}
+
+		if err := w.fn(FileInfo{Path: name, Header: header, Layer: i}); err != nil {
+			return err
+		}
+	}
+	for _, dir := range opaque {
+		w.opaque[dir] = true
+	}
+	return nil
+}
+
+// hidden returns whether a parent directory of name has been deleted,
+// replaced by a file, or made opaque by a higher layer.
+func (w *walker) hidden(name string) bool {
+	for dir := path.Dir(name); ; dir = path.Dir(dir) {
+		if w.seen[dir] || w.opaque[dir] {
+			return true
+		}
+		if dir == "." {
+			return false
+		}
+	}
+}
+
+// cleanPath normalizes tar entry names like "./etc/hosts", "/etc/hosts" and
+// "etc/" to "etc/hosts" and "etc", or "." for the root.
+func cleanPath(name string) string {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
@@ -0,0 +1,112 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package partial_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+// walkLayer returns a layer with an entry for each name; names ending in "/"
+// are directories, and the rest are files containing their own name.
+func walkLayer(t *testing.T, names ...string) v1.Layer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range names {
+		hdr := &tar.Header{Name: name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(name))}
+		if name[len(name)-1] == '/' {
+			hdr.Typeflag, hdr.Mode, hdr.Size = tar.TypeDir, 0755, 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Size > 0 {
+			if _, err := tw.Write([]byte(name)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	l, err := tarball.LayerFromReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestWalk(t *testing.T) {
+	img, err := mutate.AppendLayers(empty.Image,
+		walkLayer(t, "etc/", "etc/hosts", "etc/passwd", "opt/", "opt/a", "var/", "var/cache/", "var/cache/x", "tmp/"),
+		walkLayer(t, "./etc/hosts", "etc/.wh.passwd", "opt/.wh..wh..opq", "opt/b", "var/.wh.cache", "tmp"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]int{}
+	if err := partial.Walk(img, func(fi partial.FileInfo) error {
+		if _, ok := got[fi.Path]; ok {
+			t.Errorf("Walk() reported %s twice", fi.Path)
+		}
+		got[fi.Path] = fi.Layer
+		if fi.Path == "etc/hosts" && fi.Header.Size != int64(len("./etc/hosts")) {
+			t.Errorf("etc/hosts has size %d, want the size from the top layer", fi.Header.Size)
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("Walk() = %v", err)
+	}
+	want := map[string]int{
+		"etc":       0,
+		"etc/hosts": 1,
+		"opt":       0,
+		"opt/b":     1,
+		"var":       0,
+		"tmp":       1,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Walk() (-want +got) = %s", diff)
+	}
+
+	n := 0
+	if err := partial.Walk(img, func(fi partial.FileInfo) error {
+		n++
+		return partial.ErrStopWalk
+	}); err != nil {
+		t.Errorf("Walk() = %v, want nil after ErrStopWalk", err)
+	}
+	if n != 1 {
+		t.Errorf("Walk() called fn %d times after ErrStopWalk, want 1", n)
+	}
+
+	wantErr := errors.New("boom")
+	if err := partial.Walk(img, func(fi partial.FileInfo) error {
+		return wantErr
+	}); err != wantErr {
+		t.Errorf("Walk() = %v, want %v", err, wantErr)
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"io/ioutil"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/internal/and"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 )
 
@@ -111,6 +112,27 @@ type WithConfigFile interface {
 	ConfigFile() (*v1.ConfigFile, error)
 }
 
+// Label returns the value of the label key in the config of i, and whether
+// it is set. Docker and OCI configs both keep labels in Config.Labels.
+func Label(i WithConfigFile, key string) (string, bool, error) {
+	cfg, err := i.ConfigFile()
+	if err != nil {
+		return "", false, err
+	}
+	v, ok := cfg.Config.Labels[key]
+	return v, ok, nil
+}
+
+// History returns a copy of the history in the config of i, see
+// mutate.History to replace it.
+func History(i WithConfigFile) ([]v1.History, error) {
+	cfg, err := i.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	return append([]v1.History{}, cfg.History...), nil
+}
+
 // DiffIDs is a helper for implementing v1.Image
 func DiffIDs(i WithConfigFile) ([]v1.Hash, error) {
 	cfg, err := i.ConfigFile()
@@ -284,6 +306,18 @@ type withDescriptor interface {
 	Descriptor() (*v1.Descriptor, error)
 }
 
+// hashAlgorithm returns the algorithm of the digest in the descriptor of l, if
+// it has one, or sha256, so that layers whose digest or DiffID is computed use
+// the same algorithm as the one they're described with, without reading them.
+func hashAlgorithm(l interface{}) string {
+	if wd, ok := l.(withDescriptor); ok {
+		if desc, err := wd.Descriptor(); err == nil && desc != nil && desc.Digest.Algorithm != "" {
+			return desc.Digest.Algorithm
+		}
+	}
+	return "sha256"
+}
+
 // Describable represents something for which we can produce a v1.Descriptor.
 type Describable interface {
 	Digest() (v1.Hash, error)
@@ -296,6 +330,93 @@ type Describable interface {
 // CompressedToLayer, UncompressedToLayer, CompressedToImage, or
 // UncompressedToImage.
 func Descriptor(d Describable) (*v1.Descriptor, error) {
+	return DescriptorWithOptions(d)
+}
+
+// DescriptorWithOptions is like Descriptor, but applies opts to a copy of the
+// descriptor, e.g. to attach annotations or a platform before passing it to
+// mutate.AppendManifests.
+func DescriptorWithOptions(d Describable, opts ...DescriptorOption) (*v1.Descriptor, error) {
+	desc, err := describe(d)
+	if err != nil {
+		return nil, err
+	}
+	if len(opts) == 0 {
+		return desc, nil
+	}
+	desc = desc.DeepCopy()
+	for _, opt := range opts {
+		opt(desc)
+	}
+	return desc, nil
+}
+
+// ChildDescriptor is like Descriptor, but also fills in the platform of an
+// image from its config if the descriptor doesn't have one already, so that
+// the result can be used as is to add d to an index, e.g. as the Descriptor of
+// a mutate.IndexAddendum. Configs that don't set an OS or architecture, like
+// those of most artifacts, don't yield a platform.
+//
+// As with DescriptorWithOptions, opts are applied last, so they take
+// precedence.
+func ChildDescriptor(d Describable, opts ...DescriptorOption) (*v1.Descriptor, error) {
+	desc, err := describe(d)
+	if err != nil {
+		return nil, err
+	}
+	desc = desc.DeepCopy()
+	if wcf, ok := d.(WithConfigFile); ok && desc.Platform == nil && desc.MediaType.IsImage() {
+		cf, err := wcf.ConfigFile()
+		if err != nil {
+			return nil, err
+		}
+		if cf.OS != "" || cf.Architecture != "" {
+			desc.Platform = &v1.Platform{
+				OS:           cf.OS,
+				Architecture: cf.Architecture,
+				OSVersion:    cf.OSVersion,
+			}
+		}
+	}
+	for _, opt := range opts {
+		opt(desc)
+	}
+	return desc, nil
+}
+
+// DescriptorOption modifies the descriptor returned by DescriptorWithOptions
+// or ChildDescriptor.
+type DescriptorOption func(*v1.Descriptor)
+
+// WithAnnotations sets the annotations of the descriptor.
+func WithAnnotations(annotations map[string]string) DescriptorOption {
+	return func(desc *v1.Descriptor) {
+		desc.Annotations = annotations
+	}
+}
+
+// WithPlatform sets the platform of the descriptor.
+func WithPlatform(platform *v1.Platform) DescriptorOption {
+	return func(desc *v1.Descriptor) {
+		desc.Platform = platform
+	}
+}
+
+// WithURLs sets the URLs from which the descriptor's content may be fetched.
+func WithURLs(urls []string) DescriptorOption {
+	return func(desc *v1.Descriptor) {
+		desc.URLs = urls
+	}
+}
+
+// WithArtifactType sets the artifact type of the descriptor.
+func WithArtifactType(at string) DescriptorOption {
+	return func(desc *v1.Descriptor) {
+		desc.ArtifactType = at
+	}
+}
+
+func describe(d Describable) (*v1.Descriptor, error) {
 	// If Describable implements Descriptor itself, return that.
 	if wd, ok := d.(withDescriptor); ok {
 		return wd.Descriptor()
@@ -380,3 +501,132 @@ func UncompressedSize(l v1.Layer) (int64, error) {
 
 	return io.Copy(ioutil.Discard, rc)
 }
+
+// ImageUncompressedSize returns the sum of the uncompressed sizes of the
+// layers of img, i.e. roughly how much disk space it takes once unpacked.
+// Layers that appear more than once are only counted once. See
+// UncompressedSize for how the size of each layer is computed; for most
+// images this reads every layer.
+func ImageUncompressedSize(img v1.Image) (int64, error) {
+	layers, err := img.Layers()
+	if err != nil {
+		return -1, err
+	}
+	seen := map[v1.Hash]bool{}
+	var total int64
+	for _, l := range layers {
+		diffID, err := l.DiffID()
+		if err != nil {
+			return -1, err
+		}
+		if seen[diffID] {
+			continue
+		}
+		seen[diffID] = true
+		size, err := UncompressedSize(l)
+		if err != nil {
+			return -1, fmt.Errorf("computing uncompressed size of %v: %w", diffID, err)
+		}
+		total += size
+	}
+	return total, nil
+}
+
+// CompressedSize returns the sum of the sizes of the layers in the manifest of
+// i, not including the config. Layers that appear more than once are only
+// counted once. This doesn't read any layers, so it's cheap if the manifest
+// has already been fetched. See ImageUncompressedSize for the size once
+// unpacked.
+func CompressedSize(i WithManifest) (int64, error) {
+	m, err := i.Manifest()
+	if err != nil {
+		return -1, err
+	}
+	seen := map[v1.Hash]bool{}
+	var total int64
+	for _, desc := range m.Layers {
+		if seen[desc.Digest] {
+			continue
+		}
+		seen[desc.Digest] = true
+		total += desc.Size
+	}
+	return total, nil
+}
+
+// RangeReadLayer is implemented by layers that can read part of their
+// compressed contents without reading everything before it, e.g. with an HTTP
+// Range request.
+type RangeReadLayer interface {
+	// CompressedRange returns length bytes of the compressed layer contents
+	// starting at offset, or everything after offset if length is negative.
+	// Unlike Compressed, the contents are not checked against the digest.
+	CompressedRange(offset, length int64) (io.ReadCloser, error)
+}
+
+// CompressedRange returns length bytes of the compressed contents of l starting
+// at offset, or everything after offset if length is negative. If l doesn't
+// implement RangeReadLayer, this reads and discards the contents up to offset.
+func CompressedRange(l WithCompressed, offset, length int64) (io.ReadCloser, error) {
+	if offset < 0 {
+		return nil, fmt.Errorf("negative offset %d", offset)
+	}
+
+	// If the layer implements CompressedRange itself, use that.
+	if rrl, ok := l.(RangeReadLayer); ok {
+		return rrl.CompressedRange(offset, length)
+	}
+
+	// Otherwise, try to unwrap any partial implementations to see
+	// if the wrapped struct implements CompressedRange.
+	if cle, ok := l.(*compressedLayerExtender); ok {
+		if rrl, ok := cle.CompressedLayer.(RangeReadLayer); ok {
+			return rrl.CompressedRange(offset, length)
+		}
+	}
+
+	rc, err := l.Compressed()
+	if err != nil {
+		return nil, err
+	}
+	return SkipAndLimit(rc, offset, length)
+}
+
+// SkipAndLimit discards the first offset bytes of rc and returns a reader of
+// at most length bytes of the rest, or all of it if length is negative. It
+// is a helper for implementing RangeReadLayer.
+func SkipAndLimit(rc io.ReadCloser, offset, length int64) (io.ReadCloser, error) {
+	if _, err := io.CopyN(ioutil.Discard, rc, offset); err != nil {
+		rc.Close()
+		if err == io.EOF {
+			return nil, fmt.Errorf("offset %d is past the end of the blob", offset)
+		}
+		return nil, err
+	}
+	if length < 0 {
+		return rc, nil
+	}
+	return &and.ReadCloser{
+		Reader:    io.LimitReader(rc, length),
+		CloseFunc: rc.Close,
+	}, nil
+}
+
+// WithCompressed defines the subset of v1.Layer used by CompressedBlobSize.
+type WithCompressed interface {
+	// Compressed returns an io.ReadCloser for the compressed layer contents.
+	Compressed() (io.ReadCloser, error)
+}
+
+// CompressedBlobSize is a helper for implementing v1.Layer. It returns the size
+// of the blob returned by Compressed(), by reading all of it. Unlike BlobSize,
+// it doesn't need a manifest that describes the blob.
+func CompressedBlobSize(l WithCompressed) (int64, error) {
+	rc, err := l.Compressed()
+	if err != nil {
+		return -1, err
+	}
+	defer rc.Close()
+
+	return io.Copy(ioutil.Discard, rc)
+}
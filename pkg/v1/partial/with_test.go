@@ -15,10 +15,14 @@
 package partial_test
 
 import (
+	"bytes"
+	"io/ioutil"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/partial"
 	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/types"
@@ -217,3 +221,215 @@ func TestUncompressedSize(t *testing.T) {
 		t.Errorf("UncompressedSize() = %d != %d", got, want)
 	}
 }
+
+func TestCompressedBlobSize(t *testing.T) {
+	randLayer, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSize, err := randLayer.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	size, err := partial.CompressedBlobSize(randLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != wantSize {
+		t.Errorf("CompressedBlobSize() = %d != %d", size, wantSize)
+	}
+}
+
+func TestDescriptorOptions(t *testing.T) {
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := partial.Descriptor(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	platform := &v1.Platform{OS: "linux", Architecture: "arm64"}
+	annotations := map[string]string{"foo": "bar"}
+	urls := []string{"https://example.com"}
+	got, err := partial.DescriptorWithOptions(img,
+		partial.WithAnnotations(annotations),
+		partial.WithPlatform(platform),
+		partial.WithURLs(urls),
+		partial.WithArtifactType("application/vnd.example"),
+	)
+	if err != nil {
+		t.Fatalf("DescriptorWithOptions() = %v", err)
+	}
+
+	want := *base
+	want.Annotations = annotations
+	want.Platform = platform
+	want.URLs = urls
+	want.ArtifactType = "application/vnd.example"
+	if diff := cmp.Diff(&want, got); diff != "" {
+		t.Errorf("DescriptorWithOptions() (-want +got) = %s", diff)
+	}
+
+	// The options don't leak into descriptors computed without them.
+	again, err := partial.Descriptor(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(base, again); diff != "" {
+		t.Errorf("Descriptor() was modified by options (-want +got) = %s", diff)
+	}
+}
+
+func TestChildDescriptor(t *testing.T) {
+	base, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := base.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf = cf.DeepCopy()
+	cf.OS = "windows"
+	cf.Architecture = "amd64"
+	cf.OSVersion = "10.0.17763.1040"
+	img, err := mutate.ConfigFile(base, cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	desc, err := partial.ChildDescriptor(img)
+	if err != nil {
+		t.Fatalf("ChildDescriptor() = %v", err)
+	}
+	want := &v1.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.1040"}
+	if diff := cmp.Diff(want, desc.Platform); diff != "" {
+		t.Errorf("Platform (-want +got) = %s", diff)
+	}
+	plain, err := partial.Descriptor(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain.Platform != nil {
+		t.Errorf("Descriptor() was modified by ChildDescriptor: %v", plain.Platform)
+	}
+
+	// Options take precedence.
+	override := &v1.Platform{OS: "linux", Architecture: "arm64"}
+	desc, err = partial.ChildDescriptor(img, partial.WithPlatform(override))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc.Platform != override {
+		t.Errorf("Platform = %v, want %v", desc.Platform, override)
+	}
+
+	// Indexes don't have a platform.
+	idx, err := random.Index(1024, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc, err = partial.ChildDescriptor(idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc.Platform != nil {
+		t.Errorf("ChildDescriptor(index).Platform = %v, want nil", desc.Platform)
+	}
+
+	// The descriptor can be used as is to build an index.
+	desc, err = partial.ChildDescriptor(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ii := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{Add: img, Descriptor: *desc})
+	im, err := ii.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]v1.Descriptor{*desc}, im.Manifests); diff != "" {
+		t.Errorf("Manifests (-want +got) = %s", diff)
+	}
+}
+
+func TestCompressedRange(t *testing.T) {
+	l, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := l.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// random.Layer doesn't implement RangeReadLayer, so this falls back to
+	// skipping over the contents.
+	rc, err = partial.CompressedRange(l, 10, 20)
+	if err != nil {
+		t.Fatalf("CompressedRange() = %v", err)
+	}
+	got, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want[10:30]) {
+		t.Errorf("CompressedRange(10, 20) = %x, want %x", got, want[10:30])
+	}
+
+	if _, err := partial.CompressedRange(l, int64(len(want)+1), 1); err == nil {
+		t.Error("CompressedRange() past the end succeeded, want error")
+	}
+}
+
+func TestImageSizes(t *testing.T) {
+	img, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wantCompressed, wantUncompressed int64
+	for _, l := range layers {
+		size, err := l.Size()
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantCompressed += size
+		rc, err := l.Uncompressed()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantUncompressed += int64(len(b))
+	}
+
+	// Duplicate layers are only counted once.
+	img, err = mutate.AppendLayers(img, layers[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := partial.CompressedSize(img); err != nil {
+		t.Errorf("CompressedSize() = %v", err)
+	} else if got != wantCompressed {
+		t.Errorf("CompressedSize() = %d, want %d", got, wantCompressed)
+	}
+	if got, err := partial.ImageUncompressedSize(img); err != nil {
+		t.Errorf("ImageUncompressedSize() = %v", err)
+	} else if got != wantUncompressed {
+		t.Errorf("ImageUncompressedSize() = %d, want %d", got, wantUncompressed)
+	}
+}
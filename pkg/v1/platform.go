@@ -15,6 +15,7 @@
 package v1
 
 import (
+	"runtime"
 	"sort"
 )
 
@@ -28,6 +29,24 @@ type Platform struct {
 	Features     []string `json:"features,omitempty"`
 }
 
+// HostPlatform returns the platform of the running binary, as reported by
+// runtime.GOOS and runtime.GOARCH, e.g. to pass to remote.WithPlatform to pull
+// the image for this machine out of an index.
+//
+// For 32-bit arm the variant is the GOARM the binary was built for. The
+// variant is left empty for arm64, so that images with the implied "v8"
+// variant match either way.
+func HostPlatform() Platform {
+	p := Platform{
+		OS:           runtime.GOOS,
+		Architecture: runtime.GOARCH,
+	}
+	if p.Architecture == "arm" {
+		p.Variant = goarm
+	}
+	return p
+}
+
 // Equals returns true if the given platform is semantically equivalent to this one.
 // The order of Features and OSFeatures is not important.
 func (p Platform) Equals(o Platform) bool {
@@ -35,6 +54,55 @@ func (p Platform) Equals(o Platform) bool {
 		stringSliceEqualIgnoreOrder(p.OSFeatures, o.OSFeatures) && stringSliceEqualIgnoreOrder(p.Features, o.Features)
 }
 
+// Satisfies reports whether p, the platform of an image, matches spec, the
+// platform that was asked for. It does if
+//   - architecture and OS are identical.
+//   - OS version and variant are identical if spec has them. An empty arm64
+//     variant is taken to be "v8", the only one there is.
+//   - features and OS features of spec are subsets of those of p.
+func (p Platform) Satisfies(spec Platform) bool {
+	// Required fields that must be identical.
+	if p.Architecture != spec.Architecture || p.OS != spec.OS {
+		return false
+	}
+
+	// Optional fields that may be empty, but must be identical if provided.
+	if spec.OSVersion != "" && p.OSVersion != spec.OSVersion {
+		return false
+	}
+	if spec.Variant != "" && p.variant() != spec.variant() {
+		return false
+	}
+
+	// Verify spec's features are a subset of p's features.
+	return isSubset(p.OSFeatures, spec.OSFeatures) && isSubset(p.Features, spec.Features)
+}
+
+// variant returns the variant of p, filling in the one implied for
+// architectures that have a default.
+func (p Platform) variant() string {
+	if p.Variant == "" && p.Architecture == "arm64" {
+		return "v8"
+	}
+	return p.Variant
+}
+
+// isSubset checks if the required array of strings is a subset of the given lst.
+func isSubset(lst, required []string) bool {
+	set := make(map[string]bool)
+	for _, value := range lst {
+		set[value] = true
+	}
+
+	for _, value := range required {
+		if _, ok := set[value]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
 // stringSliceEqual compares 2 string slices and returns if their contents are identical.
 func stringSliceEqual(a, b []string) bool {
 	if len(a) != len(b) {
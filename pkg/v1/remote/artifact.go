@@ -0,0 +1,113 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// Artifact is a manifest that isn't necessarily a runnable image, e.g. an
+// SBOM or a signature. Its config and layers are exposed as descriptors,
+// without attempting to interpret their contents.
+type Artifact struct {
+	fetcher
+	descriptor v1.Descriptor
+	raw        []byte
+	manifest   *v1.Manifest
+}
+
+// Artifact converts the Descriptor into an Artifact.
+//
+// This works for any manifest that has the shape of an image manifest,
+// regardless of its config media type.
+func (d *Descriptor) Artifact() (*Artifact, error) {
+	switch d.MediaType {
+	case types.DockerManifestSchema1, types.DockerManifestSchema1Signed:
+		// We don't care to support schema 1 images:
+		// https://github.com/google/go-containerregistry/issues/377
+		return nil, newErrSchema1(d.MediaType)
+	case types.OCIImageIndex, types.DockerManifestList:
+		return nil, fmt.Errorf("unexpected media type for Artifact(): %s; call ImageIndex() instead", d.MediaType)
+	}
+
+	m, err := v1.ParseManifest(bytes.NewReader(d.Manifest))
+	if err != nil {
+		return nil, err
+	}
+	return &Artifact{
+		fetcher:    d.fetcher,
+		descriptor: d.Descriptor,
+		raw:        d.Manifest,
+		manifest:   m,
+	}, nil
+}
+
+// RawManifest implements Taggable.
+func (a *Artifact) RawManifest() ([]byte, error) {
+	return a.raw, nil
+}
+
+// Manifest returns the parsed manifest of the artifact.
+func (a *Artifact) Manifest() (*v1.Manifest, error) {
+	return a.manifest.DeepCopy(), nil
+}
+
+// MediaType implements partial.Describable.
+func (a *Artifact) MediaType() (types.MediaType, error) {
+	return a.descriptor.MediaType, nil
+}
+
+// Digest implements partial.Describable.
+func (a *Artifact) Digest() (v1.Hash, error) {
+	return a.descriptor.Digest, nil
+}
+
+// Size implements partial.Describable.
+func (a *Artifact) Size() (int64, error) {
+	return a.descriptor.Size, nil
+}
+
+// ArtifactType returns the type of the artifact. This is the manifest's
+// artifactType if it is set, otherwise the media type of its config.
+func (a *Artifact) ArtifactType() (string, error) {
+	if a.manifest.ArtifactType != "" {
+		return a.manifest.ArtifactType, nil
+	}
+	return string(a.manifest.Config.MediaType), nil
+}
+
+// Config returns the descriptor of the artifact's config blob.
+func (a *Artifact) Config() (v1.Descriptor, error) {
+	return a.manifest.Config, nil
+}
+
+// Layers returns the descriptors of the artifact's layers.
+func (a *Artifact) Layers() ([]v1.Descriptor, error) {
+	ls := make([]v1.Descriptor, len(a.manifest.Layers))
+	copy(ls, a.manifest.Layers)
+	return ls, nil
+}
+
+// Blob returns the contents of the blob with the given digest, which should
+// be the config or one of the layers of the artifact. The contents are
+// verified against the digest as they are read.
+func (a *Artifact) Blob(h v1.Hash) (io.ReadCloser, error) {
+	return a.fetchBlob(a.context, h)
+}
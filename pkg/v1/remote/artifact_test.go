@@ -0,0 +1,155 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+type rawManifest struct {
+	raw []byte
+	mt  types.MediaType
+}
+
+func (r *rawManifest) RawManifest() ([]byte, error) {
+	return r.raw, nil
+}
+
+func (r *rawManifest) MediaType() (types.MediaType, error) {
+	return r.mt, nil
+}
+
+func TestArtifact(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, err := name.NewTag(fmt.Sprintf("%s/test/artifact:sbom", u.Host))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := static.NewLayer([]byte("{}"), "application/vnd.example.sbom.config.v1+json")
+	sbom := static.NewLayer([]byte(`{"packages":[]}`), "application/spdx+json")
+	var descs []v1.Descriptor
+	for _, l := range []v1.Layer{config, sbom} {
+		if err := WriteLayer(tag.Context(), l); err != nil {
+			t.Fatal(err)
+		}
+		d, err := partial.Descriptor(l)
+		if err != nil {
+			t.Fatal(err)
+		}
+		descs = append(descs, *d)
+	}
+
+	want := v1.Manifest{
+		SchemaVersion: 2,
+		MediaType:     types.OCIManifestSchema1,
+		ArtifactType:  "application/vnd.example.sbom",
+		Config:        descs[0],
+		Layers:        descs[1:],
+	}
+	raw, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Tag(tag, &rawManifest{raw, types.OCIManifestSchema1}); err != nil {
+		t.Fatal(err)
+	}
+
+	desc, err := Get(tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := desc.Artifact()
+	if err != nil {
+		t.Fatalf("Artifact() = %v", err)
+	}
+
+	if at, err := a.ArtifactType(); err != nil {
+		t.Fatal(err)
+	} else if at != want.ArtifactType {
+		t.Errorf("ArtifactType() = %q, want %q", at, want.ArtifactType)
+	}
+	if c, err := a.Config(); err != nil {
+		t.Fatal(err)
+	} else if diff := cmp.Diff(want.Config, c); diff != "" {
+		t.Errorf("Config() (-want +got) = %s", diff)
+	}
+	ls, err := a.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want.Layers, ls); diff != "" {
+		t.Errorf("Layers() (-want +got) = %s", diff)
+	}
+
+	rc, err := a.Blob(ls[0].Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	b, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), `{"packages":[]}`; got != want {
+		t.Errorf("Blob() = %q, want %q", got, want)
+	}
+}
+
+func TestArtifactIndex(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, err := name.NewTag(fmt.Sprintf("%s/test/artifact:index", u.Host))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := random.Index(1024, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteIndex(tag, idx); err != nil {
+		t.Fatal(err)
+	}
+	desc, err := Get(tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := desc.Artifact(); err == nil {
+		t.Error("Artifact() of an index: expected error")
+	}
+}
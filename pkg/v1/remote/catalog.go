@@ -18,6 +18,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 
@@ -25,6 +26,10 @@ import (
 	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
 )
 
+// defaultCatalogPageSize is the number of repositories requested per page
+// of /_catalog, unless WithPageSize is used.
+const defaultCatalogPageSize = 10000
+
 type catalog struct {
 	Repos []string `json:"repositories"`
 }
@@ -37,7 +42,7 @@ func CatalogPage(target name.Registry, last string, n int, options ...Option) ([
 	}
 
 	scopes := []string{target.Scope(transport.PullScope)}
-	tr, err := transport.NewWithContext(o.context, target, o.auth, o.transport, scopes)
+	tr, err := transport.NewWithContext(o.context, target, o.auth, o.transport, append(scopes, o.scopes...))
 	if err != nil {
 		return nil, err
 	}
@@ -75,77 +80,114 @@ func CatalogPage(target name.Registry, last string, n int, options ...Option) ([
 }
 
 // Catalog calls /_catalog, returning the list of repositories on the registry.
+//
+// Catalog follows the registry's pagination until every page has been read.
+// Use NewCatalogger to process one page at a time instead.
 func Catalog(ctx context.Context, target name.Registry, options ...Option) ([]string, error) {
+	c, err := NewCatalogger(target, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	// WithContext overrides the ctx passed directly.
+	if c.ctx != context.Background() {
+		ctx = c.ctx
+	}
+
+	var repoList []string
+	for c.HasNext() {
+		repos, err := c.Next(ctx)
+		if err != nil {
+			return nil, err
+		}
+		repoList = append(repoList, repos...)
+	}
+	return repoList, nil
+}
+
+// Catalogger iterates over the pages of a registry's /_catalog, for
+// registries that are too large to buffer every repository in memory.
+type Catalogger struct {
+	client http.Client
+	ctx    context.Context
+	uri    *url.URL
+}
+
+// NewCatalogger returns a Catalogger for the repositories on target.
+//
+// See WithPageSize to control how many repositories are requested per page.
+func NewCatalogger(target name.Registry, options ...Option) (*Catalogger, error) {
 	o, err := makeOptions(target, options...)
 	if err != nil {
 		return nil, err
 	}
 
 	scopes := []string{target.Scope(transport.PullScope)}
-	tr, err := transport.NewWithContext(o.context, target, o.auth, o.transport, scopes)
+	tr, err := transport.NewWithContext(o.context, target, o.auth, o.transport, append(scopes, o.scopes...))
 	if err != nil {
 		return nil, err
 	}
 
-	uri := &url.URL{
-		Scheme:   target.Scheme(),
-		Host:     target.RegistryStr(),
-		Path:     "/v2/_catalog",
-		RawQuery: "n=10000",
+	n := o.pageSize
+	if n == 0 {
+		n = defaultCatalogPageSize
 	}
 
-	client := http.Client{Transport: tr}
-
-	// WithContext overrides the ctx passed directly.
-	if o.context != context.Background() {
-		ctx = o.context
-	}
+	return &Catalogger{
+		client: http.Client{Transport: tr},
+		ctx:    o.context,
+		uri: &url.URL{
+			Scheme:   target.Scheme(),
+			Host:     target.RegistryStr(),
+			Path:     "/v2/_catalog",
+			RawQuery: fmt.Sprintf("n=%d", n),
+		},
+	}, nil
+}
 
-	var (
-		parsed   catalog
-		repoList []string
-	)
+// HasNext reports whether there are more pages to read.
+func (c *Catalogger) HasNext() bool {
+	return c.uri != nil
+}
 
-	// get responses until there is no next page
-	for {
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		default:
-		}
+// Next fetches the next page of repositories, following the Link header
+// returned by the registry to find the page after it.
+func (c *Catalogger) Next(ctx context.Context) ([]string, error) {
+	if c.uri == nil {
+		return nil, io.EOF
+	}
 
-		req, err := http.NewRequest("GET", uri.String(), nil)
-		if err != nil {
-			return nil, err
-		}
-		req = req.WithContext(ctx)
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
 
-		resp, err := client.Do(req)
-		if err != nil {
-			return nil, err
-		}
+	req, err := http.NewRequest(http.MethodGet, c.uri.String(), nil)
+	if err != nil {
+		return nil, err
+	}
 
-		if err := transport.CheckError(resp, http.StatusOK); err != nil {
-			return nil, err
-		}
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
 
-		if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
-			return nil, err
-		}
-		if err := resp.Body.Close(); err != nil {
-			return nil, err
-		}
+	if err := transport.CheckError(resp, http.StatusOK); err != nil {
+		return nil, err
+	}
 
-		repoList = append(repoList, parsed.Repos...)
+	var parsed catalog
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, err
+	}
 
-		uri, err = getNextPageURL(resp)
-		if err != nil {
-			return nil, err
-		}
-		// no next page
-		if uri == nil {
-			break
-		}
+	next, err := getNextPageURL(resp)
+	if err != nil {
+		return nil, err
 	}
-	return repoList, nil
+	c.uri = next
+
+	return parsed.Repos, nil
 }
@@ -17,12 +17,14 @@ package remote
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 )
 
@@ -180,3 +182,123 @@ func TestCancelledCatalog(t *testing.T) {
 		t.Errorf("wanted %v got %v", want, got)
 	}
 }
+
+func TestCatalogger(t *testing.T) {
+	pages := map[string]string{
+		"":      `{"repositories":["test/one","test/two"]}`,
+		"two":   `{"repositories":["test/three","test/four"]}`,
+		"three": `{"repositories":["test/five"]}`,
+	}
+	next := map[string]string{
+		"":    "two",
+		"two": "three",
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case "/v2/_catalog":
+			if got, want := r.URL.Query().Get("n"), "2"; got != want {
+				t.Errorf("n = %q, want %q", got, want)
+			}
+			last := r.URL.Query().Get("last")
+			if n, ok := next[last]; ok {
+				w.Header().Set("Link", fmt.Sprintf(`</v2/_catalog?last=%s&n=2>; rel="next"`, n))
+			}
+			w.Write([]byte(pages[last]))
+		default:
+			t.Fatalf("Unexpected path: %v", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("url.Parse(%v) = %v", server.URL, err)
+	}
+
+	reg, err := name.NewRegistry(u.Host)
+	if err != nil {
+		t.Fatalf("name.NewRegistry(%v) = %v", u.Host, err)
+	}
+
+	c, err := NewCatalogger(reg, WithPageSize(2))
+	if err != nil {
+		t.Fatalf("NewCatalogger() = %v", err)
+	}
+
+	var got [][]string
+	for c.HasNext() {
+		repos, err := c.Next(context.Background())
+		if err != nil {
+			t.Fatalf("Next() = %v", err)
+		}
+		got = append(got, repos)
+	}
+	want := [][]string{
+		{"test/one", "test/two"},
+		{"test/three", "test/four"},
+		{"test/five"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Catalogger wrong pages (-want +got) = %s", diff)
+	}
+
+	if _, err := c.Next(context.Background()); err != io.EOF {
+		t.Errorf("Next() after last page = %v, want %v", err, io.EOF)
+	}
+
+	repos, err := Catalog(context.Background(), reg, WithPageSize(2))
+	if err != nil {
+		t.Fatalf("Catalog() = %v", err)
+	}
+	if diff := cmp.Diff([]string{"test/one", "test/two", "test/three", "test/four", "test/five"}, repos); diff != "" {
+		t.Errorf("Catalog() wrong repos (-want +got) = %s", diff)
+	}
+
+	if _, err := NewCatalogger(reg, WithPageSize(0)); err == nil {
+		t.Error("NewCatalogger(WithPageSize(0)) = nil, want error")
+	}
+}
+
+func TestCatalogScopes(t *testing.T) {
+	var scopes []string
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+		case "/token":
+			scopes = r.URL.Query()["scope"]
+			w.Write([]byte(`{"token": "secret"}`))
+		case "/v2/_catalog":
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"repositories": ["foo/bar"]}`))
+		default:
+			t.Errorf("Unexpected path: %v", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg, err := name.NewRegistry(u.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Anything but Anonymous triggers the token exchange.
+	auth := WithAuth(&authn.Basic{Username: "user", Password: "pass"})
+	if _, err := CatalogPage(reg, "", 10, auth, WithScopes("repository:foo/bar:pull,push")); err != nil {
+		t.Fatalf("CatalogPage() = %v", err)
+	}
+	want := []string{"registry:catalog:*", "repository:foo/bar:pull,push"}
+	if diff := cmp.Diff(want, scopes); diff != "" {
+		t.Errorf("scopes (-want +got) = %s", diff)
+	}
+}
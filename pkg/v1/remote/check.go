@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
@@ -18,15 +19,21 @@ import (
 //
 // TODO(#412): Remove the need for this method.
 func CheckPushPermission(ref name.Reference, kc authn.Keychain, t http.RoundTripper) error {
+	return CheckPushPermissionWithContext(context.Background(), ref, kc, t)
+}
+
+// CheckPushPermissionWithContext is like CheckPushPermission, but the token
+// exchange and upload requests it makes respect ctx.
+func CheckPushPermissionWithContext(ctx context.Context, ref name.Reference, kc authn.Keychain, t http.RoundTripper) error {
 	auth, err := kc.Resolve(ref.Context().Registry)
 	if err != nil {
-		return fmt.Errorf("resolving authorization for %v failed: %v", ref.Context().Registry, err)
+		return fmt.Errorf("resolving authorization for %v failed: %w", ref.Context().Registry, err)
 	}
 
 	scopes := []string{ref.Scope(transport.PushScope)}
-	tr, err := transport.New(ref.Context().Registry, auth, t, scopes)
+	tr, err := transport.NewWithContext(ctx, ref.Context().Registry, auth, t, scopes)
 	if err != nil {
-		return fmt.Errorf("creating push check transport for %v failed: %v", ref.Context().Registry, err)
+		return fmt.Errorf("creating push check transport for %v failed: %w", ref.Context().Registry, err)
 	}
 	// TODO(jasonhall): Against GCR, just doing the token handshake is
 	// enough, but this doesn't extend to Dockerhub
@@ -37,7 +44,7 @@ func CheckPushPermission(ref name.Reference, kc authn.Keychain, t http.RoundTrip
 	w := writer{
 		repo:    ref.Context(),
 		client:  &http.Client{Transport: tr},
-		context: context.Background(),
+		context: ctx,
 	}
 	loc, _, err := w.initiateUpload("", "")
 	if loc != "" {
@@ -50,10 +57,21 @@ func CheckPushPermission(ref name.Reference, kc authn.Keychain, t http.RoundTrip
 	return err
 }
 
+// cancelUploadTimeout limits how long cancelUpload waits for the registry.
+const cancelUploadTimeout = 10 * time.Second
+
+// cancelUpload deletes the upload at loc. It doesn't use the writer's context,
+// which the caller is likely to cancel as soon as CheckPushPermission returns.
 func (w *writer) cancelUpload(loc string) {
+	ctx, cancel := context.WithTimeout(context.Background(), cancelUploadTimeout)
+	defer cancel()
 	req, err := http.NewRequest(http.MethodDelete, loc, nil)
 	if err != nil {
 		return
 	}
-	_, _ = w.client.Do(req)
+	resp, err := w.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
 }
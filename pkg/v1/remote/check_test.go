@@ -15,11 +15,14 @@
 package remote
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 )
@@ -75,3 +78,58 @@ func TestCheckPushPermission(t *testing.T) {
 		}
 	}
 }
+
+func TestCheckPushPermissionWithContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("url.Parse(%v) = %v", server.URL, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	ref := mustNewTag(t, fmt.Sprintf("%s/write/time:latest", u.Host))
+	if err := CheckPushPermissionWithContext(ctx, ref, authn.DefaultKeychain, http.DefaultTransport); err == nil || !strings.Contains(err.Error(), context.Canceled.Error()) {
+		t.Errorf("CheckPushPermissionWithContext() = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestCheckPushPermissionCancelsUpload(t *testing.T) {
+	deleted := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodPost:
+			w.Header().Set("Location", "somewhere/else")
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodDelete:
+			close(deleted)
+		default:
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("url.Parse(%v) = %v", server.URL, err)
+	}
+
+	// The upload is still cancelled once the caller is done with ctx.
+	ctx, cancel := context.WithCancel(context.Background())
+	ref := mustNewTag(t, fmt.Sprintf("%s/write/time:latest", u.Host))
+	err = CheckPushPermissionWithContext(ctx, ref, authn.DefaultKeychain, http.DefaultTransport)
+	cancel()
+	if err != nil {
+		t.Fatalf("CheckPushPermissionWithContext() = %v", err)
+	}
+	select {
+	case <-deleted:
+	case <-time.After(5 * time.Second):
+		t.Error("upload was not cancelled")
+	}
+}
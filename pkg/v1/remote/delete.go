@@ -24,13 +24,29 @@ import (
 )
 
 // Delete removes the specified image reference from the remote registry.
+//
+// Many registries only allow manifests to be deleted by digest. If ref doesn't
+// exist, the error is a *transport.Error with http.StatusNotFound, so callers
+// that garbage-collect can ignore it; if the registry has deletes disabled, the
+// error says so.
 func Delete(ref name.Reference, options ...Option) error {
+	return deleteResource(ref, "manifests", options...)
+}
+
+// DeleteBlob removes the blob d from the remote registry. Errors are reported
+// as for Delete.
+func DeleteBlob(d name.Digest, options ...Option) error {
+	return deleteResource(d, "blobs", options...)
+}
+
+// deleteResource issues a DELETE for ref under /v2/<repo>/<kind>/.
+func deleteResource(ref name.Reference, kind string, options ...Option) error {
 	o, err := makeOptions(ref.Context(), options...)
 	if err != nil {
 		return err
 	}
 	scopes := []string{ref.Scope(transport.DeleteScope)}
-	tr, err := transport.NewWithContext(o.context, ref.Context().Registry, o.auth, o.transport, scopes)
+	tr, err := transport.NewWithContext(o.context, ref.Context().Registry, o.auth, o.transport, append(scopes, o.scopes...))
 	if err != nil {
 		return err
 	}
@@ -39,7 +55,7 @@ func Delete(ref name.Reference, options ...Option) error {
 	u := url.URL{
 		Scheme: ref.Context().Registry.Scheme(),
 		Host:   ref.Context().RegistryStr(),
-		Path:   fmt.Sprintf("/v2/%s/manifests/%s", ref.Context().RepositoryStr(), ref.Identifier()),
+		Path:   fmt.Sprintf("/v2/%s/%s/%s", ref.Context().RepositoryStr(), kind, ref.Identifier()),
 	}
 
 	req, err := http.NewRequest(http.MethodDelete, u.String(), nil)
@@ -53,5 +69,9 @@ func Delete(ref name.Reference, options ...Option) error {
 	}
 	defer resp.Body.Close()
 
-	return transport.CheckError(resp, http.StatusOK, http.StatusAccepted)
+	err = transport.CheckError(resp, http.StatusOK, http.StatusAccepted)
+	if resp.StatusCode == http.StatusMethodNotAllowed {
+		return fmt.Errorf("registry %s does not allow deleting %s: %w", ref.Context().RegistryStr(), kind, err)
+	}
+	return err
 }
@@ -15,13 +15,16 @@
 package remote
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
 )
 
 func TestDelete(t *testing.T) {
@@ -87,3 +90,49 @@ func TestDeleteBadStatus(t *testing.T) {
 		t.Error("Delete() = nil; wanted error")
 	}
 }
+
+func TestDeleteBlob(t *testing.T) {
+	expectedRepo := "write/time"
+	digest := "sha256:deadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeef"
+	blobPath := fmt.Sprintf("/v2/%s/blobs/%s", expectedRepo, digest)
+
+	status := http.StatusAccepted
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case blobPath:
+			if r.Method != http.MethodDelete {
+				t.Errorf("Method; got %v, want %v", r.Method, http.MethodDelete)
+			}
+			w.WriteHeader(status)
+		default:
+			t.Fatalf("Unexpected path: %v", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("url.Parse(%v) = %v", server.URL, err)
+	}
+	d, err := name.NewDigest(fmt.Sprintf("%s/%s@%s", u.Host, expectedRepo, digest))
+	if err != nil {
+		t.Fatalf("NewDigest() = %v", err)
+	}
+
+	if err := DeleteBlob(d); err != nil {
+		t.Errorf("DeleteBlob() = %v", err)
+	}
+
+	status = http.StatusNotFound
+	err = DeleteBlob(d)
+	var terr *transport.Error
+	if !errors.As(err, &terr) || terr.StatusCode != http.StatusNotFound {
+		t.Errorf("DeleteBlob() = %v, want 404 error", err)
+	}
+
+	status = http.StatusMethodNotAllowed
+	if err := DeleteBlob(d); err == nil || !strings.Contains(err.Error(), "does not allow deleting blobs") {
+		t.Errorf("DeleteBlob() = %v, want deletes disabled error", err)
+	}
+}
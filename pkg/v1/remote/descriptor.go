@@ -17,6 +17,7 @@ package remote
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -34,6 +35,10 @@ import (
 	"github.com/google/go-containerregistry/pkg/v1/types"
 )
 
+// ErrNotModified is returned by Get and Head when the manifest's ETag matches
+// the one passed to WithIfNoneMatch.
+var ErrNotModified = errors.New("manifest not modified")
+
 // ErrSchema1 indicates that we received a schema1 manifest from the registry.
 // This library doesn't have plans to support this legacy image format:
 // https://github.com/google/go-containerregistry/issues/377
@@ -60,8 +65,26 @@ type Descriptor struct {
 	v1.Descriptor
 	Manifest []byte
 
+	// ETag is the entity tag the registry returned for the manifest, if
+	// any. It can be passed to WithIfNoneMatch to poll for changes.
+	ETag string
+
 	// So we can share this implementation with Image..
 	platform v1.Platform
+
+	imageOptions
+}
+
+// imageOptions are the options that apply to the images a Descriptor
+// resolves to. They are carried over to the children of an index, so that
+// they apply to the images in it too.
+type imageOptions struct {
+	// See WithPrefetch and WithJobs.
+	prefetchDir string
+	jobs        int
+
+	// See WithSchema1Conversion.
+	convertSchema1 bool
 }
 
 // RawManifest exists to satisfy the Taggable interface.
@@ -73,7 +96,8 @@ func (d *Descriptor) RawManifest() ([]byte, error) {
 // the registry is left un-interpreted, for the most part. This is useful for
 // querying what kind of artifact a reference represents.
 //
-// See Head if you don't need the response body.
+// See Head if you don't need the response body, and WithIfNoneMatch to only
+// fetch the manifest if it has changed.
 func Get(ref name.Reference, options ...Option) (*Descriptor, error) {
 	acceptable := []types.MediaType{
 		// Just to look at them.
@@ -85,12 +109,34 @@ func Get(ref name.Reference, options ...Option) (*Descriptor, error) {
 	return get(ref, acceptable, options...)
 }
 
+// Child returns a remote.Descriptor for the manifest with digest child in the
+// repository of the index idx. Since manifests are addressable by digest on
+// their own, this fetches the child directly, without fetching idx first.
+//
+// As a result, Child doesn't check that child is actually referenced by idx,
+// and the returned Descriptor doesn't have the platform or annotations that
+// idx may record for it; use Index for those.
+func Child(idx name.Digest, child v1.Hash, options ...Option) (*Descriptor, error) {
+	return Get(idx.Context().Digest(child.String()), options...)
+}
+
 // Head returns a v1.Descriptor for the given reference by issuing a HEAD
 // request.
 //
 // Note that the server response will not have a body, so any errors encountered
 // should be retried with Get to get more details.
+//
+// As with Get, ErrNotModified is returned if WithIfNoneMatch is passed the
+// current ETag of the manifest. See HeadWithETag to get that ETag.
 func Head(ref name.Reference, options ...Option) (*v1.Descriptor, error) {
+	desc, _, err := HeadWithETag(ref, options...)
+	return desc, err
+}
+
+// HeadWithETag is like Head, but also returns the entity tag the registry
+// returned for the manifest, if any, so that it can be passed to
+// WithIfNoneMatch to poll for changes without a GET.
+func HeadWithETag(ref name.Reference, options ...Option) (*v1.Descriptor, string, error) {
 	acceptable := []types.MediaType{
 		// Just to look at them.
 		types.DockerManifestSchema1,
@@ -101,15 +147,20 @@ func Head(ref name.Reference, options ...Option) (*v1.Descriptor, error) {
 
 	o, err := makeOptions(ref.Context(), options...)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	f, err := makeFetcher(ref, o)
-	if err != nil {
-		return nil, err
+	var (
+		desc *v1.Descriptor
+		etag string
+	)
+	if err := withMirrors(ref, o, func(f *fetcher) (err error) {
+		desc, etag, err = f.headManifest(f.Ref, acceptable, o.ifNoneMatch)
+		return err
+	}); err != nil {
+		return nil, "", err
 	}
-
-	return f.headManifest(ref, acceptable)
+	return desc, etag, nil
 }
 
 // Handle options and fetch the manifest with the acceptable MediaTypes in the
@@ -119,19 +170,30 @@ func get(ref name.Reference, acceptable []types.MediaType, options ...Option) (*
 	if err != nil {
 		return nil, err
 	}
-	f, err := makeFetcher(ref, o)
-	if err != nil {
-		return nil, err
-	}
-	b, desc, err := f.fetchManifest(ref, acceptable)
-	if err != nil {
+	var (
+		f    *fetcher
+		b    []byte
+		desc *v1.Descriptor
+		etag string
+	)
+	if err := withMirrors(ref, o, func(mf *fetcher) (err error) {
+		b, desc, etag, err = mf.fetchManifestIfNoneMatch(mf.Ref, acceptable, o.ifNoneMatch)
+		f = mf
+		return err
+	}); err != nil {
 		return nil, err
 	}
 	return &Descriptor{
 		fetcher:    *f,
 		Manifest:   b,
 		Descriptor: *desc,
+		ETag:       etag,
 		platform:   o.platform,
+		imageOptions: imageOptions{
+			prefetchDir:    o.prefetchDir,
+			jobs:           o.jobs,
+			convertSchema1: o.convertSchema1,
+		},
 	}, nil
 }
 
@@ -142,10 +204,21 @@ func get(ref name.Reference, acceptable []types.MediaType, options ...Option) (*
 // If the fetched artifact is an index, it will attempt to resolve the index to
 // a child image with the appropriate platform.
 //
-// See WithPlatform to set the desired platform.
+// See WithPlatform to set the desired platform, and WithSchema1Conversion to
+// read schema 1 images.
 func (d *Descriptor) Image() (v1.Image, error) {
 	switch d.MediaType {
 	case types.DockerManifestSchema1, types.DockerManifestSchema1Signed:
+		if d.convertSchema1 {
+			img, err := partial.CompressedToImage(d.schema1Image())
+			if err != nil {
+				return nil, err
+			}
+			return d.prefetched(&mountableImage{
+				Image:     img,
+				Reference: d.Ref,
+			}), nil
+		}
 		// We don't care to support schema 1 images:
 		// https://github.com/google/go-containerregistry/issues/377
 		return nil, newErrSchema1(d.MediaType)
@@ -166,10 +239,22 @@ func (d *Descriptor) Image() (v1.Image, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &mountableImage{
+	return d.prefetched(&mountableImage{
 		Image:     imgCore,
 		Reference: d.Ref,
-	}, nil
+	}), nil
+}
+
+// prefetched wraps img to download its layers, if WithPrefetch was passed.
+func (d *Descriptor) prefetched(img v1.Image) v1.Image {
+	if d.prefetchDir == "" {
+		return img
+	}
+	return &prefetchedImage{
+		Image: img,
+		dir:   d.prefetchDir,
+		jobs:  d.jobs,
+	}
 }
 
 // ImageIndex converts the Descriptor into a v1.ImageIndex.
@@ -203,10 +288,11 @@ func (d *Descriptor) remoteImage() *remoteImage {
 
 func (d *Descriptor) remoteIndex() *remoteIndex {
 	return &remoteIndex{
-		fetcher:    d.fetcher,
-		manifest:   d.Manifest,
-		mediaType:  d.MediaType,
-		descriptor: &d.Descriptor,
+		fetcher:      d.fetcher,
+		manifest:     d.Manifest,
+		mediaType:    d.MediaType,
+		descriptor:   &d.Descriptor,
+		imageOptions: d.imageOptions,
 	}
 }
 
@@ -215,17 +301,21 @@ type fetcher struct {
 	Ref     name.Reference
 	Client  *http.Client
 	context context.Context
+
+	// See WithoutContentDigestVerification.
+	verifyContentDigest bool
 }
 
 func makeFetcher(ref name.Reference, o *options) (*fetcher, error) {
-	tr, err := transport.NewWithContext(o.context, ref.Context().Registry, o.auth, o.transport, []string{ref.Scope(transport.PullScope)})
+	tr, err := transport.NewWithContext(o.context, ref.Context().Registry, o.auth, o.transport, append([]string{ref.Scope(transport.PullScope)}, o.scopes...))
 	if err != nil {
 		return nil, err
 	}
 	return &fetcher{
-		Ref:     ref,
-		Client:  &http.Client{Transport: tr},
-		context: o.context,
+		Ref:                 ref,
+		Client:              &http.Client{Transport: tr},
+		context:             o.context,
+		verifyContentDigest: o.verifyContentDigest,
 	}, nil
 }
 
@@ -239,35 +329,49 @@ func (f *fetcher) url(resource, identifier string) url.URL {
 }
 
 func (f *fetcher) fetchManifest(ref name.Reference, acceptable []types.MediaType) ([]byte, *v1.Descriptor, error) {
+	manifest, desc, _, err := f.fetchManifestIfNoneMatch(ref, acceptable, "")
+	return manifest, desc, err
+}
+
+// fetchManifestIfNoneMatch is like fetchManifest, but returns ErrNotModified
+// if etag is set and matches the manifest's current ETag, which is returned
+// along with the manifest otherwise.
+func (f *fetcher) fetchManifestIfNoneMatch(ref name.Reference, acceptable []types.MediaType, etag string) ([]byte, *v1.Descriptor, string, error) {
 	u := f.url("manifests", ref.Identifier())
 	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, "", err
 	}
 	accept := []string{}
 	for _, mt := range acceptable {
 		accept = append(accept, string(mt))
 	}
 	req.Header.Set("Accept", strings.Join(accept, ","))
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
 
 	resp, err := f.Client.Do(req.WithContext(f.context))
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, "", err
 	}
 	defer resp.Body.Close()
 
+	if etag != "" && resp.StatusCode == http.StatusNotModified {
+		return nil, nil, "", ErrNotModified
+	}
 	if err := transport.CheckError(resp, http.StatusOK); err != nil {
-		return nil, nil, err
+		return nil, nil, "", err
 	}
 
 	manifest, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, "", err
 	}
 
 	digest, size, err := v1.SHA256(bytes.NewReader(manifest))
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, "", err
 	}
 
 	mediaType := types.MediaType(resp.Header.Get("Content-Type"))
@@ -281,16 +385,19 @@ func (f *fetcher) fetchManifest(ref name.Reference, acceptable []types.MediaType
 	// Validate the digest matches what we asked for, if pulling by digest.
 	if dgst, ok := ref.(name.Digest); ok {
 		if digest.String() != dgst.DigestStr() {
-			return nil, nil, fmt.Errorf("manifest digest: %q does not match requested digest: %q for %q", digest, dgst.DigestStr(), f.Ref)
+			return nil, nil, "", fmt.Errorf("manifest digest: %q does not match requested digest: %q for %q", digest, dgst.DigestStr(), f.Ref)
 		}
 	}
-	// Do nothing for tags; I give up.
-	//
-	// We'd like to validate that the "Docker-Content-Digest" header matches what is returned by the registry,
-	// but so many registries implement this incorrectly that it's not worth checking.
+	// For tags, check that the "Docker-Content-Digest" header matches what is returned by the registry,
+	// unless asked not to, since some registries implement this incorrectly.
 	//
 	// For reference:
 	// https://github.com/GoogleContainerTools/kaniko/issues/298
+	if f.verifyContentDigest && mediaType != types.DockerManifestSchema1Signed {
+		if header := resp.Header.Get("Docker-Content-Digest"); header != "" && header != digest.String() {
+			return nil, nil, "", fmt.Errorf("manifest digest: %q does not match Docker-Content-Digest: %q for %q", digest, header, f.Ref)
+		}
+	}
 
 	// Return all this info since we have to calculate it anyway.
 	desc := v1.Descriptor{
@@ -299,47 +406,53 @@ func (f *fetcher) fetchManifest(ref name.Reference, acceptable []types.MediaType
 		MediaType: mediaType,
 	}
 
-	return manifest, &desc, nil
+	return manifest, &desc, resp.Header.Get("ETag"), nil
 }
 
-func (f *fetcher) headManifest(ref name.Reference, acceptable []types.MediaType) (*v1.Descriptor, error) {
+func (f *fetcher) headManifest(ref name.Reference, acceptable []types.MediaType, etag string) (*v1.Descriptor, string, error) {
 	u := f.url("manifests", ref.Identifier())
 	req, err := http.NewRequest(http.MethodHead, u.String(), nil)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	accept := []string{}
 	for _, mt := range acceptable {
 		accept = append(accept, string(mt))
 	}
 	req.Header.Set("Accept", strings.Join(accept, ","))
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
 
 	resp, err := f.Client.Do(req.WithContext(f.context))
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer resp.Body.Close()
 
+	if etag != "" && resp.StatusCode == http.StatusNotModified {
+		return nil, "", ErrNotModified
+	}
 	if err := transport.CheckError(resp, http.StatusOK); err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	mediaType := types.MediaType(resp.Header.Get("Content-Type"))
 
 	size, err := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	digest, err := v1.NewHash(resp.Header.Get("Docker-Content-Digest"))
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	// Validate the digest matches what we asked for, if pulling by digest.
 	if dgst, ok := ref.(name.Digest); ok {
 		if digest.String() != dgst.DigestStr() {
-			return nil, fmt.Errorf("manifest digest: %q does not match requested digest: %q for %q", digest, dgst.DigestStr(), f.Ref)
+			return nil, "", fmt.Errorf("manifest digest: %q does not match requested digest: %q for %q", digest, dgst.DigestStr(), f.Ref)
 		}
 	}
 
@@ -348,7 +461,7 @@ func (f *fetcher) headManifest(ref name.Reference, acceptable []types.MediaType)
 		Digest:    digest,
 		Size:      size,
 		MediaType: mediaType,
-	}, nil
+	}, resp.Header.Get("ETag"), nil
 }
 
 func (f *fetcher) fetchBlob(ctx context.Context, h v1.Hash) (io.ReadCloser, error) {
@@ -371,6 +484,38 @@ func (f *fetcher) fetchBlob(ctx context.Context, h v1.Hash) (io.ReadCloser, erro
 	return verify.ReadCloser(resp.Body, h)
 }
 
+// fetchRange issues a ranged GET for u, see partial.RangeReadLayer. If the
+// server ignores the Range header, the rest of the response is skipped.
+func (f *fetcher) fetchRange(ctx context.Context, u url.URL, offset, length int64) (io.ReadCloser, error) {
+	if length == 0 {
+		return ioutil.NopCloser(bytes.NewReader(nil)), nil
+	}
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if length < 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	} else {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	}
+
+	resp, err := f.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := transport.CheckError(resp, http.StatusOK, http.StatusPartialContent); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		return partial.SkipAndLimit(resp.Body, offset, length)
+	}
+	return partial.SkipAndLimit(resp.Body, 0, length)
+}
+
 func (f *fetcher) headBlob(h v1.Hash) (*http.Response, error) {
 	u := f.url("blobs", h.String())
 	req, err := http.NewRequest(http.MethodHead, u.String(), nil)
@@ -15,14 +15,23 @@
 package remote
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 )
 
@@ -125,6 +134,62 @@ func TestGetImageAsIndex(t *testing.T) {
 	}
 }
 
+func TestChild(t *testing.T) {
+	reg := registry.New()
+	var manifestGets int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/manifests/") {
+			manifestGets++
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err := random.Index(1024, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := idx.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := name.NewDigest(fmt.Sprintf("%s/foo/bar@%s", u.Host, h))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteIndex(d, idx); err != nil {
+		t.Fatal(err)
+	}
+	im, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := im.Manifests[1]
+
+	manifestGets = 0
+	desc, err := Child(d, want.Digest)
+	if err != nil {
+		t.Fatalf("Child() = %v", err)
+	}
+	if manifestGets != 1 {
+		t.Errorf("Child() made %d manifest GETs, want 1", manifestGets)
+	}
+	if desc.Digest != want.Digest || desc.MediaType != want.MediaType || desc.Size != want.Size {
+		t.Errorf("Child() = %v, want %v", desc.Descriptor, want)
+	}
+	img, err := desc.Image()
+	if err != nil {
+		t.Fatalf("Image() = %v", err)
+	}
+	if got, err := img.Digest(); err != nil || got != want.Digest {
+		t.Errorf("Image().Digest() = %v, %v; want %v", got, err, want.Digest)
+	}
+}
+
 func TestHeadSchema1(t *testing.T) {
 	expectedRepo := "foo/bar"
 	mediaType := types.DockerManifestSchema1Signed
@@ -174,3 +239,328 @@ func TestHeadSchema1(t *testing.T) {
 		t.Errorf("Descriptor.Size = %q, expected %q", desc.Size, len(response))
 	}
 }
+
+func TestGetRetry(t *testing.T) {
+	reg := registry.New()
+	failures := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/manifests/") && r.Method == http.MethodGet && failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(u.Host + "/foo/bar:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+
+	backoff := WithRetryBackoff(Backoff{Duration: time.Millisecond, Steps: 3})
+
+	// Retried by default.
+	failures = 2
+	if _, err := Get(ref, backoff); err != nil {
+		t.Errorf("Get() = %v", err)
+	}
+
+	// Runs out of attempts.
+	failures = 3
+	if _, err := Get(ref, backoff); err == nil {
+		t.Error("Get() = nil, want error after 3 attempts")
+	}
+
+	// Not retried if the predicate says so.
+	failures = 1
+	var seen []int
+	noRetry := WithRetryPredicate(func(err error) bool {
+		if terr, ok := err.(*transport.Error); ok {
+			seen = append(seen, terr.StatusCode)
+		}
+		return false
+	})
+	if _, err := Get(ref, backoff, noRetry); err == nil {
+		t.Error("Get() = nil, want error without retries")
+	}
+	if diff := cmp.Diff([]int{http.StatusServiceUnavailable}, seen); diff != "" {
+		t.Errorf("predicate saw wrong status codes (-want +got) = %s", diff)
+	}
+
+	if _, err := Get(ref, WithRetryBackoff(Backoff{})); err == nil {
+		t.Error("Get(WithRetryBackoff(Backoff{})) = nil, want error")
+	}
+}
+
+func TestGetIfNoneMatch(t *testing.T) {
+	reg := registry.New()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.URL.Path, "/manifests/") || r.Method == http.MethodPut {
+			reg.ServeHTTP(w, r)
+			return
+		}
+		// Use the digest as the ETag, as many registries do.
+		rec := httptest.NewRecorder()
+		reg.ServeHTTP(rec, r)
+		etag := fmt.Sprintf("%q", rec.Header().Get("Docker-Content-Digest"))
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(rec.Code)
+		w.Write(rec.Body.Bytes())
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.NewTag(u.Host + "/foo/bar:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+
+	desc, err := Get(ref)
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	if desc.ETag == "" {
+		t.Fatal("Get() returned no ETag")
+	}
+
+	if _, err := Get(ref, WithIfNoneMatch(desc.ETag)); err != ErrNotModified {
+		t.Errorf("Get(WithIfNoneMatch) = %v, want ErrNotModified", err)
+	}
+	if _, err := Head(ref, WithIfNoneMatch(desc.ETag)); err != ErrNotModified {
+		t.Errorf("Head(WithIfNoneMatch) = %v, want ErrNotModified", err)
+	}
+	if _, etag, err := HeadWithETag(ref); err != nil || etag != desc.ETag {
+		t.Errorf("HeadWithETag() = %q, %v; want %q", etag, err, desc.ETag)
+	}
+
+	// Once the tag moves, the manifest is fetched again.
+	img, err = random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Get(ref, WithIfNoneMatch(desc.ETag))
+	if err != nil {
+		t.Fatalf("Get(WithIfNoneMatch) = %v", err)
+	}
+	want, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Digest != want {
+		t.Errorf("Get(WithIfNoneMatch).Digest = %v, want %v", got.Digest, want)
+	}
+	if got.ETag == desc.ETag {
+		t.Errorf("ETag = %s, want it to change", got.ETag)
+	}
+}
+
+func TestGetTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.NewTag(u.Host + "/foo/bar:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backoff := WithRetryBackoff(Backoff{Duration: time.Millisecond, Steps: 1})
+	if _, err := Get(ref, WithTimeout(10*time.Millisecond), backoff); err == nil || !strings.Contains(err.Error(), "no progress") {
+		t.Errorf("Get(WithTimeout) = %v, want timeout", err)
+	}
+	if _, err := Get(ref, WithTransportTimeouts(TransportTimeouts{ResponseHeader: 10 * time.Millisecond}), backoff); err == nil || !strings.Contains(err.Error(), "timeout awaiting response headers") {
+		t.Errorf("Get(WithTransportTimeouts) = %v, want timeout", err)
+	}
+	if _, err := Get(ref, WithTransport(http.NewFileTransport(http.Dir("."))), WithTransportTimeouts(TransportTimeouts{Dial: time.Second})); err == nil {
+		t.Error("WithTransportTimeouts() on a custom transport = nil, want error")
+	}
+	if _, err := Get(ref, WithTimeout(0)); err == nil {
+		t.Error("WithTimeout(0) = nil, want error")
+	}
+}
+
+func TestHTTP1(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+	repo, err := name.NewRepository("example.com/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		opts  []Option
+		proto string
+	}{{
+		proto: "HTTP/2.0",
+	}, {
+		opts:  []Option{WithHTTP1()},
+		proto: "HTTP/1.1",
+	}} {
+		var got string
+		opts := append([]Option{
+			WithTransport(server.Client().Transport),
+			WithConnTrace(func(ci transport.ConnInfo) { got = ci.Proto }),
+		}, tc.opts...)
+		o, err := makeOptions(repo, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := (&http.Client{Transport: o.transport}).Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got != tc.proto {
+			t.Errorf("Proto = %q, want %q", got, tc.proto)
+		}
+	}
+
+	if _, err := makeOptions(repo, WithTransport(http.NewFileTransport(http.Dir("."))), WithHTTP1()); err == nil {
+		t.Error("WithHTTP1() on a custom transport = nil, want error")
+	}
+}
+
+func TestProxy(t *testing.T) {
+	reg := registry.New()
+	wantAuth := "Basic " + base64.StdEncoding.EncodeToString([]byte("proxyuser:proxypass"))
+	proxied := 0
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Proxy-Authorization"); got != wantAuth {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		if r.Method == http.MethodConnect {
+			// Only plain http is proxied here, so the insecure ping falls back to it.
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if r.URL.Host != "registry.example" {
+			t.Errorf("proxied request for %s, want registry.example", r.URL)
+		}
+		if got := r.Header.Get("Authorization"); got == wantAuth {
+			t.Error("proxy credentials were sent in the Authorization header")
+		}
+		proxied++
+		reg.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+	u, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.User = url.UserPassword("proxyuser", "proxypass")
+
+	ref, err := name.ParseReference("registry.example/foo:bar", name.Insecure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(ref, img, WithProxy(u)); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+	if _, err := Head(ref, WithProxy(u)); err != nil {
+		t.Errorf("Head() = %v", err)
+	}
+	if proxied == 0 {
+		t.Error("no requests went through the proxy")
+	}
+
+	u.User = url.UserPassword("proxyuser", "wrong")
+	if _, err := Head(ref, WithProxy(u)); err == nil {
+		t.Error("Head() with bad proxy credentials = nil, want error")
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	reg := registry.New()
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	s.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	s.StartTLS()
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(u.Host + "/foo:bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(s.Certificate())
+	// Reuse the server's certificate as the client certificate.
+	cfg := &tls.Config{Certificates: s.TLS.Certificates}
+
+	if err := Write(ref, img, WithTLSClientConfig(cfg), WithRootCAs(pool)); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+	if _, err := Head(ref, WithTLSClientConfig(cfg), WithRootCAs(pool)); err != nil {
+		t.Errorf("Head() = %v", err)
+	}
+	if cfg.RootCAs != nil {
+		t.Error("WithRootCAs modified the TLS config")
+	}
+
+	// Without the CA, or without the client certificate, the registry
+	// can't be reached.
+	if _, err := Head(ref, WithTLSClientConfig(cfg)); err == nil {
+		t.Error("Head() without the CA = nil, want error")
+	}
+	if _, err := Head(ref, WithRootCAs(pool)); err == nil {
+		t.Error("Head() without a client certificate = nil, want error")
+	}
+}
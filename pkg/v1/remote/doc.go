@@ -14,4 +14,10 @@
 
 // Package remote provides facilities for reading/writing v1.Images from/to
 // a remote image registry.
+//
+// The options that tune the HTTP transport (WithTransportTimeouts, WithHTTP1,
+// WithProxy, WithTLSClientConfig and WithRootCAs) require the transport given
+// to WithTransport to be an *http.Transport, as http.DefaultTransport is. It
+// is cloned once and every one of them is applied to the clone, so the
+// caller's transport is never modified.
 package remote
@@ -15,6 +15,7 @@
 package remote_test
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -126,3 +127,75 @@ func TestBlobStatusCodeReturned(t *testing.T) {
 		t.Errorf("Incorrect status code received, got %v, wanted %v", terr.StatusCode, http.StatusTeapot)
 	}
 }
+
+func TestStructuredErrorReturned(t *testing.T) {
+	tcs := []struct {
+		Description string
+		Status      int
+		Code        transport.ErrorCode
+	}{{
+		Description: "manifest unknown",
+		Status:      http.StatusNotFound,
+		Code:        transport.ManifestUnknownErrorCode,
+	}, {
+		Description: "denied",
+		Status:      http.StatusForbidden,
+		Code:        transport.DeniedErrorCode,
+	}, {
+		Description: "too many requests",
+		Status:      http.StatusTooManyRequests,
+		Code:        transport.TooManyRequestsErrorCode,
+	}}
+
+	for _, tc := range tcs {
+		t.Run(tc.Description, func(t *testing.T) {
+			o := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/v2/":
+					return
+				case "/v2/foo/tags/list":
+					fmt.Fprint(w, `{"name":"foo","tags":["bar"]}`)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tc.Status)
+				fmt.Fprintf(w, `{"errors":[{"code":%q,"message":"nope"}]}`, tc.Code)
+			}))
+			defer o.Close()
+
+			repo, err := name.NewRepository(strings.TrimPrefix(o.URL+"/foo", "http://"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for what, err := range map[string]error{
+				"Get": func() error {
+					_, err := remote.Get(repo.Tag("bar"), remote.WithRetryBackoff(remote.Backoff{Steps: 1}))
+					return err
+				}(),
+				// ListDetails wraps the error with the tag it failed on. It uses
+				// HEAD, so there is no body to parse.
+				"ListDetails": func() error {
+					_, err := remote.ListDetails(repo, remote.WithRetryBackoff(remote.Backoff{Steps: 1}))
+					return err
+				}(),
+			} {
+				var terr *transport.Error
+				if !errors.As(err, &terr) {
+					t.Fatalf("%s: errors.As(%v, *transport.Error) = false", what, err)
+				}
+				if terr.StatusCode != tc.Status {
+					t.Errorf("%s: StatusCode = %d, want %d", what, terr.StatusCode, tc.Status)
+				}
+				if what == "Get" {
+					if len(terr.Errors) != 1 || terr.Errors[0].Code != tc.Code || terr.Errors[0].Message != "nope" {
+						t.Errorf("%s: Errors = %v, want one %s", what, terr.Errors, tc.Code)
+					}
+				}
+				if terr.Request == nil || !strings.HasPrefix(terr.Request.URL.Path, "/v2/foo/") {
+					t.Errorf("%s: Request = %v, want a request to /v2/foo/", what, terr.Request)
+				}
+			}
+		})
+	}
+}
@@ -35,6 +35,10 @@ var acceptableImageMediaTypes = []types.MediaType{
 	types.OCIManifestSchema1,
 }
 
+// DigestMismatchError is returned when reading a blob whose contents don't
+// match its digest, see partial.DigestMismatchError.
+type DigestMismatchError = partial.DigestMismatchError
+
 // remoteImage accesses an image from a remote registry
 type remoteImage struct {
 	fetcher
@@ -58,6 +62,38 @@ func Image(ref name.Reference, options ...Option) (v1.Image, error) {
 	return desc.Image()
 }
 
+// ConfigFile fetches the config file of a remote image reference, without
+// setting up access to its layers. Only the manifest and the config blob are
+// fetched.
+//
+// If ref is an index, the child image is selected as for Image, see
+// WithPlatform.
+func ConfigFile(ref name.Reference, options ...Option) (*v1.ConfigFile, error) {
+	desc, err := Get(ref, options...)
+	if err != nil {
+		return nil, err
+	}
+	return desc.configFile()
+}
+
+func (d *Descriptor) configFile() (*v1.ConfigFile, error) {
+	switch d.MediaType {
+	case types.DockerManifestSchema1, types.DockerManifestSchema1Signed:
+		if d.convertSchema1 {
+			return partial.ConfigFile(d.schema1Image())
+		}
+		return nil, newErrSchema1(d.MediaType)
+	case types.OCIImageIndex, types.DockerManifestList:
+		child, err := d.remoteIndex().childByPlatform(d.platform)
+		if err != nil {
+			return nil, err
+		}
+		// Recurse to handle nested indexes.
+		return child.configFile()
+	}
+	return partial.ConfigFile(d.remoteImage())
+}
+
 func (r *remoteImage) MediaType() (types.MediaType, error) {
 	if string(r.mediaType) != "" {
 		return r.mediaType, nil
@@ -133,19 +169,17 @@ func (rl *remoteImageLayer) Digest() (v1.Hash, error) {
 	return rl.digest, nil
 }
 
-// Compressed implements partial.CompressedLayer
-func (rl *remoteImageLayer) Compressed() (io.ReadCloser, error) {
-	urls := []url.URL{rl.ri.url("blobs", rl.digest.String())}
+// sources returns the URLs to fetch the layer from, in order of preference.
+func (rl *remoteImageLayer) sources() ([]url.URL, error) {
+	blob := rl.ri.url("blobs", rl.digest.String())
 
-	// Add alternative layer sources from URLs (usually none).
 	d, err := partial.BlobDescriptor(rl, rl.digest)
 	if err != nil {
 		return nil, err
 	}
 
-	// We don't want to log binary layers -- this can break terminals.
-	ctx := redact.NewContext(rl.ri.context, "omitting binary blobs from logs")
-
+	// Add alternative layer sources from URLs (usually none).
+	var urls []url.URL
 	for _, s := range d.URLs {
 		u, err := url.Parse(s)
 		if err != nil {
@@ -154,11 +188,28 @@ func (rl *remoteImageLayer) Compressed() (io.ReadCloser, error) {
 		urls = append(urls, *u)
 	}
 
-	// The lastErr for most pulls will be the same (the first error), but for
-	// foreign layers we'll want to surface the last one, since we try to pull
-	// from the registry first, which would often fail.
-	// TODO: Maybe we don't want to try pulling from the registry first?
-	var lastErr error
+	// Foreign layers usually aren't in the registry, so only fall back to it
+	// if their URLs fail. Everything else is pulled from the registry first.
+	if d.MediaType.IsDistributable() {
+		urls = append([]url.URL{blob}, urls...)
+	} else {
+		urls = append(urls, blob)
+	}
+	return urls, nil
+}
+
+// Compressed implements partial.CompressedLayer
+func (rl *remoteImageLayer) Compressed() (io.ReadCloser, error) {
+	urls, err := rl.sources()
+	if err != nil {
+		return nil, err
+	}
+
+	// We don't want to log binary layers -- this can break terminals.
+	ctx := redact.NewContext(rl.ri.context, "omitting binary blobs from logs")
+
+	// Surface the first error, i.e. the one from the preferred source.
+	var firstErr error
 	for _, u := range urls {
 		req, err := http.NewRequest(http.MethodGet, u.String(), nil)
 		if err != nil {
@@ -167,20 +218,48 @@ func (rl *remoteImageLayer) Compressed() (io.ReadCloser, error) {
 
 		resp, err := rl.ri.Client.Do(req.WithContext(ctx))
 		if err != nil {
-			lastErr = err
+			if firstErr == nil {
+				firstErr = err
+			}
 			continue
 		}
 
 		if err := transport.CheckError(resp, http.StatusOK); err != nil {
 			resp.Body.Close()
-			lastErr = err
+			if firstErr == nil {
+				firstErr = err
+			}
 			continue
 		}
 
 		return verify.ReadCloser(resp.Body, rl.digest)
 	}
 
-	return nil, lastErr
+	return nil, firstErr
+}
+
+// CompressedRange implements partial.RangeReadLayer
+func (rl *remoteImageLayer) CompressedRange(offset, length int64) (io.ReadCloser, error) {
+	urls, err := rl.sources()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := redact.NewContext(rl.ri.context, "omitting binary blobs from logs")
+
+	var firstErr error
+	for _, u := range urls {
+		rc, err := rl.ri.fetchRange(ctx, u, offset, length)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		return rc, nil
+	}
+
+	return nil, firstErr
 }
 
 // Manifest implements partial.WithManifest so that we can use partial.BlobSize below.
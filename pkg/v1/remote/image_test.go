@@ -116,6 +116,7 @@ func TestRawManifestDigests(t *testing.T) {
 		ref           string
 		responseBody  []byte
 		contentDigest string
+		verify        bool
 		wantErr       bool
 	}{{
 		name:          "normal pull, by tag",
@@ -148,6 +149,33 @@ func TestRawManifestDigests(t *testing.T) {
 		responseBody:  mustRawManifest(t, img),
 		contentDigest: bogusDigest,
 		wantErr:       false,
+	}, {
+		name:          "right content-digest, verified, by tag",
+		ref:           "latest",
+		responseBody:  mustRawManifest(t, img),
+		contentDigest: mustDigest(t, img).String(),
+		verify:        true,
+		wantErr:       false,
+	}, {
+		name:         "no content-digest, verified, by tag",
+		ref:          "latest",
+		responseBody: mustRawManifest(t, img),
+		verify:       true,
+		wantErr:      false,
+	}, {
+		name:          "right body, wrong content-digest, verified, by tag",
+		ref:           "latest",
+		responseBody:  mustRawManifest(t, img),
+		contentDigest: bogusDigest,
+		verify:        true,
+		wantErr:       true,
+	}, {
+		name:          "right body, wrong content-digest, verified, by digest",
+		ref:           mustDigest(t, img).String(),
+		responseBody:  mustRawManifest(t, img),
+		contentDigest: bogusDigest,
+		verify:        true,
+		wantErr:       true,
 	}}
 
 	for _, tc := range cases {
@@ -160,7 +188,9 @@ func TestRawManifestDigests(t *testing.T) {
 						t.Errorf("Method; got %v, want %v", r.Method, http.MethodGet)
 					}
 
-					w.Header().Set("Docker-Content-Digest", tc.contentDigest)
+					if tc.contentDigest != "" {
+						w.Header().Set("Docker-Content-Digest", tc.contentDigest)
+					}
 					w.Write(tc.responseBody)
 				default:
 					t.Fatalf("Unexpected path: %v", r.URL.Path)
@@ -179,9 +209,10 @@ func TestRawManifestDigests(t *testing.T) {
 
 			rmt := remoteImage{
 				fetcher: fetcher{
-					Ref:     ref,
-					Client:  http.DefaultClient,
-					context: context.Background(),
+					Ref:                 ref,
+					Client:              http.DefaultClient,
+					context:             context.Background(),
+					verifyContentDigest: tc.verify,
 				},
 			}
 
@@ -192,6 +223,37 @@ func TestRawManifestDigests(t *testing.T) {
 	}
 }
 
+func TestContentDigestVerificationDefault(t *testing.T) {
+	img := randomImage(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case "/v2/foo/bar/manifests/latest":
+			w.Header().Set("Docker-Content-Digest", bogusDigest)
+			w.Write(mustRawManifest(t, img))
+		default:
+			t.Fatalf("Unexpected path: %v", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("url.Parse(%v) = %v", server.URL, err)
+	}
+	ref, err := name.ParseReference(u.Host + "/foo/bar:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Get(ref); err == nil {
+		t.Error("Get() with wrong Docker-Content-Digest succeeded, want error")
+	}
+	if _, err := Get(ref, WithoutContentDigestVerification()); err != nil {
+		t.Errorf("Get(WithoutContentDigestVerification) = %v", err)
+	}
+}
+
 func TestRawManifestNotFound(t *testing.T) {
 	expectedRepo := "foo/bar"
 	manifestPath := fmt.Sprintf("/v2/%s/manifests/latest", expectedRepo)
@@ -632,7 +694,8 @@ func TestPullingForeignLayer(t *testing.T) {
 			}
 			w.WriteHeader(http.StatusOK)
 		case foreignLayerPath:
-			// Not here!
+			// Not here, and we shouldn't look since the URLs work.
+			t.Errorf("foreign layer fetched from the registry")
 			w.WriteHeader(http.StatusNotFound)
 		default:
 			t.Fatalf("Unexpected path: %v", r.URL.Path)
@@ -674,3 +737,63 @@ func TestPullingForeignLayer(t *testing.T) {
 		t.Errorf("failed to Write: %v", err)
 	}
 }
+
+func TestConfigFile(t *testing.T) {
+	var blobs []string
+	reg := registry.New()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/blobs/") {
+			blobs = append(blobs, path.Base(r.URL.Path))
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.NewTag(u.Host + "/foo/bar:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := random.Index(1024, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteIndex(ref, idx); err != nil {
+		t.Fatal(err)
+	}
+
+	// random.Index children have no platform, so the first one is picked.
+	im, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := idx.Image(im.Manifests[0].Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfgName, err := img.ConfigName()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ConfigFile(ref)
+	if err != nil {
+		t.Fatalf("ConfigFile() = %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ConfigFile() (-want +got) = %s", diff)
+	}
+	if len(blobs) != 1 || blobs[0] != cfgName.String() {
+		t.Errorf("ConfigFile() fetched blobs %v, want only %v", blobs, cfgName)
+	}
+
+	if _, err := ConfigFile(ref, WithPlatform(v1.Platform{OS: "plan9", Architecture: "mips"})); err == nil {
+		t.Error("ConfigFile(plan9/mips) = nil, want error")
+	}
+}
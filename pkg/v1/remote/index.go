@@ -37,6 +37,7 @@ type remoteIndex struct {
 	manifest     []byte
 	mediaType    types.MediaType
 	descriptor   *v1.Descriptor
+	imageOptions
 }
 
 // Index provides access to a remote index reference.
@@ -175,7 +176,7 @@ func (r *remoteIndex) childByPlatform(platform v1.Platform) (*Descriptor, error)
 			p = *childDesc.Platform
 		}
 
-		if matchesPlatform(p, platform) {
+		if p.Satisfies(platform) {
 			return r.childDescriptor(childDesc, platform)
 		}
 	}
@@ -204,58 +205,14 @@ func (r *remoteIndex) childDescriptor(child v1.Descriptor, platform v1.Platform)
 	}
 	return &Descriptor{
 		fetcher: fetcher{
-			Ref:     ref,
-			Client:  r.Client,
-			context: r.context,
+			Ref:                 ref,
+			Client:              r.Client,
+			context:             r.context,
+			verifyContentDigest: r.verifyContentDigest,
 		},
-		Manifest:   manifest,
-		Descriptor: child,
-		platform:   platform,
+		Manifest:     manifest,
+		Descriptor:   child,
+		platform:     platform,
+		imageOptions: r.imageOptions,
 	}, nil
 }
-
-// matchesPlatform checks if the given platform matches the required platforms.
-// The given platform matches the required platform if
-// - architecture and OS are identical.
-// - OS version and variant are identical if provided.
-// - features and OS features of the required platform are subsets of those of the given platform.
-func matchesPlatform(given, required v1.Platform) bool {
-	// Required fields that must be identical.
-	if given.Architecture != required.Architecture || given.OS != required.OS {
-		return false
-	}
-
-	// Optional fields that may be empty, but must be identical if provided.
-	if required.OSVersion != "" && given.OSVersion != required.OSVersion {
-		return false
-	}
-	if required.Variant != "" && given.Variant != required.Variant {
-		return false
-	}
-
-	// Verify required platform's features are a subset of given platform's features.
-	if !isSubset(given.OSFeatures, required.OSFeatures) {
-		return false
-	}
-	if !isSubset(given.Features, required.Features) {
-		return false
-	}
-
-	return true
-}
-
-// isSubset checks if the required array of strings is a subset of the given lst.
-func isSubset(lst, required []string) bool {
-	set := make(map[string]bool)
-	for _, value := range lst {
-		set[value] = true
-	}
-
-	for _, value := range required {
-		if _, ok := set[value]; !ok {
-			return false
-		}
-	}
-
-	return true
-}
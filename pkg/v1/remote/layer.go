@@ -37,6 +37,12 @@ func (rl *remoteLayer) Compressed() (io.ReadCloser, error) {
 	return rl.fetchBlob(ctx, rl.digest)
 }
 
+// CompressedRange implements partial.RangeReadLayer
+func (rl *remoteLayer) CompressedRange(offset, length int64) (io.ReadCloser, error) {
+	ctx := redact.NewContext(rl.context, "omitting binary blobs from logs")
+	return rl.fetchRange(ctx, rl.url("blobs", rl.digest.String()), offset, length)
+}
+
 // Compressed implements partial.CompressedLayer
 func (rl *remoteLayer) Size() (int64, error) {
 	resp, err := rl.headBlob(rl.digest)
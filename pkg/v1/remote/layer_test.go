@@ -15,14 +15,20 @@
 package remote
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/google/go-containerregistry/pkg/internal/compare"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/partial"
@@ -135,3 +141,155 @@ func TestRemoteLayerDescriptor(t *testing.T) {
 		t.Errorf("layer[0].urls[0] = %s != %s", got, want)
 	}
 }
+
+func TestRemoteLayerTruncated(t *testing.T) {
+	layer, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err := layer.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := layer.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncated := b[:len(b)/2]
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			return
+		}
+		w.Write(truncated)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := name.NewDigest(fmt.Sprintf("%s/some/path@%s", u.Host, digest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := Layer(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err = l.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	_, err = ioutil.ReadAll(rc)
+	var derr *DigestMismatchError
+	if !errors.As(err, &derr) {
+		t.Fatalf("ReadAll() = %v, want DigestMismatchError", err)
+	}
+	if derr.Want != digest {
+		t.Errorf("Want = %s, want %s", derr.Want, digest)
+	}
+	if derr.BytesRead != int64(len(truncated)) {
+		t.Errorf("BytesRead = %d, want %d", derr.BytesRead, len(truncated))
+	}
+}
+
+func TestRemoteLayerRange(t *testing.T) {
+	layer, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := layer.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ranges := range []bool{true, false} {
+		t.Run(fmt.Sprintf("ranges=%t", ranges), func(t *testing.T) {
+			reg := registry.New()
+			var gotRange string
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/blobs/") {
+					gotRange = r.Header.Get("Range")
+					if !ranges {
+						r.Header.Del("Range")
+					}
+				}
+				reg.ServeHTTP(w, r)
+			}))
+			defer s.Close()
+			u, err := url.Parse(s.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tag, err := name.NewTag(u.Host + "/some/path:range")
+			if err != nil {
+				t.Fatal(err)
+			}
+			img, err := mutate.AppendLayers(empty.Image, layer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := Write(tag, img); err != nil {
+				t.Fatal(err)
+			}
+			digest, err := layer.Digest()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			l, err := Layer(tag.Context().Digest(digest.String()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ri, err := Image(tag)
+			if err != nil {
+				t.Fatal(err)
+			}
+			il, err := ri.LayerByDigest(digest)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, l := range []v1.Layer{l, il} {
+				rrl, ok := l.(partial.RangeReadLayer)
+				if !ok {
+					t.Fatalf("%T does not implement partial.RangeReadLayer", l)
+				}
+				for _, tc := range []struct {
+					offset, length int64
+					header         string
+					want           []byte
+				}{
+					{10, 20, "bytes=10-29", want[10:30]},
+					{100, -1, "bytes=100-", want[100:]},
+				} {
+					rc, err := rrl.CompressedRange(tc.offset, tc.length)
+					if err != nil {
+						t.Fatalf("CompressedRange(%d, %d) = %v", tc.offset, tc.length, err)
+					}
+					got, err := ioutil.ReadAll(rc)
+					rc.Close()
+					if err != nil {
+						t.Fatal(err)
+					}
+					if gotRange != tc.header {
+						t.Errorf("Range = %q, want %q", gotRange, tc.header)
+					}
+					if !bytes.Equal(got, tc.want) {
+						t.Errorf("CompressedRange(%d, %d) = %d bytes, want %d", tc.offset, tc.length, len(got), len(tc.want))
+					}
+				}
+			}
+		})
+	}
+}
@@ -20,15 +20,29 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"golang.org/x/sync/errgroup"
 )
 
 type tags struct {
 	Name string   `json:"name"`
 	Tags []string `json:"tags"`
+
+	// Manifests is an extension of some registries, see listTags.
+	Manifests map[string]tagManifest `json:"manifest,omitempty"`
+}
+
+type tagManifest struct {
+	Created string   `json:"timeCreatedMs"`
+	Tags    []string `json:"tag"`
 }
 
 // List wraps ListWithContext using the background context.
@@ -44,20 +58,11 @@ func ListWithContext(ctx context.Context, repo name.Repository, options ...Optio
 		return nil, err
 	}
 	scopes := []string{repo.Scope(transport.PullScope)}
-	tr, err := transport.NewWithContext(o.context, repo.Registry, o.auth, o.transport, scopes)
+	tr, err := transport.NewWithContext(o.context, repo.Registry, o.auth, o.transport, append(scopes, o.scopes...))
 	if err != nil {
 		return nil, err
 	}
 
-	uri := &url.URL{
-		Scheme: repo.Registry.Scheme(),
-		Host:   repo.Registry.RegistryStr(),
-		Path:   fmt.Sprintf("/v2/%s/tags/list", repo.RepositoryStr()),
-		// ECR returns an error if n > 1000:
-		// https://github.com/google/go-containerregistry/issues/681
-		RawQuery: "n=1000",
-	}
-
 	// This is lazy, but I want to make sure List(..., WithContext(ctx)) works
 	// without calling makeOptions() twice (which can have side effects).
 	// This means ListWithContext(ctx, ..., WithContext(ctx2)) prefers ctx2.
@@ -65,46 +70,79 @@ func ListWithContext(ctx context.Context, repo name.Repository, options ...Optio
 		ctx = o.context
 	}
 
-	client := http.Client{Transport: tr}
+	tagList, _, err := listTags(ctx, &http.Client{Transport: tr}, repo, o.pageSize)
+	return tagList, err
+}
+
+// listTags gets every page of /tags/list for repo. It also returns the
+// creation times of the tags, for registries that include them in a
+// "manifest" property (like GCR).
+func listTags(ctx context.Context, client *http.Client, repo name.Repository, pageSize int) ([]string, map[string]time.Time, error) {
+	// ECR returns an error if n > 1000:
+	// https://github.com/google/go-containerregistry/issues/681
+	n := pageSize
+	if n == 0 {
+		n = 1000
+	}
+
+	uri := &url.URL{
+		Scheme:   repo.Registry.Scheme(),
+		Host:     repo.Registry.RegistryStr(),
+		Path:     fmt.Sprintf("/v2/%s/tags/list", repo.RepositoryStr()),
+		RawQuery: fmt.Sprintf("n=%d", n),
+	}
+
 	tagList := []string{}
-	parsed := tags{}
+	created := map[string]time.Time{}
 
 	// get responses until there is no next page
 	for {
 		select {
 		case <-ctx.Done():
-			return nil, ctx.Err()
+			return nil, nil, ctx.Err()
 		default:
 		}
 
 		req, err := http.NewRequest("GET", uri.String(), nil)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		req = req.WithContext(ctx)
 
 		resp, err := client.Do(req)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		if err := transport.CheckError(resp, http.StatusOK); err != nil {
-			return nil, err
+			resp.Body.Close()
+			return nil, nil, err
 		}
 
+		parsed := tags{}
 		if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
-			return nil, err
+			resp.Body.Close()
+			return nil, nil, err
 		}
 
 		if err := resp.Body.Close(); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		tagList = append(tagList, parsed.Tags...)
+		for _, m := range parsed.Manifests {
+			ms, err := strconv.ParseInt(m.Created, 10, 64)
+			if err != nil {
+				continue
+			}
+			for _, tag := range m.Tags {
+				created[tag] = time.Unix(0, ms*int64(time.Millisecond))
+			}
+		}
 
 		uri, err = getNextPageURL(resp)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		// no next page
 		if uri == nil {
@@ -112,7 +150,87 @@ func ListWithContext(ctx context.Context, repo name.Repository, options ...Optio
 		}
 	}
 
-	return tagList, nil
+	return tagList, created, nil
+}
+
+// TagDetails describes a tag returned by ListDetails.
+type TagDetails struct {
+	// Descriptor is the descriptor of the tagged manifest.
+	Descriptor v1.Descriptor
+
+	// Created is when the manifest was created, if the registry reports it
+	// in its tag list (like GCR), and the zero time otherwise.
+	Created time.Time
+}
+
+// ListDetails is like List, but also HEADs every tag to return the descriptor
+// of the manifest it points to, keyed by tag. Up to WithJobs requests are made
+// in parallel, using a single token exchange.
+//
+// If any HEAD fails, or the context passed with WithContext is cancelled, the
+// remaining requests are abandoned and the error is returned.
+func ListDetails(repo name.Repository, options ...Option) (map[string]TagDetails, error) {
+	o, err := makeOptions(repo, options...)
+	if err != nil {
+		return nil, err
+	}
+	// The fetcher only uses its Ref for the repository, so any tag will do.
+	f, err := makeFetcher(repo.Tag("latest"), o)
+	if err != nil {
+		return nil, err
+	}
+
+	tagList, created, err := listTags(o.context, f.Client, repo, o.pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	acceptable := []types.MediaType{
+		// Just to look at them.
+		types.DockerManifestSchema1,
+		types.DockerManifestSchema1Signed,
+	}
+	acceptable = append(acceptable, acceptableImageMediaTypes...)
+	acceptable = append(acceptable, acceptableIndexMediaTypes...)
+
+	g, ctx := errgroup.WithContext(o.context)
+	f.context = ctx
+	tagChan := make(chan string, 2*o.jobs)
+	g.Go(func() error {
+		defer close(tagChan)
+		for _, tag := range tagList {
+			select {
+			case tagChan <- tag:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+
+	var mu sync.Mutex
+	details := make(map[string]TagDetails, len(tagList))
+	for i := 0; i < o.jobs; i++ {
+		g.Go(func() error {
+			for tag := range tagChan {
+				desc, _, err := f.headManifest(repo.Tag(tag), acceptable, "")
+				if err != nil {
+					return fmt.Errorf("HEAD %s: %w", tag, err)
+				}
+				mu.Lock()
+				details[tag] = TagDetails{
+					Descriptor: *desc,
+					Created:    created[tag],
+				}
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return details, nil
 }
 
 // getNextPageURL checks if there is a Link header in a http.Response which
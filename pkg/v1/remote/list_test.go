@@ -20,10 +20,15 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/random"
 )
 
 func TestList(t *testing.T) {
@@ -156,3 +161,67 @@ func TestGetNextPageURL(t *testing.T) {
 		t.Errorf("expected scheme to match request, got %s", u.Scheme)
 	}
 }
+
+func TestListDetails(t *testing.T) {
+	reg := registry.New()
+	var created bool
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if created && strings.HasSuffix(r.URL.Path, "/tags/list") {
+			// Mimic GCR's extension of the tag list.
+			fmt.Fprint(w, `{"tags":["a","b"],"manifest":{"sha256:abc":{"timeCreatedMs":"1000","tag":["a"]}}}`)
+			return
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := name.NewRepository(u.Host + "/list/details")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]TagDetails{}
+	for _, tag := range []string{"a", "b"} {
+		img, err := random.Image(1024, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Write(repo.Tag(tag), img); err != nil {
+			t.Fatal(err)
+		}
+		desc, err := partial.Descriptor(img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want[tag] = TagDetails{Descriptor: *desc}
+	}
+
+	got, err := ListDetails(repo, WithJobs(1))
+	if err != nil {
+		t.Fatalf("ListDetails() = %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ListDetails() (-want +got) = %s", diff)
+	}
+
+	created = true
+	got, err = ListDetails(repo)
+	if err != nil {
+		t.Fatalf("ListDetails() = %v", err)
+	}
+	if want := time.Unix(1, 0); !got["a"].Created.Equal(want) {
+		t.Errorf("Created = %v, want %v", got["a"].Created, want)
+	}
+	if !got["b"].Created.IsZero() {
+		t.Errorf("Created = %v, want zero", got["b"].Created)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ListDetails(repo, WithContext(ctx)); err == nil {
+		t.Error("ListDetails() with cancelled context = nil, want error")
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/logs"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// mirrorRef returns ref with its registry replaced by reg. The repository
+// path is kept as-is, including any implicit "library/" namespace.
+func mirrorRef(ref name.Reference, reg name.Registry) (name.Reference, error) {
+	repo, err := name.NewRepository(fmt.Sprintf("%s/%s", reg.RegistryStr(), ref.Context().RepositoryStr()))
+	if err != nil {
+		return nil, err
+	}
+	// Preserve the scheme of the mirror (e.g. insecure registries).
+	repo.Registry = reg
+
+	if _, ok := ref.(name.Digest); ok {
+		return repo.Digest(ref.Identifier()), nil
+	}
+	return repo.Tag(ref.Identifier()), nil
+}
+
+// withMirrors calls fn with a fetcher for ref on each of the configured
+// mirrors in order, returning as soon as one succeeds. If a mirror doesn't
+// have the manifest or can't be reached, the next one is tried, and if none of
+// them can serve it, fn is called with a fetcher for the original registry.
+// Any other error from a mirror, e.g. a rejected credential or a manifest that
+// doesn't match the requested digest, is returned as-is.
+func withMirrors(ref name.Reference, o *options, fn func(*fetcher) error) error {
+	for _, reg := range o.mirrors {
+		err := tryMirror(ref, reg, o, fn)
+		if err == nil || !mirrorMissed(err) {
+			return err
+		}
+		logs.Warn.Printf("Failed to use mirror %q for %q, falling back: %v", reg, ref, err)
+	}
+
+	f, err := makeFetcher(ref, o)
+	if err != nil {
+		return err
+	}
+	return fn(f)
+}
+
+func tryMirror(ref name.Reference, reg name.Registry, o *options, fn func(*fetcher) error) error {
+	mref, err := mirrorRef(ref, reg)
+	if err != nil {
+		return err
+	}
+
+	// Credentials given with WithAuth are meant for the original registry, so
+	// mirrors only get what the keychain has for them.
+	mo := *o
+	mo.auth = authn.Anonymous
+	if o.keychain != nil {
+		auth, err := o.keychain.Resolve(mref.Context())
+		if err != nil {
+			return err
+		}
+		mo.auth = auth
+	}
+
+	f, err := makeFetcher(mref, &mo)
+	if err != nil {
+		return err
+	}
+	return fn(f)
+}
+
+// mirrorMissed reports whether err from a mirror means that the next mirror,
+// or the original registry, should be tried: the mirror doesn't have the
+// manifest, or it couldn't be reached.
+func mirrorMissed(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var terr *transport.Error
+	if errors.As(err, &terr) {
+		return terr.StatusCode == http.StatusNotFound
+	}
+	var nerr net.Error
+	return errors.As(err, &nerr)
+}
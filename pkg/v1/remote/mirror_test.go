@@ -0,0 +1,261 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+// newRegistry starts a test registry and returns it along with its name.
+func newRegistry(t *testing.T) (*httptest.Server, name.Registry) {
+	t.Helper()
+	s := httptest.NewServer(registry.New())
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg, err := name.NewRegistry(u.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, reg
+}
+
+// pushRandom writes a random image to ref and returns its digest.
+func pushRandom(t *testing.T, ref name.Reference) v1.Hash {
+	t.Helper()
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestMirrors(t *testing.T) {
+	upstream, upstreamReg := newRegistry(t)
+	defer upstream.Close()
+	mirror, mirrorReg := newRegistry(t)
+	defer mirror.Close()
+
+	// A mirror that isn't reachable at all.
+	gone, goneReg := newRegistry(t)
+	gone.Close()
+
+	cached, err := name.NewTag(upstreamReg.RegistryStr() + "/foo/bar:cached")
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing := cached.Context().Tag("missing")
+
+	pushRandom(t, cached)
+	wantMissing := pushRandom(t, missing)
+
+	mirrored, err := mirrorRef(cached, mirrorReg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantCached := pushRandom(t, mirrored)
+
+	opts := []Option{WithMirrors(goneReg, mirrorReg)}
+
+	t.Run("hit", func(t *testing.T) {
+		desc, err := Get(cached, opts...)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if desc.Digest != wantCached {
+			t.Errorf("Get: got %v, want %v from mirror", desc.Digest, wantCached)
+		}
+		if got, want := desc.Ref.Context().RegistryStr(), mirrorReg.RegistryStr(); got != want {
+			t.Errorf("Get: served by %q, want %q", got, want)
+		}
+
+		// Blobs should come from the mirror too.
+		img, err := desc.Image()
+		if err != nil {
+			t.Fatalf("Image: %v", err)
+		}
+		if err := validate.Image(img); err != nil {
+			t.Errorf("validate.Image: %v", err)
+		}
+
+		head, err := Head(cached, opts...)
+		if err != nil {
+			t.Fatalf("Head: %v", err)
+		}
+		if head.Digest != wantCached {
+			t.Errorf("Head: got %v, want %v from mirror", head.Digest, wantCached)
+		}
+	})
+
+	t.Run("miss", func(t *testing.T) {
+		img, err := Image(missing, opts...)
+		if err != nil {
+			t.Fatalf("Image: %v", err)
+		}
+		d, err := img.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d != wantMissing {
+			t.Errorf("Image: got %v, want %v from upstream", d, wantMissing)
+		}
+
+		head, err := Head(missing, opts...)
+		if err != nil {
+			t.Fatalf("Head: %v", err)
+		}
+		if head.Digest != wantMissing {
+			t.Errorf("Head: got %v, want %v from upstream", head.Digest, wantMissing)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		if _, err := Get(cached.Context().Tag("nope"), opts...); err == nil {
+			t.Error("Get: expected error for missing tag")
+		}
+	})
+}
+
+func TestMirrorRef(t *testing.T) {
+	mirror, err := name.NewRegistry("mirror.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		in, want string
+	}{{
+		in:   "ubuntu:latest",
+		want: "mirror.example.com/library/ubuntu:latest",
+	}, {
+		in:   "gcr.io/foo/bar:baz",
+		want: "mirror.example.com/foo/bar:baz",
+	}, {
+		in:   "gcr.io/foo/bar@sha256:deadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeef",
+		want: "mirror.example.com/foo/bar@sha256:deadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeef",
+	}} {
+		ref, err := name.ParseReference(tc.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := mirrorRef(ref, mirror)
+		if err != nil {
+			t.Fatalf("mirrorRef(%q): %v", tc.in, err)
+		}
+		if got.String() != tc.want {
+			t.Errorf("mirrorRef(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestMirrorsNoFallback(t *testing.T) {
+	upstream, upstreamReg := newRegistry(t)
+	defer upstream.Close()
+
+	ref, err := name.NewTag(upstreamReg.RegistryStr() + "/foo/bar:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := pushRandom(t, ref)
+
+	t.Run("credentials", func(t *testing.T) {
+		// A mirror that wants credentials we don't have for it.
+		var mu sync.Mutex
+		var sent []string
+		mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			if auth := r.Header.Get("Authorization"); auth != "" {
+				sent = append(sent, auth)
+			}
+			mu.Unlock()
+			w.Header().Set("WWW-Authenticate", `Basic realm="mirror"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer mirror.Close()
+		u, err := url.Parse(mirror.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mirrorReg, err := name.NewRegistry(u.Host)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		auth := WithAuth(&authn.Basic{Username: "upstream", Password: "secret"})
+		_, err = Get(ref, auth, WithMirrors(mirrorReg))
+		var terr *transport.Error
+		if !errors.As(err, &terr) || terr.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Get: got %v, want 401 from the mirror", err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if len(sent) != 0 {
+			t.Errorf("mirror was sent credentials: %v", sent)
+		}
+	})
+
+	t.Run("digest mismatch", func(t *testing.T) {
+		// A mirror that serves the wrong manifest for digests.
+		h := registry.New()
+		mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.Contains(r.URL.Path, "/manifests/sha256:") {
+				r.URL.Path = path.Dir(r.URL.Path) + "/other"
+			}
+			h.ServeHTTP(w, r)
+		}))
+		defer mirror.Close()
+		u, err := url.Parse(mirror.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mirrorReg, err := name.NewRegistry(u.Host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		other, err := mirrorRef(ref.Context().Tag("other"), mirrorReg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pushRandom(t, other)
+
+		if _, err := Get(ref.Context().Digest(want.String()), WithMirrors(mirrorReg)); err == nil {
+			t.Error("Get: expected error for a mirror serving the wrong manifest")
+		}
+	})
+}
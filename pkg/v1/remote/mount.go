@@ -15,6 +15,8 @@
 package remote
 
 import (
+	"io"
+
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/partial"
@@ -34,6 +36,29 @@ func (ml *MountableLayer) Descriptor() (*v1.Descriptor, error) {
 	return partial.Descriptor(ml.Layer)
 }
 
+// CompressedRange implements partial.RangeReadLayer, falling back to reading
+// everything up to offset if the wrapped layer doesn't implement it.
+func (ml *MountableLayer) CompressedRange(offset, length int64) (io.ReadCloser, error) {
+	return partial.CompressedRange(ml.Layer, offset, length)
+}
+
+// asMountable returns the MountableLayer that l is or wraps, if any. Layers
+// that wrap another one without changing its contents, such as the ones
+// mutate returns when it overrides a layer's descriptor, expose it with an
+// Unwrap method.
+func asMountable(l v1.Layer) (*MountableLayer, bool) {
+	for {
+		if ml, ok := l.(*MountableLayer); ok {
+			return ml, true
+		}
+		u, ok := l.(interface{ Unwrap() v1.Layer })
+		if !ok {
+			return nil, false
+		}
+		l = u.Unwrap()
+	}
+}
+
 // mountableImage wraps the v1.Layer references returned by the embedded v1.Image
 // in MountableLayer's so that remote.Write might attempt to mount them from their
 // source repository.
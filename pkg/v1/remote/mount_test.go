@@ -15,9 +15,15 @@
 package remote
 
 import (
+	"net/http"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
 	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/validate"
 )
@@ -53,3 +59,55 @@ func TestMountableImage(t *testing.T) {
 		}
 	}
 }
+
+func TestMutatedLayersStayMountable(t *testing.T) {
+	var mu sync.Mutex
+	mounts := 0
+	reg := registry.New()
+	s, src := newWriteAllRegistry(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Query().Get("mount") != "" {
+			mu.Lock()
+			mounts++
+			mu.Unlock()
+		}
+		// The registry shares blobs across repositories, so hide them from dst.
+		if r.Method == http.MethodHead && strings.HasPrefix(r.URL.Path, "/v2/dst/blobs/") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		reg.ServeHTTP(w, r)
+	}), "/src:latest")
+	defer s.Close()
+
+	rnd, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(src, rnd); err != nil {
+		t.Fatal(err)
+	}
+	img, err := Image(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := partial.Descriptor(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Setting a subject makes the image OCI, which rewraps every layer with
+	// an OCI media type.
+	dst, err := name.NewTag(src.Context().RegistryStr() + "/dst:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(dst, mutate.Subject(img, *subject)); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if mounts != 3 {
+		t.Errorf("mounted %d layers, want 3", mounts)
+	}
+}
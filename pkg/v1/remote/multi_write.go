@@ -15,13 +15,12 @@
 package remote
 
 import (
+	"errors"
 	"fmt"
-	"net/http"
 
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/partial"
-	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 	"golang.org/x/sync/errgroup"
 )
@@ -33,6 +32,10 @@ import (
 // Current limitations:
 // - All refs must share the same repository.
 // - Images cannot consist of stream.Layers.
+// - WithAdditionalTags can only be used to write a single Image or ImageIndex.
+//
+// See WriteAll to write a single Image or ImageIndex to several repositories
+// or registries.
 func MultiWrite(m map[name.Reference]Taggable, options ...Option) error {
 	// Determine the repository being pushed to; if asked to push to
 	// multiple repositories, give up.
@@ -49,6 +52,10 @@ func MultiWrite(m map[name.Reference]Taggable, options ...Option) error {
 	if err != nil {
 		return err
 	}
+	if len(o.additionalTags) != 0 && len(m) > 1 {
+		// Every Taggable would be tagged with the same tags.
+		return errors.New("MultiWrite only supports WithAdditionalTags when writing a single image or index")
+	}
 
 	// Collect unique blobs (layers and config blobs).
 	blobs := map[v1.Hash]v1.Layer{}
@@ -80,16 +87,11 @@ func MultiWrite(m map[name.Reference]Taggable, options ...Option) error {
 	for _, l := range blobs {
 		ls = append(ls, l)
 	}
-	scopes := scopesForUploadingImage(repo, ls)
-	tr, err := transport.NewWithContext(o.context, repo.Registry, o.auth, o.transport, scopes)
+	scopes := scopesForUploadingImage(repo, ls, o.mountFrom...)
+	w, err := makeWriter(repo, o, scopes)
 	if err != nil {
 		return err
 	}
-	w := writer{
-		repo:    repo,
-		client:  &http.Client{Transport: tr},
-		context: o.context,
-	}
 
 	// Upload individual blobs and collect any errors.
 	blobChan := make(chan v1.Layer, 2*o.jobs)
@@ -155,8 +157,19 @@ func MultiWrite(m map[name.Reference]Taggable, options ...Option) error {
 	}
 	// Push originally requested index manifests, which might depend on
 	// newly discovered manifests.
-	return commitMany(indexes)
+	if err := commitMany(indexes); err != nil {
+		return err
+	}
 
+	if len(o.additionalTags) != 0 {
+		// There's only one Taggable, see above.
+		for _, t := range m {
+			if err := w.commitAdditionalTags(t, o.additionalTags); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 // addIndexBlobs adds blobs to the set of blobs we intend to upload, and
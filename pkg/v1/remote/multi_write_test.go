@@ -149,6 +149,51 @@ func TestMultiWriteWithNondistributableLayer(t *testing.T) {
 	}
 }
 
+func TestMultiWriteAdditionalTags(t *testing.T) {
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal("random.Image:", err)
+	}
+	other, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal("random.Image:", err)
+	}
+
+	// Set up a fake registry.
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tag1 := mustNewTag(t, u.Host+"/repo:tag1")
+	tag2 := mustNewTag(t, u.Host+"/repo:tag2")
+	tags := WithAdditionalTags([]string{"extra"})
+
+	// The tags would be ambiguous with more than one image.
+	if err := MultiWrite(map[name.Reference]Taggable{tag1: img, tag2: other}, tags); err == nil {
+		t.Error("MultiWrite: expected error for additional tags with two images")
+	}
+
+	if err := MultiWrite(map[name.Reference]Taggable{tag1: img}, tags); err != nil {
+		t.Fatal("MultiWrite:", err)
+	}
+	got, err := Image(tag1.Context().Tag("extra"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, err := got.Digest(); err != nil {
+		t.Fatal(err)
+	} else if d != want {
+		t.Errorf("extra: got %v, want %v", d, want)
+	}
+}
+
 // TestMultiWrite_Deep tests that a deeply nested tree of manifest lists gets
 // pushed in the correct order (i.e., each level in sequence).
 func TestMultiWrite_Deep(t *testing.T) {
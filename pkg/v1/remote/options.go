@@ -16,15 +16,26 @@ package remote
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/internal/retry"
 	"github.com/google/go-containerregistry/pkg/logs"
+	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
 )
 
+// Backoff controls how long to wait between retries, see WithRetryBackoff.
+type Backoff = retry.Backoff
+
 // Option is a functional option for remote operations.
 type Option func(*options) error
 
@@ -37,6 +48,32 @@ type options struct {
 	jobs                           int
 	userAgent                      string
 	allowNondistributableArtifacts bool
+	chunkSize                      int64
+	monolithic                     bool
+	updates                        chan<- v1.Update
+	progress                       *progress
+	mirrors                        []name.Registry
+	mountFrom                      []name.Repository
+	skipExistingBlobCheck          bool
+	stats                          *WriteStats
+	filter                         map[string]string
+	pageSize                       int
+	prefetchDir                    string
+	ifNoneMatch                    string
+	verifyContentDigest            bool
+	timeout                        time.Duration
+	transportTimeouts              *TransportTimeouts
+	http1                          bool
+	connTrace                      func(transport.ConnInfo)
+	proxy                          *url.URL
+	tlsConfig                      *tls.Config
+	rootCAs                        *x509.CertPool
+	scopes                         []string
+	additionalTags                 []string
+	uploaded                       *blobSet
+	retryBackoff                   *Backoff
+	retryPredicate                 retry.Predicate
+	convertSchema1                 bool
 }
 
 var defaultPlatform = v1.Platform{
@@ -53,6 +90,8 @@ func makeOptions(target authn.Resource, opts ...Option) (*options, error) {
 		platform:  defaultPlatform,
 		context:   context.Background(),
 		jobs:      defaultJobs,
+
+		verifyContentDigest: true,
 	}
 
 	for _, option := range opts {
@@ -72,6 +111,56 @@ func makeOptions(target authn.Resource, opts ...Option) (*options, error) {
 		o.auth = auth
 	}
 
+	// The options that tune the *http.Transport all apply to the same clone
+	// of it, see the package documentation.
+	if o.transportTimeouts != nil || o.tlsConfig != nil || o.rootCAs != nil || o.http1 || o.proxy != nil {
+		t, ok := o.transport.(*http.Transport)
+		if !ok {
+			return nil, fmt.Errorf("WithTransportTimeouts, WithTLSClientConfig, WithRootCAs, WithHTTP1 and WithProxy require an *http.Transport, got %T", o.transport)
+		}
+		t = t.Clone()
+		if o.transportTimeouts != nil {
+			o.transportTimeouts.apply(t)
+		}
+		if o.tlsConfig != nil {
+			t.TLSClientConfig = o.tlsConfig.Clone()
+		}
+		if o.rootCAs != nil {
+			if t.TLSClientConfig == nil {
+				t.TLSClientConfig = &tls.Config{}
+			}
+			t.TLSClientConfig.RootCAs = o.rootCAs
+		}
+		if o.http1 {
+			// A non-nil, empty TLSNextProto disables HTTP/2.
+			t.ForceAttemptHTTP2 = false
+			t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+			// Once a transport has been used for HTTP/2, its TLS config offers
+			// "h2" during ALPN, which we can no longer speak.
+			if t.TLSClientConfig != nil {
+				protos := []string{}
+				for _, p := range t.TLSClientConfig.NextProtos {
+					if p != "h2" {
+						protos = append(protos, p)
+					}
+				}
+				t.TLSClientConfig.NextProtos = protos
+			}
+		}
+		if o.proxy != nil {
+			t.Proxy = http.ProxyURL(o.proxy)
+		}
+		o.transport = t
+	}
+
+	if o.connTrace != nil {
+		o.transport = transport.NewConnTrace(o.transport, o.connTrace)
+	}
+
+	if o.timeout != 0 {
+		o.transport = transport.NewTimeout(o.transport, o.timeout)
+	}
+
 	// Wrap the transport in something that logs requests and responses.
 	// It's expensive to generate the dumps, so skip it if we're writing
 	// to nothing.
@@ -80,7 +169,14 @@ func makeOptions(target authn.Resource, opts ...Option) (*options, error) {
 	}
 
 	// Wrap the transport in something that can retry network flakes.
-	o.transport = transport.NewRetry(o.transport)
+	var retryOpts []transport.Option
+	if o.retryBackoff != nil {
+		retryOpts = append(retryOpts, transport.WithRetryBackoff(*o.retryBackoff))
+	}
+	if o.retryPredicate != nil {
+		retryOpts = append(retryOpts, transport.WithRetryPredicate(o.retryPredicate))
+	}
+	o.transport = transport.NewRetry(o.transport, retryOpts...)
 
 	// Wrap this last to prevent transport.New from double-wrapping.
 	if o.userAgent != "" {
@@ -93,7 +189,9 @@ func makeOptions(target authn.Resource, opts ...Option) (*options, error) {
 // WithTransport is a functional option for overriding the default transport
 // for remote operations.
 //
-// The default transport its http.DefaultTransport.
+// The default transport is http.DefaultTransport, which has no overall
+// request timeout. See WithTimeout and WithTransportTimeouts to tune it
+// without building a transport from scratch.
 func WithTransport(t http.RoundTripper) Option {
 	return func(o *options) error {
 		o.transport = t
@@ -101,6 +199,171 @@ func WithTransport(t http.RoundTripper) Option {
 	}
 }
 
+// WithTimeout is a functional option that cancels a request if it makes no
+// progress for longer than d, i.e. if the registry takes longer than that to
+// send the response headers or the next chunk of the response body.
+//
+// The timeout applies to each request (and retry) separately, and isn't a
+// limit on the total duration of a request, so large layers can still be
+// downloaded over slow connections as long as data keeps arriving.
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) error {
+		if d <= 0 {
+			return errors.New("timeout must be greater than zero")
+		}
+		o.timeout = d
+		return nil
+	}
+}
+
+// TransportTimeouts tunes the timeouts of the *http.Transport used for remote
+// operations, see WithTransportTimeouts. Zero values leave the transport's
+// setting unchanged.
+type TransportTimeouts struct {
+	// Dial limits how long establishing a TCP connection may take.
+	Dial time.Duration
+
+	// KeepAlive is the interval between TCP keep-alive probes. It is only
+	// used if Dial is also set.
+	KeepAlive time.Duration
+
+	// TLSHandshake limits how long the TLS handshake may take.
+	TLSHandshake time.Duration
+
+	// ResponseHeader limits how long to wait for the response headers after
+	// the request has been written.
+	ResponseHeader time.Duration
+
+	// IdleConn is how long an idle keep-alive connection is kept open.
+	IdleConn time.Duration
+}
+
+// apply sets the non-zero timeouts on t.
+func (tt *TransportTimeouts) apply(t *http.Transport) {
+	if tt.Dial != 0 {
+		t.DialContext = (&net.Dialer{
+			Timeout:   tt.Dial,
+			KeepAlive: tt.KeepAlive,
+		}).DialContext
+	}
+	if tt.TLSHandshake != 0 {
+		t.TLSHandshakeTimeout = tt.TLSHandshake
+	}
+	if tt.ResponseHeader != 0 {
+		t.ResponseHeaderTimeout = tt.ResponseHeader
+	}
+	if tt.IdleConn != 0 {
+		t.IdleConnTimeout = tt.IdleConn
+	}
+}
+
+// WithTransportTimeouts is a functional option that sets the given timeouts on
+// the transport.
+func WithTransportTimeouts(tt TransportTimeouts) Option {
+	return func(o *options) error {
+		o.transportTimeouts = &tt
+		return nil
+	}
+}
+
+// WithHTTP1 is a functional option that keeps the transport from negotiating
+// HTTP/2, for registries (or proxies in front of them) with buggy HTTP/2
+// implementations.
+func WithHTTP1() Option {
+	return func(o *options) error {
+		o.http1 = true
+		return nil
+	}
+}
+
+// WithProxy is a functional option that sends all requests through the HTTP
+// proxy at proxyURL, instead of the one from the environment (HTTP_PROXY etc.).
+// If proxyURL has userinfo, it is sent to the proxy as Basic credentials in the
+// Proxy-Authorization header, which never reaches the registry and is kept
+// separate from the registry credentials in the Authorization header.
+func WithProxy(proxyURL *url.URL) Option {
+	return func(o *options) error {
+		if proxyURL == nil {
+			return errors.New("WithProxy requires a proxy URL")
+		}
+		o.proxy = proxyURL
+		return nil
+	}
+}
+
+// WithTLSClientConfig is a functional option for setting the TLS config used
+// to connect to the registry, e.g. to present a client certificate for mutual
+// TLS. The authentication and retry layers are set up on top of it as usual.
+// The same config is used for every host, including token servers and
+// redirects to blob storage.
+func WithTLSClientConfig(cfg *tls.Config) Option {
+	return func(o *options) error {
+		if cfg == nil {
+			return errors.New("WithTLSClientConfig requires a TLS config")
+		}
+		o.tlsConfig = cfg
+		return nil
+	}
+}
+
+// WithRootCAs is a functional option for trusting the certificate authorities
+// in pool instead of the system ones, e.g. for a registry with a private CA.
+// It takes precedence over the RootCAs of WithTLSClientConfig.
+func WithRootCAs(pool *x509.CertPool) Option {
+	return func(o *options) error {
+		if pool == nil {
+			return errors.New("WithRootCAs requires a certificate pool")
+		}
+		o.rootCAs = pool
+		return nil
+	}
+}
+
+// WithConnTrace is a functional option that calls f after every request with
+// the negotiated protocol and whether the connection was reused, see
+// transport.ConnInfo. Retried requests are reported once per attempt.
+func WithConnTrace(f func(transport.ConnInfo)) Option {
+	return func(o *options) error {
+		o.connTrace = f
+		return nil
+	}
+}
+
+// WithScopes is a functional option that requests additional scopes from the
+// token endpoint, on top of those derived from the operation, e.g.
+// "registry:catalog:*" or "repository:foo/bar:pull,push". This avoids having
+// to re-authenticate part way through a sequence of operations.
+func WithScopes(scopes ...string) Option {
+	return func(o *options) error {
+		o.scopes = append(o.scopes, scopes...)
+		return nil
+	}
+}
+
+// WithAdditionalTags is a functional option for Write, WriteIndex, WriteAll and
+// MultiWrite that also PUTs the manifest under each of the given tags in the
+// target repository, once it has been pushed to the target reference. This
+// allows pushing by digest and moving tags in one call. MultiWrite only
+// supports it when writing a single Image or ImageIndex.
+//
+// Failing to push some of the tags doesn't abort the others; if any fail, the
+// returned error is an *AdditionalTagsError.
+func WithAdditionalTags(tags []string) Option {
+	return func(o *options) error {
+		o.additionalTags = append(o.additionalTags, tags...)
+		return nil
+	}
+}
+
+// withoutAdditionalTags keeps the children of WriteIndex from being tagged
+// with the tags meant for the index itself.
+func withoutAdditionalTags() Option {
+	return func(o *options) error {
+		o.additionalTags = nil
+		return nil
+	}
+}
+
 // WithAuth is a functional option for overriding the default authenticator
 // for remote operations.
 //
@@ -127,7 +390,8 @@ func WithAuthFromKeychain(keys authn.Keychain) Option {
 // WithPlatform is a functional option for overriding the default platform
 // that Image and Descriptor.Image use for resolving an index to an image.
 //
-// The default platform is amd64/linux.
+// The default platform is amd64/linux. Use v1.HostPlatform() to resolve
+// indexes to the image for the machine the binary is running on.
 func WithPlatform(p v1.Platform) Option {
 	return func(o *options) error {
 		o.platform = p
@@ -153,6 +417,9 @@ func WithContext(ctx context.Context) Option {
 // operations performed by a given function. Note that not all remote
 // operations support parallelism.
 //
+// With WithPrefetch, this is also how many of an image's layers are
+// downloaded at once. On its own, it doesn't change how Image reads layers.
+//
 // The default value is 4.
 func WithJobs(jobs int) Option {
 	return func(o *options) error {
@@ -164,13 +431,65 @@ func WithJobs(jobs int) Option {
 	}
 }
 
+// WithPrefetch is a functional option for Image that downloads all of the
+// image's layers into dir, concurrently (see WithJobs), the first time the
+// contents of any of them are read. Their digests are verified as they are
+// written.
+//
+// The caller owns dir and is responsible for removing it. Layers that are
+// already present in dir, e.g. from a previous pull, are not downloaded again.
+// The downloads run until every layer is in dir, even if only some of them
+// are read; cancel the context passed to WithContext to stop them.
+//
+// This also applies to images resolved from an index, e.g. with
+// Descriptor.Image or ImageIndex.Image.
+func WithPrefetch(dir string) Option {
+	return func(o *options) error {
+		o.prefetchDir = dir
+		return nil
+	}
+}
+
+// WithIfNoneMatch is a functional option for Get and Head that makes the
+// request conditional on the manifest having changed since etag was returned
+// in Descriptor.ETag or by HeadWithETag. If it hasn't, ErrNotModified is returned, so that a tag
+// can be polled without downloading its manifest every time.
+func WithIfNoneMatch(etag string) Option {
+	return func(o *options) error {
+		o.ifNoneMatch = etag
+		return nil
+	}
+}
+
+// WithoutContentDigestVerification is a functional option that stops manifest
+// fetches by tag from checking that the manifest hashes to the
+// Docker-Content-Digest header returned by the registry. By default a
+// mismatch is an error, since it means a proxy tampered with the manifest or
+// the registry is broken; this is an escape hatch for the latter.
+//
+// Manifests fetched by digest are always checked against that digest.
+func WithoutContentDigestVerification() Option {
+	return func(o *options) error {
+		o.verifyContentDigest = false
+		return nil
+	}
+}
+
 // WithUserAgent adds the given string to the User-Agent header for any HTTP
 // requests. This header will also include "go-containerregistry/${version}".
 //
 // If you want to completely overwrite the User-Agent header, use WithTransport.
+//
+// Passing WithUserAgent more than once appends each string in order, so that
+// e.g. a tool built on crane can identify itself alongside crane.
 func WithUserAgent(ua string) Option {
 	return func(o *options) error {
-		o.userAgent = ua
+		switch {
+		case o.userAgent == "":
+			o.userAgent = ua
+		case ua != "":
+			o.userAgent = o.userAgent + " " + ua
+		}
 		return nil
 	}
 }
@@ -184,3 +503,216 @@ func WithNondistributable(o *options) error {
 	o.allowNondistributableArtifacts = true
 	return nil
 }
+
+// WithChunkSize is a functional option for uploading blobs in chunks of at
+// most n bytes, rather than in a single request. If a chunk fails to upload,
+// the upload is resumed from the last offset the registry received instead of
+// starting over, which can save a lot of bandwidth for large layers.
+//
+// By default, blobs are uploaded in a single request.
+func WithChunkSize(n int64) Option {
+	return func(o *options) error {
+		if n <= 0 {
+			return errors.New("chunk size must be greater than zero")
+		}
+		o.chunkSize = n
+		return nil
+	}
+}
+
+// WithMonolithicUpload is a functional option for uploading each blob with a
+// single PUT to the upload started by the initial POST, instead of streaming
+// it with a PATCH first, for registries that don't support the latter. It
+// takes precedence over WithChunkSize, except for streaming layers, whose
+// digest isn't known until they have been uploaded.
+//
+// Without this option, uploads fall back to this automatically once the
+// registry rejects a PATCH with 405 Method Not Allowed or 501 Not Implemented.
+func WithMonolithicUpload() Option {
+	return func(o *options) error {
+		o.monolithic = true
+		return nil
+	}
+}
+
+// WithProgress is a functional option for receiving updates about how many
+// bytes of blobs have been written so far by Write, WriteIndex or WriteLayer.
+// For an index, the total covers the blobs of all of its children.
+//
+// Once the write is finished, a final update is sent with an Error of io.EOF
+// on success, or the error that caused the write to fail. The channel is
+// never closed, and writes will block until each update is received.
+func WithProgress(updates chan<- v1.Update) Option {
+	return func(o *options) error {
+		o.updates = updates
+		return nil
+	}
+}
+
+// withProgress shares the progress of WriteIndex with the writes of its
+// children, so that they don't report their own totals.
+func withProgress(p *progress) Option {
+	return func(o *options) error {
+		o.progress = p
+		return nil
+	}
+}
+
+// WithMirrors is a functional option for pulling through registry mirrors,
+// such as a pull-through cache.
+//
+// Get, Head, Image and Index will try each mirror in order, using the same
+// repository path as the requested reference, and fall back to the original
+// registry if a mirror doesn't have the manifest or can't be reached. Other
+// errors from a mirror, such as rejected credentials or a manifest that
+// doesn't match the requested digest, are returned without falling back.
+// Blobs are fetched from whichever registry served the manifest.
+//
+// Credentials from WithAuth are only sent to the original registry. If
+// WithAuthFromKeychain is used, credentials for each mirror are resolved from
+// the keychain separately; otherwise mirrors are accessed anonymously.
+func WithMirrors(mirrors ...name.Registry) Option {
+	return func(o *options) error {
+		o.mirrors = append(o.mirrors, mirrors...)
+		return nil
+	}
+}
+
+// WithExistingBlobCheck is a functional option for controlling whether blobs
+// are checked for with a HEAD request before they are uploaded. It is enabled
+// by default, so that Write, WriteLayer and MultiWrite only upload the blobs
+// that are missing from the destination repository, e.g. only the new layers
+// of an image whose base is already there.
+//
+// Some registries check whether a blob exists when an upload is initiated, in
+// which case disabling this saves a round trip for each missing blob.
+func WithExistingBlobCheck(enabled bool) Option {
+	return func(o *options) error {
+		o.skipExistingBlobCheck = !enabled
+		return nil
+	}
+}
+
+// WithWriteStats is a functional option for finding out how many blobs were
+// uploaded by Write, WriteIndex, WriteLayer or MultiWrite, and how many were
+// skipped because they were already present. The counts in stats are added
+// to as blobs are written, so stats should only be read once the write has
+// returned.
+//
+// Blobs of child manifests that already exist in the destination are not
+// checked, so they aren't counted as skipped. Neither are blobs that are
+// shared by several children of an index, after the first upload.
+func WithWriteStats(stats *WriteStats) Option {
+	return func(o *options) error {
+		o.stats = stats
+		return nil
+	}
+}
+
+// withBlobSet shares the blobs written by WriteIndex with the writes of its
+// children.
+func withBlobSet(s *blobSet) Option {
+	return func(o *options) error {
+		o.uploaded = s
+		return nil
+	}
+}
+
+// WithFilter is a functional option for filtering the results of Referrers.
+// The only filter that is currently supported is "artifactType", which only
+// keeps manifests with the given artifact type.
+func WithFilter(key, value string) Option {
+	return func(o *options) error {
+		if o.filter == nil {
+			o.filter = map[string]string{}
+		}
+		o.filter[key] = value
+		return nil
+	}
+}
+
+// WithMountFrom is a functional option for mounting blobs from other
+// repositories on the same registry instead of uploading them.
+//
+// Before uploading a blob that isn't already present, Write, WriteLayer and
+// MultiWrite ask the registry to mount it from each of these repositories in
+// order, falling back to a regular upload if none of them has it. Repositories
+// on other registries are ignored. Layers pulled with this package are already
+// mounted from where they came from, so this is mostly useful for layers from
+// other sources.
+func WithMountFrom(repos ...name.Repository) Option {
+	return func(o *options) error {
+		o.mountFrom = append(o.mountFrom, repos...)
+		return nil
+	}
+}
+
+// WithPageSize is a functional option for setting the number of results
+// requested per page when listing tags or catalog repositories. Every page is
+// still fetched; this only controls how many requests that takes.
+//
+// By default, List requests 1000 tags and Catalog requests 10000
+// repositories per page.
+func WithPageSize(n int) Option {
+	return func(o *options) error {
+		if n <= 0 {
+			return errors.New("page size must be greater than zero")
+		}
+		o.pageSize = n
+		return nil
+	}
+}
+
+// WithRetryBackoff is a functional option for overriding the backoff used
+// when retrying requests, including blob uploads and downloads. Steps is the
+// maximum number of attempts, and each wait is Duration multiplied by Factor
+// after every attempt, with up to Jitter*Duration added at random.
+//
+// If the registry responds with a Retry-After header, e.g. on a 429, we wait
+// at least as long as it asks.
+//
+// The default backoff waits 0.1, 0.3, 0.9 and 2.7 seconds between attempts
+// for individual requests, and 1 and 3 seconds between attempts to upload a
+// blob.
+func WithRetryBackoff(backoff Backoff) Option {
+	return func(o *options) error {
+		if backoff.Steps <= 0 {
+			return errors.New("backoff steps must be greater than zero")
+		}
+		o.retryBackoff = &backoff
+		return nil
+	}
+}
+
+// WithRetryPredicate is a functional option for overriding which errors are
+// retried. Responses with an error status code are passed to the predicate as
+// a *transport.Error, so the predicate can decide based on StatusCode.
+//
+// The default predicate retries temporary network errors, such as connection
+// resets, and status codes 429, 500, 502, 503 and 504.
+func WithRetryPredicate(predicate func(error) bool) Option {
+	return func(o *options) error {
+		o.retryPredicate = predicate
+		return nil
+	}
+}
+
+// WithSchema1Conversion is a functional option for reading legacy Docker
+// schema1 manifests, which Image otherwise rejects with ErrSchema1.
+//
+// The schema1 manifest is converted to a schema2 image on a best-effort basis,
+// e.g. to copy it to a registry as schema2 or OCI. The config is taken from
+// the first v1Compatibility history entry, and the history from the
+// remaining ones. The layer IDs and parents, the per-layer container configs
+// (other than the command, which becomes CreatedBy) and the manifest
+// signatures are dropped. Since schema1 doesn't record DiffIDs, every layer
+// is downloaded to compute them when the manifest or config is first read.
+//
+// The converted manifest has a different digest than the original, which is
+// recorded in its ConvertedFromAnnotation.
+func WithSchema1Conversion() Option {
+	return func(o *options) error {
+		o.convertSchema1 = true
+		return nil
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+)
+
+// prefetchedImage downloads all of the layers of an image to dir
+// concurrently, see WithPrefetch and WithJobs. The downloads start the first
+// time the contents of any layer are read, in the order of the layers, and
+// each layer can be read as soon as its own download is done.
+type prefetchedImage struct {
+	v1.Image
+
+	dir  string
+	jobs int
+
+	mu      sync.Mutex
+	fetches map[v1.Hash]*layerFetch
+}
+
+var _ v1.Image = (*prefetchedImage)(nil)
+
+// layerFetch is the download of a single layer.
+type layerFetch struct {
+	layer v1.Layer
+	path  string
+	done  chan struct{}
+	err   error
+}
+
+// Layers implements v1.Image
+func (i *prefetchedImage) Layers() ([]v1.Layer, error) {
+	ls, err := i.Image.Layers()
+	if err != nil {
+		return nil, err
+	}
+	layers := make([]v1.Layer, 0, len(ls))
+	for _, l := range ls {
+		pl, err := i.wrap(l)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, pl)
+	}
+	return layers, nil
+}
+
+// LayerByDigest implements v1.Image
+func (i *prefetchedImage) LayerByDigest(h v1.Hash) (v1.Layer, error) {
+	l, err := i.Image.LayerByDigest(h)
+	if err != nil {
+		return nil, err
+	}
+	return i.wrap(l)
+}
+
+// LayerByDiffID implements v1.Image
+func (i *prefetchedImage) LayerByDiffID(h v1.Hash) (v1.Layer, error) {
+	l, err := i.Image.LayerByDiffID(h)
+	if err != nil {
+		return nil, err
+	}
+	return i.wrap(l)
+}
+
+// wrap returns l with its compressed contents served from its download,
+// keeping it mountable by remote.Write.
+func (i *prefetchedImage) wrap(l v1.Layer) (v1.Layer, error) {
+	pl, err := partial.CompressedToLayer(&prefetchedLayer{Layer: l, image: i})
+	if err != nil {
+		return nil, err
+	}
+	if ml, ok := asMountable(l); ok {
+		return &MountableLayer{Layer: pl, Reference: ml.Reference}, nil
+	}
+	return pl, nil
+}
+
+// start starts downloading the layers if it hasn't already. If that fails,
+// e.g. because dir can't be created, the next call tries again.
+func (i *prefetchedImage) start() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.fetches != nil {
+		return nil
+	}
+
+	layers, err := i.Image.Layers()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(i.dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	// Layers can appear more than once in an image, only fetch them once.
+	fetches := make(map[v1.Hash]*layerFetch, len(layers))
+	todo := make([]*layerFetch, 0, len(layers))
+	for _, layer := range layers {
+		d, err := layer.Digest()
+		if err != nil {
+			return err
+		}
+		if _, ok := fetches[d]; ok {
+			continue
+		}
+		f := &layerFetch{
+			layer: layer,
+			path:  filepath.Join(i.dir, d.Algorithm+"-"+d.Hex),
+			done:  make(chan struct{}),
+		}
+		fetches[d] = f
+		todo = append(todo, f)
+	}
+
+	fetchChan := make(chan *layerFetch)
+	go func() {
+		defer close(fetchChan)
+		for _, f := range todo {
+			fetchChan <- f
+		}
+	}()
+	for j := 0; j < i.jobs; j++ {
+		go func() {
+			for f := range fetchChan {
+				f.err = fetchLayerToFile(f.layer, f.path)
+				close(f.done)
+			}
+		}()
+	}
+	i.fetches = fetches
+	return nil
+}
+
+// fetched waits for the download of the layer with digest h, returning nil
+// if it isn't being downloaded or the download failed.
+func (i *prefetchedImage) fetched(h v1.Hash) *layerFetch {
+	if err := i.start(); err != nil {
+		return nil
+	}
+	i.mu.Lock()
+	f, ok := i.fetches[h]
+	i.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	<-f.done
+	if f.err != nil {
+		return nil
+	}
+	return f
+}
+
+// fetchLayerToFile writes the compressed contents of layer to path, via a
+// temporary file so that path only ever contains complete (and, since the
+// remote layer verifies its digest, valid) contents. The layer's request is
+// bound to the context of the image, see WithContext, so cancelling it stops
+// the download.
+func fetchLayerToFile(layer v1.Layer, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	d, err := layer.Digest()
+	if err != nil {
+		return err
+	}
+
+	rc, err := layer.Compressed()
+	if err != nil {
+		return fmt.Errorf("fetching layer %s: %w", d, err)
+	}
+	defer rc.Close()
+
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	// The rename below succeeds on the happy path, in which case this is a no-op.
+	defer os.Remove(f.Name())
+
+	if _, err := io.Copy(f, rc); err != nil {
+		f.Close()
+		return fmt.Errorf("fetching layer %s: %w", d, err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// prefetchedLayer serves the compressed contents of a layer from its
+// download, and everything else from the original layer. If the download
+// failed, the contents are fetched again from the original layer, so errors
+// are attributed to the layer that failed and aren't cached.
+type prefetchedLayer struct {
+	v1.Layer
+	image *prefetchedImage
+}
+
+var _ partial.CompressedLayer = (*prefetchedLayer)(nil)
+
+// Compressed implements partial.CompressedLayer
+func (l *prefetchedLayer) Compressed() (io.ReadCloser, error) {
+	d, err := l.Layer.Digest()
+	if err != nil {
+		return nil, err
+	}
+	if f := l.image.fetched(d); f != nil {
+		if rc, err := os.Open(f.path); err == nil {
+			return rc, nil
+		}
+	}
+	return l.Layer.Compressed()
+}
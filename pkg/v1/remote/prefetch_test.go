@@ -0,0 +1,249 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+func TestPrefetch(t *testing.T) {
+	reg := registry.New()
+	var (
+		blobGets int32
+		fail     atomic.Value
+	)
+	fail.Store("")
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/blobs/") {
+			atomic.AddInt32(&blobGets, 1)
+			if f := fail.Load().(string); f != "" && strings.HasSuffix(r.URL.Path, f) {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(u.Host + "/test/prefetch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := random.Image(1024, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(ref, want); err != nil {
+		t.Fatal(err)
+	}
+	wl, err := want.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	noRetry := WithRetryBackoff(Backoff{Duration: time.Millisecond, Steps: 1})
+
+	t.Run("dir", func(t *testing.T) {
+		atomic.StoreInt32(&blobGets, 0)
+		dir, err := ioutil.TempDir("", "prefetch")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		img, err := Image(ref, WithPrefetch(dir), WithJobs(2))
+		if err != nil {
+			t.Fatalf("Image() = %v", err)
+		}
+		layers, err := img.Layers()
+		if err != nil {
+			t.Fatalf("Layers() = %v", err)
+		}
+		if len(layers) != 5 {
+			t.Fatalf("len(Layers()) = %d, want 5", len(layers))
+		}
+		// Layers stay mountable for remote.Write.
+		if _, ok := layers[0].(*MountableLayer); !ok {
+			t.Errorf("Layers()[0] = %T, want *MountableLayer", layers[0])
+		}
+		// Reading the image fetches the config and every layer, once each.
+		if err := validate.Image(img); err != nil {
+			t.Errorf("validate.Image() = %v", err)
+		}
+		if got := atomic.LoadInt32(&blobGets); got != 6 {
+			t.Errorf("fetched %d blobs, want 6", got)
+		}
+	})
+
+	t.Run("jobs", func(t *testing.T) {
+		// Without WithPrefetch, nothing is downloaded ahead of time.
+		img, err := Image(ref, WithJobs(3))
+		if err != nil {
+			t.Fatalf("Image() = %v", err)
+		}
+		if _, ok := img.(*prefetchedImage); ok {
+			t.Errorf("Image(WithJobs) = %T, want layers not to be prefetched", img)
+		}
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		bad, err := wl[3].Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fail.Store(bad.String())
+		defer fail.Store("")
+
+		dir, err := ioutil.TempDir("", "prefetch")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		img, err := Image(ref, WithPrefetch(dir), noRetry)
+		if err != nil {
+			t.Fatalf("Image() = %v", err)
+		}
+		layers, err := img.Layers()
+		if err != nil {
+			t.Fatalf("Layers() = %v", err)
+		}
+		// The failure is attributed to the layer that failed.
+		if _, err := layers[3].Compressed(); err == nil || !strings.Contains(err.Error(), bad.String()) {
+			t.Errorf("Compressed() = %v, want error mentioning %s", err, bad)
+		}
+		if rc, err := layers[2].Compressed(); err != nil {
+			t.Errorf("Compressed() = %v", err)
+		} else {
+			rc.Close()
+		}
+
+		// The failure isn't cached.
+		fail.Store("")
+		rc, err := layers[3].Compressed()
+		if err != nil {
+			t.Fatalf("Compressed() after recovering = %v", err)
+		}
+		if _, err := io.Copy(ioutil.Discard, rc); err != nil {
+			t.Errorf("reading layer after recovering: %v", err)
+		}
+		rc.Close()
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "prefetch")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		img, err := Image(ref, WithContext(ctx), WithPrefetch(dir), WithJobs(2), noRetry)
+		if err != nil {
+			t.Fatalf("Image() = %v", err)
+		}
+		layers, err := img.Layers()
+		if err != nil {
+			t.Fatalf("Layers() = %v", err)
+		}
+		cancel()
+		if _, err := layers[0].Compressed(); !errors.Is(err, context.Canceled) {
+			t.Errorf("Compressed() = %v, want %v", err, context.Canceled)
+		}
+	})
+}
+
+func TestPrefetchIndexChild(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(u.Host + "/test/prefetch-index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := random.Index(1024, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteIndex(ref, idx); err != nil {
+		t.Fatal(err)
+	}
+	im, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	child := im.Manifests[0].Digest
+
+	dir, err := ioutil.TempDir("", "prefetch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	desc, err := Get(ref, WithPrefetch(dir), WithSchema1Conversion())
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	ii, err := desc.ImageIndex()
+	if err != nil {
+		t.Fatalf("ImageIndex() = %v", err)
+	}
+	img, err := ii.Image(child)
+	if err != nil {
+		t.Fatalf("Image(%s) = %v", child, err)
+	}
+	if _, ok := img.(*prefetchedImage); !ok {
+		t.Errorf("ImageIndex().Image() = %T, want layers to be prefetched", img)
+	}
+	if err := validate.Image(img); err != nil {
+		t.Errorf("validate.Image() = %v", err)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Errorf("prefetched %d layers, want 2", len(files))
+	}
+
+	cd, err := ii.(*remoteIndex).childByHash(child)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cd.imageOptions != desc.imageOptions {
+		t.Errorf("child options = %+v, want %+v", cd.imageOptions, desc.imageOptions)
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// progress aggregates the bytes written across all blobs of a write,
+// including the blobs of every child of an index, and reports them as
+// v1.Updates. Each call to Write, WriteIndex or WriteLayer creates its own,
+// so that an Option from WithProgress can be reused across writes.
+type progress struct {
+	sync.Mutex
+	updates  chan<- v1.Update
+	total    int64
+	complete int64
+}
+
+// add records n more bytes as complete and sends an update. If grow is set,
+// the bytes were not accounted for in the total, so it grows as well.
+func (p *progress) add(n int64, grow bool) {
+	p.Lock()
+	defer p.Unlock()
+	p.complete += n
+	if grow {
+		p.total += n
+	}
+	p.updates <- v1.Update{
+		Total:    p.total,
+		Complete: p.complete,
+	}
+}
+
+// done sends the final update, with io.EOF if err is nil.
+func (p *progress) done(err error) error {
+	p.Lock()
+	defer p.Unlock()
+	final := err
+	if final == nil {
+		final = io.EOF
+	}
+	p.updates <- v1.Update{
+		Total:    p.total,
+		Complete: p.complete,
+		Error:    final,
+	}
+	return err
+}
+
+// WriteStats summarizes the blobs transferred by a write, see WithWriteStats.
+type WriteStats struct {
+	// BlobsUploaded and BytesUploaded count the blobs that were uploaded.
+	BlobsUploaded int64
+	BytesUploaded int64
+
+	// BlobsSkipped and BytesSkipped count the blobs that did not need to be
+	// uploaded, because they already existed or could be mounted.
+	BlobsSkipped int64
+	BytesSkipped int64
+}
+
+func (s *WriteStats) uploaded(size int64) {
+	atomic.AddInt64(&s.BlobsUploaded, 1)
+	atomic.AddInt64(&s.BytesUploaded, size)
+}
+
+func (s *WriteStats) skipped(size int64) {
+	atomic.AddInt64(&s.BlobsSkipped, 1)
+	atomic.AddInt64(&s.BytesSkipped, size)
+}
+
+// progressReader reports bytes read from the wrapped io.ReadCloser to a
+// progress and remembers how many it has reported, so that they can be
+// taken back if the upload is retried.
+type progressReader struct {
+	io.ReadCloser
+	progress *progress
+	count    int64
+
+	// unsized is set for blobs whose size wasn't known up front.
+	unsized bool
+}
+
+func (pr *progressReader) Read(b []byte) (int, error) {
+	n, err := pr.ReadCloser.Read(b)
+	if n > 0 {
+		pr.count += int64(n)
+		pr.progress.add(int64(n), pr.unsized)
+	}
+	return n, err
+}
+
+// reset takes back everything reported by this reader.
+func (pr *progressReader) reset() {
+	if pr.count != 0 {
+		pr.progress.add(-pr.count, pr.unsized)
+		pr.count = 0
+	}
+}
+
+// imageSize returns the total size of the blobs that writing img would upload.
+func imageSize(img v1.Image, allowNondistributable bool) (int64, error) {
+	return blobsSize(img, allowNondistributable, newSeen())
+}
+
+// indexSize returns the total size of the blobs that writing ii would upload.
+// Blobs that are shared by several children are only counted once.
+func indexSize(ii v1.ImageIndex, allowNondistributable bool) (int64, error) {
+	return indexBlobsSize(ii, allowNondistributable, newSeen())
+}
+
+// newSeen returns a func that reports whether it is the first time it has
+// been called with a given digest.
+func newSeen() func(v1.Hash) bool {
+	seen := map[v1.Hash]bool{}
+	return func(h v1.Hash) bool {
+		if seen[h] {
+			return false
+		}
+		seen[h] = true
+		return true
+	}
+}
+
+// blobsSize returns the total size of the blobs of img for which first
+// returns true.
+func blobsSize(img v1.Image, allowNondistributable bool, first func(v1.Hash) bool) (int64, error) {
+	ls, err := img.Layers()
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, l := range ls {
+		mt, err := l.MediaType()
+		if err != nil {
+			return 0, err
+		}
+		if !mt.IsDistributable() && !allowNondistributable {
+			continue
+		}
+		// Streaming layers don't know their size ahead of time, so they only
+		// count towards the total once they're uploaded.
+		h, err := l.Digest()
+		if err != nil {
+			continue
+		}
+		if !first(h) {
+			continue
+		}
+		sz, err := l.Size()
+		if err != nil {
+			continue
+		}
+		total += sz
+	}
+
+	raw, err := img.RawConfigFile()
+	if err != nil {
+		// This happens for images with streaming layers.
+		return total, nil
+	}
+	h, err := img.ConfigName()
+	if err != nil {
+		return 0, err
+	}
+	if !first(h) {
+		return total, nil
+	}
+	return total + int64(len(raw)), nil
+}
+
+func indexBlobsSize(ii v1.ImageIndex, allowNondistributable bool, first func(v1.Hash) bool) (int64, error) {
+	index, err := ii.IndexManifest()
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, desc := range index.Manifests {
+		sz, err := childSize(ii, desc, allowNondistributable, first)
+		if err != nil {
+			return 0, err
+		}
+		total += sz
+	}
+	return total, nil
+}
+
+// childSize returns the total size of the blobs of the child desc of ii for
+// which first returns true.
+func childSize(ii v1.ImageIndex, desc v1.Descriptor, allowNondistributable bool, first func(v1.Hash) bool) (int64, error) {
+	switch desc.MediaType {
+	case types.OCIImageIndex, types.DockerManifestList:
+		child, err := ii.ImageIndex(desc.Digest)
+		if err != nil {
+			return 0, err
+		}
+		return indexBlobsSize(child, allowNondistributable, first)
+	case types.OCIManifestSchema1, types.DockerManifestSchema2:
+		img, err := ii.Image(desc.Digest)
+		if err != nil {
+			return 0, err
+		}
+		return blobsSize(img, allowNondistributable, first)
+	default:
+		if _, ok := ii.(withLayer); ok && first(desc.Digest) {
+			return desc.Size, nil
+		}
+	}
+	return 0, nil
+}
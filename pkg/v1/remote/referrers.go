@@ -0,0 +1,179 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// FiltersAppliedAnnotation is set on the index returned by Referrers to the
+// comma-separated list of filters that were applied to it, see WithFilter.
+const FiltersAppliedAnnotation = "org.opencontainers.referrers.filtersApplied"
+
+// Referrers returns an index of the manifests whose subject is d, e.g.
+// signatures or SBOMs attached to an image.
+//
+// It uses the referrers API if the registry supports it. Otherwise, it falls
+// back to the index tagged with the digest of d, with the ':' replaced by a
+// '-' (e.g. sha256-abc...), which is how clients attach referrers for such
+// registries. If neither exists, the returned index is empty.
+//
+// Filters passed with WithFilter are applied client-side if the registry
+// didn't apply them; FiltersAppliedAnnotation lists the filters that were
+// applied either way.
+func Referrers(d name.Digest, options ...Option) (v1.ImageIndex, error) {
+	o, err := makeOptions(d.Context(), options...)
+	if err != nil {
+		return nil, err
+	}
+	f, err := makeFetcher(d, o)
+	if err != nil {
+		return nil, err
+	}
+	return f.fetchReferrers(d, o.filter)
+}
+
+func (f *fetcher) fetchReferrers(d name.Digest, filter map[string]string) (v1.ImageIndex, error) {
+	u := f.url("referrers", d.DigestStr())
+	uv := url.Values{}
+	for k, v := range filter {
+		uv.Set(k, v)
+	}
+	u.RawQuery = uv.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", string(types.OCIImageIndex))
+
+	resp, err := f.Client.Do(req.WithContext(f.context))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var (
+		b       []byte
+		applied []string
+	)
+	if resp.StatusCode == http.StatusNotFound {
+		// The registry doesn't support the referrers API, fall back to the tag schema.
+		if b, err = f.fetchReferrersTag(d); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := transport.CheckError(resp, http.StatusOK); err != nil {
+			return nil, err
+		}
+		if b, err = ioutil.ReadAll(resp.Body); err != nil {
+			return nil, err
+		}
+		if h := resp.Header.Get("OCI-Filters-Applied"); h != "" {
+			for _, k := range strings.Split(h, ",") {
+				applied = append(applied, strings.TrimSpace(k))
+			}
+		}
+	}
+
+	im := &v1.IndexManifest{
+		SchemaVersion: 2,
+		MediaType:     types.OCIImageIndex,
+		Manifests:     []v1.Descriptor{},
+	}
+	if b != nil {
+		if im, err = v1.ParseIndexManifest(bytes.NewReader(b)); err != nil {
+			return nil, err
+		}
+	}
+	if len(filter) != 0 {
+		if im.Annotations == nil {
+			im.Annotations = map[string]string{}
+		}
+		im.Annotations[FiltersAppliedAnnotation] = strings.Join(filterReferrers(im, filter, applied), ",")
+	}
+
+	raw, err := json.Marshal(im)
+	if err != nil {
+		return nil, err
+	}
+	digest, size, err := v1.SHA256(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	return &remoteIndex{
+		fetcher:   *f,
+		manifest:  raw,
+		mediaType: types.OCIImageIndex,
+		descriptor: &v1.Descriptor{
+			MediaType: types.OCIImageIndex,
+			Size:      size,
+			Digest:    digest,
+		},
+	}, nil
+}
+
+// fetchReferrersTag fetches the index tagged with the digest of d, returning
+// nil if there is no such tag.
+func (f *fetcher) fetchReferrersTag(d name.Digest) ([]byte, error) {
+	tag := d.Context().Tag(strings.Replace(d.DigestStr(), ":", "-", 1))
+	b, _, err := f.fetchManifest(tag, []types.MediaType{types.OCIImageIndex})
+	var terr *transport.Error
+	if errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	return b, err
+}
+
+// filterReferrers removes the manifests that don't match filter, unless the
+// registry already applied that filter. It returns the sorted names of the
+// filters that have been applied.
+func filterReferrers(im *v1.IndexManifest, filter map[string]string, applied []string) []string {
+	done := map[string]bool{}
+	for _, k := range applied {
+		done[k] = true
+	}
+	if at, ok := filter["artifactType"]; ok && !done["artifactType"] {
+		manifests := []v1.Descriptor{}
+		for _, desc := range im.Manifests {
+			if desc.ArtifactType == at {
+				manifests = append(manifests, desc)
+			}
+		}
+		im.Manifests = manifests
+		done["artifactType"] = true
+	}
+
+	names := []string{}
+	for k := range filter {
+		if done[k] {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,310 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// setupReferrers pushes a subject image and two referrers to repo, returning
+// the digest of the subject and the referrers index.
+func setupReferrers(t *testing.T, repo name.Repository) (name.Digest, *v1.IndexManifest) {
+	subject, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := subject.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := repo.Digest(h.String())
+	if err := Write(d, subject); err != nil {
+		t.Fatal(err)
+	}
+
+	im := &v1.IndexManifest{
+		SchemaVersion: 2,
+		MediaType:     types.OCIImageIndex,
+	}
+	for _, at := range []string{"application/vnd.example.sig", "application/vnd.example.sbom"} {
+		img, err := random.Image(1024, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ih, err := img.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Write(repo.Digest(ih.String()), img); err != nil {
+			t.Fatal(err)
+		}
+		desc, err := partial.Descriptor(img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		desc.ArtifactType = at
+		im.Manifests = append(im.Manifests, *desc)
+	}
+	return d, im
+}
+
+func TestReferrersFallback(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := name.NewRepository(u.Host + "/test/referrers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, want := setupReferrers(t, repo)
+
+	// Nothing has been attached yet.
+	idx, err := Referrers(d)
+	if err != nil {
+		t.Fatalf("Referrers() = %v", err)
+	}
+	im, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(im.Manifests) != 0 {
+		t.Errorf("Referrers() = %d manifests, want 0", len(im.Manifests))
+	}
+
+	raw, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag := repo.Tag(strings.Replace(d.DigestStr(), ":", "-", 1))
+	if err := Tag(tag, &rawManifest{raw: raw, mt: types.OCIImageIndex}); err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err = Referrers(d)
+	if err != nil {
+		t.Fatalf("Referrers() = %v", err)
+	}
+	im, err = idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(im.Manifests) != 2 {
+		t.Fatalf("Referrers() = %d manifests, want 2", len(im.Manifests))
+	}
+	// The referrers can be fetched through the index.
+	if _, err := idx.Image(im.Manifests[0].Digest); err != nil {
+		t.Errorf("Image() = %v", err)
+	}
+
+	// The fallback is filtered client-side.
+	idx, err = Referrers(d, WithFilter("artifactType", "application/vnd.example.sbom"))
+	if err != nil {
+		t.Fatalf("Referrers() = %v", err)
+	}
+	im, err = idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(im.Manifests) != 1 || im.Manifests[0].ArtifactType != "application/vnd.example.sbom" {
+		t.Errorf("Referrers(artifactType) = %v, want only the sbom", im.Manifests)
+	}
+	if got := im.Annotations[FiltersAppliedAnnotation]; got != "artifactType" {
+		t.Errorf("%s = %q, want artifactType", FiltersAppliedAnnotation, got)
+	}
+}
+
+func TestReferrersAPI(t *testing.T) {
+	reg := registry.New()
+	var (
+		want    *v1.IndexManifest
+		applied bool
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.URL.Path, "/referrers/") {
+			reg.ServeHTTP(w, r)
+			return
+		}
+		if want == nil {
+			t.Error("referrers requested before setup")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		im := want.DeepCopy()
+		if at := r.URL.Query().Get("artifactType"); at != "" && applied {
+			manifests := []v1.Descriptor{}
+			for _, desc := range im.Manifests {
+				if desc.ArtifactType == at {
+					manifests = append(manifests, desc)
+				}
+			}
+			im.Manifests = manifests
+			w.Header().Set("OCI-Filters-Applied", "artifactType")
+		}
+		w.Header().Set("Content-Type", string(types.OCIImageIndex))
+		if err := json.NewEncoder(w).Encode(im); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := name.NewRepository(u.Host + "/test/subject")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var d name.Digest
+	d, want = setupReferrers(t, repo)
+
+	for _, serverSide := range []bool{true, false} {
+		t.Run(fmt.Sprintf("serverSide=%t", serverSide), func(t *testing.T) {
+			applied = serverSide
+			idx, err := Referrers(d, WithFilter("artifactType", "application/vnd.example.sig"))
+			if err != nil {
+				t.Fatalf("Referrers() = %v", err)
+			}
+			im, err := idx.IndexManifest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(im.Manifests) != 1 || im.Manifests[0].ArtifactType != "application/vnd.example.sig" {
+				t.Errorf("Referrers(artifactType) = %v, want only the sig", im.Manifests)
+			}
+			if got := im.Annotations[FiltersAppliedAnnotation]; got != "artifactType" {
+				t.Errorf("%s = %q, want artifactType", FiltersAppliedAnnotation, got)
+			}
+		})
+	}
+}
+
+func TestWriteSubject(t *testing.T) {
+	s := httptest.NewServer(registry.New(registry.WithReferrersSupport()))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := name.NewRepository(u.Host + "/test/subject")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, _ := setupReferrers(t, repo)
+	subject, err := Head(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img = mutate.Subject(img, *subject)
+	h, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(repo.Digest(h.String()), img); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+
+	idx, err := Referrers(d)
+	if err != nil {
+		t.Fatalf("Referrers() = %v", err)
+	}
+	im, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(im.Manifests) != 1 || im.Manifests[0].Digest != h {
+		t.Fatalf("Referrers() = %v, want %s", im.Manifests, h)
+	}
+	if mt := im.Manifests[0].MediaType; mt != types.OCIManifestSchema1 {
+		t.Errorf("referrer mediaType = %s, want %s", mt, types.OCIManifestSchema1)
+	}
+}
+
+func TestReferrersArtifactType(t *testing.T) {
+	s := httptest.NewServer(registry.New(registry.WithReferrersSupport()))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := name.NewRepository(u.Host + "/test/subject")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, _ := setupReferrers(t, repo)
+	subject, err := Head(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const at = "application/vnd.example.sbom"
+	var want v1.Hash
+	for _, withType := range []bool{true, false} {
+		img, err := random.Image(1024, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img = mutate.Subject(img, *subject)
+		if withType {
+			img = mutate.ArtifactType(img, at)
+		}
+		h, err := img.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if withType {
+			want = h
+		}
+		if err := Write(repo.Digest(h.String()), img); err != nil {
+			t.Fatalf("Write() = %v", err)
+		}
+	}
+
+	idx, err := Referrers(d, WithFilter("artifactType", at))
+	if err != nil {
+		t.Fatalf("Referrers() = %v", err)
+	}
+	im, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(im.Manifests) != 1 || im.Manifests[0].Digest != want {
+		t.Fatalf("Referrers(artifactType) = %v, want %s", im.Manifests, want)
+	}
+	if got := im.Manifests[0].ArtifactType; got != at {
+		t.Errorf("referrer artifactType = %q, want %q", got, at)
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// ConvertedFromAnnotation is set on the manifest of images converted by
+// WithSchema1Conversion to the digest of the original schema1 manifest.
+const ConvertedFromAnnotation = "dev.ggcr.image.converted-from"
+
+type schema1Manifest struct {
+	FSLayers []struct {
+		BlobSum v1.Hash `json:"blobSum"`
+	} `json:"fsLayers"`
+	History []struct {
+		V1Compatibility string `json:"v1Compatibility"`
+	} `json:"history"`
+}
+
+// v1Compatibility holds the fields of a schema1 history entry that aren't
+// part of v1.ConfigFile.
+type v1Compatibility struct {
+	Created         time.Time `json:"created"`
+	Author          string    `json:"author,omitempty"`
+	Comment         string    `json:"comment,omitempty"`
+	ThrowAway       bool      `json:"throwaway,omitempty"`
+	ContainerConfig struct {
+		Cmd []string `json:"Cmd,omitempty"`
+	} `json:"container_config,omitempty"`
+}
+
+// schema1Image is a read-only schema2 view of a schema1 manifest.
+type schema1Image struct {
+	fetcher
+	original *v1.Descriptor
+	raw      []byte
+
+	once     sync.Once
+	manifest []byte
+	config   []byte
+	err      error
+}
+
+var _ partial.CompressedImageCore = (*schema1Image)(nil)
+
+// MediaType implements partial.CompressedImageCore.
+func (s *schema1Image) MediaType() (types.MediaType, error) {
+	return types.DockerManifestSchema2, nil
+}
+
+// RawManifest implements partial.CompressedImageCore.
+func (s *schema1Image) RawManifest() ([]byte, error) {
+	s.once.Do(func() { s.err = s.convert() })
+	return s.manifest, s.err
+}
+
+// RawConfigFile implements partial.CompressedImageCore.
+func (s *schema1Image) RawConfigFile() ([]byte, error) {
+	s.once.Do(func() { s.err = s.convert() })
+	return s.config, s.err
+}
+
+// LayerByDigest implements partial.CompressedImageCore.
+func (s *schema1Image) LayerByDigest(h v1.Hash) (partial.CompressedLayer, error) {
+	m, err := partial.Manifest(s)
+	if err != nil {
+		return nil, err
+	}
+	if h == m.Config.Digest {
+		return partial.ConfigLayer(s)
+	}
+	for _, desc := range m.Layers {
+		if h == desc.Digest {
+			return &remoteLayer{
+				fetcher: s.fetcher,
+				digest:  h,
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("blob %v not found", h)
+}
+
+// convert synthesizes the schema2 manifest and config. The layers have to be
+// downloaded to compute their DiffIDs, which schema1 doesn't record.
+func (s *schema1Image) convert() error {
+	var sm schema1Manifest
+	if err := json.Unmarshal(s.raw, &sm); err != nil {
+		return err
+	}
+	if len(sm.FSLayers) == 0 || len(sm.FSLayers) != len(sm.History) {
+		return fmt.Errorf("invalid schema1 manifest: %d fsLayers and %d history entries", len(sm.FSLayers), len(sm.History))
+	}
+
+	// The first history entry describes the image as a whole.
+	cfg := &v1.ConfigFile{}
+	if err := json.Unmarshal([]byte(sm.History[0].V1Compatibility), cfg); err != nil {
+		return fmt.Errorf("parsing v1Compatibility: %v", err)
+	}
+	cfg.RootFS = v1.RootFS{Type: "layers", DiffIDs: []v1.Hash{}}
+	cfg.History = nil
+
+	m := &v1.Manifest{
+		SchemaVersion: 2,
+		MediaType:     types.DockerManifestSchema2,
+		Annotations: map[string]string{
+			ConvertedFromAnnotation: s.original.Digest.String(),
+		},
+	}
+
+	// Schema1 lists layers from the top down.
+	for i := len(sm.History) - 1; i >= 0; i-- {
+		var compat v1Compatibility
+		if err := json.Unmarshal([]byte(sm.History[i].V1Compatibility), &compat); err != nil {
+			return fmt.Errorf("parsing v1Compatibility: %v", err)
+		}
+		cfg.History = append(cfg.History, v1.History{
+			Created:    v1.Time{Time: compat.Created},
+			Author:     compat.Author,
+			CreatedBy:  strings.Join(compat.ContainerConfig.Cmd, " "),
+			Comment:    compat.Comment,
+			EmptyLayer: compat.ThrowAway,
+		})
+		if compat.ThrowAway {
+			continue
+		}
+
+		l, err := partial.CompressedToLayer(&remoteLayer{
+			fetcher: s.fetcher,
+			digest:  sm.FSLayers[i].BlobSum,
+		})
+		if err != nil {
+			return err
+		}
+		diffID, err := l.DiffID()
+		if err != nil {
+			return fmt.Errorf("computing diffid of %v: %v", sm.FSLayers[i].BlobSum, err)
+		}
+		size, err := l.Size()
+		if err != nil {
+			return err
+		}
+		cfg.RootFS.DiffIDs = append(cfg.RootFS.DiffIDs, diffID)
+		m.Layers = append(m.Layers, v1.Descriptor{
+			MediaType: types.DockerLayer,
+			Size:      size,
+			Digest:    sm.FSLayers[i].BlobSum,
+		})
+	}
+
+	config, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	h, size, err := v1.SHA256(bytes.NewReader(config))
+	if err != nil {
+		return err
+	}
+	m.Config = v1.Descriptor{
+		MediaType: types.DockerConfigJSON,
+		Size:      size,
+		Digest:    h,
+	}
+	manifest, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	s.config, s.manifest = config, manifest
+	return nil
+}
+
+func (d *Descriptor) schema1Image() *schema1Image {
+	return &schema1Image{
+		fetcher:  d.fetcher,
+		original: &d.Descriptor,
+		raw:      d.Manifest,
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+func TestSchema1Conversion(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(u.Host + "/test/schema1:legacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two layers, with a throwaway entry between them, listed top-down.
+	var layers []v1.Layer
+	for i := 0; i < 2; i++ {
+		l, err := random.Layer(1024, types.DockerLayer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := WriteLayer(ref.Context(), l); err != nil {
+			t.Fatal(err)
+		}
+		layers = append(layers, l)
+	}
+	digest := func(l v1.Layer) string {
+		h, err := l.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h.String()
+	}
+	manifest := map[string]interface{}{
+		"schemaVersion": 1,
+		"name":          "test/schema1",
+		"tag":           "legacy",
+		"architecture":  "amd64",
+		"fsLayers": []map[string]string{
+			{"blobSum": digest(layers[1])},
+			{"blobSum": digest(layers[0])},
+			{"blobSum": digest(layers[0])},
+		},
+		"history": []map[string]string{
+			{"v1Compatibility": `{"id":"c","parent":"b","created":"2016-01-03T00:00:00Z","architecture":"amd64","os":"linux","config":{"Env":["A=b"],"Cmd":["sh"]},"container_config":{"Cmd":["/bin/sh -c touch /c"]}}`},
+			{"v1Compatibility": `{"id":"b","parent":"a","created":"2016-01-02T00:00:00Z","container_config":{"Cmd":["/bin/sh -c #(nop) ENV A=b"]},"throwaway":true}`},
+			{"v1Compatibility": `{"id":"a","created":"2016-01-01T00:00:00Z","container_config":{"Cmd":["/bin/sh -c #(nop) ADD file:abc in /"]}}`},
+		},
+	}
+	raw, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Tag(ref.(name.Tag), &rawManifest{raw: raw, mt: types.DockerManifestSchema1}); err != nil {
+		t.Fatal(err)
+	}
+
+	var serr *ErrSchema1
+	if _, err := Image(ref); !errors.As(err, &serr) {
+		t.Errorf("Image() = %v, want ErrSchema1", err)
+	}
+
+	img, err := Image(ref, WithSchema1Conversion())
+	if err != nil {
+		t.Fatalf("Image() = %v", err)
+	}
+	if err := validate.Image(img); err != nil {
+		t.Errorf("validate.Image() = %v", err)
+	}
+
+	m, err := img.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.MediaType != types.DockerManifestSchema2 {
+		t.Errorf("MediaType = %s, want %s", m.MediaType, types.DockerManifestSchema2)
+	}
+	want, _, err := v1.SHA256(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Annotations[ConvertedFromAnnotation]; got != want.String() {
+		t.Errorf("%s = %q, want %q", ConvertedFromAnnotation, got, want)
+	}
+	if len(m.Layers) != 2 || m.Layers[0].Digest.String() != digest(layers[0]) || m.Layers[1].Digest.String() != digest(layers[1]) {
+		t.Errorf("Layers = %v, want the two non-throwaway layers bottom-up", m.Layers)
+	}
+
+	cf, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cf.OS != "linux" || cf.Architecture != "amd64" || len(cf.Config.Env) != 1 || cf.Config.Env[0] != "A=b" {
+		t.Errorf("ConfigFile() = %+v, want the top v1Compatibility config", cf)
+	}
+	if len(cf.History) != 3 || !cf.History[1].EmptyLayer || cf.History[2].CreatedBy != "/bin/sh -c touch /c" {
+		t.Errorf("History = %+v", cf.History)
+	}
+
+	cf2, err := ConfigFile(ref, WithSchema1Conversion())
+	if err != nil {
+		t.Fatalf("ConfigFile() = %v", err)
+	}
+	if len(cf2.RootFS.DiffIDs) != 2 {
+		t.Errorf("ConfigFile().RootFS = %v, want 2 diffids", cf2.RootFS)
+	}
+
+	// The converted image can be pushed as schema2.
+	dst := ref.Context().Tag("converted")
+	if err := Write(dst, img); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+	if _, err := Image(dst); err != nil {
+		t.Errorf("Image(converted) = %v", err)
+	}
+}
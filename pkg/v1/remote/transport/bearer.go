@@ -27,6 +27,7 @@ import (
 	authchallenge "github.com/docker/distribution/registry/client/auth/challenge"
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/internal/redact"
+	"github.com/google/go-containerregistry/pkg/logs"
 	"github.com/google/go-containerregistry/pkg/name"
 )
 
@@ -108,9 +109,16 @@ func (bt *bearerTransport) RoundTrip(in *http.Request) (*http.Response, error) {
 		// TODO(jonjohnsonjr): Teach transport.Error about "error" and "error_description" from challenge.
 
 		// Retry the request to attempt to get a valid token.
+		prev := bt.bearer.RegistryToken
 		if err = bt.refresh(in.Context()); err != nil {
 			return nil, err
 		}
+		if bt.bearer.RegistryToken == prev {
+			// Retrying with the same token won't help, e.g. when a static
+			// token was provided via authn.Bearer, so surface the challenge.
+			return res, nil
+		}
+		res.Body.Close()
 		return sendRequest()
 	}
 
@@ -163,6 +171,9 @@ func (bt *bearerTransport) refresh(ctx context.Context) error {
 	if err := json.Unmarshal(content, &response); err != nil {
 		return err
 	}
+	if logs.Enabled(logs.Debug) {
+		logs.Debug.Printf("token response: %s", redactTokenResponse(content))
+	}
 
 	// Some registries set access_token instead of token.
 	if response.AccessToken != "" {
@@ -186,6 +197,25 @@ func (bt *bearerTransport) refresh(ctx context.Context) error {
 	return nil
 }
 
+// redactTokenResponse returns content with the values of any credentials
+// replaced, so that the rest of a token response can be logged.
+func redactTokenResponse(content []byte) string {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return "<unparseable>"
+	}
+	for _, k := range []string{"token", "access_token", "refresh_token", "id_token"} {
+		if _, ok := fields[k]; ok {
+			fields[k] = "<redacted>"
+		}
+	}
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return "<unparseable>"
+	}
+	return string(b)
+}
+
 func matchesHost(reg name.Registry, in *http.Request, scheme string) bool {
 	canonicalHeaderHost := canonicalAddress(in.Host, scheme)
 	canonicalURLHost := canonicalAddress(in.URL.Host, scheme)
@@ -214,6 +214,70 @@ func TestBearerTransportTokenRefresh(t *testing.T) {
 	}
 }
 
+func TestBearerTransportStaticToken(t *testing.T) {
+	validToken := "valid"
+	var requests, exchanges int
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/token" {
+				exchanges++
+				w.Write([]byte(`{"token": "exchanged"}`))
+				return
+			}
+			requests++
+			if r.Header.Get("Authorization") == "Bearer "+validToken {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", "Bearer realm=\"unused\"")
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry, err := name.NewRegistry(u.Host, name.WeakValidation)
+	if err != nil {
+		t.Fatalf("Unexpected error during NewRegistry: %v", err)
+	}
+
+	for _, tc := range []struct {
+		token string
+		want  int
+	}{{validToken, http.StatusOK}, {"invalid", http.StatusUnauthorized}} {
+		requests = 0
+		auth := &authn.Bearer{Token: tc.token}
+		transport := &bearerTransport{
+			inner:    http.DefaultTransport,
+			basic:    auth,
+			registry: registry,
+			realm:    server.URL + "/token",
+			scheme:   "http",
+		}
+		if err := transport.refresh(context.Background()); err != nil {
+			t.Fatalf("refresh() = %v", err)
+		}
+		client := http.Client{Transport: transport}
+
+		res, err := client.Get(fmt.Sprintf("http://%s/v2/foo/bar/blobs/blah", u.Host))
+		if err != nil {
+			t.Fatalf("Unexpected error during client.Get: %v", err)
+		}
+		res.Body.Close()
+		if res.StatusCode != tc.want {
+			t.Errorf("client.Get(%s) StatusCode got %v, want: %v", tc.token, res.StatusCode, tc.want)
+		}
+		if requests != 1 {
+			t.Errorf("client.Get(%s) sent %d requests, want 1", tc.token, requests)
+		}
+	}
+	if exchanges != 0 {
+		t.Errorf("token exchanged %d times, want 0", exchanges)
+	}
+}
+
 func TestBearerTransportOauthRefresh(t *testing.T) {
 	initialToken := "foo"
 	accessToken := "bar"
@@ -557,3 +621,13 @@ func TestInsufficientScope(t *testing.T) {
 		t.Error("didn't refresh insufficient scope")
 	}
 }
+
+func TestRedactTokenResponse(t *testing.T) {
+	got := redactTokenResponse([]byte(`{"token":"secret","access_token":"secret","refresh_token":"secret","expires_in":300}`))
+	if strings.Contains(got, "secret") {
+		t.Errorf("redactTokenResponse() = %s, leaked a credential", got)
+	}
+	if !strings.Contains(got, `"expires_in":300`) {
+		t.Errorf("redactTokenResponse() = %s, want expires_in preserved", got)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// ConnInfo describes the connection that was used to make a request, see
+// NewConnTrace.
+type ConnInfo struct {
+	// Method and URL identify the request.
+	Method string
+	URL    string
+
+	// Proto is the protocol of the response, e.g. "HTTP/1.1" or "HTTP/2.0".
+	// It is empty if the request failed before a response was received.
+	Proto string
+
+	// Reused is whether the connection had been used for a previous request.
+	Reused bool
+
+	// WasIdle is whether the connection was taken from the idle pool, and
+	// IdleTime how long it had been idle for.
+	WasIdle  bool
+	IdleTime time.Duration
+}
+
+var _ http.RoundTripper = (*connTraceTransport)(nil)
+
+type connTraceTransport struct {
+	inner http.RoundTripper
+	f     func(ConnInfo)
+}
+
+// NewConnTrace returns an http.RoundTripper that calls f with the negotiated
+// protocol and whether the connection was reused for every request made
+// through inner, using net/http/httptrace. This helps diagnose problems with
+// connection reuse or HTTP/2.
+func NewConnTrace(inner http.RoundTripper, f func(ConnInfo)) http.RoundTripper {
+	return &connTraceTransport{
+		inner: inner,
+		f:     f,
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *connTraceTransport) RoundTrip(in *http.Request) (*http.Response, error) {
+	var (
+		mu   sync.Mutex
+		info = ConnInfo{
+			Method: in.Method,
+			URL:    in.URL.String(),
+		}
+	)
+	trace := &httptrace.ClientTrace{
+		GotConn: func(ci httptrace.GotConnInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			info.Reused = ci.Reused
+			info.WasIdle = ci.WasIdle
+			info.IdleTime = ci.IdleTime
+		},
+	}
+	res, err := t.inner.RoundTrip(in.WithContext(httptrace.WithClientTrace(in.Context(), trace)))
+
+	mu.Lock()
+	if err == nil {
+		info.Proto = res.Proto
+	}
+	ci := info
+	mu.Unlock()
+	t.f(ci)
+
+	return res, err
+}
@@ -0,0 +1,63 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConnTrace(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	var got []ConnInfo
+	client := http.Client{Transport: NewConnTrace(server.Client().Transport, func(ci ConnInfo) {
+		got = append(got, ci)
+	})}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Get() = %v", err)
+		}
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("got %d ConnInfos, want 2", len(got))
+	}
+	for i, ci := range got {
+		if ci.Proto != "HTTP/2.0" {
+			t.Errorf("[%d] Proto = %q, want HTTP/2.0", i, ci.Proto)
+		}
+		if ci.Method != http.MethodGet || ci.URL != server.URL {
+			t.Errorf("[%d] request = %s %s, want GET %s", i, ci.Method, ci.URL, server.URL)
+		}
+	}
+	if got[0].Reused {
+		t.Error("first request reused a connection")
+	}
+	if !got[1].Reused {
+		t.Error("second request didn't reuse the connection")
+	}
+}
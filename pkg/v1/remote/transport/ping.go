@@ -16,7 +16,6 @@ package transport
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -50,6 +49,57 @@ func (c challenge) Canonical() challenge {
 	return challenge(strings.ToLower(string(c)))
 }
 
+// Challenge is the authentication challenge returned by a registry's /v2/
+// endpoint, exposed for diagnosing authentication problems.
+type Challenge struct {
+	// Scheme is the lowercased authentication scheme, e.g. "bearer", "basic"
+	// or "anonymous" if the registry didn't require authentication.
+	Scheme string
+
+	// Parameters are the key/value pairs following the scheme in the
+	// WWW-Authenticate header, e.g. "realm", "service" and "scope".
+	Parameters map[string]string
+
+	// Insecure is true if the registry was reached by falling back to http.
+	Insecure bool
+}
+
+// Realm returns the URL of the token server, if any.
+func (c *Challenge) Realm() string {
+	return c.Parameters["realm"]
+}
+
+// Service returns the service the token server issues tokens for, if any.
+func (c *Challenge) Service() string {
+	return c.Parameters["service"]
+}
+
+// Scopes returns the scopes requested by the registry, if any.
+func (c *Challenge) Scopes() []string {
+	if scope := c.Parameters["scope"]; scope != "" {
+		return strings.Split(scope, " ")
+	}
+	return nil
+}
+
+// Ping pings the registry's /v2/ endpoint using t, and returns the challenge
+// it responded with, without performing any token exchange.
+func Ping(ctx context.Context, reg name.Registry, t http.RoundTripper) (*Challenge, error) {
+	pr, err := ping(ctx, reg, t)
+	if err != nil {
+		return nil, err
+	}
+	params := make(map[string]string, len(pr.parameters))
+	for k, v := range pr.parameters {
+		params[k] = v
+	}
+	return &Challenge{
+		Scheme:     string(pr.challenge),
+		Parameters: params,
+		Insecure:   pr.scheme == "http",
+	}, nil
+}
+
 func parseChallenge(suffix string) map[string]string {
 	kv := make(map[string]string)
 	for _, token := range strings.Split(suffix, ",") {
@@ -80,6 +130,7 @@ func ping(ctx context.Context, reg name.Registry, t http.RoundTripper) (*pingRes
 	}
 
 	var errs []string
+	var last error
 	for _, scheme := range schemes {
 		url := fmt.Sprintf("%s://%s/v2/", scheme, reg.Name())
 		req, err := http.NewRequest(http.MethodGet, url, nil)
@@ -89,6 +140,7 @@ func ping(ctx context.Context, reg name.Registry, t http.RoundTripper) (*pingRes
 		resp, err := client.Do(req.WithContext(ctx))
 		if err != nil {
 			errs = append(errs, err.Error())
+			last = err
 			// Potentially retry with http.
 			continue
 		}
@@ -125,5 +177,10 @@ func ping(ctx context.Context, reg name.Registry, t http.RoundTripper) (*pingRes
 			return nil, CheckError(resp, http.StatusOK, http.StatusUnauthorized)
 		}
 	}
-	return nil, errors.New(strings.Join(errs, "; "))
+	// Wrap the last error, so that callers can still tell e.g. network errors
+	// apart from the rest.
+	if len(errs) == 1 {
+		return nil, last
+	}
+	return nil, fmt.Errorf("%s; %w", strings.Join(errs[:len(errs)-1], "; "), last)
 }
@@ -147,6 +147,37 @@ func TestPingBearerChallengeWithParams(t *testing.T) {
 	}
 }
 
+func TestPingChallenge(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="http://auth.example.com/token",service="example.com",scope="repository:foo:pull repository:bar:pull"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		}))
+	defer server.Close()
+	tprt := &http.Transport{
+		Proxy: func(req *http.Request) (*url.URL, error) {
+			return url.Parse(server.URL)
+		},
+	}
+
+	c, err := Ping(context.Background(), testRegistry, tprt)
+	if err != nil {
+		t.Fatalf("Ping() = %v", err)
+	}
+	if got, want := c.Scheme, "bearer"; got != want {
+		t.Errorf("Scheme; got %v, want %v", got, want)
+	}
+	if got, want := c.Realm(), "http://auth.example.com/token"; got != want {
+		t.Errorf("Realm(); got %v, want %v", got, want)
+	}
+	if got, want := c.Service(), "example.com"; got != want {
+		t.Errorf("Service(); got %v, want %v", got, want)
+	}
+	if diff := cmp.Diff([]string{"repository:foo:pull", "repository:bar:pull"}, c.Scopes()); diff != "" {
+		t.Errorf("Scopes(); (-want +got) = %s", diff)
+	}
+}
+
 func TestUnsupportedStatus(t *testing.T) {
 	server := httptest.NewServer(
 		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -15,7 +15,11 @@
 package transport
 
 import (
+	"context"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/google/go-containerregistry/pkg/internal/retry"
@@ -29,9 +33,17 @@ var defaultBackoff = retry.Backoff{
 	Steps:    5,
 }
 
+// maxRetryAfter is the longest we honor a Retry-After header for, unless the
+// backoff has a lower Cap, so that a misbehaving registry can't stall a
+// request indefinitely.
+const maxRetryAfter = 5 * time.Minute
+
 var _ http.RoundTripper = (*retryTransport)(nil)
 
-// retryTransport wraps a RoundTripper and retries temporary network errors.
+// retryTransport wraps a RoundTripper and retries temporary network errors
+// and responses with temporary status codes, e.g. 429 or 503. If the registry
+// sends a Retry-After header, we wait at least that long before retrying, up
+// to maxRetryAfter or the backoff's Cap.
 type retryTransport struct {
 	inner     http.RoundTripper
 	backoff   retry.Backoff
@@ -79,10 +91,115 @@ func NewRetry(inner http.RoundTripper, opts ...Option) http.RoundTripper {
 }
 
 func (t *retryTransport) RoundTrip(in *http.Request) (out *http.Response, err error) {
-	roundtrip := func() error {
-		out, err = t.inner.RoundTrip(in)
-		return err
+	backoff := t.backoff
+	req := in
+	for {
+		out, err = t.inner.RoundTrip(req)
+
+		retryable := err
+		if err == nil {
+			retryable = t.retryableResponse(req, out)
+		}
+		if retryable == nil || !t.predicate(retryable) || backoff.Steps <= 1 {
+			return out, err
+		}
+
+		wait := backoff.Step()
+		if out != nil {
+			// We're going to retry, so drain and discard this response.
+			if ra := retryAfter(out, t.retryAfterCap()); ra > wait {
+				wait = ra
+			}
+			io.Copy(ioutil.Discard, out.Body)
+			out.Body.Close()
+
+			// Don't modify the caller's request, just replay its body.
+			if in.GetBody != nil {
+				body, err := in.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				req = in.Clone(in.Context())
+				req.Body = body
+			}
+		}
+
+		if err := sleep(in, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retryableResponse returns an error describing resp if the request could be
+// retried based on its status code, so that it can be passed to the predicate.
+// Only idempotent requests are retried, since e.g. a PATCH may have been
+// applied even though it failed, and requests with a body are only retried if
+// the body can be replayed.
+func (t *retryTransport) retryableResponse(in *http.Request, resp *http.Response) error {
+	if resp.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+	switch in.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+	default:
+		return nil
+	}
+	if in.Body != nil && in.Body != http.NoBody && in.GetBody == nil {
+		return nil
+	}
+	return &Error{
+		StatusCode: resp.StatusCode,
+		Request:    in,
+	}
+}
+
+// retryAfterCap returns the longest we wait for a Retry-After header: the
+// backoff's Cap, if it's set and lower than maxRetryAfter.
+func (t *retryTransport) retryAfterCap() time.Duration {
+	if t.backoff.Cap > 0 && t.backoff.Cap < maxRetryAfter {
+		return t.backoff.Cap
+	}
+	return maxRetryAfter
+}
+
+// retryAfter parses the Retry-After header of a 429 or 503 response, which
+// may be either a number of seconds or an HTTP date, clamped to max.
+func retryAfter(resp *http.Response, max time.Duration) time.Duration {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0
+	}
+	ra := resp.Header.Get("Retry-After")
+	if ra == "" {
+		return 0
+	}
+	var d time.Duration
+	if secs, err := strconv.Atoi(ra); err == nil {
+		// Check before multiplying, which could overflow.
+		if secs > int(max/time.Second) {
+			return max
+		}
+		d = time.Duration(secs) * time.Second
+	} else if t, err := http.ParseTime(ra); err == nil {
+		d = time.Until(t)
+	}
+	if d > max {
+		return max
+	}
+	return d
+}
+
+// sleep waits for d, or until the request's context is done.
+func sleep(in *http.Request, d time.Duration) error {
+	ctx := context.Background()
+	if in != nil {
+		ctx = in.Context()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
 	}
-	retry.Retry(roundtrip, t.predicate, t.backoff)
-	return
 }
@@ -16,8 +16,11 @@ package transport
 
 import (
 	"context"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -129,3 +132,178 @@ func TestTimeoutContext(t *testing.T) {
 		t.Fatalf("deadline was not recognized by transport")
 	}
 }
+
+func TestRetryStatusCodes(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		codes []int
+		body  func() io.Reader
+		count int
+		want  int
+	}{{
+		name:  "retry 503",
+		codes: []int{http.StatusServiceUnavailable, http.StatusOK},
+		count: 2,
+		want:  http.StatusOK,
+	}, {
+		name:  "retry 429",
+		codes: []int{http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusOK},
+		count: 3,
+		want:  http.StatusOK,
+	}, {
+		name:  "don't retry 404",
+		codes: []int{http.StatusNotFound, http.StatusOK},
+		count: 1,
+		want:  http.StatusNotFound,
+	}, {
+		name:  "give up",
+		codes: []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway, http.StatusOK},
+		count: 3,
+		want:  http.StatusBadGateway,
+	}, {
+		name:  "replay body",
+		codes: []int{http.StatusServiceUnavailable, http.StatusOK},
+		body:  func() io.Reader { return strings.NewReader("hello") },
+		count: 2,
+		want:  http.StatusOK,
+	}, {
+		name:  "can't replay body",
+		codes: []int{http.StatusServiceUnavailable, http.StatusOK},
+		body:  func() io.Reader { return ioutil.NopCloser(strings.NewReader("hello")) },
+		count: 1,
+		want:  http.StatusServiceUnavailable,
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			count := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if test.body != nil {
+					if b, err := ioutil.ReadAll(r.Body); err != nil || string(b) != "hello" {
+						t.Errorf("body = %q, %v; want %q", string(b), err, "hello")
+					}
+				}
+				w.WriteHeader(test.codes[count])
+				count++
+			}))
+			defer server.Close()
+
+			var body io.Reader
+			if test.body != nil {
+				body = test.body()
+			}
+			req, err := http.NewRequest(http.MethodPut, server.URL, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tr := NewRetry(http.DefaultTransport, WithRetryBackoff(retry.Backoff{Steps: 3}))
+			resp, err := tr.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip() = %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != test.want {
+				t.Errorf("StatusCode = %d, want %d", resp.StatusCode, test.want)
+			}
+			if count != test.count {
+				t.Errorf("wrong count, wanted %d, got %d", test.count, count)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	count := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if count == 0 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+		count++
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr := NewRetry(http.DefaultTransport, WithRetryBackoff(retry.Backoff{Duration: time.Millisecond, Steps: 3}))
+	start := time.Now()
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip() = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("StatusCode = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, want at least 1s per Retry-After", elapsed)
+	}
+
+	// The Retry-After wait should still respect the request's context.
+	count = 0
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := tr.RoundTrip(req.WithContext(ctx)); err != context.DeadlineExceeded {
+		t.Errorf("RoundTrip() = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestRetryAfterClamp(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{"seconds", "30", 30 * time.Second},
+		{"too many seconds", "86400", time.Minute},
+		{"overflow", "9223372036854775807", time.Minute},
+		{"date", time.Now().Add(24 * time.Hour).UTC().Format(http.TimeFormat), time.Minute},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			resp := &http.Response{
+				StatusCode: http.StatusTooManyRequests,
+				Header:     http.Header{"Retry-After": []string{test.value}},
+			}
+			if got := retryAfter(resp, time.Minute); got != test.want {
+				t.Errorf("retryAfter(%q) = %v, want %v", test.value, got, test.want)
+			}
+		})
+	}
+
+	// Without a Cap, the default ceiling applies.
+	tr := NewRetry(http.DefaultTransport).(*retryTransport)
+	if got := tr.retryAfterCap(); got != maxRetryAfter {
+		t.Errorf("retryAfterCap() = %v, want %v", got, maxRetryAfter)
+	}
+
+	// With one, a huge Retry-After only waits that long.
+	count := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if count == 0 {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		count++
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	capped := NewRetry(http.DefaultTransport, WithRetryBackoff(retry.Backoff{Duration: time.Millisecond, Steps: 3, Cap: 10 * time.Millisecond}))
+	start := time.Now()
+	resp, err := capped.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip() = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("StatusCode = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("retried after %v, want about the 10ms Cap", elapsed)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+var _ http.RoundTripper = (*timeoutTransport)(nil)
+
+// timeoutTransport cancels requests that make no progress for longer than
+// timeout: between reads of the request body, waiting for the response
+// headers, or between reads of the response body. Unlike
+// http.Client.Timeout, this doesn't limit the total duration of a request, so
+// large blobs can still be downloaded over a slow connection.
+type timeoutTransport struct {
+	inner   http.RoundTripper
+	timeout time.Duration
+}
+
+// NewTimeout returns an http.RoundTripper that cancels a request if it makes
+// no progress for longer than timeout, i.e. if sending the next chunk of the
+// request body, or receiving the response headers or the next chunk of the
+// response body, takes longer than that.
+func NewTimeout(inner http.RoundTripper, timeout time.Duration) http.RoundTripper {
+	return &timeoutTransport{
+		inner:   inner,
+		timeout: timeout,
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *timeoutTransport) RoundTrip(in *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(in.Context())
+	w := &watchdog{timeout: t.timeout, cancel: cancel}
+	w.timer = time.AfterFunc(t.timeout, w.fire)
+
+	out := in.WithContext(ctx)
+	if in.Body != nil && in.Body != http.NoBody {
+		// Uploads make progress as the request body is read.
+		out.Body = &requestBody{ReadCloser: in.Body, w: w}
+		if in.GetBody != nil {
+			out.GetBody = func() (io.ReadCloser, error) {
+				rc, err := in.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				return &requestBody{ReadCloser: rc, w: w}, nil
+			}
+		}
+	}
+
+	res, err := t.inner.RoundTrip(out)
+	if err != nil {
+		w.stop()
+		return nil, w.wrap(err)
+	}
+	res.Body = &timeoutBody{body: res.Body, w: w}
+	return res, nil
+}
+
+// watchdog cancels a request once its timer fires.
+type watchdog struct {
+	timer   *time.Timer
+	timeout time.Duration
+	cancel  context.CancelFunc
+	fired   int32
+	stopped int32
+}
+
+func (w *watchdog) fire() {
+	atomic.StoreInt32(&w.fired, 1)
+	w.cancel()
+}
+
+func (w *watchdog) stop() {
+	atomic.StoreInt32(&w.stopped, 1)
+	w.timer.Stop()
+	w.cancel()
+}
+
+// wrap explains err if it was caused by the watchdog firing.
+func (w *watchdog) wrap(err error) error {
+	if err != nil && err != io.EOF && atomic.LoadInt32(&w.fired) == 1 {
+		return fmt.Errorf("no progress for %v: %w", w.timeout, err)
+	}
+	return err
+}
+
+// reset restarts the timer after some progress was made.
+func (w *watchdog) reset() {
+	if atomic.LoadInt32(&w.stopped) == 0 {
+		w.timer.Reset(w.timeout)
+	}
+}
+
+// requestBody resets the watchdog every time data is read to be sent.
+type requestBody struct {
+	io.ReadCloser
+	w *watchdog
+}
+
+func (b *requestBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.w.reset()
+	}
+	return n, err
+}
+
+// timeoutBody resets the watchdog every time data is read.
+type timeoutBody struct {
+	body io.ReadCloser
+	w    *watchdog
+}
+
+func (b *timeoutBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if n > 0 {
+		b.w.reset()
+	}
+	return n, b.w.wrap(err)
+}
+
+func (b *timeoutBody) Close() error {
+	b.w.stop()
+	return b.body.Close()
+}
@@ -0,0 +1,102 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow-headers":
+			time.Sleep(4 * timeout)
+		case "/trickle":
+			// Takes longer than timeout in total, but keeps making progress.
+			for i := 0; i < 6; i++ {
+				w.Write([]byte("x"))
+				w.(http.Flusher).Flush()
+				time.Sleep(timeout / 2)
+			}
+		case "/upload":
+			if _, err := ioutil.ReadAll(r.Body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			}
+		case "/stall":
+			w.Write([]byte("x"))
+			w.(http.Flusher).Flush()
+			time.Sleep(4 * timeout)
+			w.Write([]byte("x"))
+		}
+	}))
+	defer server.Close()
+
+	client := http.Client{Transport: NewTimeout(http.DefaultTransport, timeout)}
+
+	if _, err := client.Get(server.URL + "/slow-headers"); err == nil || !strings.Contains(err.Error(), "no progress") {
+		t.Errorf("Get(slow-headers) = %v, want timeout", err)
+	} else if !errors.Is(err, context.Canceled) {
+		t.Errorf("Get(slow-headers) = %v, want it to wrap %v", err, context.Canceled)
+	}
+
+	// A slow upload keeps making progress as long as the body is being sent.
+	pr, pw := io.Pipe()
+	go func() {
+		for i := 0; i < 6; i++ {
+			pw.Write([]byte("x"))
+			time.Sleep(timeout / 2)
+		}
+		pw.Close()
+	}()
+	resp, err := client.Post(server.URL+"/upload", "text/plain", pr)
+	if err != nil {
+		t.Fatalf("Post(upload) = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Post(upload) = %d, want 200", resp.StatusCode)
+	}
+
+	resp, err = client.Get(server.URL + "/trickle")
+	if err != nil {
+		t.Fatalf("Get(trickle) = %v", err)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Errorf("ReadAll(trickle) = %v", err)
+	} else if string(b) != "xxxxxx" {
+		t.Errorf("ReadAll(trickle) = %q, want xxxxxx", b)
+	}
+
+	resp, err = client.Get(server.URL + "/stall")
+	if err != nil {
+		t.Fatalf("Get(stall) = %v", err)
+	}
+	_, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err == nil || !strings.Contains(err.Error(), "no progress") {
+		t.Errorf("ReadAll(stall) = %v, want timeout", err)
+	}
+}
@@ -22,7 +22,11 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/go-containerregistry/pkg/internal/redact"
@@ -41,8 +45,79 @@ type Taggable interface {
 	RawManifest() ([]byte, error)
 }
 
+// AdditionalTagsError is returned by Write and WriteIndex when the manifest
+// was pushed, but some of the tags passed to WithAdditionalTags were not.
+type AdditionalTagsError struct {
+	// Tagged holds the tags that were pushed.
+	Tagged []string
+
+	// Errors maps each tag that wasn't pushed to the reason why.
+	Errors map[string]error
+}
+
+// Error implements error.
+func (e *AdditionalTagsError) Error() string {
+	tags := make([]string, 0, len(e.Errors))
+	for tag := range e.Errors {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	msgs := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		msgs = append(msgs, fmt.Sprintf("%s: %v", tag, e.Errors[tag]))
+	}
+	return fmt.Sprintf("failed to push %d of %d additional tags: %s", len(tags), len(tags)+len(e.Tagged), strings.Join(msgs, "; "))
+}
+
+// ManifestSizeLimit is the manifest size, in bytes, that the OCI distribution
+// spec says registries should accept at least. Larger manifests may fail with a
+// *ManifestTooLargeError, depending on the registry.
+const ManifestSizeLimit = 4 * 1024 * 1024
+
+// ManifestTooLargeError is returned when the registry rejects a manifest
+// because it is too large (413 Request Entity Too Large).
+type ManifestTooLargeError struct {
+	// Size is the size of the rejected manifest, in bytes.
+	Size int64
+
+	// Limit is the maximum manifest size the registry reported, in bytes, or
+	// zero if it didn't report one.
+	Limit int64
+
+	// Err is the error returned by the registry.
+	Err error
+}
+
+// Error implements error.
+func (e *ManifestTooLargeError) Error() string {
+	if e.Limit > 0 {
+		return fmt.Sprintf("manifest of %d bytes exceeds the registry limit of %d bytes: %v", e.Size, e.Limit, e.Err)
+	}
+	return fmt.Sprintf("manifest of %d bytes is too large for the registry: %v", e.Size, e.Err)
+}
+
+// Unwrap returns the error returned by the registry.
+func (e *ManifestTooLargeError) Unwrap() error {
+	return e.Err
+}
+
+// manifestSizeLimit returns the limit hinted at by the Range header of a 413
+// response, e.g. "0-4194303", or zero if there is none.
+func manifestSizeLimit(h http.Header) int64 {
+	rng := strings.TrimPrefix(h.Get("Range"), "bytes=")
+	parts := strings.SplitN(rng, "-", 2)
+	if len(parts) != 2 || parts[0] != "0" {
+		return 0
+	}
+	end, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || end < 0 {
+		return 0
+	}
+	return end + 1
+}
+
 // Write pushes the provided img to the specified image reference.
-func Write(ref name.Reference, img v1.Image, options ...Option) error {
+func Write(ref name.Reference, img v1.Image, options ...Option) (rerr error) {
 	ls, err := img.Layers()
 	if err != nil {
 		return err
@@ -52,17 +127,20 @@ func Write(ref name.Reference, img v1.Image, options ...Option) error {
 	if err != nil {
 		return err
 	}
+	if o.progress == nil && o.updates != nil {
+		size, err := imageSize(img, o.allowNondistributableArtifacts)
+		if err != nil {
+			return err
+		}
+		o.progress = &progress{updates: o.updates, total: size}
+		defer func() { o.progress.done(rerr) }()
+	}
 
-	scopes := scopesForUploadingImage(ref.Context(), ls)
-	tr, err := transport.NewWithContext(o.context, ref.Context().Registry, o.auth, o.transport, scopes)
+	scopes := scopesForUploadingImage(ref.Context(), ls, o.mountFrom...)
+	w, err := makeWriter(ref.Context(), o, scopes)
 	if err != nil {
 		return err
 	}
-	w := writer{
-		repo:    ref.Context(),
-		client:  &http.Client{Transport: tr},
-		context: o.context,
-	}
 
 	// Upload individual layers in goroutines and collect any errors.
 	// If we can dedupe by the layer digest, try to do so. If we can't determine
@@ -129,7 +207,34 @@ func Write(ref name.Reference, img v1.Image, options ...Option) error {
 
 	// With all of the constituent elements uploaded, upload the manifest
 	// to commit the image.
-	return w.commitManifest(img, ref)
+	if err := w.commitManifest(img, ref); err != nil {
+		return err
+	}
+	return w.commitAdditionalTags(img, o.additionalTags)
+}
+
+// makeWriter returns a writer for repo configured by o, authorized for the
+// given scopes in addition to any from WithScopes.
+func makeWriter(repo name.Repository, o *options, scopes []string) (*writer, error) {
+	tr, err := transport.NewWithContext(o.context, repo.Registry, o.auth, o.transport, append(scopes, o.scopes...))
+	if err != nil {
+		return nil, err
+	}
+	return &writer{
+		repo:       repo,
+		client:     &http.Client{Transport: tr},
+		context:    o.context,
+		chunkSize:  o.chunkSize,
+		monolithic: o.monolithic,
+		backoff:    o.retryBackoff,
+		predicate:  o.retryPredicate,
+		progress:   o.progress,
+		mountFrom:  o.mountFrom,
+
+		skipExistingBlobCheck: o.skipExistingBlobCheck,
+		stats:                 o.stats,
+		uploaded:              o.uploaded,
+	}, nil
 }
 
 // writer writes the elements of an image to a remote image reference.
@@ -137,6 +242,63 @@ type writer struct {
 	repo    name.Repository
 	client  *http.Client
 	context context.Context
+
+	// chunkSize, if positive, splits blob uploads into chunks of this many
+	// bytes, see WithChunkSize.
+	chunkSize int64
+
+	// monolithic uploads blobs with a single PUT, see WithMonolithicUpload.
+	// patchUnsupported is set atomically once the registry has rejected a
+	// PATCH, so that the remaining blobs go straight to a PUT.
+	monolithic       bool
+	patchUnsupported int32
+
+	// progress, if set, is notified of bytes uploaded, see WithProgress.
+	progress *progress
+
+	// backoff and predicate, if set, override how blob uploads are retried,
+	// see WithRetryBackoff and WithRetryPredicate.
+	backoff   *Backoff
+	predicate retry.Predicate
+
+	// mountFrom lists additional repositories to try mounting blobs from,
+	// see WithMountFrom.
+	mountFrom []name.Repository
+
+	// skipExistingBlobCheck disables the HEAD request before each upload,
+	// see WithExistingBlobCheck.
+	skipExistingBlobCheck bool
+
+	// stats, if set, counts the blobs uploaded and skipped, see WithWriteStats.
+	stats *WriteStats
+
+	// uploaded, if set, tracks the blobs already written by this WriteIndex
+	// call, so that blobs shared by its children are only uploaded once.
+	uploaded *blobSet
+}
+
+// blobSet is a set of blob digests that is safe for concurrent use.
+type blobSet struct {
+	mu     sync.Mutex
+	hashes map[v1.Hash]struct{}
+}
+
+// add adds h to the set, returning false if it was already present. A nil
+// blobSet doesn't track anything.
+func (s *blobSet) add(h v1.Hash) bool {
+	if s == nil {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.hashes[h]; ok {
+		return false
+	}
+	if s.hashes == nil {
+		s.hashes = map[v1.Hash]struct{}{}
+	}
+	s.hashes[h] = struct{}{}
+	return true
 }
 
 // url returns a url.Url for the specified path in the context of this remote image reference.
@@ -259,6 +421,22 @@ func (w *writer) initiateUpload(from, mount string) (location string, mounted bo
 	}
 }
 
+// initiateMount attempts to mount the blob from each of the given repositories
+// in order. If none of them can be mounted from, the upload initiated by the
+// last attempt is returned, as with initiateUpload.
+func (w *writer) initiateMount(froms []string, mount string) (location string, mounted bool, err error) {
+	for i, from := range froms {
+		location, mounted, err := w.initiateUpload(from, mount)
+		if err != nil || mounted || i == len(froms)-1 {
+			return location, mounted, err
+		}
+		// The registry started an upload instead of mounting, so abandon it
+		// before trying the next repository.
+		go w.cancelUpload(location)
+	}
+	return w.initiateUpload("", mount)
+}
+
 // streamBlob streams the contents of the blob to the specified location.
 // On failure, this will return an error.  On success, this will return the location
 // header indicating how to commit the streamed blob.
@@ -283,6 +461,156 @@ func (w *writer) streamBlob(ctx context.Context, blob io.ReadCloser, streamLocat
 	return w.nextLocation(resp)
 }
 
+// Try each blob upload three times, waiting 1s after first failure, 3s after
+// second.
+var uploadBackoff = retry.Backoff{
+	Duration: 1.0 * time.Second,
+	Factor:   3.0,
+	Jitter:   0.1,
+	Steps:    3,
+}
+
+// Try each chunk five times, waiting 0.5s after the first failure, 1s
+// after the second, and so on.
+var chunkBackoff = retry.Backoff{
+	Duration: 500 * time.Millisecond,
+	Factor:   2.0,
+	Jitter:   0.1,
+	Steps:    5,
+}
+
+// streamBlobChunked streams the contents of the blob to the specified location
+// in chunks of w.chunkSize bytes. If a chunk fails to upload, we ask the
+// registry how much of the upload it has received and resume from that offset
+// instead of starting over. On success, this will return the location header
+// indicating how to commit the streamed blob.
+func (w *writer) streamBlobChunked(ctx context.Context, blob io.ReadCloser, location string) (commitLocation string, err error) {
+	defer blob.Close()
+
+	buf := make([]byte, w.chunkSize)
+	var offset int64
+	for {
+		n, err := io.ReadFull(blob, buf)
+		if err == io.EOF {
+			break
+		} else if err != nil && err != io.ErrUnexpectedEOF {
+			return "", err
+		}
+		chunk := buf[:n]
+		start, end := offset, offset+int64(n)
+
+		backoff := chunkBackoff
+		for offset < end {
+			loc, err := w.uploadChunk(ctx, location, offset, chunk[offset-start:])
+			if err == nil {
+				location, offset = loc, end
+				break
+			}
+			if backoff.Steps <= 1 || !resumable(ctx, err) {
+				return "", err
+			}
+			t := time.NewTimer(backoff.Step())
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return "", ctx.Err()
+			case <-t.C:
+			}
+
+			loc, committed, serr := w.uploadStatus(ctx, location)
+			if serr != nil {
+				logs.Warn.Printf("failed to query upload status, retrying chunk: %v", serr)
+				continue
+			}
+			if committed < start || committed > end {
+				return "", fmt.Errorf("cannot resume upload: registry has %d bytes, want between %d and %d", committed, start, end)
+			}
+			location, offset = loc, committed
+		}
+
+		if int64(n) < w.chunkSize {
+			break
+		}
+	}
+
+	return location, nil
+}
+
+// uploadChunk sends a single chunk of a blob, starting at offset, to the
+// specified location. On success, this returns the location for the next
+// chunk.
+func (w *writer) uploadChunk(ctx context.Context, location string, offset int64, chunk []byte) (string, error) {
+	req, err := http.NewRequest(http.MethodPatch, location, bytes.NewReader(chunk))
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = int64(len(chunk))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Range", fmt.Sprintf("%d-%d", offset, offset+int64(len(chunk))-1))
+
+	resp, err := w.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if err := transport.CheckError(resp, http.StatusNoContent, http.StatusAccepted, http.StatusCreated); err != nil {
+		return "", err
+	}
+
+	return w.nextLocation(resp)
+}
+
+// uploadStatus asks the registry about an in-progress upload via a GET to the
+// upload location. On success, this returns the location for the next chunk
+// and the number of bytes the registry has received so far.
+func (w *writer) uploadStatus(ctx context.Context, location string) (string, int64, error) {
+	req, err := http.NewRequest(http.MethodGet, location, nil)
+	if err != nil {
+		return "", 0, err
+	}
+
+	resp, err := w.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	if err := transport.CheckError(resp, http.StatusNoContent); err != nil {
+		return "", 0, err
+	}
+
+	// The Range header is inclusive (RFC 7233), e.g. "0-99" means we have 100
+	// bytes and "0-0" means we have 1. Without one, we have nothing.
+	var first, last int64
+	if rng := resp.Header.Get("Range"); rng != "" {
+		if _, err := fmt.Sscanf(rng, "%d-%d", &first, &last); err != nil {
+			return "", 0, fmt.Errorf("parsing Range header %q: %w", rng, err)
+		}
+		last++
+	}
+
+	loc, err := w.nextLocation(resp)
+	if err != nil {
+		return "", 0, err
+	}
+	return loc, last, nil
+}
+
+// resumable determines whether a failed chunk upload is worth resuming.
+// Errors from the registry are resumable if they're temporary; anything else
+// came from the network, so the registry may have received part of the chunk.
+func resumable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var terr *transport.Error
+	if errors.As(err, &terr) {
+		return terr.Temporary()
+	}
+	return true
+}
+
 // commitBlob commits this blob by sending a PUT to the location returned from
 // streaming the blob.
 func (w *writer) commitBlob(location, digest string) error {
@@ -308,33 +636,81 @@ func (w *writer) commitBlob(location, digest string) error {
 	return transport.CheckError(resp, http.StatusCreated)
 }
 
+// uploadMonolithic uploads the whole blob and commits it with a single PUT to
+// the location of an upload started by initiateUpload.
+func (w *writer) uploadMonolithic(ctx context.Context, blob io.ReadCloser, size int64, location, digest string) error {
+	u, err := url.Parse(location)
+	if err != nil {
+		blob.Close()
+		return err
+	}
+	v := u.Query()
+	v.Set("digest", digest)
+	u.RawQuery = v.Encode()
+
+	req, err := http.NewRequest(http.MethodPut, u.String(), blob)
+	if err != nil {
+		blob.Close()
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if size >= 0 {
+		req.ContentLength = size
+	}
+
+	resp, err := w.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return transport.CheckError(resp, http.StatusCreated)
+}
+
+// isPatchUnsupported returns whether err means that the registry doesn't
+// support uploading blobs with PATCH.
+func isPatchUnsupported(err error) bool {
+	var terr *transport.Error
+	if !errors.As(err, &terr) {
+		return false
+	}
+	return terr.StatusCode == http.StatusMethodNotAllowed || terr.StatusCode == http.StatusNotImplemented
+}
+
 // uploadOne performs a complete upload of a single layer.
 func (w *writer) uploadOne(l v1.Layer) error {
-	var from, mount string
+	var froms []string
+	var mount string
 	if h, err := l.Digest(); err == nil {
+		if !w.uploaded.add(h) {
+			// Another child of the index being written has this blob, and it
+			// was counted towards the progress total only once.
+			logs.Progress.Printf("already uploaded blob: %v", h)
+			return nil
+		}
+
 		// If we know the digest, this isn't a streaming layer. Do an existence
 		// check so we can skip uploading the layer if possible.
-		existing, err := w.checkExistingBlob(h)
-		if err != nil {
-			return err
-		}
-		if existing {
-			logs.Progress.Printf("existing blob: %v", h)
-			return nil
+		if !w.skipExistingBlobCheck {
+			existing, err := w.checkExistingBlob(h)
+			if err != nil {
+				return err
+			}
+			if existing {
+				logs.Progress.Printf("existing blob: %v", h)
+				w.skipped(l)
+				return nil
+			}
 		}
 
 		mount = h.String()
-	}
-	if ml, ok := l.(*MountableLayer); ok {
-		if w.repo.RegistryStr() == ml.Reference.Context().RegistryStr() {
-			from = ml.Reference.Context().RepositoryStr()
-		}
+		froms = w.mountSources(l)
 	}
 
 	ctx := w.context
 
-	tryUpload := func() error {
-		location, mounted, err := w.initiateUpload(from, mount)
+	tryUpload := func() (err error) {
+		location, mounted, err := w.initiateMount(froms, mount)
 		if err != nil {
 			return err
 		} else if mounted {
@@ -343,6 +719,7 @@ func (w *writer) uploadOne(l v1.Layer) error {
 				return err
 			}
 			logs.Progress.Printf("mounted blob: %s", h.String())
+			w.skipped(l)
 			return nil
 		}
 
@@ -357,11 +734,83 @@ func (w *writer) uploadOne(l v1.Layer) error {
 			ctx = redact.NewContext(ctx, "omitting binary blobs from logs")
 		}
 
-		blob, err := l.Compressed()
+		var prs []*progressReader
+		// If this attempt fails, take back what it reported so that a
+		// retry doesn't count the blob twice.
+		defer func() {
+			if err != nil {
+				for _, pr := range prs {
+					pr.reset()
+				}
+			}
+		}()
+		open := func() (io.ReadCloser, error) {
+			blob, err := l.Compressed()
+			if err != nil {
+				return nil, err
+			}
+			if w.progress != nil {
+				_, serr := l.Size()
+				pr := &progressReader{ReadCloser: blob, progress: w.progress, unsized: serr != nil}
+				prs = append(prs, pr)
+				blob = pr
+			}
+			return blob, nil
+		}
+		// uploadMonolithic uploads l with a single PUT, if its digest is
+		// known up front, which isn't the case for streaming layers.
+		uploadMonolithic := func() (ok bool, err error) {
+			h, err := l.Digest()
+			if err != nil {
+				return false, nil
+			}
+			size, err := l.Size()
+			if err != nil {
+				size = -1
+			}
+			blob, err := open()
+			if err != nil {
+				return true, err
+			}
+			if err := w.uploadMonolithic(ctx, blob, size, location, h.String()); err != nil {
+				return true, err
+			}
+			logs.Progress.Printf("pushed blob: %s", h.String())
+			if w.stats != nil && size >= 0 {
+				w.stats.uploaded(size)
+			}
+			return true, nil
+		}
+
+		if w.monolithic || atomic.LoadInt32(&w.patchUnsupported) == 1 {
+			if ok, err := uploadMonolithic(); ok {
+				return err
+			}
+		}
+
+		blob, err := open()
 		if err != nil {
 			return err
 		}
-		location, err = w.streamBlob(ctx, blob, location)
+		var commitLocation string
+		if w.chunkSize > 0 {
+			commitLocation, err = w.streamBlobChunked(ctx, blob, location)
+		} else {
+			commitLocation, err = w.streamBlob(ctx, blob, location)
+		}
+		if isPatchUnsupported(err) {
+			// Some minimal registries only support monolithic uploads, so
+			// retry the upload that way, and skip the PATCH from now on.
+			logs.Warn.Printf("registry doesn't support chunked uploads, falling back to monolithic uploads: %v", err)
+			atomic.StoreInt32(&w.patchUnsupported, 1)
+			for _, pr := range prs {
+				pr.reset()
+			}
+			prs = nil
+			if ok, err := uploadMonolithic(); ok {
+				return err
+			}
+		}
 		if err != nil {
 			return err
 		}
@@ -372,22 +821,69 @@ func (w *writer) uploadOne(l v1.Layer) error {
 		}
 		digest := h.String()
 
-		if err := w.commitBlob(location, digest); err != nil {
+		if err := w.commitBlob(commitLocation, digest); err != nil {
 			return err
 		}
 		logs.Progress.Printf("pushed blob: %s", digest)
+		if w.stats != nil {
+			// Streaming layers know their size once they've been uploaded.
+			sz, err := l.Size()
+			if err != nil {
+				return err
+			}
+			w.stats.uploaded(sz)
+		}
 		return nil
 	}
 
-	// Try this three times, waiting 1s after first failure, 3s after second.
-	backoff := retry.Backoff{
-		Duration: 1.0 * time.Second,
-		Factor:   3.0,
-		Jitter:   0.1,
-		Steps:    3,
+	backoff, predicate := uploadBackoff, retry.IsTemporary
+	if w.backoff != nil {
+		backoff = *w.backoff
+	}
+	if w.predicate != nil {
+		predicate = w.predicate
+	}
+	return retry.Retry(tryUpload, predicate, backoff)
+}
+
+// mountSources returns the repositories on the target registry that l might be
+// mounted from: where it was pulled from, if it is a MountableLayer, followed by
+// any repositories passed to WithMountFrom.
+func (w *writer) mountSources(l v1.Layer) []string {
+	repos := []name.Repository{}
+	if ml, ok := asMountable(l); ok {
+		repos = append(repos, ml.Reference.Context())
 	}
+	repos = append(repos, w.mountFrom...)
 
-	return retry.Retry(tryUpload, retry.IsTemporary, backoff)
+	froms := []string{}
+	seen := map[string]bool{w.repo.RepositoryStr(): true}
+	for _, repo := range repos {
+		if repo.RegistryStr() != w.repo.RegistryStr() || seen[repo.RepositoryStr()] {
+			continue
+		}
+		seen[repo.RepositoryStr()] = true
+		froms = append(froms, repo.RepositoryStr())
+	}
+	return froms
+}
+
+// skipped reports the size of a blob that didn't need to be uploaded as
+// complete, since it was accounted for in the total.
+func (w *writer) skipped(l v1.Layer) {
+	if w.progress == nil && w.stats == nil {
+		return
+	}
+	sz, err := l.Size()
+	if err != nil {
+		return
+	}
+	if w.progress != nil {
+		w.progress.add(sz, false)
+	}
+	if w.stats != nil {
+		w.stats.skipped(sz)
+	}
 }
 
 type withLayer interface {
@@ -409,6 +905,9 @@ func (w *writer) writeIndex(ref name.Reference, ii v1.ImageIndex, options ...Opt
 		}
 		if exists {
 			logs.Progress.Print("existing manifest: ", desc.Digest)
+			if err := w.skippedManifest(ii, desc, options...); err != nil {
+				return err
+			}
 			continue
 		}
 
@@ -419,7 +918,7 @@ func (w *writer) writeIndex(ref name.Reference, ii v1.ImageIndex, options ...Opt
 				return err
 			}
 
-			if err := w.writeIndex(ref, ii); err != nil {
+			if err := w.writeIndex(ref, ii, options...); err != nil {
 				return err
 			}
 		case types.OCIManifestSchema1, types.DockerManifestSchema2:
@@ -452,6 +951,29 @@ func (w *writer) writeIndex(ref name.Reference, ii v1.ImageIndex, options ...Opt
 	return w.commitManifest(ii, ref)
 }
 
+// skippedManifest reports the size of the blobs of a child manifest that
+// already exists as complete, since they were accounted for in the total.
+// Its blobs are also marked as uploaded, so that other children don't check
+// for them again.
+func (w *writer) skippedManifest(ii v1.ImageIndex, desc v1.Descriptor, options ...Option) error {
+	if w.progress == nil && w.uploaded == nil {
+		return nil
+	}
+	o, err := makeOptions(w.repo, options...)
+	if err != nil {
+		return err
+	}
+
+	sz, err := childSize(ii, desc, o.allowNondistributableArtifacts, w.uploaded.add)
+	if err != nil {
+		return err
+	}
+	if w.progress != nil {
+		w.progress.add(sz, false)
+	}
+	return nil
+}
+
 type withMediaType interface {
 	MediaType() (types.MediaType, error)
 }
@@ -517,6 +1039,13 @@ func (w *writer) commitManifest(t Taggable, ref name.Reference) error {
 	defer resp.Body.Close()
 
 	if err := transport.CheckError(resp, http.StatusOK, http.StatusCreated, http.StatusAccepted); err != nil {
+		if resp.StatusCode == http.StatusRequestEntityTooLarge {
+			return &ManifestTooLargeError{
+				Size:  int64(len(raw)),
+				Limit: manifestSizeLimit(resp.Header),
+				Err:   err,
+			}
+		}
 		return err
 	}
 
@@ -525,12 +1054,43 @@ func (w *writer) commitManifest(t Taggable, ref name.Reference) error {
 	return nil
 }
 
-func scopesForUploadingImage(repo name.Repository, layers []v1.Layer) []string {
+// commitAdditionalTags PUTs the manifest under each of tags, which are tags
+// in w.repo. It attempts every tag, returning an *AdditionalTagsError if any
+// of them fail.
+func (w *writer) commitAdditionalTags(t Taggable, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	aerr := &AdditionalTagsError{Errors: map[string]error{}}
+	for _, tag := range tags {
+		ref, err := w.repo.ParseTag(tag)
+		if err == nil {
+			err = w.commitManifest(t, ref)
+		}
+		if err != nil {
+			aerr.Errors[tag] = err
+		} else {
+			aerr.Tagged = append(aerr.Tagged, tag)
+		}
+	}
+	if len(aerr.Errors) != 0 {
+		return aerr
+	}
+	return nil
+}
+
+func scopesForUploadingImage(repo name.Repository, layers []v1.Layer, mountFrom ...name.Repository) []string {
 	// use a map as set to remove duplicates scope strings
 	scopeSet := map[string]struct{}{}
 
+	for _, from := range mountFrom {
+		if from.String() != repo.String() && from.Registry.String() == repo.Registry.String() {
+			scopeSet[from.Scope(transport.PullScope)] = struct{}{}
+		}
+	}
+
 	for _, l := range layers {
-		if ml, ok := l.(*MountableLayer); ok {
+		if ml, ok := asMountable(l); ok {
 			// we will add push scope for ref.Context() after the loop.
 			// for now we ask pull scope for references of the same registry
 			if ml.Reference.Context().String() != repo.String() && ml.Reference.Context().Registry.String() == repo.Registry.String() {
@@ -553,40 +1113,59 @@ func scopesForUploadingImage(repo name.Repository, layers []v1.Layer) []string {
 // WriteIndex pushes the provided ImageIndex to the specified image reference.
 // WriteIndex will attempt to push all of the referenced manifests before
 // attempting to push the ImageIndex, to retain referential integrity.
-func WriteIndex(ref name.Reference, ii v1.ImageIndex, options ...Option) error {
+func WriteIndex(ref name.Reference, ii v1.ImageIndex, options ...Option) (rerr error) {
 	o, err := makeOptions(ref.Context(), options...)
 	if err != nil {
 		return err
 	}
+	// Options for the children are appended below; copy them first, so that
+	// we never write into the caller's array.
+	options = append([]Option{}, options...)
+	if o.uploaded == nil {
+		// Share the set with children, which are written with options.
+		o.uploaded = &blobSet{}
+		options = append(options, withBlobSet(o.uploaded))
+	}
+	if o.progress == nil && o.updates != nil {
+		size, err := indexSize(ii, o.allowNondistributableArtifacts)
+		if err != nil {
+			return err
+		}
+		// Share the progress with children, which are written with options.
+		o.progress = &progress{updates: o.updates, total: size}
+		defer func() { o.progress.done(rerr) }()
+		options = append(options, withProgress(o.progress))
+	}
 	scopes := []string{ref.Scope(transport.PushScope)}
-	tr, err := transport.NewWithContext(o.context, ref.Context().Registry, o.auth, o.transport, scopes)
+	w, err := makeWriter(ref.Context(), o, scopes)
 	if err != nil {
 		return err
 	}
-	w := writer{
-		repo:    ref.Context(),
-		client:  &http.Client{Transport: tr},
-		context: o.context,
+	// The additional tags are only meant for the index itself.
+	options = append(options, withoutAdditionalTags())
+	if err := w.writeIndex(ref, ii, options...); err != nil {
+		return err
 	}
-	return w.writeIndex(ref, ii, options...)
+	return w.commitAdditionalTags(ii, o.additionalTags)
 }
 
 // WriteLayer uploads the provided Layer to the specified repo.
-func WriteLayer(repo name.Repository, layer v1.Layer, options ...Option) error {
+func WriteLayer(repo name.Repository, layer v1.Layer, options ...Option) (rerr error) {
 	o, err := makeOptions(repo, options...)
 	if err != nil {
 		return err
 	}
-	scopes := scopesForUploadingImage(repo, []v1.Layer{layer})
-	tr, err := transport.NewWithContext(o.context, repo.Registry, o.auth, o.transport, scopes)
+	if o.progress == nil && o.updates != nil {
+		// Streaming layers don't know their size yet, see progressReader.
+		size, _ := layer.Size()
+		o.progress = &progress{updates: o.updates, total: size}
+		defer func() { o.progress.done(rerr) }()
+	}
+	scopes := scopesForUploadingImage(repo, []v1.Layer{layer}, o.mountFrom...)
+	w, err := makeWriter(repo, o, scopes)
 	if err != nil {
 		return err
 	}
-	w := writer{
-		repo:    repo,
-		client:  &http.Client{Transport: tr},
-		context: o.context,
-	}
 
 	return w.uploadOne(layer)
 }
@@ -615,15 +1194,10 @@ func Tag(tag name.Tag, t Taggable, options ...Option) error {
 	// * Allow callers to pass in a transport.Transport, typecheck
 	//   it to allow them to reuse the transport across multiple calls.
 	// * WithTag option to do multiple manifest PUTs in commitManifest.
-	tr, err := transport.NewWithContext(o.context, tag.Context().Registry, o.auth, o.transport, scopes)
+	w, err := makeWriter(tag.Context(), o, scopes)
 	if err != nil {
 		return err
 	}
-	w := writer{
-		repo:    tag.Context(),
-		client:  &http.Client{Transport: tr},
-		context: o.context,
-	}
 
 	return w.commitManifest(t, tag)
 }
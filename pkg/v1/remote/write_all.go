@@ -0,0 +1,363 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"golang.org/x/sync/errgroup"
+)
+
+// WriteAllError is returned by WriteAll when t was written to some of the
+// refs, but not all of them.
+type WriteAllError struct {
+	// Written holds the refs that t was written to.
+	Written []name.Reference
+
+	// Errors maps each ref that t wasn't written to to the reason why.
+	Errors map[name.Reference]error
+}
+
+// Error implements error.
+func (e *WriteAllError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for ref, err := range e.Errors {
+		msgs = append(msgs, fmt.Sprintf("%s: %v", ref, err))
+	}
+	sort.Strings(msgs)
+	return fmt.Sprintf("failed to write to %d of %d refs: %s", len(e.Errors), len(e.Errors)+len(e.Written), strings.Join(msgs, "; "))
+}
+
+// WriteAll writes t, a v1.Image or v1.ImageIndex, to each of refs, which
+// unlike with MultiWrite may be in different repositories or registries, e.g.
+// to mirror an image to several registries at once.
+//
+// Each blob is read from t at most once: it is uploaded to every destination
+// concurrently, and the contents read from Compressed are copied to each of
+// those uploads as they are read. The uploads proceed at the pace of the
+// slowest destination. Blobs that a destination already has, or can mount, are
+// skipped for that destination, and a destination that retries an upload
+// reads the blob from t again.
+//
+// Destinations succeed or fail independently: a failure to write to one ref
+// doesn't abort the others. If any fail, the returned error is a
+// *WriteAllError. If t was written to a ref but some of the tags passed to
+// WithAdditionalTags were not, the error for that ref is an
+// *AdditionalTagsError. Refs that appear more than once are only written once.
+//
+// The options apply to every destination; credentials from
+// WithAuthFromKeychain are resolved for each one. The totals reported by
+// WithProgress cover the uploads to all of them.
+func WriteAll(refs []name.Reference, t Taggable, opts ...Option) (rerr error) {
+	refs = dedupRefs(refs)
+	if len(refs) == 0 {
+		return nil
+	}
+
+	dests := make([]*writer, len(refs))
+	destOpts := make([]*options, len(refs))
+	for i, ref := range refs {
+		o, err := makeOptions(ref.Context(), opts...)
+		if err != nil {
+			return err
+		}
+		destOpts[i] = o
+	}
+	o := destOpts[0]
+
+	// Collect the blobs and child manifests to upload, as MultiWrite does.
+	// The child manifests are keyed by digest in the first repository.
+	blobs := map[v1.Hash]v1.Layer{}
+	var children []map[name.Reference]Taggable
+	switch v := t.(type) {
+	case v1.Image:
+		if err := addImageBlobs(v, blobs, o.allowNondistributableArtifacts); err != nil {
+			return err
+		}
+	case v1.ImageIndex:
+		var err error
+		if children, err = addIndexBlobs(v, blobs, refs[0].Context(), nil, 0, o.allowNondistributableArtifacts); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("pushable resource was not Image or ImageIndex: %T", t)
+	}
+	ls := make([]v1.Layer, 0, len(blobs))
+	for _, l := range blobs {
+		ls = append(ls, l)
+	}
+
+	var p *progress
+	if o.updates != nil {
+		var size int64
+		switch v := t.(type) {
+		case v1.Image:
+			sz, err := imageSize(v, o.allowNondistributableArtifacts)
+			if err != nil {
+				return err
+			}
+			size = sz
+		case v1.ImageIndex:
+			sz, err := indexSize(v, o.allowNondistributableArtifacts)
+			if err != nil {
+				return err
+			}
+			size = sz
+		}
+		p = &progress{updates: o.updates, total: size * int64(len(refs))}
+		defer func() { p.done(rerr) }()
+	}
+
+	var mu sync.Mutex
+	errs := map[name.Reference]error{}
+	fail := func(i int, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if _, ok := errs[refs[i]]; !ok {
+			errs[refs[i]] = err
+		}
+	}
+	failed := func(i int) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		_, ok := errs[refs[i]]
+		return ok
+	}
+
+	for i, ref := range refs {
+		o := destOpts[i]
+		scopes := scopesForUploadingImage(ref.Context(), ls, o.mountFrom...)
+		w, err := makeWriter(ref.Context(), o, scopes)
+		if err != nil {
+			fail(i, err)
+			continue
+		}
+		w.progress = p
+		dests[i] = w
+	}
+
+	// Upload each blob to every destination at the same time, so that a
+	// single read can be shared by all of them.
+	blobChan := make(chan v1.Layer, 2*o.jobs)
+	var g errgroup.Group
+	for j := 0; j < o.jobs; j++ {
+		g.Go(func() error {
+			for l := range blobChan {
+				tb := newTeeBlob(l, len(refs))
+				var wg sync.WaitGroup
+				for i, w := range dests {
+					if w == nil || failed(i) {
+						tb.done(i)
+						continue
+					}
+					wg.Add(1)
+					go func(i int, w *writer) {
+						defer wg.Done()
+						defer tb.done(i)
+						if err := w.uploadOne(tb.layer(i)); err != nil {
+							fail(i, err)
+						}
+					}(i, w)
+				}
+				wg.Wait()
+			}
+			return nil
+		})
+	}
+	for _, l := range ls {
+		blobChan <- l
+	}
+	close(blobChan)
+	g.Wait()
+
+	// With the blobs in place, commit the manifests, from the lowest level of
+	// the index up.
+	var wg sync.WaitGroup
+	for i, w := range dests {
+		if w == nil || failed(i) {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, w *writer) {
+			defer wg.Done()
+			for lvl := len(children) - 1; lvl >= 0; lvl-- {
+				for ref, child := range children[lvl] {
+					if err := w.commitManifest(child, w.repo.Digest(ref.Identifier())); err != nil {
+						fail(i, err)
+						return
+					}
+				}
+			}
+			if err := w.commitManifest(t, refs[i]); err != nil {
+				fail(i, err)
+				return
+			}
+			if err := w.commitAdditionalTags(t, destOpts[i].additionalTags); err != nil {
+				fail(i, err)
+			}
+		}(i, w)
+	}
+	wg.Wait()
+
+	if len(errs) == 0 {
+		return nil
+	}
+	werr := &WriteAllError{Errors: errs}
+	for _, ref := range refs {
+		if _, ok := errs[ref]; !ok {
+			werr.Written = append(werr.Written, ref)
+		}
+	}
+	return werr
+}
+
+// dedupRefs returns refs without the refs that appear more than once, keeping
+// the first of each.
+func dedupRefs(refs []name.Reference) []name.Reference {
+	seen := make(map[string]bool, len(refs))
+	out := make([]name.Reference, 0, len(refs))
+	for _, ref := range refs {
+		if seen[ref.Name()] {
+			continue
+		}
+		seen[ref.Name()] = true
+		out = append(out, ref)
+	}
+	return out
+}
+
+// teeBlob copies a single read of a blob to the uploads of several
+// destinations, see WriteAll.
+type teeBlob struct {
+	l    v1.Layer
+	once sync.Once
+	prs  []*io.PipeReader
+	pws  []*io.PipeWriter
+
+	mu     sync.Mutex
+	opened []bool
+}
+
+func newTeeBlob(l v1.Layer, n int) *teeBlob {
+	tb := &teeBlob{
+		l:      l,
+		prs:    make([]*io.PipeReader, n),
+		pws:    make([]*io.PipeWriter, n),
+		opened: make([]bool, n),
+	}
+	for i := range tb.prs {
+		tb.prs[i], tb.pws[i] = io.Pipe()
+	}
+	return tb
+}
+
+// layer returns the layer for the upload to the i'th destination.
+func (tb *teeBlob) layer(i int) v1.Layer {
+	var l v1.Layer = &teeLayer{Layer: tb.l, tb: tb, i: i}
+	if ml, ok := asMountable(tb.l); ok {
+		// Keep mounting from the source repository.
+		l = &MountableLayer{Layer: l, Reference: ml.Reference}
+	}
+	return l
+}
+
+// done releases the i'th destination once its upload has finished, so that
+// the copy doesn't wait for a destination that skipped the blob.
+func (tb *teeBlob) done(i int) {
+	tb.prs[i].Close()
+}
+
+// open returns the contents of the blob for the i'th destination. Only the
+// first call for each destination shares the read; retries read the blob
+// again by themselves.
+func (tb *teeBlob) open(i int) (io.ReadCloser, error) {
+	tb.mu.Lock()
+	first := !tb.opened[i]
+	tb.opened[i] = true
+	tb.mu.Unlock()
+	if !first {
+		return tb.l.Compressed()
+	}
+	tb.once.Do(func() { go tb.copy() })
+	return tb.prs[i], nil
+}
+
+// copy reads the blob once, writing it to every destination that is still
+// reading. A destination that stops reading is dropped without affecting
+// the others.
+func (tb *teeBlob) copy() {
+	rc, err := tb.l.Compressed()
+	if err != nil {
+		for _, pw := range tb.pws {
+			pw.CloseWithError(err)
+		}
+		return
+	}
+	defer rc.Close()
+
+	live := make([]bool, len(tb.pws))
+	for i := range live {
+		live[i] = true
+	}
+	buf := make([]byte, 32*1024)
+	for {
+		n, rerr := rc.Read(buf)
+		if n > 0 {
+			left := 0
+			for i, pw := range tb.pws {
+				if !live[i] {
+					continue
+				}
+				if _, err := pw.Write(buf[:n]); err != nil {
+					live[i] = false
+					continue
+				}
+				left++
+			}
+			if left == 0 {
+				return
+			}
+		}
+		if rerr != nil {
+			if rerr == io.EOF {
+				rerr = nil
+			}
+			for i, pw := range tb.pws {
+				if live[i] {
+					pw.CloseWithError(rerr)
+				}
+			}
+			return
+		}
+	}
+}
+
+// teeLayer is the layer uploaded to a single destination by WriteAll.
+type teeLayer struct {
+	v1.Layer
+	tb *teeBlob
+	i  int
+}
+
+// Compressed implements v1.Layer
+func (tl *teeLayer) Compressed() (io.ReadCloser, error) {
+	return tl.tb.open(tl.i)
+}
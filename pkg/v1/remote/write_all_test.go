@@ -0,0 +1,202 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+// newWriteAllRegistry returns a new registry server and a ref in it.
+func newWriteAllRegistry(t *testing.T, h http.Handler, ref string) (*httptest.Server, name.Reference) {
+	t.Helper()
+	s := httptest.NewServer(h)
+	r, err := name.ParseReference(strings.TrimPrefix(s.URL, "http://") + ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, r
+}
+
+func TestWriteAll(t *testing.T) {
+	var mu sync.Mutex
+	reads := map[string]int{}
+	srcReg := registry.New()
+	src, srcRef := newWriteAllRegistry(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/blobs/") {
+			mu.Lock()
+			reads[r.URL.Path]++
+			mu.Unlock()
+		}
+		srcReg.ServeHTTP(w, r)
+	}), "/src:latest")
+	defer src.Close()
+
+	rnd, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(srcRef, rnd); err != nil {
+		t.Fatal(err)
+	}
+	img, err := Image(srcRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ls, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok1, ref1 := newWriteAllRegistry(t, registry.New(), "/dst:one")
+	defer ok1.Close()
+
+	// This one already has a layer, so it skips it.
+	ok2, ref2 := newWriteAllRegistry(t, registry.New(), "/dst:two")
+	defer ok2.Close()
+	if err := WriteLayer(ref2.Context(), ls[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	// This one rejects uploads.
+	bad, ref3 := newWriteAllRegistry(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		registry.New().ServeHTTP(w, r)
+	}), "/dst:three")
+	defer bad.Close()
+
+	// Only count the reads made by WriteAll.
+	mu.Lock()
+	reads = map[string]int{}
+	mu.Unlock()
+
+	err = WriteAll([]name.Reference{ref1, ref2, ref3}, img)
+	var werr *WriteAllError
+	if !errors.As(err, &werr) {
+		t.Fatalf("WriteAll() = %v, want WriteAllError", err)
+	}
+	if len(werr.Errors) != 1 || werr.Errors[ref3] == nil {
+		t.Errorf("Errors = %v, want only %s", werr.Errors, ref3)
+	}
+	if len(werr.Written) != 2 || werr.Written[0] != ref1 || werr.Written[1] != ref2 {
+		t.Errorf("Written = %v, want %s and %s", werr.Written, ref1, ref2)
+	}
+
+	for _, ref := range []name.Reference{ref1, ref2} {
+		got, err := Image(ref)
+		if err != nil {
+			t.Fatalf("Image(%s) = %v", ref, err)
+		}
+		if err := validate.Image(got); err != nil {
+			t.Errorf("validate.Image(%s) = %v", ref, err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, l := range ls {
+		h, err := l.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := "/v2/src/blobs/" + h.String()
+		if n := reads[path]; n != 1 {
+			t.Errorf("%s was read %d times, want once", path, n)
+		}
+	}
+}
+
+func TestWriteAllIndex(t *testing.T) {
+	idx, err := random.Index(1024, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s1, ref1 := newWriteAllRegistry(t, registry.New(), "/repo/a:idx")
+	defer s1.Close()
+	s2, ref2 := newWriteAllRegistry(t, registry.New(), "/b/c:idx")
+	defer s2.Close()
+
+	if err := WriteAll([]name.Reference{ref1, ref2}, idx); err != nil {
+		t.Fatalf("WriteAll() = %v", err)
+	}
+	for _, ref := range []name.Reference{ref1, ref2} {
+		got, err := Index(ref)
+		if err != nil {
+			t.Fatalf("Index(%s) = %v", ref, err)
+		}
+		if err := validate.Index(got); err != nil {
+			t.Errorf("validate.Index(%s) = %v", ref, err)
+		}
+	}
+}
+
+func TestWriteAllOptions(t *testing.T) {
+	img, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s1, ref1 := newWriteAllRegistry(t, registry.New(), "/repo:one")
+	defer s1.Close()
+	s2, ref2 := newWriteAllRegistry(t, registry.New(), "/repo:two")
+	defer s2.Close()
+	dup, err := name.ParseReference(ref1.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	size, err := imageSize(img, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := 2 * size
+
+	c := make(chan v1.Update, 400)
+	if err := WriteAll([]name.Reference{ref1, ref2, dup}, img, WithProgress(c), WithAdditionalTags([]string{"extra"})); err != nil {
+		t.Fatalf("WriteAll() = %v", err)
+	}
+	close(c)
+	var last v1.Update
+	for update := range c {
+		last = update
+	}
+	if last.Error != io.EOF || last.Total != want || last.Complete != want {
+		t.Errorf("final update = %d/%d, %v; want %d/%d, io.EOF", last.Complete, last.Total, last.Error, want, want)
+	}
+
+	for _, ref := range []name.Reference{ref1, ref2} {
+		extra := ref.Context().Tag("extra")
+		got, err := Image(extra)
+		if err != nil {
+			t.Fatalf("Image(%s) = %v", extra, err)
+		}
+		if err := validate.Image(got); err != nil {
+			t.Errorf("validate.Image(%s) = %v", extra, err)
+		}
+	}
+}
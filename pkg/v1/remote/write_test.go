@@ -20,18 +20,22 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
 	"strings"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/google/go-containerregistry/pkg/internal/retry"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/registry"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
@@ -413,6 +417,132 @@ func TestInitiateUploadMountsWithMountFromTheSameRegistry(t *testing.T) {
 	}
 }
 
+func TestWriteLayerMountFrom(t *testing.T) {
+	layer, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := layer.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var froms []string
+	cancelled := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == http.MethodPost:
+			if got := r.URL.Query().Get("mount"); got != h.String() {
+				t.Errorf("mount; got %v, want %v", got, h)
+			}
+			from := r.URL.Query().Get("from")
+			froms = append(froms, from)
+			if from == "has/blob" {
+				w.WriteHeader(http.StatusCreated)
+				return
+			}
+			w.Header().Set("Location", "/upload")
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodDelete && r.URL.Path == "/upload":
+			close(cancelled)
+		default:
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mustRepo := func(s string) name.Repository {
+		repo, err := name.NewRepository(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return repo
+	}
+	dst := mustRepo(u.Host + "/target")
+	mountFrom := []name.Repository{
+		mustRepo("other.registry.example/has/blob"),
+		dst,
+		mustRepo(u.Host + "/no/blob"),
+		mustRepo(u.Host + "/has/blob"),
+	}
+
+	if err := WriteLayer(dst, layer, WithMountFrom(mountFrom...)); err != nil {
+		t.Fatalf("WriteLayer() = %v", err)
+	}
+	// Only repositories on the same registry other than dst are tried.
+	if diff := cmp.Diff([]string{"no/blob", "has/blob"}, froms); diff != "" {
+		t.Errorf("mounted from (-want +got): %s", diff)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Error("upload started by the failed mount was not cancelled")
+	}
+}
+
+func TestWriteExistingBlobCheck(t *testing.T) {
+	var heads int32
+	reg := registry.New()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead && strings.Contains(r.URL.Path, "/blobs/") {
+			atomic.AddInt32(&heads, 1)
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(u.Host + "/test/existing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stats WriteStats
+	if err := Write(ref, img, WithWriteStats(&stats)); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+	if stats.BlobsUploaded != 3 {
+		t.Errorf("BlobsUploaded = %d, want 3", stats.BlobsUploaded)
+	}
+
+	// Everything already exists, so nothing is uploaded.
+	stats = WriteStats{}
+	if err := Write(ref, img, WithWriteStats(&stats)); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+	if stats.BlobsUploaded != 0 || stats.BlobsSkipped != 3 {
+		t.Errorf("uploaded %d, skipped %d blobs, want 0, 3", stats.BlobsUploaded, stats.BlobsSkipped)
+	}
+
+	// Without the check, everything is uploaded again.
+	atomic.StoreInt32(&heads, 0)
+	stats = WriteStats{}
+	if err := Write(ref, img, WithWriteStats(&stats), WithExistingBlobCheck(false)); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+	if stats.BlobsUploaded != 3 {
+		t.Errorf("BlobsUploaded = %d, want 3", stats.BlobsUploaded)
+	}
+	if got := atomic.LoadInt32(&heads); got != 0 {
+		t.Errorf("got %d blob HEAD requests, want 0", got)
+	}
+}
+
 func TestDedupeLayers(t *testing.T) {
 	newBlob := func() io.ReadCloser { return ioutil.NopCloser(bytes.NewReader(bytes.Repeat([]byte{'a'}, 10000))) }
 
@@ -714,6 +844,64 @@ func TestUploadOne(t *testing.T) {
 	}
 }
 
+func TestWriteLayerStreamRetry(t *testing.T) {
+	var failed int32
+	reg := registry.New()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Fail the first upload after reading some of it.
+		if r.Method == http.MethodPatch && atomic.CompareAndSwapInt32(&failed, 0, 1) {
+			if _, err := io.ReadFull(r.Body, make([]byte, 10)); err != nil {
+				t.Errorf("Reading body: %v", err)
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := name.NewRepository(u.Host + "/test/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sl := stream.NewLayer(ioutil.NopCloser(bytes.NewReader(bytes.Repeat([]byte{'a'}, 10000))), stream.WithBuffer(dir))
+	defer sl.Close()
+
+	if err := WriteLayer(repo, sl, WithRetryBackoff(Backoff{Duration: time.Millisecond, Steps: 3})); err != nil {
+		t.Fatalf("WriteLayer() = %v", err)
+	}
+	if atomic.LoadInt32(&failed) != 1 {
+		t.Error("first upload did not fail")
+	}
+	h, err := sl.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := Layer(repo.Digest(h.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := l.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if got, _, err := v1.SHA256(rc); err != nil {
+		t.Fatal(err)
+	} else if got != h {
+		t.Errorf("pushed blob digest = %v, want %v", got, h)
+	}
+}
+
 func TestUploadOneStreamedLayer(t *testing.T) {
 	expectedRepo := "baz/blah"
 	initiatePath := fmt.Sprintf("/v2/%s/blobs/uploads/", expectedRepo)
@@ -912,7 +1100,7 @@ func TestWriteWithErrors(t *testing.T) {
 		t.Error("Write() = nil; wanted error")
 	} else if se, ok := err.(*transport.Error); !ok {
 		t.Errorf("Write() = %T; wanted *remote.Error", se)
-	} else if diff := cmp.Diff(expectedError, se, cmpopts.IgnoreUnexported(transport.Error{})); diff != "" {
+	} else if diff := cmp.Diff(expectedError, se, cmpopts.IgnoreUnexported(transport.Error{}), cmpopts.IgnoreFields(transport.Error{}, "Request")); diff != "" {
 		t.Errorf("Write(); (-want +got) = %s", diff)
 	}
 }
@@ -1302,6 +1490,64 @@ func TestSkipForeignLayersByDefault(t *testing.T) {
 	}
 }
 
+func TestSkipForeignAddendum(t *testing.T) {
+	// Mark an ordinary layer as foreign when appending it.
+	base := setupImage(t)
+	layer, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := layer.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	urls := []string{"https://example.com/" + d.String()}
+	img, err := mutate.Append(base, mutate.Addendum{
+		Layer:     layer,
+		MediaType: types.DockerForeignLayer,
+		URLs:      urls,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := registry.New()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, d.String()) || r.URL.Query().Get("digest") == d.String() {
+			t.Errorf("%s %s: foreign layer should not be uploaded", r.Method, r.URL)
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(fmt.Sprintf("%s/test/foreign/addendum", u.Host))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(ref, img); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+
+	got, err := Image(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := got.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc := m.Layers[len(m.Layers)-1]
+	if desc.MediaType != types.DockerForeignLayer {
+		t.Errorf("MediaType = %s, want %s", desc.MediaType, types.DockerForeignLayer)
+	}
+	if diff := cmp.Diff(urls, desc.URLs); diff != "" {
+		t.Errorf("URLs (-want +got) = %s", diff)
+	}
+}
+
 func TestWriteForeignLayerIfOptionSet(t *testing.T) {
 	// Set up an image with a foreign layer.
 	base := setupImage(t)
@@ -1546,3 +1792,527 @@ func BenchmarkWrite(b *testing.B) {
 		}
 	}
 }
+
+func TestWriteLayerChunked(t *testing.T) {
+	// Don't actually wait between retries.
+	defer func(b retry.Backoff) { chunkBackoff = b }(chunkBackoff)
+	chunkBackoff.Duration = 0
+
+	l, err := random.Layer(10000, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, err := l.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunkSize := size / 4
+
+	reg := registry.New()
+	var posts, patches int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			atomic.AddInt32(&posts, 1)
+		case http.MethodPatch:
+			if r.Header.Get("Content-Range") == "" {
+				t.Errorf("PATCH without Content-Range")
+			}
+			switch atomic.AddInt32(&patches, 1) {
+			case 2:
+				// Fail before the registry sees the chunk.
+				http.Error(w, "Unavailable", http.StatusServiceUnavailable)
+				return
+			case 4:
+				// Fail after the registry has committed the chunk.
+				reg.ServeHTTP(httptest.NewRecorder(), r)
+				http.Error(w, "Bad Gateway", http.StatusBadGateway)
+				return
+			}
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := name.NewRepository(fmt.Sprintf("%s/test/chunked", u.Host))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteLayer(repo, l, WithChunkSize(chunkSize)); err != nil {
+		t.Fatalf("WriteLayer: %v", err)
+	}
+	if posts != 1 {
+		t.Errorf("got %d POSTs, want 1", posts)
+	}
+
+	digest, err := l.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Layer(repo.Digest(digest.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Layer(got); err != nil {
+		t.Errorf("validate.Layer: %v", err)
+	}
+
+	if _, err := makeOptions(repo, WithChunkSize(0)); err == nil {
+		t.Error("WithChunkSize(0): expected error")
+	}
+}
+
+func TestUploadStatusRange(t *testing.T) {
+	for _, tc := range []struct {
+		rng  string
+		want int64
+	}{
+		{"", 0},
+		// The Range header is inclusive, so this is one byte.
+		{"0-0", 1},
+		{"0-99", 100},
+	} {
+		t.Run(tc.rng, func(t *testing.T) {
+			w, closer, err := setupWriter("status", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Location", "/next")
+				if tc.rng != "" {
+					w.Header().Set("Range", tc.rng)
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer closer.Close()
+
+			u := w.url("/upload")
+			_, got, err := w.uploadStatus(context.Background(), u.String())
+			if err != nil {
+				t.Fatalf("uploadStatus() = %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("uploadStatus() = %d bytes, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestStreamBlobChunkedCancel(t *testing.T) {
+	// Wait long enough between retries that only ctx can end the loop.
+	defer func(b retry.Backoff) { chunkBackoff = b }(chunkBackoff)
+	chunkBackoff.Duration = time.Hour
+
+	w, closer, err := setupWriter("cancel", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Unavailable", http.StatusServiceUnavailable)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer.Close()
+	w.chunkSize = 10
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	u := w.url("/upload")
+	blob := ioutil.NopCloser(strings.NewReader("some blob contents"))
+	if _, err := w.streamBlobChunked(ctx, blob, u.String()); !errors.Is(err, context.Canceled) {
+		t.Errorf("streamBlobChunked() = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestWriteWithProgress(t *testing.T) {
+	img, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(fmt.Sprintf("%s/test/progress", u.Host))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := imageSize(img, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Write the image twice, so that the second write skips existing blobs.
+	for i := 0; i < 2; i++ {
+		c := make(chan v1.Update, 200)
+		if err := Write(ref, img, WithProgress(c)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		close(c)
+
+		var last v1.Update
+		for update := range c {
+			last = update
+		}
+		if last.Error != io.EOF {
+			t.Errorf("final update error = %v, want io.EOF", last.Error)
+		}
+		if last.Total != want || last.Complete != want {
+			t.Errorf("final update = %d/%d, want %d/%d", last.Complete, last.Total, want, want)
+		}
+	}
+
+	// The same option can be reused across writes, each reporting its own totals.
+	c := make(chan v1.Update, 400)
+	opt := WithProgress(c)
+	for i := 0; i < 2; i++ {
+		other, err := random.Image(1024, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := imageSize(other, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Write(ref, other, opt); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		var last v1.Update
+		for last.Error == nil {
+			last = <-c
+			if last.Complete > last.Total {
+				t.Errorf("update = %d/%d, complete exceeds total", last.Complete, last.Total)
+			}
+		}
+		if last.Error != io.EOF || last.Total != want || last.Complete != want {
+			t.Errorf("final update = %d/%d, %v; want %d/%d, io.EOF", last.Complete, last.Total, last.Error, want, want)
+		}
+	}
+}
+
+func TestWriteUserAgent(t *testing.T) {
+	reg := registry.New()
+	var requests int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		ua := r.Header.Get("User-Agent")
+		if !strings.HasPrefix(ua, "foo/v1 bar/v2 ") || !strings.Contains(ua, "go-containerregistry") {
+			t.Errorf("%s %s: User-Agent = %q, want foo/v1 bar/v2 go-containerregistry", r.Method, r.URL.Path, ua)
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(u.Host + "/test/ua:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(ref, img, WithUserAgent("foo/v1"), WithUserAgent("bar/v2")); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+	if atomic.LoadInt32(&requests) == 0 {
+		t.Error("no requests were made")
+	}
+}
+
+func TestWriteIndexSharedLayers(t *testing.T) {
+	shared, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sharedDigest, err := shared.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var adds []mutate.IndexAddendum
+	for i := 0; i < 3; i++ {
+		l, err := random.Layer(1024, types.DockerLayer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := mutate.AppendLayers(empty.Image, shared, l)
+		if err != nil {
+			t.Fatal(err)
+		}
+		adds = append(adds, mutate.IndexAddendum{Add: img})
+	}
+	idx := mutate.AppendManifests(empty.Index, adds...)
+
+	reg := registry.New()
+	var heads, puts int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/blobs/"+sharedDigest.String()) && r.Method == http.MethodHead {
+			atomic.AddInt32(&heads, 1)
+		}
+		if r.Method == http.MethodPut && r.URL.Query().Get("digest") == sharedDigest.String() {
+			atomic.AddInt32(&puts, 1)
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(u.Host + "/test/shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := indexSize(idx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := make(chan v1.Update, 200)
+	if err := WriteIndex(ref, idx, WithProgress(c)); err != nil {
+		t.Fatalf("WriteIndex() = %v", err)
+	}
+	close(c)
+	var last v1.Update
+	for update := range c {
+		last = update
+	}
+	if last.Error != io.EOF {
+		t.Errorf("final update error = %v, want io.EOF", last.Error)
+	}
+	if last.Total != want || last.Complete != want {
+		t.Errorf("final update = %d/%d, want %d/%d", last.Complete, last.Total, want, want)
+	}
+	if got := atomic.LoadInt32(&heads); got != 1 {
+		t.Errorf("shared layer was checked %d times, want 1", got)
+	}
+	if got := atomic.LoadInt32(&puts); got != 1 {
+		t.Errorf("shared layer was uploaded %d times, want 1", got)
+	}
+	got, err := Index(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Index(got); err != nil {
+		t.Errorf("validate.Index() = %v", err)
+	}
+}
+
+func TestWriteAdditionalTags(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := name.NewRepository(u.Host + "/write/tags")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Write(repo.Digest(h.String()), img, WithAdditionalTags([]string{"latest", "bad:tag", "v1"}))
+	var aerr *AdditionalTagsError
+	if !errors.As(err, &aerr) {
+		t.Fatalf("Write() = %v, want *AdditionalTagsError", err)
+	}
+	if diff := cmp.Diff([]string{"latest", "v1"}, aerr.Tagged); diff != "" {
+		t.Errorf("Tagged (-want +got) = %s", diff)
+	}
+	if _, ok := aerr.Errors["bad:tag"]; !ok || len(aerr.Errors) != 1 {
+		t.Errorf("Errors = %v, want only bad:tag", aerr.Errors)
+	}
+	for _, tag := range aerr.Tagged {
+		desc, err := Head(repo.Tag(tag))
+		if err != nil {
+			t.Fatalf("Head(%s) = %v", tag, err)
+		}
+		if desc.Digest != h {
+			t.Errorf("Head(%s).Digest = %v, want %v", tag, desc.Digest, h)
+		}
+	}
+
+	// The children of an index are not tagged.
+	idx, err := random.Index(1024, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteIndex(repo.Tag("index"), idx, WithAdditionalTags([]string{"index-v1"})); err != nil {
+		t.Fatalf("WriteIndex() = %v", err)
+	}
+	tags, err := List(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"index", "index-v1", "latest", "v1"}, tags); diff != "" {
+		t.Errorf("List() (-want +got) = %s", diff)
+	}
+
+	// WriteIndex adds options for the children, but not to the caller's array.
+	opts := make([]Option, 1, 4)
+	opts[0] = WithAdditionalTags([]string{"index-v2"})
+	if err := WriteIndex(repo.Tag("index"), idx, opts...); err != nil {
+		t.Fatalf("WriteIndex() = %v", err)
+	}
+	for i, opt := range opts[1:cap(opts)] {
+		if opt != nil {
+			t.Errorf("WriteIndex() wrote to options[%d]", i+1)
+		}
+	}
+}
+
+func TestWriteArtifact(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(u.Host + "/write/artifact:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	layer, err := random.Layer(1024, types.OCILayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := mutate.AppendLayers(empty.Artifact, layer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(ref, img); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+
+	got, err := Image(ref)
+	if err != nil {
+		t.Fatalf("Image() = %v", err)
+	}
+	m, err := got.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Config.MediaType != types.OCIEmptyJSON {
+		t.Errorf("config mediaType = %s, want %s", m.Config.MediaType, types.OCIEmptyJSON)
+	}
+	// The config blob is fetched from the registry, so it must have been uploaded.
+	raw, err := got.RawConfigFile()
+	if err != nil {
+		t.Fatalf("RawConfigFile() = %v", err)
+	}
+	if string(raw) != "{}" {
+		t.Errorf("RawConfigFile() = %s, want {}", raw)
+	}
+}
+
+func TestWriteManifestTooLarge(t *testing.T) {
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := registry.New()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut && strings.Contains(r.URL.Path, "/manifests/") {
+			w.Header().Set("Range", "0-99")
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(u.Host + "/write/large")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	werr := Write(ref, img)
+	var merr *ManifestTooLargeError
+	if !errors.As(werr, &merr) {
+		t.Fatalf("Write() = %v, want *ManifestTooLargeError", werr)
+	}
+	size, err := img.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merr.Size != size || merr.Limit != 100 {
+		t.Errorf("ManifestTooLargeError{Size: %d, Limit: %d}, want {%d, 100}", merr.Size, merr.Limit, size)
+	}
+	var terr *transport.Error
+	if !errors.As(werr, &terr) || terr.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("Write() = %v, want a wrapped 413", werr)
+	}
+}
+
+func TestWriteMonolithic(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		opts    []Option
+		patched bool
+	}{{
+		name: "option",
+		opts: []Option{WithMonolithicUpload()},
+	}, {
+		// The PATCH is rejected, so the upload is retried with a PUT.
+		name:    "fallback",
+		patched: true,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			reg := registry.New()
+			var patches int32
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodPatch {
+					atomic.AddInt32(&patches, 1)
+					w.WriteHeader(http.StatusMethodNotAllowed)
+					return
+				}
+				reg.ServeHTTP(w, r)
+			}))
+			defer s.Close()
+			u, err := url.Parse(s.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ref, err := name.ParseReference(u.Host + "/write/monolithic")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			img, err := random.Image(1024, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := Write(ref, img, tc.opts...); err != nil {
+				t.Fatalf("Write() = %v", err)
+			}
+			if got := atomic.LoadInt32(&patches); (got != 0) != tc.patched {
+				t.Errorf("registry got %d PATCH requests, want some: %t", got, tc.patched)
+			}
+
+			got, err := Image(ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := validate.Image(got); err != nil {
+				t.Errorf("validate.Image() = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package static implements a single-layer image with static content.
+package static
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/compression"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/internal/gzip"
+	"github.com/google/go-containerregistry/pkg/v1/internal/zstd"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// NewLayer returns a layer containing the given bytes, with the given mediaType.
+//
+// Contents will not be compressed, so the Digest and DiffID are identical.
+// This is only correct for uncompressed media types; use
+// NewLayerWithCompression for compressed ones.
+func NewLayer(b []byte, mt types.MediaType) v1.Layer {
+	return &staticLayer{b: b, mt: mt, algorithm: "sha256"}
+}
+
+// NewLayerWithAlgorithm is like NewLayer, but computes the Digest and DiffID
+// with the given hash algorithm (e.g. "sha512") instead of sha256.
+func NewLayerWithAlgorithm(b []byte, mt types.MediaType, algorithm string) (v1.Layer, error) {
+	if _, err := v1.Hasher(algorithm); err != nil {
+		return nil, err
+	}
+	return &staticLayer{b: b, mt: mt, algorithm: algorithm}, nil
+}
+
+// NewLayerWithCompression returns a layer whose Uncompressed contents are the
+// given bytes and whose Compressed contents are those bytes compressed with
+// the given algorithm.
+//
+// The Digest is computed over the compressed bytes and the DiffID over the
+// uncompressed bytes. If c is compression.None, this is equivalent to NewLayer.
+//
+// It is an error for mt to be a layer media type that implies a different
+// compression than c, e.g. types.OCILayerZStd with compression.GZip.
+func NewLayerWithCompression(b []byte, mt types.MediaType, c compression.Compression) (v1.Layer, error) {
+	if want, ok := compression.FromMediaType(mt); ok && want != c {
+		return nil, fmt.Errorf("media type %q implies %s compression, not %s", mt, want, c)
+	}
+
+	var rc io.ReadCloser
+	switch c {
+	case compression.None:
+		return NewLayer(b, mt), nil
+	case compression.GZip:
+		rc = gzip.ReadCloser(ioutil.NopCloser(bytes.NewReader(b)))
+	case compression.ZStd:
+		rc = zstd.ReadCloser(ioutil.NopCloser(bytes.NewReader(b)))
+	default:
+		return nil, fmt.Errorf("unsupported compression: %q", c)
+	}
+	defer rc.Close()
+
+	compressed, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	return &compressedLayer{
+		staticLayer:  staticLayer{b: compressed, mt: mt, algorithm: "sha256"},
+		uncompressed: b,
+	}, nil
+}
+
+type staticLayer struct {
+	b         []byte
+	mt        types.MediaType
+	algorithm string
+
+	once sync.Once
+	h    v1.Hash
+}
+
+func (l *staticLayer) Digest() (v1.Hash, error) {
+	var err error
+	// Only calculate digest the first time we're asked.
+	l.once.Do(func() {
+		l.h, _, err = v1.ComputeHash(l.algorithm, bytes.NewReader(l.b))
+	})
+	return l.h, err
+}
+
+func (l *staticLayer) DiffID() (v1.Hash, error) {
+	return l.Digest()
+}
+
+func (l *staticLayer) Uncompressed() (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(l.b)), nil
+}
+
+func (l *staticLayer) Compressed() (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(l.b)), nil
+}
+
+func (l *staticLayer) Size() (int64, error) {
+	return int64(len(l.b)), nil
+}
+
+func (l *staticLayer) MediaType() (types.MediaType, error) {
+	return l.mt, nil
+}
+
+// compressedLayer is a staticLayer whose bytes are the compressed contents,
+// with the original uncompressed contents kept alongside.
+type compressedLayer struct {
+	staticLayer
+
+	uncompressed []byte
+
+	diffOnce sync.Once
+	diffID   v1.Hash
+}
+
+func (l *compressedLayer) DiffID() (v1.Hash, error) {
+	var err error
+	// Only calculate diffid the first time we're asked.
+	l.diffOnce.Do(func() {
+		l.diffID, _, err = v1.ComputeHash(l.algorithm, bytes.NewReader(l.uncompressed))
+	})
+	return l.diffID, err
+}
+
+func (l *compressedLayer) Uncompressed() (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(l.uncompressed)), nil
+}
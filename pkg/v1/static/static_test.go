@@ -0,0 +1,215 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package static
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/compression"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/internal/zstd"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+func tarball(t *testing.T, contents string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     "file.txt",
+		Size:     int64(len(contents)),
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte(contents)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestNewLayer(t *testing.T) {
+	b := tarball(t, strings.Repeat(".", 10))
+	l := NewLayer(b, types.OCIUncompressedLayer)
+
+	if err := validate.Layer(l); err != nil {
+		t.Fatalf("validate.Layer: %v", err)
+	}
+
+	h, sz, err := v1.SHA256(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, err := l.Digest(); err != nil {
+		t.Fatalf("Digest: %v", err)
+	} else if d != h {
+		t.Errorf("Digest: got %v, want %v", d, h)
+	}
+	if d, err := l.DiffID(); err != nil {
+		t.Fatalf("DiffID: %v", err)
+	} else if d != h {
+		t.Errorf("DiffID: got %v, want %v", d, h)
+	}
+	if s, err := l.Size(); err != nil {
+		t.Fatalf("Size: %v", err)
+	} else if s != sz {
+		t.Errorf("Size: got %d, want %d", s, sz)
+	}
+	if mt, err := l.MediaType(); err != nil {
+		t.Fatalf("MediaType: %v", err)
+	} else if mt != types.OCIUncompressedLayer {
+		t.Errorf("MediaType: got %v, want %v", mt, types.OCIUncompressedLayer)
+	}
+}
+
+func TestNewLayerWithAlgorithm(t *testing.T) {
+	b := tarball(t, strings.Repeat(".", 10))
+	l, err := NewLayerWithAlgorithm(b, types.OCIUncompressedLayer, "sha512")
+	if err != nil {
+		t.Fatalf("NewLayerWithAlgorithm: %v", err)
+	}
+
+	if err := validate.Layer(l); err != nil {
+		t.Fatalf("validate.Layer: %v", err)
+	}
+
+	h, _, err := v1.ComputeHash("sha512", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, err := l.Digest(); err != nil {
+		t.Fatalf("Digest: %v", err)
+	} else if d != h {
+		t.Errorf("Digest: got %v, want %v", d, h)
+	}
+	if d, err := l.DiffID(); err != nil {
+		t.Fatalf("DiffID: %v", err)
+	} else if d != h {
+		t.Errorf("DiffID: got %v, want %v", d, h)
+	}
+
+	if _, err := NewLayerWithAlgorithm(b, types.OCIUncompressedLayer, "md5"); err == nil {
+		t.Error("NewLayerWithAlgorithm(md5): expected error")
+	}
+}
+
+func TestNewLayerWithCompression(t *testing.T) {
+	want := tarball(t, strings.Repeat("zstd", 100))
+
+	for c, mt := range map[compression.Compression]types.MediaType{
+		compression.GZip: types.OCILayer,
+		compression.ZStd: types.OCILayerZStd,
+	} {
+		c, mt := c, mt
+		t.Run(string(c), func(t *testing.T) {
+			l, err := NewLayerWithCompression(want, mt, c)
+			if err != nil {
+				t.Fatalf("NewLayerWithCompression: %v", err)
+			}
+			if err := validate.Layer(l); err != nil {
+				t.Fatalf("validate.Layer: %v", err)
+			}
+
+			digest, err := l.Digest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			diffID, err := l.DiffID()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if digest == diffID {
+				t.Errorf("Digest == DiffID (%v) for compressed layer", digest)
+			}
+
+			rc, err := l.Uncompressed()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ioutil.ReadAll(rc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("Uncompressed: got %q, want %q", got, want)
+			}
+		})
+	}
+
+	if _, err := NewLayerWithCompression(want, "application/vnd.example.layer", "bogus"); err == nil {
+		t.Error("NewLayerWithCompression(bogus): expected error")
+	}
+	if _, err := NewLayerWithCompression(want, types.OCILayerZStd, compression.GZip); err == nil {
+		t.Error("NewLayerWithCompression(zstd media type, gzip): expected error")
+	}
+	if _, err := NewLayerWithCompression(want, types.DockerLayer, compression.None); err == nil {
+		t.Error("NewLayerWithCompression(gzip media type, none): expected error")
+	}
+}
+
+func TestWriteZStdLayer(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := NewLayerWithCompression(tarball(t, "hello, zstd"), types.OCILayerZStd, compression.ZStd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err := l.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := name.NewRepository(fmt.Sprintf("%s/test/zstd", u.Host))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteLayer(repo, l); err != nil {
+		t.Fatalf("WriteLayer: %v", err)
+	}
+
+	got, err := remote.Layer(repo.Digest(digest.String()))
+	if err != nil {
+		t.Fatalf("remote.Layer: %v", err)
+	}
+	rc, err := got.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if ok, err := zstd.Is(rc); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Error("pushed blob is not zstd-compressed")
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"errors"
 	"hash"
 	"io"
+	"io/ioutil"
 	"os"
 	"sync"
 
@@ -45,9 +46,17 @@ type Layer struct {
 	consumed    bool
 	compression int
 
+	// buffer and bufferDir are set by WithBuffer.
+	buffer    bool
+	bufferDir string
+
 	mu             sync.Mutex
 	digest, diffID *v1.Hash
 	size           int64
+
+	// bufferPath is the file holding the compressed stream, once it has been
+	// fully buffered.
+	bufferPath string
 }
 
 var _ v1.Layer = (*Layer)(nil)
@@ -62,6 +71,22 @@ func WithCompressionLevel(level int) LayerOption {
 	}
 }
 
+// WithBuffer is a functional option for buffering the compressed stream to a
+// temporary file in dir (or the default directory for temporary files, if dir
+// is empty) as it is read. Once it has been read, Compressed returns the
+// buffered contents instead of ErrConsumed, so that e.g. a failed upload can
+// be retried.
+//
+// The file is created when Compressed is first called. If a reader returned by
+// Compressed is closed early, the rest of the stream is still buffered. Call
+// Close to remove the file once the layer is no longer needed.
+func WithBuffer(dir string) LayerOption {
+	return func(l *Layer) {
+		l.buffer = true
+		l.bufferDir = dir
+	}
+}
+
 // NewLayer creates a Layer from an io.ReadCloser.
 func NewLayer(rc io.ReadCloser, opts ...LayerOption) *Layer {
 	layer := &Layer{
@@ -120,12 +145,31 @@ func (l *Layer) Uncompressed() (io.ReadCloser, error) {
 
 // Compressed implements v1.Layer.
 func (l *Layer) Compressed() (io.ReadCloser, error) {
-	if l.consumed {
+	l.mu.Lock()
+	path, consumed := l.bufferPath, l.consumed
+	l.mu.Unlock()
+	if path != "" {
+		return os.Open(path)
+	}
+	if consumed {
 		return nil, ErrConsumed
 	}
 	return newCompressedReader(l)
 }
 
+// Close removes the file created by WithBuffer, if any. After that, the layer
+// can no longer be read.
+func (l *Layer) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.bufferPath == "" {
+		return nil
+	}
+	path := l.bufferPath
+	l.bufferPath = ""
+	return os.Remove(path)
+}
+
 type compressedReader struct {
 	closer io.Closer // original blob's Closer.
 
@@ -134,6 +178,9 @@ type compressedReader struct {
 	bw    *bufio.Writer
 	count *countWriter
 
+	// buffer, if set, receives a copy of the compressed stream, see WithBuffer.
+	buffer *os.File
+
 	l *Layer // stream.Layer to update upon Close.
 }
 
@@ -148,13 +195,28 @@ func newCompressedReader(l *Layer) (*compressedReader, error) {
 	pr, pw := io.Pipe()
 
 	// Write compressed bytes to be read by the pipe.Reader, hashed by zh, and counted by count.
-	mw := io.MultiWriter(pw, zh, count)
+	writers := []io.Writer{pw, zh, count}
+
+	var buffer *os.File
+	if l.buffer {
+		f, err := ioutil.TempFile(l.bufferDir, "stream-layer-")
+		if err != nil {
+			return nil, err
+		}
+		buffer = f
+		writers = append(writers, buffer)
+	}
+	mw := io.MultiWriter(writers...)
 
 	// Buffer the output of the gzip writer so we don't have to wait on pr to keep writing.
 	// 64K ought to be small enough for anybody.
 	bw := bufio.NewWriterSize(mw, 2<<16)
 	zw, err := gzip.NewWriterLevel(bw, l.compression)
 	if err != nil {
+		if buffer != nil {
+			buffer.Close()
+			os.Remove(buffer.Name())
+		}
 		return nil, err
 	}
 
@@ -165,10 +227,15 @@ func newCompressedReader(l *Layer) (*compressedReader, error) {
 		h:      h,
 		zh:     zh,
 		count:  count,
+		buffer: buffer,
 		l:      l,
 	}
 	go func() {
 		if _, err := io.Copy(io.MultiWriter(h, zw), l.blob); err != nil {
+			if buffer != nil {
+				buffer.Close()
+				os.Remove(buffer.Name())
+			}
 			pw.CloseWithError(err)
 			return
 		}
@@ -176,7 +243,7 @@ func newCompressedReader(l *Layer) (*compressedReader, error) {
 		// and calculate digest/diffID/size. This will cause pr to
 		// return EOF which will cause readers of the Compressed stream
 		// to finish reading.
-		pw.CloseWithError(cr.Close())
+		pw.CloseWithError(cr.finish())
 	}()
 
 	return cr, nil
@@ -185,6 +252,19 @@ func newCompressedReader(l *Layer) (*compressedReader, error) {
 func (cr *compressedReader) Read(b []byte) (int, error) { return cr.pr.Read(b) }
 
 func (cr *compressedReader) Close() error {
+	if cr.buffer == nil {
+		return cr.finish()
+	}
+	// Read whatever is left, so that the whole stream ends up in the buffer
+	// and the layer can be read again. The goroutine in newCompressedReader
+	// finishes the layer once it's done.
+	_, err := io.Copy(ioutil.Discard, cr.pr)
+	return err
+}
+
+// finish flushes the compressed stream and records the digest, diffID and size
+// of the layer.
+func (cr *compressedReader) finish() error {
 	cr.l.mu.Lock()
 	defer cr.l.mu.Unlock()
 
@@ -212,6 +292,13 @@ func (cr *compressedReader) Close() error {
 
 	cr.l.size = cr.count.n
 	cr.l.consumed = true
+
+	if cr.buffer != nil {
+		if err := cr.buffer.Close(); err != nil {
+			return err
+		}
+		cr.l.bufferPath = cr.buffer.Name()
+	}
 	return nil
 }
 
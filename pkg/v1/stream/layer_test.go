@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
 	"strings"
 	"testing"
 
@@ -234,3 +235,62 @@ func TestMediaType(t *testing.T) {
 		t.Errorf("MediaType(): want %q, got %q", want, got)
 	}
 }
+
+func TestBuffer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	l := NewLayer(ioutil.NopCloser(bytes.NewBufferString(strings.Repeat("hello", 1000))), WithBuffer(dir))
+
+	// Read part of the stream, as if an upload failed halfway through.
+	rc, err := l.Compressed()
+	if err != nil {
+		t.Fatalf("Compressed: %v", err)
+	}
+	if _, err := io.ReadFull(rc, make([]byte, 10)); err != nil {
+		t.Fatalf("Error reading contents: %v", err)
+	}
+	if err := rc.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	digest, err := l.Digest()
+	if err != nil {
+		t.Fatalf("Digest: %v", err)
+	}
+	size, err := l.Size()
+	if err != nil {
+		t.Fatalf("Size: %v", err)
+	}
+
+	// The whole stream can be read again, from the buffer.
+	for i := 0; i < 2; i++ {
+		rc, err := l.Compressed()
+		if err != nil {
+			t.Fatalf("Compressed() after consuming: %v", err)
+		}
+		got, n, err := v1.SHA256(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rc.Close()
+		if got != digest || n != size {
+			t.Errorf("buffered contents: got %v (%d bytes), want %v (%d bytes)", got, n, digest, size)
+		}
+	}
+
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if fis, err := ioutil.ReadDir(dir); err != nil {
+		t.Fatal(err)
+	} else if len(fis) != 0 {
+		t.Errorf("buffer was not removed: %d files left", len(fis))
+	}
+	if _, err := l.Compressed(); err != ErrConsumed {
+		t.Errorf("Compressed() after Close; got %v, want %v", err, ErrConsumed)
+	}
+}
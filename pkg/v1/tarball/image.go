@@ -38,6 +38,9 @@ type image struct {
 	config        []byte
 	imgDescriptor *Descriptor
 
+	// Whether each layer file in the tarball is gzipped, keyed by path.
+	compressed map[string]bool
+
 	tag *name.Tag
 }
 
@@ -69,6 +72,9 @@ func ImageFromPath(path string, tag *name.Tag) (v1.Image, error) {
 }
 
 // Image exposes an image from the tarball at the provided path.
+//
+// The layers may be stored gzipped (as written by Write) or uncompressed (as
+// written by some versions of `docker save`), or even a mix of both.
 func Image(opener Opener, tag *name.Tag) (v1.Image, error) {
 	img := &image{
 		opener: opener,
@@ -78,7 +84,9 @@ func Image(opener Opener, tag *name.Tag) (v1.Image, error) {
 		return nil, err
 	}
 
-	// Peek at the first layer and see if it's compressed.
+	// Peek at the layers and see if they're compressed. If only some of them
+	// are, treat the image as uncompressed and decompress the others on the
+	// fly, since the config only tells us their DiffIDs.
 	if len(img.imgDescriptor.Layers) > 0 {
 		compressed, err := img.areLayersCompressed()
 		if err != nil {
@@ -138,33 +146,80 @@ func (m Manifest) findDescriptor(tag *name.Tag) (*Descriptor, error) {
 	return nil, fmt.Errorf("tag %s not found in tarball", tag)
 }
 
+// areLayersCompressed sniffs every layer file for the gzip magic bytes in a
+// single pass over the tarball, recording the result in i.compressed. It
+// returns whether all of them are compressed.
 func (i *image) areLayersCompressed() (bool, error) {
 	if len(i.imgDescriptor.Layers) == 0 {
 		return false, errors.New("0 layers found in image")
 	}
-	layer := i.imgDescriptor.Layers[0]
-	blob, err := extractFileFromTar(i.opener, layer)
+	remaining := make(map[string]bool, len(i.imgDescriptor.Layers))
+	for _, layer := range i.imgDescriptor.Layers {
+		remaining[layer] = true
+	}
+
+	f, err := i.opener()
 	if err != nil {
 		return false, err
 	}
-	defer blob.Close()
-	return gzip.Is(blob)
+	defer f.Close()
+
+	i.compressed = make(map[string]bool, len(remaining))
+	tf := tar.NewReader(f)
+	for len(remaining) > 0 {
+		hdr, err := tf.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return false, err
+		}
+		if !remaining[hdr.Name] {
+			continue
+		}
+		delete(remaining, hdr.Name)
+		// Zero-length layers are uncompressed.
+		if i.compressed[hdr.Name], err = gzip.Is(tf); err != nil {
+			return false, err
+		}
+	}
+	for layer := range remaining {
+		return false, fmt.Errorf("file %s not found in tar", layer)
+	}
+
+	for _, compressed := range i.compressed {
+		if !compressed {
+			return false, nil
+		}
+	}
+	return true, nil
 }
 
-func (i *image) loadTarDescriptorAndConfig() error {
-	m, err := extractFileFromTar(i.opener, "manifest.json")
+// LoadManifest reads and parses the manifest.json of the tarball.
+func LoadManifest(opener Opener) (Manifest, error) {
+	m, err := extractFileFromTar(opener, "manifest.json")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer m.Close()
 
-	if err := json.NewDecoder(m).Decode(&i.manifest); err != nil {
-		return err
+	var manifest Manifest
+	if err := json.NewDecoder(m).Decode(&manifest); err != nil {
+		return nil, err
 	}
 
-	if i.manifest == nil {
-		return errors.New("no valid manifest.json in tarball")
+	if manifest == nil {
+		return nil, errors.New("no valid manifest.json in tarball")
 	}
+	return manifest, nil
+}
+
+func (i *image) loadTarDescriptorAndConfig() error {
+	m, err := LoadManifest(i.opener)
+	if err != nil {
+		return err
+	}
+	i.manifest = &m
 
 	i.imgDescriptor, err = i.manifest.findDescriptor(i.tag)
 	if err != nil {
@@ -232,6 +287,10 @@ type uncompressedLayerFromTarball struct {
 	mediaType types.MediaType
 	opener    Opener
 	filePath  string
+
+	// Whether the file is actually gzipped, e.g. if the other layers of
+	// the image aren't.
+	compressed bool
 }
 
 // foreignUncompressedLayer implements partial.UncompressedLayer but returns
@@ -253,7 +312,14 @@ func (ulft *uncompressedLayerFromTarball) DiffID() (v1.Hash, error) {
 
 // Uncompressed implements partial.UncompressedLayer
 func (ulft *uncompressedLayerFromTarball) Uncompressed() (io.ReadCloser, error) {
-	return extractFileFromTar(ulft.opener, ulft.filePath)
+	rc, err := extractFileFromTar(ulft.opener, ulft.filePath)
+	if err != nil {
+		return nil, err
+	}
+	if ulft.compressed {
+		return gzip.UnzipReadCloser(rc)
+	}
+	return rc, nil
 }
 
 func (ulft *uncompressedLayerFromTarball) MediaType() (types.MediaType, error) {
@@ -275,19 +341,21 @@ func (i *uncompressedImage) LayerByDiffID(h v1.Hash) (partial.UncompressedLayer,
 				// Overwrite the mediaType for foreign layers.
 				return &foreignUncompressedLayer{
 					uncompressedLayerFromTarball: uncompressedLayerFromTarball{
-						diffID:    diffID,
-						mediaType: bd.MediaType,
-						opener:    i.opener,
-						filePath:  i.imgDescriptor.Layers[idx],
+						diffID:     diffID,
+						mediaType:  bd.MediaType,
+						opener:     i.opener,
+						filePath:   i.imgDescriptor.Layers[idx],
+						compressed: i.compressed[i.imgDescriptor.Layers[idx]],
 					},
 					desc: bd,
 				}, nil
 			}
 			return &uncompressedLayerFromTarball{
-				diffID:    diffID,
-				mediaType: mt,
-				opener:    i.opener,
-				filePath:  i.imgDescriptor.Layers[idx],
+				diffID:     diffID,
+				mediaType:  mt,
+				opener:     i.opener,
+				filePath:   i.imgDescriptor.Layers[idx],
+				compressed: i.compressed[i.imgDescriptor.Layers[idx]],
 			}, nil
 		}
 	}
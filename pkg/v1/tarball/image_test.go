@@ -15,9 +15,16 @@
 package tarball
 
 import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
 	"testing"
 
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/internal/gzip"
 	"github.com/google/go-containerregistry/pkg/v1/validate"
 )
 
@@ -95,3 +102,75 @@ func TestBundleMultiple(t *testing.T) {
 		})
 	}
 }
+
+func TestMixedCompression(t *testing.T) {
+	layerTar := func(contents string) []byte {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		if err := tw.WriteHeader(&tar.Header{Name: "file", Size: int64(len(contents)), Typeflag: tar.TypeReg, Mode: 0644}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(contents)); err != nil {
+			t.Fatal(err)
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	// A gzipped layer, an uncompressed one, and a zero-length one, which is
+	// an empty tar as far as archive/tar is concerned.
+	plain := [][]byte{layerTar("gzipped"), layerTar("uncompressed"), {}}
+	gzipped, err := ioutil.ReadAll(gzip.ReadCloser(ioutil.NopCloser(bytes.NewReader(plain[0]))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		"0/layer.tar.gz": gzipped,
+		"1/layer.tar":    plain[1],
+		"2/layer.tar":    plain[2],
+	}
+
+	cfg := v1.ConfigFile{RootFS: v1.RootFS{Type: "layers"}}
+	for _, b := range plain {
+		h, _, err := v1.SHA256(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.RootFS.DiffIDs = append(cfg.RootFS.DiffIDs, h)
+	}
+	if files["config.json"], err = json.Marshal(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if files["manifest.json"], err = json.Marshal(Manifest{{
+		Config: "config.json",
+		Layers: []string{"0/layer.tar.gz", "1/layer.tar", "2/layer.tar"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range []string{"manifest.json", "config.json", "0/layer.tar.gz", "1/layer.tar", "2/layer.tar"} {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Size: int64(len(files[name])), Typeflag: tar.TypeReg, Mode: 0644}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(files[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := Image(func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(buf.Bytes())), nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("Image() = %v", err)
+	}
+	if err := validate.Image(img); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
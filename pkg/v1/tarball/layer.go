@@ -17,6 +17,7 @@ package tarball
 import (
 	"bytes"
 	"compress/gzip"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
@@ -39,6 +40,12 @@ type layer struct {
 	compression        int
 	annotations        map[string]string
 	estgzopts          []estargz.Option
+
+	// lazy defers computing digest, diffID and size until they are first
+	// requested, see WithLazyDigest.
+	lazy bool
+	once sync.Once
+	err  error
 }
 
 // Descriptor implements partial.withDescriptor.
@@ -47,8 +54,12 @@ func (l *layer) Descriptor() (*v1.Descriptor, error) {
 	if err != nil {
 		return nil, err
 	}
+	size, err := l.Size()
+	if err != nil {
+		return nil, err
+	}
 	return &v1.Descriptor{
-		Size:        l.size,
+		Size:        size,
 		Digest:      digest,
 		Annotations: l.annotations,
 		MediaType:   types.DockerLayer,
@@ -57,11 +68,17 @@ func (l *layer) Descriptor() (*v1.Descriptor, error) {
 
 // Digest implements v1.Layer
 func (l *layer) Digest() (v1.Hash, error) {
+	if err := l.compute(); err != nil {
+		return v1.Hash{}, err
+	}
 	return l.digest, nil
 }
 
 // DiffID implements v1.Layer
 func (l *layer) DiffID() (v1.Hash, error) {
+	if err := l.compute(); err != nil {
+		return v1.Hash{}, err
+	}
 	return l.diffID, nil
 }
 
@@ -77,6 +94,9 @@ func (l *layer) Uncompressed() (io.ReadCloser, error) {
 
 // Size implements v1.Layer
 func (l *layer) Size() (int64, error) {
+	if err := l.compute(); err != nil {
+		return -1, err
+	}
 	return l.size, nil
 }
 
@@ -89,7 +109,12 @@ func (l *layer) MediaType() (types.MediaType, error) {
 type LayerOption func(*layer)
 
 // WithCompressionLevel is a functional option for overriding the default
-// compression level used for compressing uncompressed tarballs.
+// compression level used for compressing uncompressed tarballs. It accepts
+// the levels defined by compress/gzip, from gzip.HuffmanOnly to
+// gzip.BestCompression; the default is gzip.BestSpeed.
+//
+// Since the compressed bytes depend on the level, so does the digest of the
+// layer. Layers that are already compressed are left as they are.
 func WithCompressionLevel(level int) LayerOption {
 	return func(l *layer) {
 		l.compression = level
@@ -123,6 +148,15 @@ func WithCompressedCaching(l *layer) {
 	}
 }
 
+// WithLazyDigest is a functional option that defers computing the layer's
+// Digest, DiffID and Size until one of them is first requested, instead of
+// reading the layer contents when it is constructed. The contents are read
+// at most once for this purpose, and any error is returned from each of
+// those methods.
+func WithLazyDigest(l *layer) {
+	l.lazy = true
+}
+
 // WithEstargzOptions is a functional option that allow the caller to pass
 // through estargz.Options to the underlying compression layer.  This is
 // only meaningful when estargz is enabled.
@@ -145,7 +179,9 @@ func WithEstargz(l *layer) {
 		if err != nil {
 			return nil, err
 		}
-		l.annotations[estargz.TOCJSONDigestAnnotation] = h.String()
+		l.annotations = map[string]string{
+			estargz.TOCJSONDigestAnnotation: h.String(),
+		}
 		return &and.ReadCloser{
 			Reader: rc,
 			CloseFunc: func() error {
@@ -201,9 +237,10 @@ func LayerFromOpener(opener Opener, opts ...LayerOption) (v1.Layer, error) {
 		return nil, err
 	}
 
+	// annotations is left nil unless estargz sets it, since an empty map
+	// doesn't survive a round trip through JSON.
 	layer := &layer{
 		compression: gzip.BestSpeed,
-		annotations: make(map[string]string, 1),
 	}
 
 	if estgz := os.Getenv("GGCR_EXPERIMENT_ESTARGZ"); estgz == "1" {
@@ -234,13 +271,12 @@ func LayerFromOpener(opener Opener, opts ...LayerOption) (v1.Layer, error) {
 		opt(layer)
 	}
 
-	if layer.digest, layer.size, err = computeDigest(layer.compressedopener); err != nil {
-		return nil, err
+	if layer.compression < gzip.HuffmanOnly || layer.compression > gzip.BestCompression {
+		return nil, fmt.Errorf("invalid compression level %d: must be between %d and %d", layer.compression, gzip.HuffmanOnly, gzip.BestCompression)
 	}
 
-	empty := v1.Hash{}
-	if layer.diffID == empty {
-		if layer.diffID, err = computeDiffID(layer.uncompressedopener); err != nil {
+	if !layer.lazy {
+		if err := layer.compute(); err != nil {
 			return nil, err
 		}
 	}
@@ -248,6 +284,21 @@ func LayerFromOpener(opener Opener, opts ...LayerOption) (v1.Layer, error) {
 	return layer, nil
 }
 
+// compute populates the digest, diffID and size of the layer exactly once.
+func (l *layer) compute() error {
+	l.once.Do(func() {
+		if l.digest, l.size, l.err = computeDigest(l.compressedopener); l.err != nil {
+			return
+		}
+
+		empty := v1.Hash{}
+		if l.diffID == empty {
+			l.diffID, l.err = computeDiffID(l.uncompressedopener)
+		}
+	})
+	return l.err
+}
+
 // LayerFromReader returns a v1.Layer given a io.Reader.
 func LayerFromReader(reader io.Reader, opts ...LayerOption) (v1.Layer, error) {
 	// Buffering due to Opener requiring multiple calls.
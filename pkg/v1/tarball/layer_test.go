@@ -17,6 +17,7 @@ package tarball
 import (
 	"bytes"
 	"compress/gzip"
+	"errors"
 	"io"
 	"io/ioutil"
 	"os"
@@ -76,6 +77,10 @@ func TestLayerFromFile(t *testing.T) {
 	if defaultDigest.String() == speedDigest.String() {
 		t.Errorf("expected digests to differ: %s", defaultDigest.String())
 	}
+
+	if _, err := LayerFromFile("testdata/content.tar", WithCompressionLevel(gzip.BestCompression+1)); err == nil {
+		t.Error("expected an error for an invalid compression level")
+	}
 }
 
 func TestLayerFromFileEstargz(t *testing.T) {
@@ -225,6 +230,66 @@ func TestLayerFromOpenerReader(t *testing.T) {
 	}
 }
 
+func TestLayerFromOpenerLazy(t *testing.T) {
+	ucBytes, err := ioutil.ReadFile("testdata/content.tar")
+	if err != nil {
+		t.Fatalf("Unable to read tar file: %v", err)
+	}
+	count := 0
+	ucOpener := func() (io.ReadCloser, error) {
+		count++
+		return ioutil.NopCloser(bytes.NewReader(ucBytes)), nil
+	}
+	lazyLayer, err := LayerFromOpener(ucOpener, WithLazyDigest)
+	if err != nil {
+		t.Fatal("Unable to create layer from tar file:", err)
+	}
+
+	// We expect only the gzip sniff before anything is requested.
+	if count != 1 {
+		t.Errorf("count = %d, wanted %d", count, 1)
+	}
+
+	if _, err := lazyLayer.Size(); err != nil {
+		t.Fatal("Size() =", err)
+	}
+	if _, err := lazyLayer.Digest(); err != nil {
+		t.Fatal("Digest() =", err)
+	}
+	if _, err := lazyLayer.DiffID(); err != nil {
+		t.Fatal("DiffID() =", err)
+	}
+
+	// Plus digest and diffid computation, exactly once.
+	if count != 3 {
+		t.Errorf("count = %d, wanted %d", count, 3)
+	}
+
+	eagerLayer, err := LayerFromOpener(ucOpener)
+	if err != nil {
+		t.Fatal("Unable to create layer from tar file:", err)
+	}
+	if err := compare.Layers(lazyLayer, eagerLayer); err != nil {
+		t.Errorf("compare.Layers: %v", err)
+	}
+
+	failed := false
+	failOpener := func() (io.ReadCloser, error) {
+		if failed {
+			return nil, errors.New("opener failed")
+		}
+		failed = true
+		return ioutil.NopCloser(bytes.NewReader(ucBytes)), nil
+	}
+	failLayer, err := LayerFromOpener(failOpener, WithLazyDigest)
+	if err != nil {
+		t.Fatal("Unable to create layer from tar file:", err)
+	}
+	if _, err := failLayer.Size(); err == nil {
+		t.Error("Size() = nil, wanted error")
+	}
+}
+
 func TestLayerFromReader(t *testing.T) {
 	setupFixtures(t)
 	defer teardownFixtures(t)
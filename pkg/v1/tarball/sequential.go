@@ -0,0 +1,112 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tarball
+
+import (
+	"archive/tar"
+	"io"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// LayerReader reads the layers of a single image from a tarball in the order
+// they appear in the archive, reading the archive only once after the manifest
+// has been loaded. It is meant for callers that consume every layer exactly
+// once; use Image for random access.
+//
+// The API mirrors archive/tar.Reader: call Next to advance to the next layer,
+// then Read its contents as they are stored in the tarball (they may or may
+// not be gzip compressed).
+type LayerReader struct {
+	f  io.ReadCloser
+	tr *tar.Reader
+
+	// Maps a layer's path in the tarball to its index in Descriptor.Layers.
+	layers map[string]int
+	cur    io.Reader
+
+	// Descriptor is the entry in manifest.json for the image being read.
+	Descriptor *Descriptor
+}
+
+// NewLayerReader returns a LayerReader for the image identified by tag in the
+// tarball. As with Image, tag may be nil if the tarball only contains a single
+// image.
+func NewLayerReader(opener Opener, tag *name.Tag) (*LayerReader, error) {
+	m, err := LoadManifest(opener)
+	if err != nil {
+		return nil, err
+	}
+	desc, err := m.findDescriptor(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	layers := make(map[string]int, len(desc.Layers))
+	for i := len(desc.Layers) - 1; i >= 0; i-- {
+		// Iterate backwards so that duplicate layers map to their first index.
+		layers[desc.Layers[i]] = i
+	}
+
+	f, err := opener()
+	if err != nil {
+		return nil, err
+	}
+	return &LayerReader{
+		f:          f,
+		tr:         tar.NewReader(f),
+		layers:     layers,
+		Descriptor: desc,
+	}, nil
+}
+
+// Next advances to the next layer in the archive and returns its index in
+// Descriptor.Layers. Layers that are referenced more than once are only
+// yielded once, with the index of their first reference. Next returns io.EOF
+// once every layer has been read.
+func (r *LayerReader) Next() (int, error) {
+	r.cur = nil
+	for len(r.layers) > 0 {
+		hdr, err := r.tr.Next()
+		if err == io.EOF {
+			return 0, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return 0, err
+		}
+		i, ok := r.layers[hdr.Name]
+		if !ok {
+			continue
+		}
+		delete(r.layers, hdr.Name)
+		r.cur = r.tr
+		return i, nil
+	}
+	return 0, io.EOF
+}
+
+// Read reads from the current layer. It returns io.EOF at the end of the
+// layer, or if Next has not been called.
+func (r *LayerReader) Read(p []byte) (int, error) {
+	if r.cur == nil {
+		return 0, io.EOF
+	}
+	return r.cur.Read(p)
+}
+
+// Close closes the underlying tarball.
+func (r *LayerReader) Close() error {
+	return r.f.Close()
+}
@@ -0,0 +1,105 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tarball_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+func TestLayerReader(t *testing.T) {
+	fp, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fp.Close()
+	defer os.Remove(fp.Name())
+
+	img, err := random.Image(256, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, err := name.NewTag("gcr.io/foo/bar:latest", name.StrictValidation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tarball.WriteToFile(fp.Name(), tag, img); err != nil {
+		t.Fatal(err)
+	}
+	opener := func() (io.ReadCloser, error) {
+		return os.Open(fp.Name())
+	}
+
+	m, err := tarball.LoadManifest(opener)
+	if err != nil {
+		t.Fatalf("LoadManifest() = %v", err)
+	}
+	if len(m) != 1 || len(m[0].Layers) != 4 {
+		t.Fatalf("LoadManifest() = %v, want 1 image with 4 layers", m)
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := tarball.NewLayerReader(opener, &tag)
+	if err != nil {
+		t.Fatalf("NewLayerReader() = %v", err)
+	}
+	defer r.Close()
+
+	seen := map[int]bool{}
+	for {
+		i, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next() = %v", err)
+		}
+		if seen[i] {
+			t.Errorf("Next() returned layer %d twice", i)
+		}
+		seen[i] = true
+
+		got, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatalf("ReadAll() = %v", err)
+		}
+		rc, err := layers[i].Compressed()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("layer %d contents differ from the original", i)
+		}
+	}
+	if len(seen) != len(layers) {
+		t.Errorf("read %d layers, want %d", len(seen), len(layers))
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"sort"
 	"strings"
@@ -30,6 +31,8 @@ import (
 	"github.com/google/go-containerregistry/pkg/v1/partial"
 )
 
+var errNoTags = errors.New("at least one tag is required")
+
 // WriteToFile writes in the compressed format to a tarball, on disk.
 // This is just syntactic sugar wrapping tarball.Write with a new file.
 func WriteToFile(p string, ref name.Reference, img v1.Image, opts ...WriteOption) error {
@@ -42,6 +45,21 @@ func WriteToFile(p string, ref name.Reference, img v1.Image, opts ...WriteOption
 	return Write(ref, img, w, opts...)
 }
 
+// WriteToFileWithRefs writes in the compressed format to a tarball, on disk.
+// This is just syntactic sugar wrapping tarball.WriteWithRefs with a new file.
+func WriteToFileWithRefs(p string, tags []name.Tag, img v1.Image, opts ...WriteOption) error {
+	if len(tags) == 0 {
+		return errNoTags
+	}
+	w, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	return WriteWithRefs(tags, img, w, opts...)
+}
+
 // MultiWriteToFile writes in the compressed format to a tarball, on disk.
 // This is just syntactic sugar wrapping tarball.MultiWrite with a new file.
 func MultiWriteToFile(p string, tagToImage map[name.Tag]v1.Image, opts ...WriteOption) error {
@@ -69,6 +87,20 @@ func Write(ref name.Reference, img v1.Image, w io.Writer, opts ...WriteOption) e
 	return MultiRefWrite(map[name.Reference]v1.Image{ref: img}, w, opts...)
 }
 
+// WriteWithRefs is a wrapper to write a single image to a tarball with each of
+// the given tags in its RepoTags, e.g. to name an image pulled from one registry
+// after the repository it will be loaded into. At least one tag is required.
+func WriteWithRefs(tags []name.Tag, img v1.Image, w io.Writer, opts ...WriteOption) error {
+	if len(tags) == 0 {
+		return errNoTags
+	}
+	refToImage := make(map[name.Reference]v1.Image, len(tags))
+	for _, tag := range tags {
+		refToImage[tag] = img
+	}
+	return MultiRefWrite(refToImage, w, opts...)
+}
+
 // MultiWrite writes the contents of each image to the provided reader, in the compressed format.
 // The contents are written in the following format:
 // One manifest.json file at the top level containing information about several images.
@@ -191,16 +223,7 @@ func writeImagesToTar(refToImage map[name.Reference]v1.Image, m []byte, size int
 			}
 			seenLayerDigests[hex] = struct{}{}
 
-			r, err := l.Compressed()
-			if err != nil {
-				return sendProgressWriterReturn(pw, err)
-			}
-			blobSize, err := l.Size()
-			if err != nil {
-				return sendProgressWriterReturn(pw, err)
-			}
-
-			if err := writeTarEntry(tf, layerFiles[i], r, blobSize); err != nil {
+			if err := writeLayerEntry(tf, layerFiles[i], l); err != nil {
 				return sendProgressWriterReturn(pw, err)
 			}
 		}
@@ -361,9 +384,46 @@ func dedupRefToImage(refToImage map[name.Reference]v1.Image) map[v1.Image][]stri
 		}
 	}
 
+	// Sort the tags so that the manifest doesn't depend on map iteration order.
+	for _, tags := range imageToTags {
+		sort.Strings(tags)
+	}
+
 	return imageToTags
 }
 
+// writeLayerEntry streams the compressed contents of a layer to the provided
+// writer. If the layer can't tell us its size up front, we need to know it to
+// write the tar header, so we buffer just this layer to a temporary file.
+func writeLayerEntry(tf *tar.Writer, path string, l v1.Layer) error {
+	r, err := l.Compressed()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	size, err := l.Size()
+	if err == nil {
+		return writeTarEntry(tf, path, r, size)
+	}
+
+	tmp, err := ioutil.TempFile("", "layer")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	size, err = io.Copy(tmp, r)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return writeTarEntry(tf, path, tmp, size)
+}
+
 // writeTarEntry writes a file to the provided writer with a corresponding tar header
 func writeTarEntry(tf *tar.Writer, path string, r io.Reader, size int64) error {
 	hdr := &tar.Header{
@@ -18,6 +18,7 @@ import (
 	"archive/tar"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -144,6 +145,61 @@ func TestMultiWriteSameImage(t *testing.T) {
 	}
 }
 
+func TestWriteWithRefs(t *testing.T) {
+	fp, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatalf("Error creating temp file.")
+	}
+	defer fp.Close()
+	defer os.Remove(fp.Name())
+
+	randImage, err := random.Image(256, 2)
+	if err != nil {
+		t.Fatalf("Error creating random image.")
+	}
+	var tags []name.Tag
+	for _, s := range []string{"registry.example.com/mirror/bar:v1", "registry.example.com/mirror/bar:latest"} {
+		tag, err := name.NewTag(s, name.StrictValidation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tags = append(tags, tag)
+	}
+
+	if err := tarball.WriteWithRefs(nil, randImage, ioutil.Discard); err == nil {
+		t.Error("WriteWithRefs() with no tags = nil, want error")
+	}
+
+	if err := tarball.WriteToFileWithRefs(fp.Name(), tags, randImage); err != nil {
+		t.Fatalf("WriteToFileWithRefs() = %v", err)
+	}
+
+	m, err := tarball.LoadManifest(func() (io.ReadCloser, error) {
+		return os.Open(fp.Name())
+	})
+	if err != nil {
+		t.Fatalf("LoadManifest() = %v", err)
+	}
+	if len(m) != 1 {
+		t.Fatalf("LoadManifest() = %d images, want 1", len(m))
+	}
+	want := []string{"registry.example.com/mirror/bar:latest", "registry.example.com/mirror/bar:v1"}
+	if got := m[0].RepoTags; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("RepoTags = %v, want %v", got, want)
+	}
+
+	for _, tag := range tags {
+		tag := tag
+		tarImage, err := tarball.ImageFromPath(fp.Name(), &tag)
+		if err != nil {
+			t.Fatalf("ImageFromPath(%s) = %v", tag, err)
+		}
+		if err := compare.Images(randImage, tarImage); err != nil {
+			t.Errorf("compare.Images: %v", err)
+		}
+	}
+}
+
 func TestMultiWriteDifferentImages(t *testing.T) {
 	// Make a tempfile for tarball writes.
 	fp, err := ioutil.TempFile("", "")
@@ -500,3 +556,57 @@ func getLayersFilenames(hashes []string) []string {
 	}
 	return filenames
 }
+
+// unsizedLayer can't report its size up front.
+type unsizedLayer struct {
+	v1.Layer
+}
+
+func (l *unsizedLayer) Size() (int64, error) {
+	return -1, errors.New("size unknown")
+}
+
+// unsizedImage returns unsizedLayers, but otherwise behaves like Image.
+type unsizedImage struct {
+	v1.Image
+}
+
+func (i *unsizedImage) Layers() ([]v1.Layer, error) {
+	ls, err := i.Image.Layers()
+	if err != nil {
+		return nil, err
+	}
+	for i, l := range ls {
+		ls[i] = &unsizedLayer{l}
+	}
+	return ls, nil
+}
+
+func TestWriteUnsizedLayers(t *testing.T) {
+	randImage, err := random.Image(256, 3)
+	if err != nil {
+		t.Fatalf("Error creating random image: %v", err)
+	}
+	tag, err := name.NewTag("gcr.io/foo/bar:latest", name.StrictValidation)
+	if err != nil {
+		t.Fatalf("Error creating test tag: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tarball.Write(tag, &unsizedImage{randImage}, &buf); err != nil {
+		t.Fatalf("Unexpected error writing tarball: %v", err)
+	}
+
+	tarImage, err := tarball.Image(func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(buf.Bytes())), nil
+	}, &tag)
+	if err != nil {
+		t.Fatalf("Unexpected error reading tarball: %v", err)
+	}
+	if err := validate.Image(tarImage); err != nil {
+		t.Errorf("validate.Image: %v", err)
+	}
+	if err := compare.Images(randImage, tarImage); err != nil {
+		t.Errorf("compare.Images: %v", err)
+	}
+}
@@ -27,6 +27,11 @@ const (
 	OCIRestrictedLayer             MediaType = "application/vnd.oci.image.layer.nondistributable.v1.tar+gzip"
 	OCIUncompressedLayer           MediaType = "application/vnd.oci.image.layer.v1.tar"
 	OCIUncompressedRestrictedLayer MediaType = "application/vnd.oci.image.layer.nondistributable.v1.tar"
+	OCILayerZStd                   MediaType = "application/vnd.oci.image.layer.v1.tar+zstd"
+
+	// OCIEmptyJSON is the media type of the empty config, "{}", which is
+	// used by artifacts that have no real config.
+	OCIEmptyJSON MediaType = "application/vnd.oci.empty.v1+json"
 
 	DockerManifestSchema1       MediaType = "application/vnd.docker.distribution.manifest.v1+json"
 	DockerManifestSchema1Signed MediaType = "application/vnd.docker.distribution.manifest.v1+prettyjws"
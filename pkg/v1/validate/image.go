@@ -22,6 +22,7 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
 )
 
 // Image validates that img does not violate any invariants of the image format.
@@ -89,7 +90,8 @@ func validateConfig(img v1.Image) error {
 		errs = append(errs, fmt.Sprintf("mismatched config content: (-ParseConfigFile(RawConfigFile()) +ConfigFile()) %s", diff))
 	}
 
-	if cf.RootFS.Type != "layers" {
+	// The empty config of an artifact has no rootfs.
+	if cf.RootFS.Type != "layers" && m.Config.MediaType != types.OCIEmptyJSON {
 		errs = append(errs, fmt.Sprintf("invalid ConfigFile.RootFS.Type: %q != %q", cf.RootFS.Type, "layers"))
 	}
 
@@ -134,6 +136,19 @@ func validateLayers(img v1.Image) error {
 	}
 
 	errs := []string{}
+	// The empty config of an artifact doesn't list the layers.
+	emptyConfig := m.Config.MediaType == types.OCIEmptyJSON
+	if got, want := len(cf.RootFS.DiffIDs), len(layers); got != want && !emptyConfig {
+		errs = append(errs, fmt.Sprintf("mismatched layer count: len(ConfigFile.RootFS.DiffIDs)=%d, len(Layers())=%d", got, want))
+	}
+	if got, want := len(m.Layers), len(layers); got != want {
+		errs = append(errs, fmt.Sprintf("mismatched layer count: len(Manifest.Layers)=%d, len(Layers())=%d", got, want))
+	}
+	if len(errs) != 0 {
+		// The per-layer checks below assume these line up.
+		return errors.New(strings.Join(errs, "\n"))
+	}
+
 	for i, layer := range layers {
 		digest, err := layer.Digest()
 		if err != nil {
@@ -176,7 +191,9 @@ func validateLayers(img v1.Image) error {
 			errs = append(errs, fmt.Sprintf("mismatched layer[%d] diffid: DiffID()=%s, SHA256(Uncompressed())=%s", i, diffid, udiffids[i]))
 		}
 
-		if cf.RootFS.DiffIDs[i] != diffids[i] {
+		// Catches layers that are out of order with respect to the config,
+		// e.g. after a botched mutation.
+		if !emptyConfig && cf.RootFS.DiffIDs[i] != diffids[i] {
 			errs = append(errs, fmt.Sprintf("mismatched layer[%d] diffid: ConfigFile.RootFS.DiffIDs[%d]=%s, SHA256(Gunzip(Compressed()))=%s", i, i, cf.RootFS.DiffIDs[i], diffids[i]))
 		}
 
@@ -0,0 +1,124 @@
+// Copyright 2021 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+// badConfig replaces the config of an image without touching its manifest.
+type badConfig struct {
+	v1.Image
+	rawConfig []byte
+}
+
+func (b *badConfig) RawConfigFile() ([]byte, error) {
+	return b.rawConfig, nil
+}
+
+func (b *badConfig) LayerByDigest(h v1.Hash) (partial.CompressedLayer, error) {
+	return b.Image.LayerByDigest(h)
+}
+
+// badLayers returns different layers than its manifest and config describe.
+type badLayers struct {
+	v1.Image
+	layers []v1.Layer
+}
+
+func (b *badLayers) Layers() ([]v1.Layer, error) {
+	return b.layers, nil
+}
+
+func TestImageDiffIDs(t *testing.T) {
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Image(img); err != nil {
+		t.Fatalf("validate.Image(random) = %v", err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	extra, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Swap the diff_ids in the config, keeping the manifest as-is.
+	cf, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf = cf.DeepCopy()
+	cf.RootFS.DiffIDs[0], cf.RootFS.DiffIDs[1] = cf.RootFS.DiffIDs[1], cf.RootFS.DiffIDs[0]
+	b, err := json.Marshal(cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	swapped, err := partial.CompressedToImage(&badConfig{Image: img, rawConfig: b})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		img  v1.Image
+		want []string
+	}{{
+		name: "swapped",
+		img:  swapped,
+		want: []string{
+			"mismatched layer[0] diffid: ConfigFile.RootFS.DiffIDs[0]=",
+			"mismatched layer[1] diffid: ConfigFile.RootFS.DiffIDs[1]=",
+		},
+	}, {
+		name: "missing",
+		img:  &badLayers{Image: img, layers: layers[:1]},
+		want: []string{
+			"mismatched layer count: len(ConfigFile.RootFS.DiffIDs)=2, len(Layers())=1",
+			"mismatched layer count: len(Manifest.Layers)=2, len(Layers())=1",
+		},
+	}, {
+		name: "extra",
+		img:  &badLayers{Image: img, layers: append(layers, extra)},
+		want: []string{
+			"mismatched layer count: len(ConfigFile.RootFS.DiffIDs)=2, len(Layers())=3",
+			"mismatched layer count: len(Manifest.Layers)=2, len(Layers())=3",
+		},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validate.Image(tc.img)
+			if err == nil {
+				t.Fatal("validate.Image() = nil, want error")
+			}
+			for _, want := range tc.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("validate.Image() = %v, want %q", err, want)
+				}
+			}
+		})
+	}
+}
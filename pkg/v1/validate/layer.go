@@ -16,8 +16,6 @@ package validate
 
 import (
 	"archive/tar"
-	"compress/gzip"
-	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -26,6 +24,7 @@ import (
 	"strings"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/internal/decompress"
 )
 
 // Layer validates that the values return by its methods are consistent with the
@@ -85,14 +84,37 @@ type computedLayer struct {
 	uncompressedSize   int64
 }
 
+// hashAlgorithms returns the algorithms of the layer's Digest and DiffID, which
+// default to sha256 if they can't be determined up front (e.g. for
+// stream.Layer, which only knows its hashes after it has been consumed).
+func hashAlgorithms(layer v1.Layer) (digest, diffid string) {
+	digest, diffid = "sha256", "sha256"
+	if h, err := layer.Digest(); err == nil {
+		digest = h.Algorithm
+	}
+	if h, err := layer.DiffID(); err == nil {
+		diffid = h.Algorithm
+	}
+	return digest, diffid
+}
+
 func computeLayer(layer v1.Layer) (*computedLayer, error) {
+	digestAlgorithm, diffidAlgorithm := hashAlgorithms(layer)
+	digester, err := v1.Hasher(digestAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	diffider, err := v1.Hasher(diffidAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+
 	compressed, err := layer.Compressed()
 	if err != nil {
 		return nil, err
 	}
 
 	// Keep track of compressed digest.
-	digester := sha256.New()
 	// Everything read from compressed is written to digester to compute digest.
 	hashCompressed := io.TeeReader(compressed, digester)
 
@@ -115,12 +137,11 @@ func computeLayer(layer v1.Layer) (*computedLayer, error) {
 		pw.CloseWithError(compressed.Close())
 	}()
 
-	// Read the bytes through gzip.Reader to compute the DiffID.
-	uncompressed, err := gzip.NewReader(pr)
+	// Read the bytes through the matching decompressor to compute the DiffID.
+	uncompressed, _, err := decompress.ReadCloser(pr)
 	if err != nil {
 		return nil, err
 	}
-	diffider := sha256.New()
 	hashUncompressed := io.TeeReader(uncompressed, diffider)
 
 	// Ensure there aren't duplicate file paths.
@@ -150,12 +171,12 @@ func computeLayer(layer v1.Layer) (*computedLayer, error) {
 	}
 
 	digest := v1.Hash{
-		Algorithm: "sha256",
+		Algorithm: digestAlgorithm,
 		Hex:       hex.EncodeToString(digester.Sum(make([]byte, 0, digester.Size()))),
 	}
 
 	diffid := v1.Hash{
-		Algorithm: "sha256",
+		Algorithm: diffidAlgorithm,
 		Hex:       hex.EncodeToString(diffider.Sum(make([]byte, 0, diffider.Size()))),
 	}
 
@@ -164,7 +185,7 @@ func computeLayer(layer v1.Layer) (*computedLayer, error) {
 		return nil, err
 	}
 	defer ur.Close()
-	udiffid, usize, err := v1.SHA256(ur)
+	udiffid, usize, err := v1.ComputeHash(diffidAlgorithm, ur)
 	if err != nil {
 		return nil, err
 	}
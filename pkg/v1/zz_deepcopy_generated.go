@@ -216,6 +216,11 @@ func (in *IndexManifest) DeepCopyInto(out *IndexManifest) {
 			(*out)[key] = val
 		}
 	}
+	if in.Subject != nil {
+		in, out := &in.Subject, &out.Subject
+		*out = new(Descriptor)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -247,6 +252,11 @@ func (in *Manifest) DeepCopyInto(out *Manifest) {
 			(*out)[key] = val
 		}
 	}
+	if in.Subject != nil {
+		in, out := &in.Subject, &out.Subject
+		*out = new(Descriptor)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 